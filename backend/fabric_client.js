@@ -88,9 +88,10 @@ class FabricClient {
     }
 
     // ===== Asset Contract Methods =====
-    async initUser(userId, initialBalance = 100000) {
+    async initUser(userId) {
         try {
-            await this.contract.submitTransaction('AssetContract:InitUser', userId, initialBalance.toString());
+            // New users start with a zero balance; the starter kit pays the starting balance
+            await this.contract.submitTransaction('AssetContract:InitUser', userId, '0');
             console.log('✓ User initialized successfully');
             return { success: true };
         } catch (error) {
@@ -221,11 +222,11 @@ class FabricClient {
     }
 
     // ===== Trade Contract Methods =====
-    async createTrade(tradeId, fromUserId, toUserId, commodityId, quantity, price, action) {
+    async createTrade(tradeId, fromUserId, toUserId, commodityId, quantity, unitPrice, action) {
         try {
             await this.contract.submitTransaction('TradeContract:CreateTrade', 
                 tradeId, fromUserId, toUserId, commodityId, 
-                quantity.toString(), price.toString(), action);
+                quantity.toString(), unitPrice.toString(), action);
             return { success: true };
         } catch (error) {
             throw error;
@@ -241,9 +242,9 @@ class FabricClient {
         }
     }
 
-    async rejectTrade(tradeId) {
+    async rejectTrade(tradeId, userId) {
         try {
-            await this.contract.submitTransaction('TradeContract:RejectTrade', tradeId);
+            await this.contract.submitTransaction('TradeContract:RejectTrade', tradeId, userId);
             return { success: true };
         } catch (error) {
             throw error;
@@ -341,6 +342,40 @@ class FabricClient {
             return [];
         }
     }
+
+    // ===== Gameplay Contract Methods =====
+    async claimStarterKit(userId) {
+        try {
+            const result = await this.contract.submitTransaction('GameplayContract:ClaimStarterKit', userId);
+            return JSON.parse(result.toString());
+        } catch (error) {
+            if (error.message.includes('already received')) {
+                return null;
+            }
+            throw error;
+        }
+    }
+
+    async createStarterRule(userId) {
+        try {
+            const result = await this.contract.submitTransaction('GameplayContract:CreateStarterRule', userId);
+            return JSON.parse(result.toString());
+        } catch (error) {
+            if (error.message.includes('already exists')) {
+                return null;
+            }
+            throw error;
+        }
+    }
+
+    async purchaseRefresh(userId) {
+        try {
+            const result = await this.contract.submitTransaction('GameplayContract:PurchaseRefresh', userId);
+            return JSON.parse(result.toString());
+        } catch (error) {
+            throw error;
+        }
+    }
 }
 
 // Singleton instance
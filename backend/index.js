@@ -30,8 +30,9 @@ app.post('/api/register', async (req, res) => {
     const hashedPassword = await bcrypt.hash(password, 10);
     const user = await db.User.create({ username, password: hashedPassword });
     
-    // Initialize user on blockchain with initial balance
-    await fabricClient.initUser(user.id.toString(), 100000);
+    // Initialize user on blockchain with the starter balance and items
+    await fabricClient.initUser(user.id.toString());
+    await fabricClient.claimStarterKit(user.id.toString());
     
     res.status(201).send({ message: 'User registered successfully', userId: user.id });
   } catch (error) {
@@ -129,13 +130,11 @@ io.on('connection', (socket) => {
             
             // If user doesn't exist on blockchain, initialize them
             if (!userAsset) {
-                await fabricClient.initUser(userId, 100000);
+                await fabricClient.initUser(userId);
+                await fabricClient.claimStarterKit(userId);
                 userAsset = await fabricClient.getUserAssets(userId);
             }
 
-            // Get all commodities from blockchain
-            const allCommodities = await fabricClient.getAllCommodities();
-
             // Initialize player state
             gameState.players[user.id] = {
                 id: user.id,
@@ -149,20 +148,9 @@ io.on('connection', (socket) => {
             const inventory = await fabricClient.getAllInventory(userId);
             console.log('✓ Inventory result:', inventory);
             
-            // If user has no inventory, give them random items
-            if (inventory.length === 0) {
-                for (const commodity of allCommodities) {
-                    const quantity = Math.floor(Math.random() * 10);
-                    if (quantity > 0) {
-                        await fabricClient.updateInventory(userId, commodity.commodityId, quantity, 'add');
-                        gameState.players[user.id].inventory[commodity.commodityId] = quantity;
-                    }
-                }
-            } else {
-                // Load existing inventory
-                for (const item of inventory) {
-                    gameState.players[user.id].inventory[item.commodityId] = item.quantity;
-                }
+            // Load existing inventory
+            for (const item of inventory) {
+                gameState.players[user.id].inventory[item.commodityId] = item.quantity;
             }
             console.log('✓ Player state:', gameState.players[user.id]);
 
@@ -170,17 +158,7 @@ io.on('connection', (socket) => {
             let rule = await fabricClient.getRedemptionRule(userId);
 
             if (!rule) {
-                const reward = Math.floor(Math.random() * 3001) + 2000;
-                const numItems = Math.floor(Math.random() * 2) + 2;
-                const shuffledCommodities = [...allCommodities].sort(() => 0.5 - Math.random());
-                const selectedCommodities = shuffledCommodities.slice(0, numItems);
-                
-                const requiredItems = selectedCommodities.map(commodity => ({
-                    commodityId: commodity.commodityId,
-                    quantity: Math.floor(Math.random() * 7) + 1
-                }));
-
-                await fabricClient.createRedemptionRule(userId, requiredItems, reward);
+                await fabricClient.createStarterRule(userId);
                 rule = await fabricClient.getRedemptionRule(userId);
             }
 
@@ -235,7 +213,7 @@ io.on('connection', (socket) => {
                     toUserIdStr,
                     commodityIdStr,
                     tradeDetails.quantity,
-                    tradeDetails.price,
+                    tradeDetails.unitPrice,
                     tradeDetails.action
                 );
             });
@@ -290,7 +268,7 @@ io.on('connection', (socket) => {
                 const trade = await fabricClient.getTradeStatus(tradeId);
 
                 // Determine seller and buyer
-                const { commodityId, quantity, price } = tradeDetails;
+                const { commodityId } = tradeDetails;
                 const sellerId = tradeDetails.action === 'buy' ? toUserId : fromUserId;
                 const buyerId = tradeDetails.action === 'buy' ? fromUserId : toUserId;
 
@@ -318,7 +296,7 @@ io.on('connection', (socket) => {
             } else {
                 // Reject trade on blockchain with retry mechanism
                 await retryOnMVCCConflict(async () => {
-                    await fabricClient.rejectTrade(tradeId);
+                    await fabricClient.rejectTrade(tradeId, toUserId.toString());
                 });
                 
                 const result = { success: false, message: 'Trade rejected', tradeId };
@@ -404,21 +382,9 @@ io.on('connection', (socket) => {
                 return socket.emit('refreshResult', { success: false, message: 'Insufficient balance.' });
             }
 
-            // Add 5 random commodities FIRST with retry mechanism
-            const allCommodities = await fabricClient.getAllCommodities();
-            console.log('✓ All commodities:', allCommodities);
-            for (let i = 0; i < 5; i++) {
-                const randomCommodity = allCommodities[Math.floor(Math.random() * allCommodities.length)];
-                console.log('✓ Random commodity:', randomCommodity);
-                await retryOnMVCCConflict(async () => {
-                    await fabricClient.updateInventory(userIdStr, randomCommodity.commodityId, 1, 'add');
-                });
-                console.log('✓ Updated inventory successfully');  
-            }
-
-            // Deduct refresh cost AFTER successfully adding all items
+            // Charge the refresh and add 5 random commodities in one transaction
             await retryOnMVCCConflict(async () => {
-                await fabricClient.updateBalance(userIdStr, REFRESH_COST, 'subtract');
+                await fabricClient.purchaseRefresh(userIdStr);
             });
 
             // Update local game state from blockchain
@@ -0,0 +1,183 @@
+package contracts
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-samples/game-chaincode/models"
+	"github.com/hyperledger/fabric-samples/game-chaincode/utils"
+)
+
+// Roles that can be granted to client identities
+const (
+	RoleAdmin       = "admin"
+	RolePriceFeeder = "price_feeder"
+)
+
+// BootstrapAdminMSPID is the organization whose admin identities (certificate
+// OU "admin") may claim the first admin role with InitAdmin
+const BootstrapAdminMSPID = "Org1MSP"
+
+// AccessContract provides functions for managing the roles of client identities
+type AccessContract struct {
+	contractapi.Contract
+}
+
+// InitAdmin makes the calling identity the first admin. Only an admin identity of
+// BootstrapAdminMSPID may call it, and it fails once any admin exists. The deploy
+// script calls it right after committing the chaincode.
+func (c *AccessContract) InitAdmin(ctx contractapi.TransactionContextInterface) error {
+	if err := checkBootstrapIdentity(ctx); err != nil {
+		return err
+	}
+
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(utils.RoleObjectType, []string{RoleAdmin})
+	if err != nil {
+		return fmt.Errorf("failed to get role iterator: %v", err)
+	}
+	hasAdmin := iterator.HasNext()
+	iterator.Close()
+	if hasAdmin {
+		return fmt.Errorf("admin already initialized")
+	}
+
+	callerID, err := utils.GetCallerID(ctx)
+	if err != nil {
+		return err
+	}
+
+	return putRoleGrant(ctx, callerID, RoleAdmin, callerID)
+}
+
+// GrantRole grants a role to an identity (admin only)
+func (c *AccessContract) GrantRole(ctx contractapi.TransactionContextInterface, identityID, role string) error {
+	callerID, err := requireRole(ctx, RoleAdmin)
+	if err != nil {
+		return err
+	}
+
+	if role == "" || identityID == "" {
+		return fmt.Errorf("identity and role cannot be empty")
+	}
+
+	return putRoleGrant(ctx, identityID, role, callerID)
+}
+
+// RevokeRole revokes a role from an identity (admin only)
+func (c *AccessContract) RevokeRole(ctx contractapi.TransactionContextInterface, identityID, role string) error {
+	callerID, err := requireRole(ctx, RoleAdmin)
+	if err != nil {
+		return err
+	}
+
+	if role == RoleAdmin && identityID == callerID {
+		return fmt.Errorf("admins cannot revoke their own admin role")
+	}
+
+	key, err := utils.GetRoleKey(ctx, role, identityID)
+	if err != nil {
+		return fmt.Errorf("failed to create role key: %v", err)
+	}
+	return ctx.GetStub().DelState(key)
+}
+
+// HasRole reports whether an identity holds a role
+func (c *AccessContract) HasRole(ctx contractapi.TransactionContextInterface, identityID, role string) (bool, error) {
+	return hasRole(ctx, identityID, role)
+}
+
+// GetCallerIdentity returns the ID of the calling identity, as used by GrantRole
+func (c *AccessContract) GetCallerIdentity(ctx contractapi.TransactionContextInterface) (string, error) {
+	return utils.GetCallerID(ctx)
+}
+
+// requireRole verifies the caller holds role (admins hold every role) and returns the caller ID
+func requireRole(ctx contractapi.TransactionContextInterface, role string) (string, error) {
+	callerID, err := utils.GetCallerID(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	ok, err := hasRole(ctx, callerID, role)
+	if err != nil {
+		return "", err
+	}
+	if !ok && role != RoleAdmin {
+		ok, err = hasRole(ctx, callerID, RoleAdmin)
+		if err != nil {
+			return "", err
+		}
+	}
+	if !ok {
+		return "", fmt.Errorf("caller is not authorized (requires role %s)", role)
+	}
+
+	return callerID, nil
+}
+
+// checkBootstrapIdentity verifies the caller is an admin identity of BootstrapAdminMSPID
+func checkBootstrapIdentity(ctx contractapi.TransactionContextInterface) error {
+	identity := ctx.GetClientIdentity()
+	if identity == nil {
+		return fmt.Errorf("client identity not available")
+	}
+
+	mspID, err := identity.GetMSPID()
+	if err != nil {
+		return fmt.Errorf("failed to get client MSP ID: %v", err)
+	}
+	cert, err := identity.GetX509Certificate()
+	if err != nil {
+		return fmt.Errorf("failed to get client certificate: %v", err)
+	}
+	if mspID == BootstrapAdminMSPID && cert != nil {
+		for _, ou := range cert.Subject.OrganizationalUnit {
+			if ou == "admin" {
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("only an admin identity of %s can initialize the admin", BootstrapAdminMSPID)
+}
+
+// hasRole reports whether an identity holds a role
+func hasRole(ctx contractapi.TransactionContextInterface, identityID, role string) (bool, error) {
+	key, err := utils.GetRoleKey(ctx, role, identityID)
+	if err != nil {
+		return false, fmt.Errorf("failed to create role key: %v", err)
+	}
+	grantJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return false, fmt.Errorf("failed to read role: %v", err)
+	}
+	return grantJSON != nil, nil
+}
+
+// putRoleGrant writes a role grant to the ledger
+func putRoleGrant(ctx contractapi.TransactionContextInterface, identityID, role, grantedBy string) error {
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	grant := models.RoleGrant{
+		IdentityID: identityID,
+		Role:       role,
+		GrantedBy:  grantedBy,
+		GrantedAt:  timestamp,
+	}
+
+	grantJSON, err := json.Marshal(grant)
+	if err != nil {
+		return fmt.Errorf("failed to marshal role grant: %v", err)
+	}
+
+	key, err := utils.GetRoleKey(ctx, role, identityID)
+	if err != nil {
+		return fmt.Errorf("failed to create role key: %v", err)
+	}
+	return ctx.GetStub().PutState(key, grantJSON)
+}
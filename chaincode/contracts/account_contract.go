@@ -0,0 +1,466 @@
+package contracts
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-samples/game-chaincode/models"
+	"github.com/hyperledger/fabric-samples/game-chaincode/utils"
+)
+
+// Account statuses. Frozen and closed accounts cannot move balance or items;
+// accounts suspended from trading cannot trade, bid or swap.
+const (
+	AccountActive           = "active"
+	AccountFrozen           = "frozen"
+	AccountSuspendedTrading = "suspended-trading"
+	AccountClosed           = "closed"
+)
+
+// AccountContract provides account sanctions with an audit trail of status changes
+type AccountContract struct {
+	contractapi.Contract
+	AssetContract   *AssetContract
+	TradeContract   *TradeContract
+	AuctionContract *AuctionContract
+	GuildContract   *GuildContract
+	AMMContract     *AMMContract
+}
+
+// SetAccountStatus changes a user's account status with a reason (admin only).
+// Accounts are closed with CloseAccount and cannot be reopened.
+func (c *AccountContract) SetAccountStatus(ctx contractapi.TransactionContextInterface, userID, status, reason string) error {
+	callerID, err := requireRole(ctx, RoleAdmin)
+	if err != nil {
+		return err
+	}
+
+	switch status {
+	case AccountActive, AccountFrozen, AccountSuspendedTrading:
+	case AccountClosed:
+		return fmt.Errorf("use CloseAccount to close an account")
+	default:
+		return fmt.Errorf("invalid account status: %s (must be 'active', 'frozen' or 'suspended-trading')", status)
+	}
+	if reason == "" {
+		return fmt.Errorf("reason cannot be empty")
+	}
+
+	// Initialize asset contract if not set
+	if c.AssetContract == nil {
+		c.AssetContract = &AssetContract{}
+	}
+
+	if _, err := c.AssetContract.GetUserAssets(ctx, userID); err != nil {
+		return err
+	}
+
+	current, err := c.GetAccountStatus(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if current.Status == AccountClosed {
+		return fmt.Errorf("account %s is closed", userID)
+	}
+	if current.Status == status {
+		return fmt.Errorf("account %s is already %s", userID, status)
+	}
+
+	return putAccountStatus(ctx, current, status, reason, callerID)
+}
+
+// CloseAccount closes a user's account (admin only). Open auctions, guild
+// leadership and pool shares must be settled first. Pending trades are
+// cancelled, guild membership is ended, the balance and items are swept to
+// sweepToUserID (or burned if it is empty) and the user's asset and inventory
+// keys are deleted. The closed status remains as a tombstone so InitUser cannot
+// reuse the user ID.
+func (c *AccountContract) CloseAccount(ctx contractapi.TransactionContextInterface, userID, sweepToUserID, reason string) error {
+	callerID, err := requireRole(ctx, RoleAdmin)
+	if err != nil {
+		return err
+	}
+
+	if reason == "" {
+		return fmt.Errorf("reason cannot be empty")
+	}
+
+	// Initialize asset contract if not set
+	if c.AssetContract == nil {
+		c.AssetContract = &AssetContract{}
+	}
+	// Initialize trade contract if not set
+	if c.TradeContract == nil {
+		c.TradeContract = &TradeContract{AssetContract: c.AssetContract}
+	}
+	// Initialize auction contract if not set
+	if c.AuctionContract == nil {
+		c.AuctionContract = &AuctionContract{AssetContract: c.AssetContract}
+	}
+	// Initialize guild contract if not set
+	if c.GuildContract == nil {
+		c.GuildContract = &GuildContract{AssetContract: c.AssetContract}
+	}
+	// Initialize AMM contract if not set
+	if c.AMMContract == nil {
+		c.AMMContract = &AMMContract{AssetContract: c.AssetContract}
+	}
+
+	userAsset, err := c.AssetContract.GetUserAssets(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	current, err := c.GetAccountStatus(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if current.Status == AccountClosed {
+		return fmt.Errorf("account %s is already closed", userID)
+	}
+
+	// Held assets belong to open loans or stakes, which must be settled first
+	holds, err := c.AssetContract.GetHolds(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if len(holds) > 0 {
+		return fmt.Errorf("account %s has %d active holds", userID, len(holds))
+	}
+
+	// Escrow in open auctions, guild leadership and pool shares cannot be swept
+	err = c.checkNoOpenPositions(ctx, userID)
+	if err != nil {
+		return err
+	}
+	guild, err := c.GuildContract.findUserGuild(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if sweepToUserID != "" {
+		if sweepToUserID == userID {
+			return fmt.Errorf("cannot sweep an account into itself")
+		}
+		if _, err := c.AssetContract.GetUserAssets(ctx, sweepToUserID); err != nil {
+			return err
+		}
+	}
+
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	closure := models.AccountClosure{
+		UserID:          userID,
+		SweptTo:         sweepToUserID,
+		Balance:         userAsset.Balance,
+		Items:           []models.RequiredItem{},
+		CancelledTrades: []string{},
+		Reason:          reason,
+		ClosedBy:        callerID,
+		ClosedAt:        timestamp,
+	}
+
+	// 1. Leave the user's guild and cancel their pending trades
+	if guild != nil {
+		err = c.GuildContract.removeMember(ctx, guild, userID)
+		if err != nil {
+			return err
+		}
+		err = c.GuildContract.logActivity(ctx, guild, models.GuildActivity{ActorID: callerID, Action: "account_closed", TargetID: userID})
+		if err != nil {
+			return err
+		}
+		err = c.GuildContract.putGuild(ctx, guild)
+		if err != nil {
+			return err
+		}
+	}
+
+	trades, err := c.TradeContract.GetTradeHistory(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for _, trade := range trades {
+		if trade.Status != "pending" {
+			continue
+		}
+
+		trade.Status = "cancelled"
+		trade.CompletedAt = timestamp
+
+		tradeJSON, err := json.Marshal(trade)
+		if err != nil {
+			return fmt.Errorf("failed to marshal trade: %v", err)
+		}
+		err = ctx.GetStub().PutState(utils.GetTradeKey(trade.TradeID), tradeJSON)
+		if err != nil {
+			return fmt.Errorf("failed to cancel trade %s: %v", trade.TradeID, err)
+		}
+		closure.CancelledTrades = append(closure.CancelledTrades, trade.TradeID)
+	}
+
+	// 2. Sweep or burn the items and delete the inventory keys
+	inventories, err := c.AssetContract.scanInventory(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for _, inventory := range inventories {
+		if inventory.Quantity > 0 {
+			closure.Items = append(closure.Items, models.RequiredItem{
+				CommodityID: inventory.CommodityID,
+				Quantity:    inventory.Quantity,
+			})
+		}
+	}
+	if sweepToUserID != "" {
+		err = checkStorageCapacity(ctx, c.AssetContract, sweepToUserID, closure.Items)
+		if err != nil {
+			return err
+		}
+	}
+	for _, inventory := range inventories {
+		if inventory.Quantity > 0 && sweepToUserID != "" {
+			err = c.AssetContract.giveItems(ctx, sweepToUserID, inventory.CommodityID, inventory.Quantity, inventory.Lots)
+			if err != nil {
+				return fmt.Errorf("failed to sweep %s: %v", inventory.CommodityID, err)
+			}
+		}
+
+		err = c.AssetContract.deleteInventory(ctx, userID, inventory.CommodityID)
+		if err != nil {
+			return err
+		}
+	}
+
+	// 3. Sweep or burn the balance and delete the user asset key
+	if sweepToUserID != "" && userAsset.Balance > 0 {
+		err = c.AssetContract.updateBalance(ctx, sweepToUserID, userAsset.Balance, "add")
+		if err != nil {
+			return fmt.Errorf("failed to sweep balance: %v", err)
+		}
+	}
+	err = c.AssetContract.deleteUserAsset(ctx, userID)
+	if err != nil {
+		return err
+	}
+	err = c.AssetContract.updateRichest(ctx, &models.UserAsset{UserID: userID})
+	if err != nil {
+		return err
+	}
+
+	// 4. Record the closure and leave the closed status as a tombstone
+	closureJSON, err := json.Marshal(closure)
+	if err != nil {
+		return fmt.Errorf("failed to marshal account closure: %v", err)
+	}
+	err = ctx.GetStub().PutState(utils.GetAccountClosureKey(userID), closureJSON)
+	if err != nil {
+		return fmt.Errorf("failed to save account closure: %v", err)
+	}
+
+	err = putAccountStatus(ctx, current, AccountClosed, reason, callerID)
+	if err != nil {
+		return err
+	}
+
+	// 5. Emit event
+	eventJSON, _ := json.Marshal(closure)
+	ctx.GetStub().SetEvent("AccountClosed", eventJSON)
+
+	return nil
+}
+
+// GetAccountClosure retrieves the closure record of a closed account
+func (c *AccountContract) GetAccountClosure(ctx contractapi.TransactionContextInterface, userID string) (*models.AccountClosure, error) {
+	closureJSON, err := ctx.GetStub().GetState(utils.GetAccountClosureKey(userID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read account closure: %v", err)
+	}
+	if closureJSON == nil {
+		return nil, fmt.Errorf("account %s is not closed", userID)
+	}
+
+	var closure models.AccountClosure
+	err = json.Unmarshal(closureJSON, &closure)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal account closure: %v", err)
+	}
+
+	return &closure, nil
+}
+
+// GetAccountStatus retrieves a user's account status; accounts without a record are active
+func (c *AccountContract) GetAccountStatus(ctx contractapi.TransactionContextInterface, userID string) (*models.AccountStatus, error) {
+	return getAccountStatus(ctx, userID)
+}
+
+// GetAccountStatusHistory retrieves the audit trail of a user's account status changes, oldest first
+func (c *AccountContract) GetAccountStatusHistory(ctx contractapi.TransactionContextInterface, userID string) ([]*models.AccountStatusChange, error) {
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(utils.AccountStatusLogObjectType, []string{userID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get account status iterator: %v", err)
+	}
+	defer iterator.Close()
+
+	var changes []*models.AccountStatusChange
+	for iterator.HasNext() {
+		queryResponse, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate account status changes: %v", err)
+		}
+
+		var change models.AccountStatusChange
+		err = json.Unmarshal(queryResponse.Value, &change)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal account status change: %v", err)
+		}
+
+		changes = append(changes, &change)
+	}
+
+	return changes, nil
+}
+
+// getAccountStatus retrieves a user's account status; accounts without a record are active
+func getAccountStatus(ctx contractapi.TransactionContextInterface, userID string) (*models.AccountStatus, error) {
+	statusJSON, err := ctx.GetStub().GetState(utils.GetAccountStatusKey(userID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read account status: %v", err)
+	}
+	if statusJSON == nil {
+		return &models.AccountStatus{UserID: userID, Status: AccountActive}, nil
+	}
+
+	var status models.AccountStatus
+	err = json.Unmarshal(statusJSON, &status)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal account status: %v", err)
+	}
+
+	return &status, nil
+}
+
+// putAccountStatus moves an account to a new status and appends the change to its audit trail
+func putAccountStatus(ctx contractapi.TransactionContextInterface, current *models.AccountStatus, status, reason, changedBy string) error {
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	change := models.AccountStatusChange{
+		UserID:     current.UserID,
+		Seq:        current.ChangeCount + 1,
+		FromStatus: current.Status,
+		ToStatus:   status,
+		Reason:     reason,
+		ChangedBy:  changedBy,
+		ChangedAt:  timestamp,
+	}
+
+	changeJSON, err := json.Marshal(change)
+	if err != nil {
+		return fmt.Errorf("failed to marshal account status change: %v", err)
+	}
+	changeKey, err := utils.GetAccountStatusLogKey(ctx, current.UserID, change.Seq)
+	if err != nil {
+		return fmt.Errorf("failed to create account status log key: %v", err)
+	}
+	err = ctx.GetStub().PutState(changeKey, changeJSON)
+	if err != nil {
+		return fmt.Errorf("failed to save account status change: %v", err)
+	}
+
+	current.Status = status
+	current.Reason = reason
+	current.UpdatedBy = changedBy
+	current.UpdatedAt = timestamp
+	current.ChangeCount = change.Seq
+
+	statusJSON, err := json.Marshal(current)
+	if err != nil {
+		return fmt.Errorf("failed to marshal account status: %v", err)
+	}
+	err = ctx.GetStub().PutState(utils.GetAccountStatusKey(current.UserID), statusJSON)
+	if err != nil {
+		return fmt.Errorf("failed to save account status: %v", err)
+	}
+
+	eventJSON, _ := json.Marshal(change)
+	ctx.GetStub().SetEvent("AccountStatusChanged", eventJSON)
+
+	return nil
+}
+
+// checkNoOpenPositions verifies a user is not a party to an open auction, does
+// not lead a guild and holds no pool shares
+func (c *AccountContract) checkNoOpenPositions(ctx contractapi.TransactionContextInterface, userID string) error {
+	auctions, err := c.AuctionContract.GetOpenAuctions(ctx)
+	if err != nil {
+		return err
+	}
+	for _, auction := range auctions {
+		if auction.SellerID == userID || auction.HighestBidderID == userID {
+			return fmt.Errorf("account %s is a party to open auction %s", userID, auction.AuctionID)
+		}
+		if auction.AuctionType != "sealed" {
+			continue
+		}
+		sealedBids, err := c.AuctionContract.GetSealedBids(ctx, auction.AuctionID)
+		if err != nil {
+			return err
+		}
+		for _, sealedBid := range sealedBids {
+			if sealedBid.BidderID == userID {
+				return fmt.Errorf("account %s has a sealed bid on open auction %s", userID, auction.AuctionID)
+			}
+		}
+	}
+
+	guild, err := c.GuildContract.findUserGuild(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if guild != nil && guild.LeaderID == userID {
+		return fmt.Errorf("account %s leads guild %s; leadership must be transferred first", userID, guild.GuildID)
+	}
+
+	positions, err := c.AMMContract.getUserPositions(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if len(positions) > 0 {
+		return fmt.Errorf("account %s holds shares in the %s pool; liquidity must be removed first", userID, positions[0].CommodityID)
+	}
+
+	return nil
+}
+
+// checkAccountCanTransfer verifies a user's account may move balance or items
+func checkAccountCanTransfer(ctx contractapi.TransactionContextInterface, userID string) error {
+	status, err := getAccountStatus(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if status.Status == AccountFrozen || status.Status == AccountClosed {
+		return fmt.Errorf("account %s is %s", userID, status.Status)
+	}
+	return nil
+}
+
+// checkAccountCanTrade verifies a user's account may trade
+func checkAccountCanTrade(ctx contractapi.TransactionContextInterface, userID string) error {
+	status, err := getAccountStatus(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if status.Status != AccountActive {
+		return fmt.Errorf("account %s is %s", userID, status.Status)
+	}
+	return nil
+}
@@ -0,0 +1,540 @@
+package contracts
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-samples/game-chaincode/models"
+	"github.com/hyperledger/fabric-samples/game-chaincode/utils"
+)
+
+// dustShares is the share total below which a pool is treated as empty; float
+// residue can leave a few shares behind after the last provider withdraws
+const dustShares = 1e-9
+
+// AMMContract provides constant-product liquidity pools pairing each commodity with balance
+type AMMContract struct {
+	contractapi.Contract
+	AssetContract     *AssetContract
+	CommodityContract *CommodityContract
+}
+
+// CreatePool creates an empty liquidity pool for an existing commodity
+func (m *AMMContract) CreatePool(ctx contractapi.TransactionContextInterface, commodityID string, feeRate float64) error {
+	if feeRate < 0 || feeRate >= 1 {
+		return fmt.Errorf("fee rate must be in [0, 1)")
+	}
+
+	// Initialize commodity contract if not set
+	if m.CommodityContract == nil {
+		m.CommodityContract = &CommodityContract{}
+	}
+
+	if _, err := m.CommodityContract.GetCommodity(ctx, commodityID); err != nil {
+		return err
+	}
+
+	// Check if pool already exists
+	existing, err := m.GetPool(ctx, commodityID)
+	if err == nil && existing != nil {
+		return fmt.Errorf("pool for commodity %s already exists", commodityID)
+	}
+
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	pool := models.LiquidityPool{
+		CommodityID: commodityID,
+		FeeRate:     feeRate,
+		CreatedAt:   timestamp,
+		UpdatedAt:   timestamp,
+	}
+
+	return m.putPool(ctx, &pool)
+}
+
+// AddLiquidity deposits commodity units and balance into a pool in exchange for pool shares.
+// The first provider into an empty pool sets the price; later providers deposit balance at
+// the current ratio, which must not exceed maxBalanceAmount.
+func (m *AMMContract) AddLiquidity(ctx contractapi.TransactionContextInterface, commodityID, userID string, commodityAmount int, maxBalanceAmount float64) error {
+	if commodityAmount <= 0 {
+		return fmt.Errorf("commodity amount must be positive")
+	}
+	if err := checkAccountCanTrade(ctx, userID); err != nil {
+		return err
+	}
+
+	pool, err := m.GetPool(ctx, commodityID)
+	if err != nil {
+		return err
+	}
+
+	var balanceAmount, shares float64
+	if pool.TotalShares < dustShares {
+		if maxBalanceAmount <= 0 {
+			return fmt.Errorf("initial balance amount must be positive")
+		}
+		balanceAmount = maxBalanceAmount
+		shares = math.Sqrt(float64(commodityAmount) * balanceAmount)
+	} else if pool.ReserveCommodity == 0 || pool.ReserveBalance <= 0 {
+		// Outstanding shares with a drained reserve have no price to deposit at
+		return fmt.Errorf("pool for commodity %s has no reserves left; remaining shares must be withdrawn first", commodityID)
+	} else {
+		balanceAmount = float64(commodityAmount) * pool.ReserveBalance / float64(pool.ReserveCommodity)
+		if balanceAmount > maxBalanceAmount {
+			return fmt.Errorf("required balance %.2f exceeds maximum %.2f", balanceAmount, maxBalanceAmount)
+		}
+		shares = pool.TotalShares * float64(commodityAmount) / float64(pool.ReserveCommodity)
+	}
+
+	// Initialize asset contract if not set
+	if m.AssetContract == nil {
+		m.AssetContract = &AssetContract{}
+	}
+
+	// 1. Move the deposit from the user into the pool
+	lots, err := m.AssetContract.takeItems(ctx, userID, commodityID, commodityAmount)
+	if err != nil {
+		return fmt.Errorf("failed to deposit commodity: %v", err)
+	}
+	err = m.AssetContract.updateBalance(ctx, userID, balanceAmount, "subtract")
+	if err != nil {
+		return fmt.Errorf("failed to deposit balance: %v", err)
+	}
+
+	// 2. Mint pool shares
+	position, err := m.GetLiquidityPosition(ctx, commodityID, userID)
+	if err != nil {
+		return err
+	}
+	position.Shares += shares
+
+	addReserveLots(pool, lots)
+	pool.ReserveCommodity += commodityAmount
+	pool.ReserveBalance += balanceAmount
+	pool.TotalShares += shares
+
+	if err := m.putPosition(ctx, position); err != nil {
+		return err
+	}
+	if err := m.putPool(ctx, pool); err != nil {
+		return err
+	}
+
+	// 3. Emit event
+	eventPayload := map[string]interface{}{
+		"commodityId":     commodityID,
+		"userId":          userID,
+		"commodityAmount": commodityAmount,
+		"balanceAmount":   balanceAmount,
+		"shares":          shares,
+		"timestamp":       pool.UpdatedAt,
+	}
+	eventJSON, _ := json.Marshal(eventPayload)
+	ctx.GetStub().SetEvent("LiquidityAdded", eventJSON)
+
+	return nil
+}
+
+// RemoveLiquidity burns pool shares and returns the proportional reserves to the user.
+// Commodity units are rounded down; the fractional remainder stays in the pool.
+func (m *AMMContract) RemoveLiquidity(ctx contractapi.TransactionContextInterface, commodityID, userID string, shares float64, minCommodityAmount int, minBalanceAmount float64) error {
+	if shares <= 0 {
+		return fmt.Errorf("shares must be positive")
+	}
+
+	pool, err := m.GetPool(ctx, commodityID)
+	if err != nil {
+		return err
+	}
+
+	position, err := m.GetLiquidityPosition(ctx, commodityID, userID)
+	if err != nil {
+		return err
+	}
+	if position.Shares < shares {
+		return fmt.Errorf("insufficient pool shares for user %s", userID)
+	}
+
+	fraction := shares / pool.TotalShares
+	commodityAmount := int(math.Floor(fraction * float64(pool.ReserveCommodity)))
+	balanceAmount := fraction * pool.ReserveBalance
+	if commodityAmount < minCommodityAmount || balanceAmount < minBalanceAmount {
+		return fmt.Errorf("withdrawal (%d units, %.2f balance) is below the requested minimum", commodityAmount, balanceAmount)
+	}
+
+	// Initialize asset contract if not set
+	if m.AssetContract == nil {
+		m.AssetContract = &AssetContract{}
+	}
+
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	// 1. Burn pool shares
+	position.Shares -= shares
+	lots := takeReserveLots(pool, commodityAmount, timestamp)
+	pool.ReserveCommodity -= commodityAmount
+	pool.ReserveBalance -= balanceAmount
+	pool.TotalShares -= shares
+
+	if err := m.putPosition(ctx, position); err != nil {
+		return err
+	}
+	if err := m.putPool(ctx, pool); err != nil {
+		return err
+	}
+
+	// 2. Pay out the reserves
+	if commodityAmount > 0 {
+		err = m.AssetContract.giveItems(ctx, userID, commodityID, commodityAmount, lots)
+		if err != nil {
+			return fmt.Errorf("failed to withdraw commodity: %v", err)
+		}
+	}
+	err = m.AssetContract.updateBalance(ctx, userID, balanceAmount, "add")
+	if err != nil {
+		return fmt.Errorf("failed to withdraw balance: %v", err)
+	}
+
+	// 3. Emit event
+	eventPayload := map[string]interface{}{
+		"commodityId":     commodityID,
+		"userId":          userID,
+		"commodityAmount": commodityAmount,
+		"balanceAmount":   balanceAmount,
+		"shares":          shares,
+		"timestamp":       pool.UpdatedAt,
+	}
+	eventJSON, _ := json.Marshal(eventPayload)
+	ctx.GetStub().SetEvent("LiquidityRemoved", eventJSON)
+
+	return nil
+}
+
+// BuyFromPool buys quantity units of a commodity from the pool, paying at most maxBalanceIn
+func (m *AMMContract) BuyFromPool(ctx contractapi.TransactionContextInterface, commodityID, userID string, quantity int, maxBalanceIn float64) error {
+	if err := checkAccountCanTrade(ctx, userID); err != nil {
+		return err
+	}
+
+	pool, err := m.GetPool(ctx, commodityID)
+	if err != nil {
+		return err
+	}
+
+	cost, err := quoteBuy(pool, quantity)
+	if err != nil {
+		return err
+	}
+	if cost > maxBalanceIn {
+		return fmt.Errorf("price %.2f exceeds maximum %.2f", cost, maxBalanceIn)
+	}
+
+	// Initialize asset contract if not set
+	if m.AssetContract == nil {
+		m.AssetContract = &AssetContract{}
+	}
+
+	err = m.AssetContract.updateBalance(ctx, userID, cost, "subtract")
+	if err != nil {
+		return fmt.Errorf("failed to pay for commodity: %v", err)
+	}
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	lots := takeReserveLots(pool, quantity, timestamp)
+	err = m.AssetContract.giveItems(ctx, userID, commodityID, quantity, lots)
+	if err != nil {
+		return fmt.Errorf("failed to deliver commodity: %v", err)
+	}
+
+	pool.ReserveBalance += cost
+	pool.ReserveCommodity -= quantity
+
+	return m.settleSwap(ctx, pool, userID, "buy", quantity, cost)
+}
+
+// SellToPool sells quantity units of a commodity to the pool, receiving at least minBalanceOut
+func (m *AMMContract) SellToPool(ctx contractapi.TransactionContextInterface, commodityID, userID string, quantity int, minBalanceOut float64) error {
+	if err := checkAccountCanTrade(ctx, userID); err != nil {
+		return err
+	}
+
+	pool, err := m.GetPool(ctx, commodityID)
+	if err != nil {
+		return err
+	}
+
+	proceeds, err := quoteSell(pool, quantity)
+	if err != nil {
+		return err
+	}
+	if proceeds < minBalanceOut {
+		return fmt.Errorf("proceeds %.2f are below minimum %.2f", proceeds, minBalanceOut)
+	}
+
+	// Initialize asset contract if not set
+	if m.AssetContract == nil {
+		m.AssetContract = &AssetContract{}
+	}
+
+	lots, err := m.AssetContract.takeItems(ctx, userID, commodityID, quantity)
+	if err != nil {
+		return fmt.Errorf("failed to collect commodity: %v", err)
+	}
+	addReserveLots(pool, lots)
+	err = m.AssetContract.updateBalance(ctx, userID, proceeds, "add")
+	if err != nil {
+		return fmt.Errorf("failed to pay seller: %v", err)
+	}
+
+	pool.ReserveCommodity += quantity
+	pool.ReserveBalance -= proceeds
+
+	return m.settleSwap(ctx, pool, userID, "sell", quantity, proceeds)
+}
+
+// QuoteBuy returns the balance needed to buy quantity units from the pool, including fees
+func (m *AMMContract) QuoteBuy(ctx contractapi.TransactionContextInterface, commodityID string, quantity int) (float64, error) {
+	pool, err := m.GetPool(ctx, commodityID)
+	if err != nil {
+		return 0, err
+	}
+	return quoteBuy(pool, quantity)
+}
+
+// QuoteSell returns the balance received for selling quantity units to the pool, after fees
+func (m *AMMContract) QuoteSell(ctx contractapi.TransactionContextInterface, commodityID string, quantity int) (float64, error) {
+	pool, err := m.GetPool(ctx, commodityID)
+	if err != nil {
+		return 0, err
+	}
+	return quoteSell(pool, quantity)
+}
+
+// GetPool retrieves the liquidity pool for a commodity
+func (m *AMMContract) GetPool(ctx contractapi.TransactionContextInterface, commodityID string) (*models.LiquidityPool, error) {
+	key := utils.GetLiquidityPoolKey(commodityID)
+	poolJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read liquidity pool: %v", err)
+	}
+	if poolJSON == nil {
+		return nil, fmt.Errorf("liquidity pool not found for commodity %s", commodityID)
+	}
+
+	var pool models.LiquidityPool
+	err = json.Unmarshal(poolJSON, &pool)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal liquidity pool: %v", err)
+	}
+
+	return &pool, nil
+}
+
+// GetLiquidityPosition retrieves a user's share of a pool
+func (m *AMMContract) GetLiquidityPosition(ctx contractapi.TransactionContextInterface, commodityID, userID string) (*models.LiquidityPosition, error) {
+	key, err := utils.GetLiquidityPositionKey(ctx, commodityID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create liquidity position key: %v", err)
+	}
+	positionJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read liquidity position: %v", err)
+	}
+	if positionJSON == nil {
+		// Return empty position instead of error
+		return &models.LiquidityPosition{
+			CommodityID: commodityID,
+			UserID:      userID,
+		}, nil
+	}
+
+	var position models.LiquidityPosition
+	err = json.Unmarshal(positionJSON, &position)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal liquidity position: %v", err)
+	}
+
+	return &position, nil
+}
+
+// GetMarketQuotes returns the AMM spot price of every commodity from GetAllCommodities that has a funded pool
+func (m *AMMContract) GetMarketQuotes(ctx contractapi.TransactionContextInterface) ([]*models.PoolQuote, error) {
+	// Initialize commodity contract if not set
+	if m.CommodityContract == nil {
+		m.CommodityContract = &CommodityContract{}
+	}
+
+	commodities, err := m.CommodityContract.GetAllCommodities(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var quotes []*models.PoolQuote
+	for _, commodity := range commodities {
+		pool, err := m.GetPool(ctx, commodity.CommodityID)
+		if err != nil || pool.ReserveCommodity == 0 {
+			continue
+		}
+
+		quotes = append(quotes, &models.PoolQuote{
+			CommodityID:      commodity.CommodityID,
+			Name:             commodity.Name,
+			SpotPrice:        pool.ReserveBalance / float64(pool.ReserveCommodity),
+			ReserveCommodity: pool.ReserveCommodity,
+			ReserveBalance:   pool.ReserveBalance,
+			FeeRate:          pool.FeeRate,
+		})
+	}
+
+	return quotes, nil
+}
+
+// getUserPositions retrieves a user's non-dust positions across all pools
+func (m *AMMContract) getUserPositions(ctx contractapi.TransactionContextInterface, userID string) ([]*models.LiquidityPosition, error) {
+	iterator, err := ctx.GetStub().GetStateByRange(utils.LiquidityPoolPrefix, utils.LiquidityPoolPrefix+"\uffff")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get liquidity pool iterator: %v", err)
+	}
+	defer iterator.Close()
+
+	var positions []*models.LiquidityPosition
+	for iterator.HasNext() {
+		queryResponse, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate liquidity pools: %v", err)
+		}
+
+		var pool models.LiquidityPool
+		err = json.Unmarshal(queryResponse.Value, &pool)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal liquidity pool: %v", err)
+		}
+
+		position, err := m.GetLiquidityPosition(ctx, pool.CommodityID, userID)
+		if err != nil {
+			return nil, err
+		}
+		if position.Shares >= dustShares {
+			positions = append(positions, position)
+		}
+	}
+
+	return positions, nil
+}
+
+// settleSwap stores the pool after a swap and emits the swap event
+func (m *AMMContract) settleSwap(ctx contractapi.TransactionContextInterface, pool *models.LiquidityPool, userID, side string, quantity int, amount float64) error {
+	if err := m.putPool(ctx, pool); err != nil {
+		return err
+	}
+
+	eventPayload := map[string]interface{}{
+		"commodityId": pool.CommodityID,
+		"userId":      userID,
+		"side":        side,
+		"quantity":    quantity,
+		"amount":      amount,
+		"unitPrice":   amount / float64(quantity),
+		"timestamp":   pool.UpdatedAt,
+	}
+	eventJSON, _ := json.Marshal(eventPayload)
+	ctx.GetStub().SetEvent("PoolSwap", eventJSON)
+
+	return nil
+}
+
+// putPool stamps and writes a liquidity pool to the ledger
+func (m *AMMContract) putPool(ctx contractapi.TransactionContextInterface, pool *models.LiquidityPool) error {
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	pool.UpdatedAt = timestamp
+
+	poolJSON, err := json.Marshal(pool)
+	if err != nil {
+		return fmt.Errorf("failed to marshal liquidity pool: %v", err)
+	}
+
+	key := utils.GetLiquidityPoolKey(pool.CommodityID)
+	return ctx.GetStub().PutState(key, poolJSON)
+}
+
+// putPosition stamps and writes a liquidity position to the ledger
+func (m *AMMContract) putPosition(ctx contractapi.TransactionContextInterface, position *models.LiquidityPosition) error {
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	position.UpdatedAt = timestamp
+
+	positionJSON, err := json.Marshal(position)
+	if err != nil {
+		return fmt.Errorf("failed to marshal liquidity position: %v", err)
+	}
+
+	key, err := utils.GetLiquidityPositionKey(ctx, position.CommodityID, position.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to create liquidity position key: %v", err)
+	}
+	return ctx.GetStub().PutState(key, positionJSON)
+}
+
+// takeReserveLots removes quantity units from a pool's reserve lots, before the
+// reserve is reduced, and returns the lots they were taken from
+func takeReserveLots(pool *models.LiquidityPool, quantity int, now time.Time) []models.InventoryLot {
+	reserve := models.Inventory{Quantity: pool.ReserveCommodity, Lots: pool.ReserveLots}
+	lots := takeFromLots(&reserve, quantity, now)
+	pool.ReserveLots = reserve.Lots
+	return lots
+}
+
+// addReserveLots files deposited lots under a pool's reserve
+func addReserveLots(pool *models.LiquidityPool, lots []models.InventoryLot) {
+	reserve := models.Inventory{Lots: pool.ReserveLots}
+	mergeLots(&reserve, lots)
+	pool.ReserveLots = reserve.Lots
+}
+
+// quoteBuy prices an exact-output purchase against the constant product x*y=k
+func quoteBuy(pool *models.LiquidityPool, quantity int) (float64, error) {
+	if quantity <= 0 {
+		return 0, fmt.Errorf("quantity must be positive")
+	}
+	if quantity >= pool.ReserveCommodity {
+		return 0, fmt.Errorf("insufficient pool liquidity for commodity %s", pool.CommodityID)
+	}
+
+	amountIn := pool.ReserveBalance * float64(quantity) / float64(pool.ReserveCommodity-quantity)
+	return amountIn / (1 - pool.FeeRate), nil
+}
+
+// quoteSell prices an exact-input sale against the constant product x*y=k
+func quoteSell(pool *models.LiquidityPool, quantity int) (float64, error) {
+	if quantity <= 0 {
+		return 0, fmt.Errorf("quantity must be positive")
+	}
+	if pool.ReserveCommodity == 0 {
+		return 0, fmt.Errorf("insufficient pool liquidity for commodity %s", pool.CommodityID)
+	}
+
+	effectiveIn := float64(quantity) * (1 - pool.FeeRate)
+	return pool.ReserveBalance * effectiveIn / (float64(pool.ReserveCommodity) + effectiveIn), nil
+}
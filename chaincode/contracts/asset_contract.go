@@ -3,6 +3,7 @@ package contracts
 import (
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 	"github.com/hyperledger/fabric-samples/game-chaincode/models"
@@ -12,16 +13,43 @@ import (
 // AssetContract provides functions for managing user assets
 type AssetContract struct {
 	contractapi.Contract
+	LeaderboardContract *LeaderboardContract
+	QuestContract       *QuestContract
 }
 
-// InitUser initializes a user's asset with an initial balance
+// InitUser initializes a user's asset with an initial balance. Anyone may
+// create a user with a zero balance; a non-zero initial balance mints currency
+// and needs the admin role, or a mint proposal once a multisig policy is set.
 func (c *AssetContract) InitUser(ctx contractapi.TransactionContextInterface, userID string, initialBalance float64) error {
+	if initialBalance < 0 {
+		return fmt.Errorf("initial balance cannot be negative")
+	}
+	if initialBalance > 0 {
+		if err := requireDirectOperation(ctx, OperationMint); err != nil {
+			return err
+		}
+	}
+
+	return c.initUser(ctx, userID, initialBalance)
+}
+
+// initUser creates a user's asset with the given balance
+func (c *AssetContract) initUser(ctx contractapi.TransactionContextInterface, userID string, initialBalance float64) error {
 	// Check if user already exists
 	existing, err := c.GetUserAssets(ctx, userID)
 	if err == nil && existing != nil {
 		return fmt.Errorf("user %s already exists", userID)
 	}
 
+	// Closed accounts leave a tombstone so their IDs are not reused
+	status, err := getAccountStatus(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if status.Status == AccountClosed {
+		return fmt.Errorf("user ID %s belongs to a closed account", userID)
+	}
+
 	// Get deterministic timestamp
 	timestamp, err := utils.GetTxTimestamp(ctx)
 	if err != nil {
@@ -30,18 +58,18 @@ func (c *AssetContract) InitUser(ctx contractapi.TransactionContextInterface, us
 
 	// Create new user asset
 	userAsset := models.UserAsset{
-		UserID:    userID,
-		Balance:   initialBalance,
-		UpdatedAt: timestamp,
+		UserID:        userID,
+		Balance:       initialBalance,
+		LastAccrualAt: timestamp,
+		UpdatedAt:     timestamp,
 	}
 
-	userAssetJSON, err := json.Marshal(userAsset)
+	err = c.putUserAsset(ctx, &userAsset)
 	if err != nil {
-		return fmt.Errorf("failed to marshal user asset: %v", err)
+		return err
 	}
 
-	key := utils.GetUserAssetKey(userID)
-	return ctx.GetStub().PutState(key, userAssetJSON)
+	return c.updateRichest(ctx, &userAsset)
 }
 
 // GetUserAssets retrieves a user's asset information
@@ -61,6 +89,12 @@ func (c *AssetContract) GetUserAssets(ctx contractapi.TransactionContextInterfac
 		return nil, fmt.Errorf("failed to unmarshal user asset: %v", err)
 	}
 
+	// Apply the balance rate due since the last accrual; it is saved with the next write
+	_, err = accrueBalance(ctx, &userAsset)
+	if err != nil {
+		return nil, err
+	}
+
 	return &userAsset, nil
 }
 
@@ -97,39 +131,40 @@ func (c *AssetContract) GetInventory(ctx contractapi.TransactionContextInterface
 
 // GetAllInventory retrieves all inventory items for a user
 func (c *AssetContract) GetAllInventory(ctx contractapi.TransactionContextInterface, userID string) ([]*models.Inventory, error) {
-	// Use range query to get all inventory items for a user
-	startKey := fmt.Sprintf("%s%s_", utils.InventoryPrefix, userID)
-	endKey := fmt.Sprintf("%s%s_\uffff", utils.InventoryPrefix, userID)
-
-	iterator, err := ctx.GetStub().GetStateByRange(startKey, endKey)
+	records, err := c.scanInventory(ctx, userID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get inventory iterator: %v", err)
+		return nil, err
 	}
-	defer iterator.Close()
 
 	var inventories []*models.Inventory
-	for iterator.HasNext() {
-		queryResponse, err := iterator.Next()
-		if err != nil {
-			return nil, fmt.Errorf("failed to iterate inventory: %v", err)
-		}
-
-		var inventory models.Inventory
-		err = json.Unmarshal(queryResponse.Value, &inventory)
-		if err != nil {
-			return nil, fmt.Errorf("failed to unmarshal inventory: %v", err)
-		}
-
+	for _, inventory := range records {
 		if inventory.Quantity > 0 {
-			inventories = append(inventories, &inventory)
+			inventories = append(inventories, inventory)
 		}
 	}
 
 	return inventories, nil
 }
 
-// UpdateBalance updates a user's balance (internal function)
+// UpdateBalance lets an admin adjust a user's balance directly. Once a multisig
+// policy exists, adjustments must go through an adjust_balance proposal.
 func (c *AssetContract) UpdateBalance(ctx contractapi.TransactionContextInterface, userID string, amount float64, operation string) error {
+	if err := requireDirectOperation(ctx, OperationAdjustBalance); err != nil {
+		return err
+	}
+	if amount <= 0 {
+		return fmt.Errorf("amount must be positive")
+	}
+
+	return c.updateBalance(ctx, userID, amount, operation)
+}
+
+// updateBalance updates a user's balance (internal function)
+func (c *AssetContract) updateBalance(ctx contractapi.TransactionContextInterface, userID string, amount float64, operation string) error {
+	if err := checkAccountCanTransfer(ctx, userID); err != nil {
+		return err
+	}
+
 	userAsset, err := c.GetUserAssets(ctx, userID)
 	if err != nil {
 		return err
@@ -142,46 +177,536 @@ func (c *AssetContract) UpdateBalance(ctx contractapi.TransactionContextInterfac
 		if userAsset.Balance < amount {
 			return fmt.Errorf("insufficient balance for user %s", userID)
 		}
+		// Held balance cannot be spent
+		if userAsset.Balance-userAsset.Locked < amount {
+			return fmt.Errorf("insufficient available balance for user %s (%.2f locked)", userID, userAsset.Locked)
+		}
 		userAsset.Balance -= amount
 	default:
 		return fmt.Errorf("invalid operation: %s", operation)
 	}
 
-	// Get deterministic timestamp
-	timestamp, err := utils.GetTxTimestamp(ctx)
+	err = c.putUserAsset(ctx, userAsset)
 	if err != nil {
 		return err
 	}
-	userAsset.UpdatedAt = timestamp
 
-	userAssetJSON, err := json.Marshal(userAsset)
+	return c.updateRichest(ctx, userAsset)
+}
+
+// returnEscrow credits escrowed balance back to its owner. Unlike updateBalance
+// it ignores the account status, so the funds stay frozen with the account.
+func (c *AssetContract) returnEscrow(ctx contractapi.TransactionContextInterface, userID string, amount float64) error {
+	userAsset, err := c.GetUserAssets(ctx, userID)
 	if err != nil {
-		return fmt.Errorf("failed to marshal user asset: %v", err)
+		return err
 	}
 
-	key := utils.GetUserAssetKey(userID)
-	return ctx.GetStub().PutState(key, userAssetJSON)
+	userAsset.Balance += amount
+
+	err = c.putUserAsset(ctx, userAsset)
+	if err != nil {
+		return err
+	}
+
+	return c.updateRichest(ctx, userAsset)
 }
 
-// UpdateInventory updates a user's inventory (internal function)
+// UpdateInventory lets an admin adjust a user's inventory directly. Once a
+// multisig policy exists, adjustments must go through an adjust_inventory proposal.
 func (c *AssetContract) UpdateInventory(ctx contractapi.TransactionContextInterface, userID, commodityID string, quantity int, operation string) error {
+	if err := requireDirectOperation(ctx, OperationAdjustInventory); err != nil {
+		return err
+	}
+	if quantity <= 0 {
+		return fmt.Errorf("quantity must be positive")
+	}
+
+	return c.updateInventory(ctx, userID, commodityID, quantity, operation)
+}
+
+// updateInventory updates a user's inventory (internal function)
+func (c *AssetContract) updateInventory(ctx contractapi.TransactionContextInterface, userID, commodityID string, quantity int, operation string) error {
+	_, err := c.updateInventoryLots(ctx, userID, commodityID, quantity, operation, nil)
+	return err
+}
+
+// takeItems removes items from a user's inventory and returns the perishable
+// lots they were taken from, so a transfer can carry their expiry along
+func (c *AssetContract) takeItems(ctx contractapi.TransactionContextInterface, userID, commodityID string, quantity int) ([]models.InventoryLot, error) {
+	return c.updateInventoryLots(ctx, userID, commodityID, quantity, "subtract", nil)
+}
+
+// giveItems adds items to a user's inventory under the lots they were taken
+// from; units not covered by lots are newly acquired
+func (c *AssetContract) giveItems(ctx contractapi.TransactionContextInterface, userID, commodityID string, quantity int, lots []models.InventoryLot) error {
+	_, err := c.updateInventoryLots(ctx, userID, commodityID, quantity, "add", lots)
+	return err
+}
+
+// returnEscrowItems adds escrowed items back to their owner under the lots they
+// were taken from. Unlike giveItems it ignores the account status, so the items
+// stay frozen with the account, and the storage capacity, since the items were
+// stored by the owner before they were escrowed.
+func (c *AssetContract) returnEscrowItems(ctx contractapi.TransactionContextInterface, userID, commodityID string, quantity int, lots []models.InventoryLot) error {
+	_, err := c.changeInventory(ctx, userID, commodityID, quantity, "add", lots)
+	return err
+}
+
+// updateInventoryLots updates a user's inventory. "add" files the items under
+// the given lots, and "subtract" returns the lots the items were taken from.
+func (c *AssetContract) updateInventoryLots(ctx contractapi.TransactionContextInterface, userID, commodityID string, quantity int, operation string, lots []models.InventoryLot) ([]models.InventoryLot, error) {
+	if err := checkAccountCanTransfer(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	if operation == "add" {
+		items := []models.RequiredItem{{CommodityID: commodityID, Quantity: quantity}}
+		if err := checkStorageCapacity(ctx, c, userID, items); err != nil {
+			return nil, err
+		}
+	}
+
+	return c.changeInventory(ctx, userID, commodityID, quantity, operation, lots)
+}
+
+// settleItems adds items to a user's inventory under the given lots without
+// checking storage capacity, for items the user was already committed to, such
+// as a won auction, and for the shop house account, whose stock is capped by
+// its listings
+func (c *AssetContract) settleItems(ctx contractapi.TransactionContextInterface, userID, commodityID string, quantity int, lots []models.InventoryLot) error {
+	if err := checkAccountCanTransfer(ctx, userID); err != nil {
+		return err
+	}
+
+	_, err := c.changeInventory(ctx, userID, commodityID, quantity, "add", lots)
+	return err
+}
+
+// changeInventory applies an inventory update without checking the account
+// status or the storage capacity
+func (c *AssetContract) changeInventory(ctx contractapi.TransactionContextInterface, userID, commodityID string, quantity int, operation string, lots []models.InventoryLot) ([]models.InventoryLot, error) {
 	inventory, err := c.GetInventory(ctx, userID, commodityID)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	shelfLife, err := commodityShelfLife(ctx, commodityID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return nil, err
 	}
 
+	var taken []models.InventoryLot
 	switch operation {
 	case "add":
 		inventory.Quantity += quantity
+		if shelfLife > 0 {
+			// Carried lots keep their expiry; the rest is newly acquired
+			if fresh := quantity - mergeLots(inventory, lots); fresh > 0 {
+				addLot(inventory, fresh, timestamp, shelfLife)
+			}
+		}
 	case "subtract":
 		if inventory.Quantity < quantity {
-			return fmt.Errorf("insufficient inventory for user %s, commodity %s", userID, commodityID)
+			return nil, fmt.Errorf("insufficient inventory for user %s, commodity %s", userID, commodityID)
 		}
-		inventory.Quantity -= quantity
+		// Held and spoiled items cannot be spent
+		if availableQuantity(inventory, timestamp) < quantity {
+			return nil, fmt.Errorf("insufficient available inventory for user %s, commodity %s (%d locked, %d spoiled)",
+				userID, commodityID, inventory.Locked, spoiledQuantity(inventory, timestamp))
+		}
+		taken = takeFromLots(inventory, quantity, timestamp)
 	default:
-		return fmt.Errorf("invalid operation: %s", operation)
+		return nil, fmt.Errorf("invalid operation: %s", operation)
+	}
+
+	err = c.putInventory(ctx, inventory)
+	if err != nil {
+		return nil, err
+	}
+
+	// Initialize quest contract if not set
+	if c.QuestContract == nil {
+		c.QuestContract = &QuestContract{AssetContract: c}
+	}
+
+	err = c.QuestContract.recordHolding(ctx, userID, commodityID, inventory.Quantity)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update quest progress: %v", err)
+	}
+	return taken, nil
+}
+
+// GetAvailableBalance returns the part of a user's balance not held by holds
+func (c *AssetContract) GetAvailableBalance(ctx contractapi.TransactionContextInterface, userID string) (float64, error) {
+	userAsset, err := c.GetUserAssets(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	return userAsset.Balance - userAsset.Locked, nil
+}
+
+// GetAvailableInventory returns the quantity of a commodity a user holds that is neither held by holds nor spoiled
+func (c *AssetContract) GetAvailableInventory(ctx contractapi.TransactionContextInterface, userID, commodityID string) (int, error) {
+	inventory, err := c.GetInventory(ctx, userID, commodityID)
+	if err != nil {
+		return 0, err
+	}
+
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	return availableQuantity(inventory, timestamp), nil
+}
+
+// SweepSpoiledItems removes the spoiled lots of a perishable commodity from
+// every inventory (admin only) and returns the number of items removed. Held
+// units of a lot are left in place until their hold is released.
+func (c *AssetContract) SweepSpoiledItems(ctx contractapi.TransactionContextInterface, commodityID string) (int, error) {
+	if _, err := requireRole(ctx, RoleAdmin); err != nil {
+		return 0, err
+	}
+
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	iterator, err := ctx.GetStub().GetStateByRange(utils.InventoryPrefix, utils.InventoryPrefix+"\uffff")
+	if err != nil {
+		return 0, fmt.Errorf("failed to get inventory iterator: %v", err)
+	}
+	defer iterator.Close()
+
+	var spoiled []*models.Inventory
+	for iterator.HasNext() {
+		queryResponse, err := iterator.Next()
+		if err != nil {
+			return 0, fmt.Errorf("failed to iterate inventory: %v", err)
+		}
+
+		var inventory models.Inventory
+		err = json.Unmarshal(queryResponse.Value, &inventory)
+		if err != nil {
+			return 0, fmt.Errorf("failed to unmarshal inventory: %v", err)
+		}
+
+		if inventory.CommodityID == commodityID && spoiledQuantity(&inventory, timestamp) > 0 {
+			spoiled = append(spoiled, &inventory)
+		}
+	}
+
+	type spoilage struct {
+		UserID   string `json:"userId"`
+		Quantity int    `json:"quantity"`
+	}
+	var removed []spoilage
+	total := 0
+	for _, inventory := range spoiled {
+		quantity := spoiledQuantity(inventory, timestamp)
+		if free := inventory.Quantity - inventory.Locked; quantity > free {
+			quantity = free
+		}
+		if quantity <= 0 {
+			continue
+		}
+
+		// Remove the unheld units of the oldest spoiled lots
+		remaining := quantity
+		for i := range inventory.Lots {
+			lot := &inventory.Lots[i]
+			if remaining > 0 && !timestamp.Before(lot.ExpiresAt) {
+				take := lot.Quantity - lot.Held
+				if take > remaining {
+					take = remaining
+				}
+				lot.Quantity -= take
+				remaining -= take
+			}
+		}
+		pruneLots(inventory)
+		inventory.Quantity -= quantity
+
+		err = c.putInventory(ctx, inventory)
+		if err != nil {
+			return 0, err
+		}
+
+		removed = append(removed, spoilage{UserID: inventory.UserID, Quantity: quantity})
+		total += quantity
+	}
+
+	if total > 0 {
+		eventPayload := map[string]interface{}{
+			"commodityId": commodityID,
+			"removed":     removed,
+			"total":       total,
+			"timestamp":   timestamp,
+		}
+		eventJSON, _ := json.Marshal(eventPayload)
+		ctx.GetStub().SetEvent("ItemsSpoiled", eventJSON)
+	}
+
+	return total, nil
+}
+
+// GetHold retrieves a hold on a user's assets
+func (c *AssetContract) GetHold(ctx contractapi.TransactionContextInterface, userID, reason, referenceID string) (*models.Hold, error) {
+	holdKey, err := utils.GetHoldKey(ctx, userID, reason, referenceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create hold key: %v", err)
+	}
+
+	holdJSON, err := ctx.GetStub().GetState(holdKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read hold: %v", err)
+	}
+	if holdJSON == nil {
+		return nil, fmt.Errorf("no %s hold %s for user %s", reason, referenceID, userID)
+	}
+
+	var hold models.Hold
+	err = json.Unmarshal(holdJSON, &hold)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal hold: %v", err)
+	}
+
+	return &hold, nil
+}
+
+// GetHolds retrieves every hold on a user's assets
+func (c *AssetContract) GetHolds(ctx contractapi.TransactionContextInterface, userID string) ([]*models.Hold, error) {
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(utils.HoldObjectType, []string{userID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get hold iterator: %v", err)
+	}
+	defer iterator.Close()
+
+	var holds []*models.Hold
+	for iterator.HasNext() {
+		queryResponse, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate holds: %v", err)
+		}
+
+		var hold models.Hold
+		err = json.Unmarshal(queryResponse.Value, &hold)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal hold: %v", err)
+		}
+
+		holds = append(holds, &hold)
+	}
+
+	return holds, nil
+}
+
+// placeHold locks part of a user's available balance and items. Held assets
+// stay in the account but cannot be spent until the hold is released.
+func (c *AssetContract) placeHold(ctx contractapi.TransactionContextInterface, userID, reason, referenceID string, amount float64, items []models.RequiredItem) error {
+	if err := checkAccountCanTransfer(ctx, userID); err != nil {
+		return err
+	}
+
+	if amount < 0 {
+		return fmt.Errorf("hold amount cannot be negative")
+	}
+
+	existing, err := c.GetHold(ctx, userID, reason, referenceID)
+	if err == nil && existing != nil {
+		return fmt.Errorf("%s hold %s already exists for user %s", reason, referenceID, userID)
+	}
+
+	// Merge repeated commodities so each inventory record is written once
+	var merged []models.RequiredItem
+	positions := map[string]int{}
+	for _, item := range items {
+		if item.Quantity <= 0 {
+			return fmt.Errorf("hold quantities must be positive")
+		}
+		if i, ok := positions[item.CommodityID]; ok {
+			merged[i].Quantity += item.Quantity
+			continue
+		}
+		positions[item.CommodityID] = len(merged)
+		merged = append(merged, item)
 	}
 
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	// 1. Lock the balance
+	if amount > 0 {
+		userAsset, err := c.GetUserAssets(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if userAsset.Balance-userAsset.Locked < amount {
+			return fmt.Errorf("insufficient available balance for user %s", userID)
+		}
+		userAsset.Locked += amount
+
+		err = c.putUserAsset(ctx, userAsset)
+		if err != nil {
+			return err
+		}
+	}
+
+	// 2. Lock the items, recording which lots they belong to
+	var heldLots []models.HeldLot
+	for _, item := range merged {
+		inventory, err := c.GetInventory(ctx, userID, item.CommodityID)
+		if err != nil {
+			return err
+		}
+		if availableQuantity(inventory, timestamp) < item.Quantity {
+			return fmt.Errorf("insufficient available inventory for user %s, commodity %s", userID, item.CommodityID)
+		}
+		heldLots = append(heldLots, holdFromLots(inventory, item.Quantity, timestamp)...)
+
+		err = c.putInventory(ctx, inventory)
+		if err != nil {
+			return err
+		}
+	}
+
+	hold := models.Hold{
+		UserID:      userID,
+		Reason:      reason,
+		ReferenceID: referenceID,
+		Amount:      amount,
+		Items:       merged,
+		Lots:        heldLots,
+		CreatedAt:   timestamp,
+	}
+
+	holdJSON, err := json.Marshal(hold)
+	if err != nil {
+		return fmt.Errorf("failed to marshal hold: %v", err)
+	}
+	holdKey, err := utils.GetHoldKey(ctx, userID, reason, referenceID)
+	if err != nil {
+		return fmt.Errorf("failed to create hold key: %v", err)
+	}
+	return ctx.GetStub().PutState(holdKey, holdJSON)
+}
+
+// releaseHold unlocks a hold's balance and items. If consume is set the held
+// assets are also removed from the account, e.g. when collateral is seized;
+// the caller is responsible for crediting them elsewhere.
+func (c *AssetContract) releaseHold(ctx contractapi.TransactionContextInterface, userID, reason, referenceID string, consume bool) (*models.Hold, error) {
+	hold, err := c.GetHold(ctx, userID, reason, referenceID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 1. Unlock the balance
+	if hold.Amount > 0 {
+		userAsset, err := c.GetUserAssets(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		userAsset.Locked -= hold.Amount
+		if consume {
+			userAsset.Balance -= hold.Amount
+		}
+
+		err = c.putUserAsset(ctx, userAsset)
+		if err != nil {
+			return nil, err
+		}
+		if consume {
+			err = c.updateRichest(ctx, userAsset)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// 2. Unlock the items
+	for _, item := range hold.Items {
+		inventory, err := c.GetInventory(ctx, userID, item.CommodityID)
+		if err != nil {
+			return nil, err
+		}
+		inventory.Locked -= item.Quantity
+		covered := releaseLots(inventory, hold.Lots, consume)
+		if consume && item.Quantity > covered {
+			timestamp, err := utils.GetTxTimestamp(ctx)
+			if err != nil {
+				return nil, err
+			}
+			takeFromLots(inventory, item.Quantity-covered, timestamp)
+		}
+
+		err = c.putInventory(ctx, inventory)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	holdKey, err := utils.GetHoldKey(ctx, userID, reason, referenceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create hold key: %v", err)
+	}
+	err = ctx.GetStub().DelState(holdKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete hold: %v", err)
+	}
+
+	return hold, nil
+}
+
+// putUserAsset saves a user's asset record
+func (c *AssetContract) putUserAsset(ctx contractapi.TransactionContextInterface, userAsset *models.UserAsset) error {
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	userAsset.UpdatedAt = timestamp
+
+	err = recordBalanceChange(ctx, userAsset.UserID, userAsset)
+	if err != nil {
+		return err
+	}
+
+	userAssetJSON, err := json.Marshal(userAsset)
+	if err != nil {
+		return fmt.Errorf("failed to marshal user asset: %v", err)
+	}
+	return ctx.GetStub().PutState(utils.GetUserAssetKey(userAsset.UserID), userAssetJSON)
+}
+
+// deleteUserAsset deletes a user's asset record
+func (c *AssetContract) deleteUserAsset(ctx contractapi.TransactionContextInterface, userID string) error {
+	err := recordBalanceChange(ctx, userID, nil)
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().DelState(utils.GetUserAssetKey(userID))
+	if err != nil {
+		return fmt.Errorf("failed to delete user asset: %v", err)
+	}
+	return nil
+}
+
+// putInventory saves a user's inventory record for a commodity
+func (c *AssetContract) putInventory(ctx contractapi.TransactionContextInterface, inventory *models.Inventory) error {
 	// Get deterministic timestamp
 	timestamp, err := utils.GetTxTimestamp(ctx)
 	if err != nil {
@@ -189,11 +714,281 @@ func (c *AssetContract) UpdateInventory(ctx contractapi.TransactionContextInterf
 	}
 	inventory.UpdatedAt = timestamp
 
+	err = recordSupplyChange(ctx, inventory.UserID, inventory.CommodityID, inventory)
+	if err != nil {
+		return err
+	}
+
 	inventoryJSON, err := json.Marshal(inventory)
 	if err != nil {
 		return fmt.Errorf("failed to marshal inventory: %v", err)
 	}
+	return ctx.GetStub().PutState(utils.GetInventoryKey(inventory.UserID, inventory.CommodityID), inventoryJSON)
+}
 
-	key := utils.GetInventoryKey(userID, commodityID)
-	return ctx.GetStub().PutState(key, inventoryJSON)
+// deleteInventory deletes a user's inventory record for a commodity
+func (c *AssetContract) deleteInventory(ctx contractapi.TransactionContextInterface, userID, commodityID string) error {
+	err := recordSupplyChange(ctx, userID, commodityID, nil)
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().DelState(utils.GetInventoryKey(userID, commodityID))
+	if err != nil {
+		return fmt.Errorf("failed to delete inventory: %v", err)
+	}
+	return nil
+}
+
+// updateRichest keeps the richest leaderboard in sync with a user's balance
+func (c *AssetContract) updateRichest(ctx contractapi.TransactionContextInterface, userAsset *models.UserAsset) error {
+	// Initialize leaderboard contract if not set
+	if c.LeaderboardContract == nil {
+		c.LeaderboardContract = &LeaderboardContract{}
+	}
+
+	err := c.LeaderboardContract.setScore(ctx, BoardRichest, userAsset.UserID, userAsset.Balance)
+	if err != nil {
+		return fmt.Errorf("failed to update leaderboard: %v", err)
+	}
+	return nil
+}
+
+// scanInventory retrieves every inventory record of a user, including empty ones
+func (c *AssetContract) scanInventory(ctx contractapi.TransactionContextInterface, userID string) ([]*models.Inventory, error) {
+	// Use range query to get all inventory items for a user
+	startKey := fmt.Sprintf("%s%s_", utils.InventoryPrefix, userID)
+	endKey := fmt.Sprintf("%s%s_\uffff", utils.InventoryPrefix, userID)
+
+	iterator, err := ctx.GetStub().GetStateByRange(startKey, endKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get inventory iterator: %v", err)
+	}
+	defer iterator.Close()
+
+	var inventories []*models.Inventory
+	for iterator.HasNext() {
+		queryResponse, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate inventory: %v", err)
+		}
+
+		var inventory models.Inventory
+		err = json.Unmarshal(queryResponse.Value, &inventory)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal inventory: %v", err)
+		}
+
+		// Skip users whose ID extends this one
+		if inventory.UserID == userID {
+			inventories = append(inventories, &inventory)
+		}
+	}
+
+	return inventories, nil
+}
+
+// addLot records quantity newly acquired units of a perishable commodity
+func addLot(inventory *models.Inventory, quantity int, acquiredAt time.Time, shelfLife time.Duration) {
+	// Units acquired in the same transaction share a lot
+	if n := len(inventory.Lots); n > 0 && inventory.Lots[n-1].AcquiredAt.Equal(acquiredAt) {
+		inventory.Lots[n-1].Quantity += quantity
+		return
+	}
+
+	inventory.Lots = append(inventory.Lots, models.InventoryLot{
+		Quantity:   quantity,
+		AcquiredAt: acquiredAt,
+		ExpiresAt:  acquiredAt.Add(shelfLife),
+	})
+}
+
+// mergeLots files lots carried over from another inventory in acquisition
+// order and returns the number of units they cover. The caller adjusts the quantity.
+func mergeLots(inventory *models.Inventory, lots []models.InventoryLot) int {
+	covered := 0
+	for _, lot := range lots {
+		covered += lot.Quantity
+		lot.Held = 0
+
+		i := 0
+		for i < len(inventory.Lots) && inventory.Lots[i].AcquiredAt.Before(lot.AcquiredAt) {
+			i++
+		}
+		if i < len(inventory.Lots) && inventory.Lots[i].AcquiredAt.Equal(lot.AcquiredAt) {
+			inventory.Lots[i].Quantity += lot.Quantity
+			continue
+		}
+		inventory.Lots = append(inventory.Lots, models.InventoryLot{})
+		copy(inventory.Lots[i+1:], inventory.Lots[i:])
+		inventory.Lots[i] = lot
+	}
+	return covered
+}
+
+// takeFromLots removes quantity unheld units from an inventory first in, first
+// out and returns the lots they were taken from. Units acquired before the
+// commodity had lots are taken first, then fresh lots, and spoiled lots only
+// if nothing else is left.
+func takeFromLots(inventory *models.Inventory, quantity int, now time.Time) []models.InventoryLot {
+	remaining := quantity - untrackedFree(inventory)
+	inventory.Quantity -= quantity
+
+	var taken []models.InventoryLot
+	for _, takeSpoiled := range []bool{false, true} {
+		for i := range inventory.Lots {
+			lot := &inventory.Lots[i]
+			spoiled := !now.Before(lot.ExpiresAt)
+			if remaining <= 0 || spoiled != takeSpoiled {
+				continue
+			}
+			take := lot.Quantity - lot.Held
+			if take > remaining {
+				take = remaining
+			}
+			if take <= 0 {
+				continue
+			}
+			lot.Quantity -= take
+			remaining -= take
+			taken = append(taken, models.InventoryLot{Quantity: take, AcquiredAt: lot.AcquiredAt, ExpiresAt: lot.ExpiresAt})
+		}
+	}
+
+	pruneLots(inventory)
+	return taken
+}
+
+// holdFromLots marks quantity available units of an inventory as held, in the
+// same order takeFromLots spends them, and returns the lots they belong to
+func holdFromLots(inventory *models.Inventory, quantity int, now time.Time) []models.HeldLot {
+	remaining := quantity - untrackedFree(inventory)
+	inventory.Locked += quantity
+
+	var held []models.HeldLot
+	for i := range inventory.Lots {
+		lot := &inventory.Lots[i]
+		if remaining <= 0 || !now.Before(lot.ExpiresAt) {
+			continue
+		}
+		take := lot.Quantity - lot.Held
+		if take > remaining {
+			take = remaining
+		}
+		if take <= 0 {
+			continue
+		}
+		lot.Held += take
+		remaining -= take
+		held = append(held, models.HeldLot{
+			CommodityID: inventory.CommodityID,
+			Quantity:    take,
+			AcquiredAt:  lot.AcquiredAt,
+			ExpiresAt:   lot.ExpiresAt,
+		})
+	}
+	return held
+}
+
+// releaseLots unmarks a hold's lots of the inventory's commodity as held,
+// removing them as well if consume is set, and returns the units they cover
+func releaseLots(inventory *models.Inventory, heldLots []models.HeldLot, consume bool) int {
+	covered := 0
+	for _, held := range heldLots {
+		if held.CommodityID != inventory.CommodityID {
+			continue
+		}
+		for i := range inventory.Lots {
+			lot := &inventory.Lots[i]
+			if !lot.AcquiredAt.Equal(held.AcquiredAt) {
+				continue
+			}
+			lot.Held -= held.Quantity
+			if consume {
+				lot.Quantity -= held.Quantity
+				inventory.Quantity -= held.Quantity
+			}
+			covered += held.Quantity
+			break
+		}
+	}
+
+	pruneLots(inventory)
+	return covered
+}
+
+// mergeItems merges repeated commodities of an item list, keeping the order of
+// their first appearance, so each inventory record is written once
+func mergeItems(items []models.RequiredItem) []models.RequiredItem {
+	var merged []models.RequiredItem
+	positions := map[string]int{}
+	for _, item := range items {
+		if i, ok := positions[item.CommodityID]; ok {
+			merged[i].Quantity += item.Quantity
+			continue
+		}
+		positions[item.CommodityID] = len(merged)
+		merged = append(merged, item)
+	}
+	return merged
+}
+
+// heldInventoryLots returns a hold's lots of a commodity as inventory lots, so
+// items moved out of a consumed hold keep their expiry
+func heldInventoryLots(heldLots []models.HeldLot, commodityID string) []models.InventoryLot {
+	var lots []models.InventoryLot
+	for _, held := range heldLots {
+		if held.CommodityID == commodityID {
+			lots = append(lots, models.InventoryLot{Quantity: held.Quantity, AcquiredAt: held.AcquiredAt, ExpiresAt: held.ExpiresAt})
+		}
+	}
+	return lots
+}
+
+// untrackedFree returns the number of unheld units acquired before the commodity had lots
+func untrackedFree(inventory *models.Inventory) int {
+	untracked, untrackedHeld := inventory.Quantity, inventory.Locked
+	for _, lot := range inventory.Lots {
+		untracked -= lot.Quantity
+		untrackedHeld -= lot.Held
+	}
+	if untrackedHeld > 0 {
+		untracked -= untrackedHeld
+	}
+	if untracked < 0 {
+		return 0
+	}
+	return untracked
+}
+
+// pruneLots drops empty lots
+func pruneLots(inventory *models.Inventory) {
+	var lots []models.InventoryLot
+	for _, lot := range inventory.Lots {
+		if lot.Quantity > 0 {
+			lots = append(lots, lot)
+		}
+	}
+	inventory.Lots = lots
+}
+
+// spoiledQuantity returns the number of an inventory's items that have spoiled
+// and are not held. Held units are already counted in Locked.
+func spoiledQuantity(inventory *models.Inventory, now time.Time) int {
+	spoiled := 0
+	for _, lot := range inventory.Lots {
+		if !now.Before(lot.ExpiresAt) {
+			spoiled += lot.Quantity - lot.Held
+		}
+	}
+	return spoiled
+}
+
+// availableQuantity returns the number of an inventory's items that are neither held nor spoiled
+func availableQuantity(inventory *models.Inventory, now time.Time) int {
+	available := inventory.Quantity - inventory.Locked - spoiledQuantity(inventory, now)
+	if available < 0 {
+		return 0
+	}
+	return available
 }
@@ -0,0 +1,644 @@
+package contracts
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-samples/game-chaincode/models"
+	"github.com/hyperledger/fabric-samples/game-chaincode/utils"
+)
+
+// AuctionContract provides functions for auctioning commodities
+type AuctionContract struct {
+	contractapi.Contract
+	AssetContract *AssetContract
+}
+
+// sealedBidPayload is the bid passed in the transient map under the "bid" key
+type sealedBidPayload struct {
+	Amount float64 `json:"amount"`
+	Salt   string  `json:"salt"`
+}
+
+// CreateAuction lists a quantity of a commodity for auction and escrows it from the seller.
+// A quantity of 1 auctions a unique item. Sealed auctions accept reveals until revealEndTime
+// and need a positive reserve price, which each sealed bidder deposits when committing.
+func (a *AuctionContract) CreateAuction(ctx contractapi.TransactionContextInterface, auctionID, sellerID, commodityID string, quantity int, reservePrice float64, auctionType, startTime, endTime, revealEndTime string) error {
+	// Validate auction type
+	if auctionType != "english" && auctionType != "sealed" {
+		return fmt.Errorf("invalid auction type: %s (must be 'english' or 'sealed')", auctionType)
+	}
+
+	if quantity <= 0 {
+		return fmt.Errorf("quantity must be positive")
+	}
+	if reservePrice < 0 {
+		return fmt.Errorf("reserve price cannot be negative")
+	}
+
+	if err := checkAccountCanTrade(ctx, sellerID); err != nil {
+		return err
+	}
+
+	// Check if auction already exists
+	existing, err := a.GetAuction(ctx, auctionID)
+	if err == nil && existing != nil {
+		return fmt.Errorf("auction %s already exists", auctionID)
+	}
+
+	start, err := utils.ParseTimestamp(startTime)
+	if err != nil {
+		return err
+	}
+	end, err := utils.ParseTimestamp(endTime)
+	if err != nil {
+		return err
+	}
+	if !end.After(start) {
+		return fmt.Errorf("end time must be after start time")
+	}
+
+	auction := models.Auction{
+		AuctionID:    auctionID,
+		SellerID:     sellerID,
+		CommodityID:  commodityID,
+		Quantity:     quantity,
+		ReservePrice: reservePrice,
+		AuctionType:  auctionType,
+		StartTime:    start,
+		EndTime:      end,
+		Status:       "open",
+	}
+
+	if auctionType == "sealed" {
+		revealEnd, err := utils.ParseTimestamp(revealEndTime)
+		if err != nil {
+			return err
+		}
+		if !revealEnd.After(end) {
+			return fmt.Errorf("reveal end time must be after end time")
+		}
+		if reservePrice <= 0 {
+			return fmt.Errorf("sealed auctions require a positive reserve price")
+		}
+		auction.RevealEndTime = revealEnd
+	}
+
+	// Initialize asset contract if not set
+	if a.AssetContract == nil {
+		a.AssetContract = &AssetContract{}
+	}
+
+	// Escrow the items from the seller, keeping their lots for delivery or return
+	auction.Lots, err = a.AssetContract.takeItems(ctx, sellerID, commodityID, quantity)
+	if err != nil {
+		return fmt.Errorf("failed to escrow seller inventory: %v", err)
+	}
+
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	auction.CreatedAt = timestamp
+
+	return a.putAuction(ctx, &auction)
+}
+
+// PlaceBid places an open bid on an English auction, escrowing the bid amount
+// and refunding the previous highest bidder. The highest bidder may raise their
+// own bid and pays only the difference.
+func (a *AuctionContract) PlaceBid(ctx contractapi.TransactionContextInterface, auctionID, bidderID string, amount float64) error {
+	auction, err := a.GetAuction(ctx, auctionID)
+	if err != nil {
+		return err
+	}
+
+	if auction.AuctionType != "english" {
+		return fmt.Errorf("auction %s does not accept open bids", auctionID)
+	}
+	if err := a.checkBiddingOpen(ctx, auction); err != nil {
+		return err
+	}
+	if bidderID == auction.SellerID {
+		return fmt.Errorf("seller cannot bid on own auction")
+	}
+	if err := checkAccountCanTrade(ctx, bidderID); err != nil {
+		return err
+	}
+	if amount < auction.ReservePrice {
+		return fmt.Errorf("bid %.2f is below reserve price %.2f", amount, auction.ReservePrice)
+	}
+	if auction.HighestBidderID != "" && amount <= auction.HighestBid {
+		return fmt.Errorf("bid %.2f must exceed current highest bid %.2f", amount, auction.HighestBid)
+	}
+
+	// Initialize asset contract if not set
+	if a.AssetContract == nil {
+		a.AssetContract = &AssetContract{}
+	}
+
+	if err := a.outbid(ctx, auction, bidderID, amount, 0); err != nil {
+		return err
+	}
+	auction.BidCount++
+
+	return a.putAuction(ctx, auction)
+}
+
+// CommitBid records a sealed bid for a sealed auction. The bid itself
+// ({"amount": ..., "salt": ...}) is passed in the transient map under "bid",
+// stored in the auction bids private data collection, and only its hash is
+// written to the public ledger. The reserve price is taken from the bidder as a
+// deposit, so skipping the reveal is not free.
+func (a *AuctionContract) CommitBid(ctx contractapi.TransactionContextInterface, auctionID, bidderID string) error {
+	auction, err := a.GetAuction(ctx, auctionID)
+	if err != nil {
+		return err
+	}
+
+	if auction.AuctionType != "sealed" {
+		return fmt.Errorf("auction %s does not accept sealed bids", auctionID)
+	}
+	if err := a.checkBiddingOpen(ctx, auction); err != nil {
+		return err
+	}
+	if bidderID == auction.SellerID {
+		return fmt.Errorf("seller cannot bid on own auction")
+	}
+	if err := checkAccountCanTrade(ctx, bidderID); err != nil {
+		return err
+	}
+
+	bidJSON, _, err := readSealedBid(ctx)
+	if err != nil {
+		return err
+	}
+
+	key, err := utils.GetSealedBidKey(ctx, auctionID, bidderID)
+	if err != nil {
+		return fmt.Errorf("failed to create sealed bid key: %v", err)
+	}
+
+	existing, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return fmt.Errorf("failed to read sealed bid: %v", err)
+	}
+	if existing != nil {
+		return fmt.Errorf("bidder %s already committed a bid to auction %s", bidderID, auctionID)
+	}
+
+	// Initialize asset contract if not set
+	if a.AssetContract == nil {
+		a.AssetContract = &AssetContract{}
+	}
+
+	err = a.AssetContract.updateBalance(ctx, bidderID, auction.ReservePrice, "subtract")
+	if err != nil {
+		return fmt.Errorf("failed to take bid deposit: %v", err)
+	}
+
+	err = ctx.GetStub().PutPrivateData(utils.AuctionBidsCollection, key, bidJSON)
+	if err != nil {
+		return fmt.Errorf("failed to store private bid: %v", err)
+	}
+
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	hash := sha256.Sum256(bidJSON)
+	sealedBid := models.SealedBid{
+		AuctionID:   auctionID,
+		BidderID:    bidderID,
+		BidHash:     hex.EncodeToString(hash[:]),
+		Deposit:     auction.ReservePrice,
+		CommittedAt: timestamp,
+	}
+
+	sealedBidJSON, err := json.Marshal(sealedBid)
+	if err != nil {
+		return fmt.Errorf("failed to marshal sealed bid: %v", err)
+	}
+	err = ctx.GetStub().PutState(key, sealedBidJSON)
+	if err != nil {
+		return fmt.Errorf("failed to save sealed bid: %v", err)
+	}
+
+	auction.BidCount++
+	return a.putAuction(ctx, auction)
+}
+
+// RevealBid reveals a committed sealed bid after bidding has ended. The same
+// transient "bid" bytes used in CommitBid must be supplied. A revealed bid that
+// beats the current highest bid is escrowed, counting the deposit towards it,
+// and the previous leader refunded; any other revealed bid gets its deposit back.
+func (a *AuctionContract) RevealBid(ctx contractapi.TransactionContextInterface, auctionID, bidderID string) error {
+	auction, err := a.GetAuction(ctx, auctionID)
+	if err != nil {
+		return err
+	}
+
+	if auction.AuctionType != "sealed" {
+		return fmt.Errorf("auction %s does not accept sealed bids", auctionID)
+	}
+	if auction.Status != "open" {
+		return fmt.Errorf("auction is not open (status: %s)", auction.Status)
+	}
+
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	if timestamp.Before(auction.EndTime) {
+		return fmt.Errorf("bidding has not ended yet")
+	}
+	if !timestamp.Before(auction.RevealEndTime) {
+		return fmt.Errorf("reveal period has ended")
+	}
+
+	key, err := utils.GetSealedBidKey(ctx, auctionID, bidderID)
+	if err != nil {
+		return fmt.Errorf("failed to create sealed bid key: %v", err)
+	}
+	sealedBidJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return fmt.Errorf("failed to read sealed bid: %v", err)
+	}
+	if sealedBidJSON == nil {
+		return fmt.Errorf("no sealed bid from %s for auction %s", bidderID, auctionID)
+	}
+
+	var sealedBid models.SealedBid
+	err = json.Unmarshal(sealedBidJSON, &sealedBid)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal sealed bid: %v", err)
+	}
+	if sealedBid.Revealed {
+		return fmt.Errorf("bid already revealed")
+	}
+
+	bidJSON, bid, err := readSealedBid(ctx)
+	if err != nil {
+		return err
+	}
+	hash := sha256.Sum256(bidJSON)
+	if hex.EncodeToString(hash[:]) != sealedBid.BidHash {
+		return fmt.Errorf("revealed bid does not match commitment")
+	}
+	if bid.Amount < auction.ReservePrice {
+		return fmt.Errorf("bid %.2f is below reserve price %.2f", bid.Amount, auction.ReservePrice)
+	}
+
+	sealedBid.Revealed = true
+	sealedBid.Amount = bid.Amount
+	sealedBid.RevealedAt = timestamp
+
+	sealedBidJSON, err = json.Marshal(sealedBid)
+	if err != nil {
+		return fmt.Errorf("failed to marshal sealed bid: %v", err)
+	}
+	err = ctx.GetStub().PutState(key, sealedBidJSON)
+	if err != nil {
+		return fmt.Errorf("failed to save sealed bid: %v", err)
+	}
+
+	// Initialize asset contract if not set
+	if a.AssetContract == nil {
+		a.AssetContract = &AssetContract{}
+	}
+
+	// Ties go to the earliest reveal
+	if auction.HighestBidderID != "" && bid.Amount <= auction.HighestBid {
+		if sealedBid.Deposit > 0 {
+			err = a.AssetContract.returnEscrow(ctx, bidderID, sealedBid.Deposit)
+			if err != nil {
+				return fmt.Errorf("failed to refund bid deposit: %v", err)
+			}
+		}
+		return nil
+	}
+
+	if err := a.outbid(ctx, auction, bidderID, bid.Amount, sealedBid.Deposit); err != nil {
+		return err
+	}
+
+	return a.putAuction(ctx, auction)
+}
+
+// CloseAuction settles an auction once bidding (and, for sealed auctions,
+// revealing) has ended: the winner receives the items and the seller the
+// winning bid, or the items are returned to the seller if there is no winner.
+// Deposits of sealed bids that were never revealed are paid to the seller.
+func (a *AuctionContract) CloseAuction(ctx contractapi.TransactionContextInterface, auctionID string) error {
+	auction, err := a.GetAuction(ctx, auctionID)
+	if err != nil {
+		return err
+	}
+
+	if auction.Status != "open" {
+		return fmt.Errorf("auction is not open (status: %s)", auction.Status)
+	}
+
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	closesAt := auction.EndTime
+	if auction.AuctionType == "sealed" {
+		closesAt = auction.RevealEndTime
+	}
+	if timestamp.Before(closesAt) {
+		return fmt.Errorf("auction cannot be closed before %s", closesAt.Format(time.RFC3339))
+	}
+
+	// Sum the deposits forfeited by unrevealed sealed bids
+	forfeited := 0.0
+	if auction.AuctionType == "sealed" {
+		sealedBids, err := a.GetSealedBids(ctx, auctionID)
+		if err != nil {
+			return err
+		}
+		for _, sealedBid := range sealedBids {
+			if !sealedBid.Revealed {
+				forfeited += sealedBid.Deposit
+			}
+		}
+	}
+
+	// Initialize asset contract if not set
+	if a.AssetContract == nil {
+		a.AssetContract = &AssetContract{}
+	}
+
+	payment := forfeited
+	if auction.HighestBidderID == "" {
+		// No winner, return escrowed items to the seller
+		err = a.AssetContract.returnEscrowItems(ctx, auction.SellerID, auction.CommodityID, auction.Quantity, auction.Lots)
+		if err != nil {
+			return fmt.Errorf("failed to return items to seller: %v", err)
+		}
+		auction.Status = "unsold"
+	} else if checkAccountCanTransfer(ctx, auction.HighestBidderID) != nil || checkAccountCanTransfer(ctx, auction.SellerID) != nil {
+		// A frozen winner cannot receive the items and a frozen seller cannot
+		// hand them over, so rather than blocking the auction the sale is voided:
+		// the items go back to the seller and the escrowed bid to the winner
+		err = a.AssetContract.returnEscrowItems(ctx, auction.SellerID, auction.CommodityID, auction.Quantity, auction.Lots)
+		if err != nil {
+			return fmt.Errorf("failed to return items to seller: %v", err)
+		}
+		err = a.AssetContract.returnEscrow(ctx, auction.HighestBidderID, auction.HighestBid)
+		if err != nil {
+			return fmt.Errorf("failed to refund winner: %v", err)
+		}
+		auction.Status = "voided"
+	} else {
+		// 1. Deliver items to the winner
+		err = a.AssetContract.settleItems(ctx, auction.HighestBidderID, auction.CommodityID, auction.Quantity, auction.Lots)
+		if err != nil {
+			return fmt.Errorf("failed to deliver items to winner: %v", err)
+		}
+		auction.Status = "settled"
+		payment += auction.HighestBid
+	}
+
+	// 2. Pay the escrowed winning bid and any forfeited deposits to the seller in
+	// one write; a frozen seller still receives the forfeited deposits, which stay frozen
+	if payment > 0 {
+		err = a.AssetContract.returnEscrow(ctx, auction.SellerID, payment)
+		if err != nil {
+			return fmt.Errorf("failed to pay seller: %v", err)
+		}
+	}
+	auction.SettledAt = timestamp
+
+	if err := a.putAuction(ctx, auction); err != nil {
+		return err
+	}
+
+	// Emit event
+	eventPayload := map[string]interface{}{
+		"auctionId":   auction.AuctionID,
+		"sellerId":    auction.SellerID,
+		"winnerId":    auction.HighestBidderID,
+		"commodityId": auction.CommodityID,
+		"quantity":    auction.Quantity,
+		"price":       auction.HighestBid,
+		"status":      auction.Status,
+		"timestamp":   auction.SettledAt,
+	}
+	eventJSON, _ := json.Marshal(eventPayload)
+	ctx.GetStub().SetEvent("AuctionSettled", eventJSON)
+
+	return nil
+}
+
+// CancelAuction cancels an auction that has not received any bids and
+// returns the escrowed items to the seller
+func (a *AuctionContract) CancelAuction(ctx contractapi.TransactionContextInterface, auctionID string) error {
+	auction, err := a.GetAuction(ctx, auctionID)
+	if err != nil {
+		return err
+	}
+
+	if auction.Status != "open" {
+		return fmt.Errorf("auction is not open (status: %s)", auction.Status)
+	}
+	if auction.BidCount > 0 {
+		return fmt.Errorf("auction with bids cannot be cancelled")
+	}
+
+	// Initialize asset contract if not set
+	if a.AssetContract == nil {
+		a.AssetContract = &AssetContract{}
+	}
+
+	err = a.AssetContract.returnEscrowItems(ctx, auction.SellerID, auction.CommodityID, auction.Quantity, auction.Lots)
+	if err != nil {
+		return fmt.Errorf("failed to return items to seller: %v", err)
+	}
+
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	auction.Status = "cancelled"
+	auction.SettledAt = timestamp
+
+	return a.putAuction(ctx, auction)
+}
+
+// GetAuction retrieves an auction by ID
+func (a *AuctionContract) GetAuction(ctx contractapi.TransactionContextInterface, auctionID string) (*models.Auction, error) {
+	key := utils.GetAuctionKey(auctionID)
+	auctionJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read auction: %v", err)
+	}
+	if auctionJSON == nil {
+		return nil, fmt.Errorf("auction not found: %s", auctionID)
+	}
+
+	var auction models.Auction
+	err = json.Unmarshal(auctionJSON, &auction)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal auction: %v", err)
+	}
+
+	return &auction, nil
+}
+
+// GetOpenAuctions retrieves all auctions that have not been settled or cancelled
+func (a *AuctionContract) GetOpenAuctions(ctx contractapi.TransactionContextInterface) ([]*models.Auction, error) {
+	iterator, err := ctx.GetStub().GetStateByRange(utils.AuctionPrefix, utils.AuctionPrefix+"\uffff")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get auction iterator: %v", err)
+	}
+	defer iterator.Close()
+
+	var auctions []*models.Auction
+	for iterator.HasNext() {
+		queryResponse, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate auctions: %v", err)
+		}
+
+		var auction models.Auction
+		err = json.Unmarshal(queryResponse.Value, &auction)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal auction: %v", err)
+		}
+
+		if auction.Status == "open" {
+			auctions = append(auctions, &auction)
+		}
+	}
+
+	return auctions, nil
+}
+
+// GetSealedBids retrieves the public commitments of all sealed bids for an auction
+func (a *AuctionContract) GetSealedBids(ctx contractapi.TransactionContextInterface, auctionID string) ([]*models.SealedBid, error) {
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(utils.SealedBidObjectType, []string{auctionID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sealed bid iterator: %v", err)
+	}
+	defer iterator.Close()
+
+	var bids []*models.SealedBid
+	for iterator.HasNext() {
+		queryResponse, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate sealed bids: %v", err)
+		}
+
+		var bid models.SealedBid
+		err = json.Unmarshal(queryResponse.Value, &bid)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal sealed bid: %v", err)
+		}
+
+		bids = append(bids, &bid)
+	}
+
+	return bids, nil
+}
+
+// checkBiddingOpen verifies the auction is open and the transaction falls in the bidding window
+func (a *AuctionContract) checkBiddingOpen(ctx contractapi.TransactionContextInterface, auction *models.Auction) error {
+	if auction.Status != "open" {
+		return fmt.Errorf("auction is not open (status: %s)", auction.Status)
+	}
+
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	if timestamp.Before(auction.StartTime) {
+		return fmt.Errorf("auction has not started yet")
+	}
+	if !timestamp.Before(auction.EndTime) {
+		return fmt.Errorf("bidding has ended")
+	}
+
+	return nil
+}
+
+// outbid escrows the new leading bid and refunds the previous leader. held is
+// the part of the bid already escrowed from the bidder. A leader raising their
+// own bid is only charged the difference, so each balance is written once:
+// Fabric reads do not see earlier writes in the same transaction.
+func (a *AuctionContract) outbid(ctx contractapi.TransactionContextInterface, auction *models.Auction, bidderID string, amount, held float64) error {
+	previousID := auction.HighestBidderID
+	if previousID == bidderID {
+		held += auction.HighestBid
+		previousID = ""
+	}
+
+	if amount > held {
+		err := a.AssetContract.updateBalance(ctx, bidderID, amount-held, "subtract")
+		if err != nil {
+			return fmt.Errorf("failed to escrow bid: %v", err)
+		}
+	}
+
+	if previousID != "" {
+		err := a.AssetContract.returnEscrow(ctx, previousID, auction.HighestBid)
+		if err != nil {
+			return fmt.Errorf("failed to refund outbid bidder: %v", err)
+		}
+	}
+
+	auction.HighestBidderID = bidderID
+	auction.HighestBid = amount
+	return nil
+}
+
+// putAuction writes an auction to the ledger
+func (a *AuctionContract) putAuction(ctx contractapi.TransactionContextInterface, auction *models.Auction) error {
+	auctionJSON, err := json.Marshal(auction)
+	if err != nil {
+		return fmt.Errorf("failed to marshal auction: %v", err)
+	}
+
+	key := utils.GetAuctionKey(auction.AuctionID)
+	return ctx.GetStub().PutState(key, auctionJSON)
+}
+
+// readSealedBid reads and parses the sealed bid from the transient map
+func readSealedBid(ctx contractapi.TransactionContextInterface) ([]byte, *sealedBidPayload, error) {
+	transientMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read transient map: %v", err)
+	}
+
+	bidJSON, ok := transientMap["bid"]
+	if !ok || len(bidJSON) == 0 {
+		return nil, nil, fmt.Errorf("bid must be passed in the transient map under the \"bid\" key")
+	}
+
+	var bid sealedBidPayload
+	err = json.Unmarshal(bidJSON, &bid)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse bid: %v", err)
+	}
+	if bid.Amount <= 0 {
+		return nil, nil, fmt.Errorf("bid amount must be positive")
+	}
+	if bid.Salt == "" {
+		return nil, nil, fmt.Errorf("bid salt cannot be empty")
+	}
+
+	return bidJSON, &bid, nil
+}
@@ -3,6 +3,7 @@ package contracts
 import (
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 	"github.com/hyperledger/fabric-samples/game-chaincode/models"
@@ -31,6 +32,17 @@ func (c *CommodityContract) CreateCommodity(ctx contractapi.TransactionContextIn
 		}
 	}
 
+	// Perishable commodities declare their shelf life and bulky ones their
+	// storage size in the metadata
+	shelfLifeSeconds, err := metadataWholeNumber(metadata, "shelfLifeSeconds")
+	if err != nil {
+		return err
+	}
+	size, err := metadataWholeNumber(metadata, "size")
+	if err != nil {
+		return err
+	}
+
 	// Get deterministic timestamp from transaction
 	timestamp, err := utils.GetTxTimestamp(ctx)
 	if err != nil {
@@ -39,10 +51,12 @@ func (c *CommodityContract) CreateCommodity(ctx contractapi.TransactionContextIn
 
 	// Create new commodity
 	commodity := models.Commodity{
-		CommodityID: commodityID,
-		Name:        name,
-		Metadata:    metadata,
-		CreatedAt:   timestamp,
+		CommodityID:      commodityID,
+		Name:             name,
+		Metadata:         metadata,
+		ShelfLifeSeconds: shelfLifeSeconds,
+		Size:             size,
+		CreatedAt:        timestamp,
 	}
 
 	commodityJSON, err := json.Marshal(commodity)
@@ -113,9 +127,9 @@ func (c *CommodityContract) InitializeCommodities(ctx contractapi.TransactionCon
 		{"2", "Silver", `{"imageUrl": "/images/silver.png"}`},
 		{"3", "Crude Oil", `{"imageUrl": "/images/oil.png"}`},
 		{"4", "Natural Gas", `{"imageUrl": "/images/gas.png"}`},
-		{"5", "Corn", `{"imageUrl": "/images/corn.png"}`},
-		{"6", "Wheat", `{"imageUrl": "/images/wheat.png"}`},
-		{"7", "Coffee", `{"imageUrl": "/images/coffee.png"}`},
+		{"5", "Corn", `{"imageUrl": "/images/corn.png", "shelfLifeSeconds": 2592000}`},
+		{"6", "Wheat", `{"imageUrl": "/images/wheat.png", "shelfLifeSeconds": 5184000}`},
+		{"7", "Coffee", `{"imageUrl": "/images/coffee.png", "shelfLifeSeconds": 7776000}`},
 		{"8", "Sugar", `{"imageUrl": "/images/sugar.png"}`},
 	}
 
@@ -129,3 +143,57 @@ func (c *CommodityContract) InitializeCommodities(ctx contractapi.TransactionCon
 
 	return nil
 }
+
+// metadataWholeNumber reads an optional positive whole number from commodity metadata
+func metadataWholeNumber(metadata map[string]interface{}, key string) (int, error) {
+	value, ok := metadata[key]
+	if !ok {
+		return 0, nil
+	}
+	number, ok := value.(float64)
+	if !ok || number <= 0 || number != float64(int(number)) {
+		return 0, fmt.Errorf("%s must be a positive whole number", key)
+	}
+	return int(number), nil
+}
+
+// readCommodity returns a commodity, or nil if it is not registered
+func readCommodity(ctx contractapi.TransactionContextInterface, commodityID string) (*models.Commodity, error) {
+	commodityJSON, err := ctx.GetStub().GetState(utils.GetCommodityKey(commodityID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read commodity: %v", err)
+	}
+	if commodityJSON == nil {
+		return nil, nil
+	}
+
+	var commodity models.Commodity
+	err = json.Unmarshal(commodityJSON, &commodity)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal commodity: %v", err)
+	}
+
+	return &commodity, nil
+}
+
+// commodityShelfLife returns how long a commodity's items last after they are
+// acquired, or 0 if they never spoil or the commodity is not registered
+func commodityShelfLife(ctx contractapi.TransactionContextInterface, commodityID string) (time.Duration, error) {
+	commodity, err := readCommodity(ctx, commodityID)
+	if err != nil || commodity == nil {
+		return 0, err
+	}
+	return time.Duration(commodity.ShelfLifeSeconds) * time.Second, nil
+}
+
+// commoditySize returns the storage units each item of a commodity takes up
+func commoditySize(ctx contractapi.TransactionContextInterface, commodityID string) (int, error) {
+	commodity, err := readCommodity(ctx, commodityID)
+	if err != nil {
+		return 0, err
+	}
+	if commodity == nil || commodity.Size == 0 {
+		return 1, nil
+	}
+	return commodity.Size, nil
+}
@@ -1,33 +1,80 @@
 package contracts
 
 import (
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"testing"
+	"time"
 
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
 	"github.com/hyperledger/fabric-chaincode-go/shim"
 	"github.com/hyperledger/fabric-chaincode-go/shimtest"
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 	"github.com/hyperledger/fabric-samples/game-chaincode/models"
+	"github.com/hyperledger/fabric-samples/game-chaincode/utils"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
+// MockClientIdentity is a mock client identity
+type MockClientIdentity struct {
+	id   string
+	cert *x509.Certificate
+}
+
+func (m *MockClientIdentity) GetID() (string, error) { return m.id, nil }
+
+func (m *MockClientIdentity) GetMSPID() (string, error) { return "Org1MSP", nil }
+
+func (m *MockClientIdentity) GetAttributeValue(attrName string) (string, bool, error) {
+	return "", false, nil
+}
+
+func (m *MockClientIdentity) AssertAttributeValue(attrName, attrValue string) error {
+	return fmt.Errorf("attribute %s not found", attrName)
+}
+
+func (m *MockClientIdentity) GetX509Certificate() (*x509.Certificate, error) { return m.cert, nil }
+
 // MockTransactionContext is a mock transaction context
 type MockTransactionContext struct {
 	contractapi.TransactionContext
-	stub *shimtest.MockStub
+	stub     *shimtest.MockStub
+	identity *MockClientIdentity
 }
 
 func (m *MockTransactionContext) GetStub() shim.ChaincodeStubInterface {
 	return m.stub
 }
 
+func (m *MockTransactionContext) GetClientIdentity() cid.ClientIdentity {
+	return m.identity
+}
+
 func NewMockContext() *MockTransactionContext {
 	return &MockTransactionContext{
-		stub: shimtest.NewMockStub("mockStub", nil),
+		stub:     shimtest.NewMockStub("mockStub", nil),
+		identity: &MockClientIdentity{id: "admin", cert: orgAdminCert},
 	}
 }
 
+// orgAdminCert is the certificate of an organization admin identity
+var orgAdminCert = &x509.Certificate{Subject: pkix.Name{OrganizationalUnit: []string{"admin"}}}
+
+// setCaller switches the client identity submitting the next calls
+func setCaller(ctx *MockTransactionContext, identityID string) {
+	ctx.identity = &MockClientIdentity{id: identityID}
+}
+
+// setTxTime overrides the timestamp of the current mock transaction
+func setTxTime(ctx *MockTransactionContext, t time.Time) {
+	ctx.stub.TxTimestamp = timestamppb.New(t)
+}
+
 // Test AssetContract
 func TestInitUser(t *testing.T) {
 	ctx := NewMockContext()
@@ -35,6 +82,7 @@ func TestInitUser(t *testing.T) {
 
 	// Test successful user initialization
 	ctx.stub.MockTransactionStart("someTxID")
+	new(AccessContract).InitAdmin(ctx)
 	err := contract.InitUser(ctx, "user1", 1000.0)
 	ctx.stub.MockTransactionEnd("someTxID")
 	assert.NoError(t, err)
@@ -49,6 +97,25 @@ func TestInitUser(t *testing.T) {
 	err = contract.InitUser(ctx, "user1", 500.0)
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "already exists")
+
+	// Anyone may create a user, but only with a zero balance
+	ctx.stub.MockTransactionStart("txID2")
+	setCaller(ctx, "user2")
+	err = contract.InitUser(ctx, "user2", 500.0)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "not authorized")
+	err = contract.InitUser(ctx, "user2", 0)
+	assert.NoError(t, err)
+	err = contract.InitUser(ctx, "user3", -1)
+	assert.Error(t, err)
+
+	// A multisig policy makes a minting InitUser a proposal
+	ctx.stub.PutState(utils.MultisigPolicyKey, []byte(`{}`))
+	setCaller(ctx, "admin")
+	err = contract.InitUser(ctx, "user3", 500.0)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "propose mint")
+	ctx.stub.MockTransactionEnd("txID2")
 }
 
 func TestGetUserAssets(t *testing.T) {
@@ -61,7 +128,7 @@ func TestGetUserAssets(t *testing.T) {
 
 	// Initialize user and test retrieval
 	ctx.stub.MockTransactionStart("someTxID")
-	err = contract.InitUser(ctx, "user1", 1500.0)
+	err = contract.initUser(ctx, "user1", 1500.0)
 	assert.NoError(t, err)
 	ctx.stub.MockTransactionEnd("someTxID")
 	asset, err := contract.GetUserAssets(ctx, "user1")
@@ -75,24 +142,24 @@ func TestUpdateBalance(t *testing.T) {
 	contract := new(AssetContract)
 	ctx.stub.MockTransactionStart("someTxID")
 	// Initialize user
-	err := contract.InitUser(ctx, "user1", 1000.0)
+	err := contract.initUser(ctx, "user1", 1000.0)
 	assert.NoError(t, err)
 	// Test add operation
-	err = contract.UpdateBalance(ctx, "user1", 500.0, "add")
+	err = contract.updateBalance(ctx, "user1", 500.0, "add")
 	assert.NoError(t, err)
 
 	asset, _ := contract.GetUserAssets(ctx, "user1")
 	assert.Equal(t, 1500.0, asset.Balance)
 
 	// Test subtract operation
-	err = contract.UpdateBalance(ctx, "user1", 300.0, "subtract")
+	err = contract.updateBalance(ctx, "user1", 300.0, "subtract")
 	assert.NoError(t, err)
 
 	asset, _ = contract.GetUserAssets(ctx, "user1")
 	assert.Equal(t, 1200.0, asset.Balance)
 
 	// Test insufficient balance
-	err = contract.UpdateBalance(ctx, "user1", 2000.0, "subtract")
+	err = contract.updateBalance(ctx, "user1", 2000.0, "subtract")
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "insufficient balance")
 	ctx.stub.MockTransactionEnd("someTxID")
@@ -104,37 +171,371 @@ func TestUpdateInventory(t *testing.T) {
 
 	ctx.stub.MockTransactionStart("txID1")
 	// Initialize user
-	err := contract.InitUser(ctx, "user1", 1000.0)
+	err := contract.initUser(ctx, "user1", 1000.0)
 	assert.NoError(t, err)
 
 	// Test add inventory
-	err = contract.UpdateInventory(ctx, "user1", "commodity1", 10, "add")
+	err = contract.updateInventory(ctx, "user1", "commodity1", 10, "add")
 	assert.NoError(t, err)
 
 	inventory, _ := contract.GetInventory(ctx, "user1", "commodity1")
 	assert.Equal(t, 10, inventory.Quantity)
 
 	// Test add more
-	err = contract.UpdateInventory(ctx, "user1", "commodity1", 5, "add")
+	err = contract.updateInventory(ctx, "user1", "commodity1", 5, "add")
 	assert.NoError(t, err)
 
 	inventory, _ = contract.GetInventory(ctx, "user1", "commodity1")
 	assert.Equal(t, 15, inventory.Quantity)
 
 	// Test subtract
-	err = contract.UpdateInventory(ctx, "user1", "commodity1", 7, "subtract")
+	err = contract.updateInventory(ctx, "user1", "commodity1", 7, "subtract")
 	assert.NoError(t, err)
 
 	inventory, _ = contract.GetInventory(ctx, "user1", "commodity1")
 	assert.Equal(t, 8, inventory.Quantity)
 
 	// Test insufficient inventory
-	err = contract.UpdateInventory(ctx, "user1", "commodity1", 20, "subtract")
+	err = contract.updateInventory(ctx, "user1", "commodity1", 20, "subtract")
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "insufficient inventory")
 	ctx.stub.MockTransactionEnd("txID1")
 }
 
+func TestHolds(t *testing.T) {
+	ctx := NewMockContext()
+	contract := new(AssetContract)
+	tradeContract := &TradeContract{AssetContract: contract}
+	accountContract := &AccountContract{AssetContract: contract, TradeContract: tradeContract}
+
+	ctx.stub.MockTransactionStart("txID1")
+	new(AccessContract).InitAdmin(ctx)
+	contract.initUser(ctx, "user1", 100.0)
+	contract.initUser(ctx, "user2", 100.0)
+	contract.updateInventory(ctx, "user1", "commodity1", 10, "add")
+
+	// Repeated commodities are merged into one hold
+	items := []models.RequiredItem{{CommodityID: "commodity1", Quantity: 4}, {CommodityID: "commodity1", Quantity: 2}}
+	err := contract.placeHold(ctx, "user1", "test", "ref1", 60.0, items)
+	assert.NoError(t, err)
+	err = contract.placeHold(ctx, "user1", "test", "ref1", 1.0, nil)
+	assert.Error(t, err)
+	err = contract.placeHold(ctx, "user1", "test", "ref2", 50.0, nil)
+	assert.Error(t, err)
+
+	hold, _ := contract.GetHold(ctx, "user1", "test", "ref1")
+	assert.Equal(t, 1, len(hold.Items))
+	assert.Equal(t, 6, hold.Items[0].Quantity)
+
+	// Subtracting cannot dip into held amounts
+	err = contract.updateBalance(ctx, "user1", 50.0, "subtract")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "insufficient available balance")
+	err = contract.updateBalance(ctx, "user1", 40.0, "subtract")
+	assert.NoError(t, err)
+	err = contract.updateInventory(ctx, "user1", "commodity1", 5, "subtract")
+	assert.Error(t, err)
+	err = contract.updateInventory(ctx, "user1", "commodity1", 4, "subtract")
+	assert.NoError(t, err)
+
+	available, _ := contract.GetAvailableBalance(ctx, "user1")
+	assert.Equal(t, 0.0, available)
+	availableItems, _ := contract.GetAvailableInventory(ctx, "user1", "commodity1")
+	assert.Equal(t, 0, availableItems)
+	err = tradeContract.CreateTrade(ctx, "trade1", "user2", "user1", "commodity1", 1, 1.0, "buy")
+	assert.Error(t, err)
+
+	// Accounts with holds cannot be closed
+	err = accountContract.CloseAccount(ctx, "user1", "user2", "user request")
+	assert.Error(t, err)
+
+	// Releasing frees the assets; consuming removes them
+	_, err = contract.releaseHold(ctx, "user1", "test", "ref1", false)
+	assert.NoError(t, err)
+	availableItems, _ = contract.GetAvailableInventory(ctx, "user1", "commodity1")
+	assert.Equal(t, 6, availableItems)
+
+	contract.placeHold(ctx, "user1", "test", "ref3", 30.0, items)
+	_, err = contract.releaseHold(ctx, "user1", "test", "ref3", true)
+	assert.NoError(t, err)
+	userAsset, _ := contract.GetUserAssets(ctx, "user1")
+	assert.Equal(t, 30.0, userAsset.Balance)
+	assert.Equal(t, 0.0, userAsset.Locked)
+	inventory, _ := contract.GetInventory(ctx, "user1", "commodity1")
+	assert.Equal(t, 0, inventory.Quantity)
+
+	holds, _ := contract.GetHolds(ctx, "user1")
+	assert.Equal(t, 0, len(holds))
+	ctx.stub.MockTransactionEnd("txID1")
+}
+
+func TestPerishableItems(t *testing.T) {
+	ctx := NewMockContext()
+	contract := new(AssetContract)
+	commodityContract := new(CommodityContract)
+	tradeContract := &TradeContract{AssetContract: contract}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	ctx.stub.MockTransactionStart("txID1")
+	setTxTime(ctx, start)
+	new(AccessContract).InitAdmin(ctx)
+	err := commodityContract.CreateCommodity(ctx, "corn", "Corn", `{"shelfLifeSeconds": 864000}`)
+	assert.NoError(t, err)
+	err = commodityContract.CreateCommodity(ctx, "rock", "Rock", `{"shelfLifeSeconds": -1}`)
+	assert.Error(t, err)
+	contract.initUser(ctx, "user1", 1000.0)
+	contract.initUser(ctx, "user2", 1000.0)
+	contract.updateInventory(ctx, "user1", "corn", 5, "add")
+	ctx.stub.MockTransactionEnd("txID1")
+
+	// Items are consumed oldest lot first
+	ctx.stub.MockTransactionStart("txID2")
+	setTxTime(ctx, start.Add(5*day))
+	contract.updateInventory(ctx, "user1", "corn", 3, "add")
+	err = contract.updateInventory(ctx, "user1", "corn", 2, "subtract")
+	assert.NoError(t, err)
+	inventory, _ := contract.GetInventory(ctx, "user1", "corn")
+	assert.Equal(t, 6, inventory.Quantity)
+	assert.Equal(t, 2, len(inventory.Lots))
+	assert.Equal(t, 3, inventory.Lots[0].Quantity)
+	ctx.stub.MockTransactionEnd("txID2")
+
+	// Spoiled lots cannot be spent or traded
+	ctx.stub.MockTransactionStart("txID3")
+	setTxTime(ctx, start.Add(11*day))
+	available, _ := contract.GetAvailableInventory(ctx, "user1", "corn")
+	assert.Equal(t, 3, available)
+	err = contract.updateInventory(ctx, "user1", "corn", 4, "subtract")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "3 spoiled")
+	err = tradeContract.CreateTrade(ctx, "trade1", "user2", "user1", "corn", 4, 1.0, "buy")
+	assert.Error(t, err)
+	err = tradeContract.CreateTrade(ctx, "trade1", "user2", "user1", "corn", 3, 1.0, "buy")
+	assert.NoError(t, err)
+
+	// Sweeping removes the spoiled lots
+	setCaller(ctx, "user1")
+	_, err = contract.SweepSpoiledItems(ctx, "corn")
+	assert.Error(t, err)
+	setCaller(ctx, "admin")
+	removed, err := contract.SweepSpoiledItems(ctx, "corn")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, removed)
+	inventory, _ = contract.GetInventory(ctx, "user1", "corn")
+	assert.Equal(t, 3, inventory.Quantity)
+	assert.Equal(t, 1, len(inventory.Lots))
+	ctx.stub.MockTransactionEnd("txID3")
+}
+
+func TestPerishableHolds(t *testing.T) {
+	ctx := NewMockContext()
+	contract := new(AssetContract)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	ctx.stub.MockTransactionStart("txID1")
+	setTxTime(ctx, start)
+	new(AccessContract).InitAdmin(ctx)
+	new(CommodityContract).CreateCommodity(ctx, "corn", "Corn", `{"shelfLifeSeconds": 864000}`)
+	contract.initUser(ctx, "user1", 1000.0)
+	contract.updateInventory(ctx, "user1", "corn", 5, "add")
+
+	// The hold covers the oldest lot
+	setTxTime(ctx, start.Add(5*day))
+	contract.updateInventory(ctx, "user1", "corn", 5, "add")
+	err := contract.placeHold(ctx, "user1", "test", "hold1", 0, []models.RequiredItem{{CommodityID: "corn", Quantity: 5}})
+	assert.NoError(t, err)
+	hold, _ := contract.GetHold(ctx, "user1", "test", "hold1")
+	assert.Equal(t, 1, len(hold.Lots))
+	assert.Equal(t, start, hold.Lots[0].AcquiredAt)
+
+	// Once it spoils, the held lot is not subtracted twice and is not swept
+	setTxTime(ctx, start.Add(11*day))
+	available, _ := contract.GetAvailableInventory(ctx, "user1", "corn")
+	assert.Equal(t, 5, available)
+	removed, err := contract.SweepSpoiledItems(ctx, "corn")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, removed)
+	inventory, _ := contract.GetInventory(ctx, "user1", "corn")
+	assert.Equal(t, 10, inventory.Quantity)
+
+	// After release the spoiled lot is swept and the fresh one kept
+	_, err = contract.releaseHold(ctx, "user1", "test", "hold1", false)
+	assert.NoError(t, err)
+	available, _ = contract.GetAvailableInventory(ctx, "user1", "corn")
+	assert.Equal(t, 5, available)
+	removed, _ = contract.SweepSpoiledItems(ctx, "corn")
+	assert.Equal(t, 5, removed)
+	inventory, _ = contract.GetInventory(ctx, "user1", "corn")
+	assert.Equal(t, 5, inventory.Quantity)
+	assert.Equal(t, start.Add(5*day), inventory.Lots[0].AcquiredAt)
+	ctx.stub.MockTransactionEnd("txID1")
+}
+
+func TestPerishableTransfersKeepLots(t *testing.T) {
+	ctx := NewMockContext()
+	assetContract := new(AssetContract)
+	commodityContract := new(CommodityContract)
+	tradeContract := &TradeContract{AssetContract: assetContract}
+	guildContract := &GuildContract{AssetContract: assetContract}
+	ammContract := &AMMContract{AssetContract: assetContract, CommodityContract: commodityContract}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	ctx.stub.MockTransactionStart("txID1")
+	setTxTime(ctx, start)
+	new(AccessContract).InitAdmin(ctx)
+	commodityContract.CreateCommodity(ctx, "corn", "Corn", `{"shelfLifeSeconds": 864000}`)
+	assetContract.initUser(ctx, "user1", 1000.0)
+	assetContract.initUser(ctx, "user2", 1000.0)
+	assetContract.updateInventory(ctx, "user1", "corn", 10, "add")
+	ctx.stub.MockTransactionEnd("txID1")
+
+	// Items moved through a trade, a guild treasury and a pool keep their expiry
+	ctx.stub.MockTransactionStart("txID2")
+	setTxTime(ctx, start.Add(5*day))
+	tradeContract.CreateTrade(ctx, "trade1", "user2", "user1", "corn", 2, 10.0, "buy")
+	err := tradeContract.ExecuteTrade(ctx, "trade1")
+	assert.NoError(t, err)
+
+	guildContract.CreateGuild(ctx, "guild1", "Guild", "user2")
+	err = guildContract.DepositItems(ctx, "guild1", "user2", "corn", 2)
+	assert.NoError(t, err)
+	treasury, _ := guildContract.GetGuildInventory(ctx, "guild1")
+	assert.Equal(t, start, treasury[0].Lots[0].AcquiredAt)
+	err = guildContract.WithdrawItems(ctx, "guild1", "user2", "corn", 1)
+	assert.NoError(t, err)
+
+	ammContract.CreatePool(ctx, "corn", 0.0)
+	err = ammContract.AddLiquidity(ctx, "corn", "user1", 4, 40.0)
+	assert.NoError(t, err)
+	err = ammContract.BuyFromPool(ctx, "corn", "user2", 1, 100.0)
+	assert.NoError(t, err)
+	pool, _ := ammContract.GetPool(ctx, "corn")
+	assert.Equal(t, []models.InventoryLot{{Quantity: 3, AcquiredAt: start, ExpiresAt: start.Add(10 * day)}}, pool.ReserveLots)
+
+	inventory, _ := assetContract.GetInventory(ctx, "user2", "corn")
+	assert.Equal(t, 2, inventory.Quantity)
+	assert.Equal(t, []models.InventoryLot{{Quantity: 2, AcquiredAt: start, ExpiresAt: start.Add(10 * day)}}, inventory.Lots)
+	ctx.stub.MockTransactionEnd("txID2")
+
+	ctx.stub.MockTransactionStart("txID3")
+	setTxTime(ctx, start.Add(11*day))
+	available, _ := assetContract.GetAvailableInventory(ctx, "user2", "corn")
+	assert.Equal(t, 0, available)
+	ctx.stub.MockTransactionEnd("txID3")
+}
+
+func TestAuctionEscrowKeepsLots(t *testing.T) {
+	ctx := NewMockContext()
+	assetContract := new(AssetContract)
+	auctionContract := &AuctionContract{AssetContract: assetContract}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	lot := func(quantity int) []models.InventoryLot {
+		return []models.InventoryLot{{Quantity: quantity, AcquiredAt: start, ExpiresAt: start.Add(10 * day)}}
+	}
+
+	ctx.stub.MockTransactionStart("txID1")
+	setTxTime(ctx, start)
+	new(AccessContract).InitAdmin(ctx)
+	new(CommodityContract).CreateCommodity(ctx, "corn", "Corn", `{"shelfLifeSeconds": 864000}`)
+	assetContract.initUser(ctx, "seller", 0.0)
+	assetContract.initUser(ctx, "bidder", 1000.0)
+	assetContract.updateInventory(ctx, "seller", "corn", 5, "add")
+	ctx.stub.MockTransactionEnd("txID1")
+
+	// Escrowed items keep their expiry whether they are delivered or returned
+	ctx.stub.MockTransactionStart("txID2")
+	setTxTime(ctx, start.Add(5*day))
+	err := auctionContract.CreateAuction(ctx, "auction1", "seller", "corn", 3, 10.0, "english",
+		"2024-01-06T00:00:00Z", "2024-01-06T01:00:00Z", "")
+	assert.NoError(t, err)
+	err = auctionContract.CreateAuction(ctx, "auction2", "seller", "corn", 1, 10.0, "english",
+		"2024-01-06T00:00:00Z", "2024-01-06T01:00:00Z", "")
+	assert.NoError(t, err)
+	auction, _ := auctionContract.GetAuction(ctx, "auction1")
+	assert.Equal(t, lot(3), auction.Lots)
+
+	err = auctionContract.CancelAuction(ctx, "auction2")
+	assert.NoError(t, err)
+	err = auctionContract.PlaceBid(ctx, "auction1", "bidder", 20.0)
+	assert.NoError(t, err)
+
+	setTxTime(ctx, start.Add(5*day+2*time.Hour))
+	err = auctionContract.CloseAuction(ctx, "auction1")
+	assert.NoError(t, err)
+
+	inventory, _ := assetContract.GetInventory(ctx, "bidder", "corn")
+	assert.Equal(t, lot(3), inventory.Lots)
+	inventory, _ = assetContract.GetInventory(ctx, "seller", "corn")
+	assert.Equal(t, lot(2), inventory.Lots)
+	ctx.stub.MockTransactionEnd("txID2")
+}
+
+func TestPerishableSettlementsKeepLots(t *testing.T) {
+	ctx := NewMockContext()
+	assetContract := new(AssetContract)
+	marketDataContract := new(MarketDataContract)
+	tradeContract := &TradeContract{AssetContract: assetContract, MarketDataContract: marketDataContract}
+	lendingContract := &LendingContract{
+		AssetContract:     assetContract,
+		ValuationContract: &ValuationContract{AssetContract: assetContract, MarketDataContract: marketDataContract},
+	}
+	shopContract := &ShopContract{AssetContract: assetContract}
+	accountContract := &AccountContract{AssetContract: assetContract, TradeContract: tradeContract}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	lot := func(quantity int) []models.InventoryLot {
+		return []models.InventoryLot{{Quantity: quantity, AcquiredAt: start, ExpiresAt: start.Add(100 * day)}}
+	}
+
+	ctx.stub.MockTransactionStart("txID1")
+	setTxTime(ctx, start)
+	new(AccessContract).InitAdmin(ctx)
+	new(CommodityContract).CreateCommodity(ctx, "corn", "Corn", `{"shelfLifeSeconds": 8640000}`)
+	for _, userID := range []string{"bank", "alice", "bob", "carol", "dave", "house", "treasury"} {
+		assetContract.initUser(ctx, userID, 1000.0)
+	}
+	assetContract.updateInventory(ctx, "bob", "corn", 10, "add")
+	assetContract.updateInventory(ctx, "carol", "corn", 5, "add")
+	tradeContract.CreateTrade(ctx, "trade1", "alice", "carol", "corn", 1, 10.0, "buy")
+	tradeContract.ExecuteTrade(ctx, "trade1")
+	lendingContract.SetLendingConfig(ctx, "bank", PriceSourceLast, 0.1, 0.5, 0.8)
+	err := lendingContract.OpenLoan(ctx, "loan1", "bob", `[{"commodityId":"corn","quantity":10}]`, 50.0)
+	assert.NoError(t, err)
+	shopContract.SetShopHouse(ctx, "house")
+	err = shopContract.SetShopListing(ctx, "corn", 10.0, 4.0, 10, 5, 0, secondsPerDay)
+	assert.NoError(t, err)
+	ctx.stub.MockTransactionEnd("txID1")
+
+	// Seized collateral, shop stock and swept items keep their expiry
+	ctx.stub.MockTransactionStart("txID2")
+	setTxTime(ctx, start.Add(day))
+	tradeContract.CreateTrade(ctx, "trade2", "alice", "carol", "corn", 1, 5.0, "buy")
+	tradeContract.ExecuteTrade(ctx, "trade2")
+	err = lendingContract.LiquidateLoan(ctx, "loan1", "carol")
+	assert.NoError(t, err)
+	inventory, _ := assetContract.GetInventory(ctx, "carol", "corn")
+	assert.Equal(t, lot(13), inventory.Lots)
+
+	err = shopContract.SellToShop(ctx, "carol", "corn", 3)
+	assert.NoError(t, err)
+	err = shopContract.BuyFromShop(ctx, "dave", "corn", 2)
+	assert.NoError(t, err)
+	inventory, _ = assetContract.GetInventory(ctx, "house", "corn")
+	assert.Equal(t, lot(1), inventory.Lots)
+	inventory, _ = assetContract.GetInventory(ctx, "dave", "corn")
+	assert.Equal(t, lot(2), inventory.Lots)
+
+	err = accountContract.CloseAccount(ctx, "alice", "treasury", "user request")
+	assert.NoError(t, err)
+	inventory, _ = assetContract.GetInventory(ctx, "treasury", "corn")
+	assert.Equal(t, lot(2), inventory.Lots)
+	ctx.stub.MockTransactionEnd("txID2")
+}
+
 // Test CommodityContract
 func TestCreateCommodity(t *testing.T) {
 	ctx := NewMockContext()
@@ -203,11 +604,11 @@ func TestCreateTrade(t *testing.T) {
 
 	ctx.stub.MockTransactionStart("txID1")
 	// Initialize users
-	assetContract.InitUser(ctx, "user1", 1000.0)
-	assetContract.InitUser(ctx, "user2", 1000.0)
+	assetContract.initUser(ctx, "user1", 1000.0)
+	assetContract.initUser(ctx, "user2", 1000.0)
 
 	// Give user2 some inventory
-	assetContract.UpdateInventory(ctx, "user2", "commodity1", 10, "add")
+	assetContract.updateInventory(ctx, "user2", "commodity1", 10, "add")
 
 	// Test successful trade creation (user1 wants to buy from user2)
 	err := tradeContract.CreateTrade(ctx, "trade1", "user1", "user2", "commodity1", 5, 100.0, "buy")
@@ -229,6 +630,16 @@ func TestCreateTrade(t *testing.T) {
 	err = tradeContract.CreateTrade(ctx, "trade3", "user1", "user2", "commodity1", 5, 2000.0, "buy")
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "insufficient balance")
+
+	// Test total price exceeding balance (5 * 300 = 1500)
+	err = tradeContract.CreateTrade(ctx, "trade4", "user1", "user2", "commodity1", 5, 300.0, "buy")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "insufficient balance")
+
+	// Test self-trade
+	err = tradeContract.CreateTrade(ctx, "trade5", "user2", "user2", "commodity1", 5, 0.0, "sell")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "cannot trade with yourself")
 	ctx.stub.MockTransactionEnd("txID1")
 }
 
@@ -239,14 +650,14 @@ func TestExecuteTrade(t *testing.T) {
 
 	ctx.stub.MockTransactionStart("txID1")
 	// Initialize users
-	assetContract.InitUser(ctx, "user1", 1000.0)
-	assetContract.InitUser(ctx, "user2", 1000.0)
+	assetContract.initUser(ctx, "user1", 1000.0)
+	assetContract.initUser(ctx, "user2", 1000.0)
 
 	// Give user2 some inventory
-	assetContract.UpdateInventory(ctx, "user2", "commodity1", 10, "add")
+	assetContract.updateInventory(ctx, "user2", "commodity1", 10, "add")
 
-	// Create trade
-	tradeContract.CreateTrade(ctx, "trade1", "user1", "user2", "commodity1", 5, 100.0, "buy")
+	// Create trade (5 units at 20 each)
+	tradeContract.CreateTrade(ctx, "trade1", "user1", "user2", "commodity1", 5, 20.0, "buy")
 
 	// Execute trade
 	err := tradeContract.ExecuteTrade(ctx, "trade1")
@@ -272,6 +683,94 @@ func TestExecuteTrade(t *testing.T) {
 	ctx.stub.MockTransactionEnd("txID1")
 }
 
+func TestAcceptTradePartially(t *testing.T) {
+	ctx := NewMockContext()
+	assetContract := new(AssetContract)
+	tradeContract := &TradeContract{AssetContract: assetContract}
+
+	ctx.stub.MockTransactionStart("txID1")
+	assetContract.initUser(ctx, "user1", 1000.0)
+	assetContract.initUser(ctx, "user2", 1000.0)
+	assetContract.updateInventory(ctx, "user2", "commodity1", 10, "add")
+
+	// user2 offers 10 units at 15 each
+	err := tradeContract.CreateTrade(ctx, "trade1", "user2", "user1", "commodity1", 10, 15.0, "sell")
+	assert.NoError(t, err)
+
+	// Accept 4 units first
+	err = tradeContract.AcceptTrade(ctx, "trade1", 4)
+	assert.NoError(t, err)
+
+	trade, _ := tradeContract.GetTradeStatus(ctx, "trade1")
+	assert.Equal(t, "pending", trade.Status)
+	assert.Equal(t, 6, trade.Quantity)
+	assert.Equal(t, 4, trade.FilledQuantity)
+	assert.Equal(t, 90.0, trade.TotalPrice) // 6 * 15
+
+	user1Asset, _ := assetContract.GetUserAssets(ctx, "user1")
+	assert.Equal(t, 940.0, user1Asset.Balance) // 1000 - 4 * 15
+
+	// Cannot accept more than what is left
+	err = tradeContract.AcceptTrade(ctx, "trade1", 7)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "exceeds pending quantity")
+
+	// Accept the remainder
+	err = tradeContract.ExecuteTrade(ctx, "trade1")
+	assert.NoError(t, err)
+
+	trade, _ = tradeContract.GetTradeStatus(ctx, "trade1")
+	assert.Equal(t, "successful", trade.Status)
+	assert.Equal(t, 0, trade.Quantity)
+	assert.Equal(t, 10, trade.FilledQuantity)
+
+	fills, err := tradeContract.GetTradeFills(ctx, "trade1")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(fills))
+	assert.Equal(t, 4, fills[0].Quantity)
+	assert.Equal(t, 6, fills[1].Quantity)
+	assert.Equal(t, 90.0, fills[1].TotalPrice)
+
+	user1Inventory, _ := assetContract.GetInventory(ctx, "user1", "commodity1")
+	assert.Equal(t, 10, user1Inventory.Quantity)
+	user2Asset, _ := assetContract.GetUserAssets(ctx, "user2")
+	assert.Equal(t, 1150.0, user2Asset.Balance) // 1000 + 10 * 15
+	ctx.stub.MockTransactionEnd("txID1")
+}
+
+func TestExecuteLegacyTrade(t *testing.T) {
+	ctx := NewMockContext()
+	assetContract := new(AssetContract)
+	tradeContract := &TradeContract{AssetContract: assetContract}
+
+	ctx.stub.MockTransactionStart("txID1")
+	assetContract.initUser(ctx, "user1", 1000.0)
+	assetContract.initUser(ctx, "user2", 1000.0)
+	assetContract.updateInventory(ctx, "user2", "commodity1", 3, "add")
+
+	// A pending trade saved before unit pricing only has its total price
+	legacyJSON := []byte(`{"tradeId":"trade1","fromUserId":"user1","toUserId":"user2","commodityId":"commodity1","quantity":3,"price":100,"action":"buy","status":"pending"}`)
+	ctx.stub.PutState(utils.GetTradeKey("trade1"), legacyJSON)
+	ctx.stub.MockTransactionEnd("txID1")
+
+	ctx.stub.MockTransactionStart("txID2")
+	trade, err := tradeContract.GetTradeStatus(ctx, "trade1")
+	assert.NoError(t, err)
+	assert.Equal(t, 100.0, trade.TotalPrice)
+	assert.InDelta(t, 33.3333, trade.UnitPrice, 0.0001)
+
+	err = tradeContract.ExecuteTrade(ctx, "trade1")
+	assert.NoError(t, err)
+	ctx.stub.MockTransactionEnd("txID2")
+
+	ctx.stub.MockTransactionStart("txID3")
+	user1Asset, _ := assetContract.GetUserAssets(ctx, "user1")
+	assert.Equal(t, 900.0, user1Asset.Balance)
+	user2Asset, _ := assetContract.GetUserAssets(ctx, "user2")
+	assert.Equal(t, 1100.0, user2Asset.Balance)
+	ctx.stub.MockTransactionEnd("txID3")
+}
+
 func TestRejectTrade(t *testing.T) {
 	ctx := NewMockContext()
 	assetContract := new(AssetContract)
@@ -279,20 +778,23 @@ func TestRejectTrade(t *testing.T) {
 
 	ctx.stub.MockTransactionStart("txID1")
 	// Initialize users
-	assetContract.InitUser(ctx, "user1", 1000.0)
-	assetContract.InitUser(ctx, "user2", 1000.0)
-	assetContract.UpdateInventory(ctx, "user2", "commodity1", 10, "add")
+	assetContract.initUser(ctx, "user1", 1000.0)
+	assetContract.initUser(ctx, "user2", 1000.0)
+	assetContract.updateInventory(ctx, "user2", "commodity1", 10, "add")
 
 	// Create trade
 	tradeContract.CreateTrade(ctx, "trade1", "user1", "user2", "commodity1", 5, 100.0, "buy")
 
-	// Reject trade
-	err := tradeContract.RejectTrade(ctx, "trade1")
+	// Only the counterparty can reject the trade
+	err := tradeContract.RejectTrade(ctx, "trade1", "user1")
+	assert.Error(t, err)
+	err = tradeContract.RejectTrade(ctx, "trade1", "user2")
 	assert.NoError(t, err)
 
 	// Verify trade status
 	trade, _ := tradeContract.GetTradeStatus(ctx, "trade1")
 	assert.Equal(t, "rejected", trade.Status)
+	assert.Equal(t, "user2", trade.RejectedBy)
 
 	// Verify balances and inventory unchanged
 	user1Asset, _ := assetContract.GetUserAssets(ctx, "user1")
@@ -303,12 +805,88 @@ func TestRejectTrade(t *testing.T) {
 	ctx.stub.MockTransactionEnd("txID1")
 }
 
+// Test MarketDataContract
+func TestPriceHistory(t *testing.T) {
+	ctx := NewMockContext()
+	assetContract := new(AssetContract)
+	marketDataContract := new(MarketDataContract)
+	tradeContract := &TradeContract{AssetContract: assetContract, MarketDataContract: marketDataContract}
+	base := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	ctx.stub.MockTransactionStart("txID1")
+	setTxTime(ctx, base)
+	assetContract.initUser(ctx, "buyer", 10000.0)
+	assetContract.initUser(ctx, "seller", 0.0)
+	assetContract.updateInventory(ctx, "seller", "gold", 100, "add")
+
+	executeAt := func(tradeID string, at time.Time, quantity int, unitPrice float64) {
+		setTxTime(ctx, at)
+		err := tradeContract.CreateTrade(ctx, tradeID, "buyer", "seller", "gold", quantity, unitPrice, "buy")
+		assert.NoError(t, err)
+		err = tradeContract.ExecuteTrade(ctx, tradeID)
+		assert.NoError(t, err)
+	}
+	executeAt("trade1", base.Add(10*time.Second), 10, 10.0)
+	executeAt("trade2", base.Add(20*time.Second), 10, 14.0)
+	executeAt("trade3", base.Add(30*time.Second), 20, 8.0)
+	executeAt("trade4", base.Add(90*time.Second), 10, 12.0)
+
+	stats, err := marketDataContract.GetPriceStats(ctx, "gold")
+	assert.NoError(t, err)
+	assert.Equal(t, 12.0, stats.LastPrice)
+	assert.Equal(t, 50, stats.Volume)
+	assert.Equal(t, 4, stats.TradeCount)
+	assert.InDelta(t, 10.4, stats.VWAP, 1e-9) // (100 + 140 + 160 + 120) / 50
+
+	// Two one-minute candles
+	candles, err := marketDataContract.GetPriceCandles(ctx, "gold", "1m", "2025-01-01T12:00:00Z", "2025-01-01T13:00:00Z")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(candles))
+	assert.Equal(t, 10.0, candles[0].Open)
+	assert.Equal(t, 14.0, candles[0].High)
+	assert.Equal(t, 8.0, candles[0].Low)
+	assert.Equal(t, 8.0, candles[0].Close)
+	assert.Equal(t, 40, candles[0].Volume)
+	assert.Equal(t, 12.0, candles[1].Open)
+
+	// Range filtering
+	candles, _ = marketDataContract.GetPriceCandles(ctx, "gold", "1m", "2025-01-01T12:01:00Z", "2025-01-01T13:00:00Z")
+	assert.Equal(t, 1, len(candles))
+
+	// One hourly candle covering everything
+	candles, _ = marketDataContract.GetPriceCandles(ctx, "gold", "1h", "2025-01-01T00:00:00Z", "2025-01-02T00:00:00Z")
+	assert.Equal(t, 1, len(candles))
+	assert.Equal(t, 12.0, candles[0].Close)
+	assert.Equal(t, 50, candles[0].Volume)
+
+	_, err = marketDataContract.GetPriceCandles(ctx, "gold", "5m", "2025-01-01T00:00:00Z", "2025-01-02T00:00:00Z")
+	assert.Error(t, err)
+	ctx.stub.MockTransactionEnd("txID1")
+}
+
+func TestPriceStatsReadError(t *testing.T) {
+	ctx := NewMockContext()
+	marketDataContract := new(MarketDataContract)
+
+	ctx.stub.MockTransactionStart("txID1")
+	err := marketDataContract.recordExecution(ctx, "gold", 10, 10.0)
+	assert.NoError(t, err)
+
+	// Unreadable stats are an error rather than a fresh start
+	ctx.stub.PutState(utils.GetPriceStatsKey("gold"), []byte("not json"))
+	err = marketDataContract.recordExecution(ctx, "gold", 10, 12.0)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to unmarshal price stats")
+	ctx.stub.MockTransactionEnd("txID1")
+}
+
 // Test RedemptionContract
 func TestCreateRedemptionRule(t *testing.T) {
 	ctx := NewMockContext()
 	contract := new(RedemptionContract)
 
 	ctx.stub.MockTransactionStart("txID1")
+	new(AccessContract).InitAdmin(ctx)
 	// Test successful rule creation
 	requiredItems := []models.RequiredItem{
 		{CommodityID: "commodity1", Quantity: 3},
@@ -344,12 +922,13 @@ func TestExecuteRedemption(t *testing.T) {
 	redemptionContract := &RedemptionContract{AssetContract: assetContract}
 
 	ctx.stub.MockTransactionStart("txID1")
+	new(AccessContract).InitAdmin(ctx)
 	// Initialize user
-	assetContract.InitUser(ctx, "user1", 1000.0)
+	assetContract.initUser(ctx, "user1", 1000.0)
 
 	// Give user inventory
-	assetContract.UpdateInventory(ctx, "user1", "commodity1", 5, "add")
-	assetContract.UpdateInventory(ctx, "user1", "commodity2", 3, "add")
+	assetContract.updateInventory(ctx, "user1", "commodity1", 5, "add")
+	assetContract.updateInventory(ctx, "user1", "commodity2", 3, "add")
 
 	// Create redemption rule
 	requiredItems := []models.RequiredItem{
@@ -373,6 +952,17 @@ func TestExecuteRedemption(t *testing.T) {
 
 	inventory2, _ := assetContract.GetInventory(ctx, "user1", "commodity2")
 	assert.Equal(t, 1, inventory2.Quantity) // 3 - 2
+
+	// A record ID cannot be reused
+	assetContract.updateInventory(ctx, "user1", "commodity1", 1, "add")
+	assetContract.updateInventory(ctx, "user1", "commodity2", 1, "add")
+	err = redemptionContract.ExecuteRedemption(ctx, "user1", "record1")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "already exists")
+	asset, _ = assetContract.GetUserAssets(ctx, "user1")
+	assert.Equal(t, 1500.0, asset.Balance)
+	inventory1, _ = assetContract.GetInventory(ctx, "user1", "commodity1")
+	assert.Equal(t, 3, inventory1.Quantity)
 	ctx.stub.MockTransactionEnd("txID1")
 }
 
@@ -382,12 +972,13 @@ func TestExecuteRedemptionInsufficientInventory(t *testing.T) {
 	redemptionContract := &RedemptionContract{AssetContract: assetContract}
 
 	ctx.stub.MockTransactionStart("txID1")
+	new(AccessContract).InitAdmin(ctx)
 	// Initialize user
-	assetContract.InitUser(ctx, "user1", 1000.0)
+	assetContract.initUser(ctx, "user1", 1000.0)
 
 	// Give user insufficient inventory
-	assetContract.UpdateInventory(ctx, "user1", "commodity1", 2, "add")
-	assetContract.UpdateInventory(ctx, "user1", "commodity2", 1, "add")
+	assetContract.updateInventory(ctx, "user1", "commodity1", 2, "add")
+	assetContract.updateInventory(ctx, "user1", "commodity2", 1, "add")
 
 	// Create redemption rule
 	requiredItems := []models.RequiredItem{
@@ -404,60 +995,2437 @@ func TestExecuteRedemptionInsufficientInventory(t *testing.T) {
 	ctx.stub.MockTransactionEnd("txID1")
 }
 
-// Integration test: Complete trade flow
-func TestCompleteTradeFlow(t *testing.T) {
+// Test AuctionContract
+func TestEnglishAuction(t *testing.T) {
 	ctx := NewMockContext()
 	assetContract := new(AssetContract)
-	tradeContract := &TradeContract{AssetContract: assetContract}
-	commodityContract := new(CommodityContract)
+	auctionContract := &AuctionContract{AssetContract: assetContract}
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
 
 	ctx.stub.MockTransactionStart("txID1")
-	// Create commodity
-	commodityContract.CreateCommodity(ctx, "apple", "Apple", `{"type": "fruit"}`)
-
-	// Initialize users
-	assetContract.InitUser(ctx, "alice", 1000.0)
-	assetContract.InitUser(ctx, "bob", 1000.0)
+	setTxTime(ctx, start)
+	assetContract.initUser(ctx, "seller", 0.0)
+	assetContract.initUser(ctx, "bidder1", 1000.0)
+	assetContract.initUser(ctx, "bidder2", 1000.0)
+	assetContract.updateInventory(ctx, "seller", "commodity1", 5, "add")
+
+	err := auctionContract.CreateAuction(ctx, "auction1", "seller", "commodity1", 5, 100.0, "english",
+		"2025-01-01T12:00:00Z", "2025-01-01T13:00:00Z", "")
+	assert.NoError(t, err)
 
-	// Give Bob some apples
-	assetContract.UpdateInventory(ctx, "bob", "apple", 10, "add")
+	// Items are escrowed
+	inventory, _ := assetContract.GetInventory(ctx, "seller", "commodity1")
+	assert.Equal(t, 0, inventory.Quantity)
 
-	fmt.Println("=== Initial State ===")
-	aliceAsset, _ := assetContract.GetUserAssets(ctx, "alice")
-	bobAsset, _ := assetContract.GetUserAssets(ctx, "bob")
-	bobInventory, _ := assetContract.GetInventory(ctx, "bob", "apple")
-	fmt.Printf("Alice Balance: %.2f\n", aliceAsset.Balance)
-	fmt.Printf("Bob Balance: %.2f, Apples: %d\n", bobAsset.Balance, bobInventory.Quantity)
+	// Bid below reserve
+	err = auctionContract.PlaceBid(ctx, "auction1", "bidder1", 50.0)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "below reserve price")
 
-	// Alice wants to buy 5 apples from Bob for 200
-	err := tradeContract.CreateTrade(ctx, "trade123", "alice", "bob", "apple", 5, 200.0, "buy")
+	err = auctionContract.PlaceBid(ctx, "auction1", "bidder1", 150.0)
 	assert.NoError(t, err)
-	fmt.Println("\n=== Trade Created ===")
-
-	// Execute trade
-	err = tradeContract.ExecuteTrade(ctx, "trade123")
+	err = auctionContract.PlaceBid(ctx, "auction1", "bidder2", 150.0)
+	assert.Error(t, err)
+	err = auctionContract.PlaceBid(ctx, "auction1", "bidder2", 200.0)
 	assert.NoError(t, err)
-	fmt.Println("=== Trade Executed ===")
 
-	// Verify final state
-	aliceAsset, _ = assetContract.GetUserAssets(ctx, "alice")
-	bobAsset, _ = assetContract.GetUserAssets(ctx, "bob")
-	aliceInventory, _ := assetContract.GetInventory(ctx, "alice", "apple")
-	bobInventory, _ = assetContract.GetInventory(ctx, "bob", "apple")
+	// Outbid funds are refunded
+	bidder1, _ := assetContract.GetUserAssets(ctx, "bidder1")
+	assert.Equal(t, 1000.0, bidder1.Balance)
+	bidder2, _ := assetContract.GetUserAssets(ctx, "bidder2")
+	assert.Equal(t, 800.0, bidder2.Balance)
 
-	fmt.Println("\n=== Final State ===")
-	fmt.Printf("Alice Balance: %.2f, Apples: %d\n", aliceAsset.Balance, aliceInventory.Quantity)
-	fmt.Printf("Bob Balance: %.2f, Apples: %d\n", bobAsset.Balance, bobInventory.Quantity)
+	// Cannot close before the end
+	err = auctionContract.CloseAuction(ctx, "auction1")
+	assert.Error(t, err)
 
-	assert.Equal(t, 800.0, aliceAsset.Balance)  // 1000 - 200
-	assert.Equal(t, 1200.0, bobAsset.Balance)   // 1000 + 200
-	assert.Equal(t, 5, aliceInventory.Quantity) // 0 + 5
-	assert.Equal(t, 5, bobInventory.Quantity)   // 10 - 5
+	setTxTime(ctx, start.Add(time.Hour))
+	err = auctionContract.PlaceBid(ctx, "auction1", "bidder1", 300.0)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "bidding has ended")
+
+	err = auctionContract.CloseAuction(ctx, "auction1")
+	assert.NoError(t, err)
+
+	auction, _ := auctionContract.GetAuction(ctx, "auction1")
+	assert.Equal(t, "settled", auction.Status)
+	assert.Equal(t, "bidder2", auction.HighestBidderID)
+
+	seller, _ := assetContract.GetUserAssets(ctx, "seller")
+	assert.Equal(t, 200.0, seller.Balance)
+	winnerInventory, _ := assetContract.GetInventory(ctx, "bidder2", "commodity1")
+	assert.Equal(t, 5, winnerInventory.Quantity)
 	ctx.stub.MockTransactionEnd("txID1")
 }
 
-// Benchmark tests
-func BenchmarkInitUser(b *testing.B) {
+func TestSealedBidAuction(t *testing.T) {
+	ctx := NewMockContext()
+	assetContract := new(AssetContract)
+	auctionContract := &AuctionContract{AssetContract: assetContract}
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	ctx.stub.MockTransactionStart("txID1")
+	setTxTime(ctx, start)
+	assetContract.initUser(ctx, "seller", 0.0)
+	assetContract.initUser(ctx, "bidder1", 1000.0)
+	assetContract.initUser(ctx, "bidder2", 1000.0)
+	assetContract.updateInventory(ctx, "seller", "sword", 1, "add")
+
+	err := auctionContract.CreateAuction(ctx, "auction1", "seller", "sword", 1, 100.0, "sealed",
+		"2025-01-01T12:00:00Z", "2025-01-01T13:00:00Z", "2025-01-01T14:00:00Z")
+	assert.NoError(t, err)
+
+	bid1 := []byte(`{"amount":300,"salt":"a1"}`)
+	bid2 := []byte(`{"amount":250,"salt":"b2"}`)
+
+	ctx.stub.TransientMap = map[string][]byte{"bid": bid1}
+	err = auctionContract.CommitBid(ctx, "auction1", "bidder1")
+	assert.NoError(t, err)
+	ctx.stub.TransientMap = map[string][]byte{"bid": bid2}
+	err = auctionContract.CommitBid(ctx, "auction1", "bidder2")
+	assert.NoError(t, err)
+
+	// Only the hash is public, the bid lives in the private collection
+	bids, _ := auctionContract.GetSealedBids(ctx, "auction1")
+	assert.Equal(t, 2, len(bids))
+	assert.Equal(t, 0.0, bids[0].Amount)
+	assert.NotEmpty(t, ctx.stub.PvtState[utils.AuctionBidsCollection])
+
+	// Cannot reveal while bidding is open
+	err = auctionContract.RevealBid(ctx, "auction1", "bidder2")
+	assert.Error(t, err)
+
+	setTxTime(ctx, start.Add(90*time.Minute))
+
+	// Reveal must match the commitment
+	ctx.stub.TransientMap = map[string][]byte{"bid": []byte(`{"amount":1,"salt":"b2"}`)}
+	err = auctionContract.RevealBid(ctx, "auction1", "bidder2")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "does not match")
+
+	ctx.stub.TransientMap = map[string][]byte{"bid": bid2}
+	err = auctionContract.RevealBid(ctx, "auction1", "bidder2")
+	assert.NoError(t, err)
+	ctx.stub.TransientMap = map[string][]byte{"bid": bid1}
+	err = auctionContract.RevealBid(ctx, "auction1", "bidder1")
+	assert.NoError(t, err)
+
+	bidder2, _ := assetContract.GetUserAssets(ctx, "bidder2")
+	assert.Equal(t, 1000.0, bidder2.Balance) // refunded after being outbid
+
+	setTxTime(ctx, start.Add(2*time.Hour))
+	err = auctionContract.CloseAuction(ctx, "auction1")
+	assert.NoError(t, err)
+
+	bidder1, _ := assetContract.GetUserAssets(ctx, "bidder1")
+	assert.Equal(t, 700.0, bidder1.Balance)
+	seller, _ := assetContract.GetUserAssets(ctx, "seller")
+	assert.Equal(t, 300.0, seller.Balance)
+	sword, _ := assetContract.GetInventory(ctx, "bidder1", "sword")
+	assert.Equal(t, 1, sword.Quantity)
+	ctx.stub.MockTransactionEnd("txID1")
+}
+
+func TestEnglishAuctionRaiseOwnBid(t *testing.T) {
+	ctx := NewMockContext()
+	assetContract := new(AssetContract)
+	auctionContract := &AuctionContract{AssetContract: assetContract}
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	ctx.stub.MockTransactionStart("txID1")
+	setTxTime(ctx, start)
+	assetContract.initUser(ctx, "seller", 0.0)
+	assetContract.initUser(ctx, "bidder1", 1000.0)
+	assetContract.updateInventory(ctx, "seller", "commodity1", 1, "add")
+	err := auctionContract.CreateAuction(ctx, "auction1", "seller", "commodity1", 1, 100.0, "english",
+		"2025-01-01T12:00:00Z", "2025-01-01T13:00:00Z", "")
+	assert.NoError(t, err)
+	ctx.stub.MockTransactionEnd("txID1")
+
+	ctx.stub.MockTransactionStart("txID2")
+	setTxTime(ctx, start)
+	err = auctionContract.PlaceBid(ctx, "auction1", "bidder1", 150.0)
+	assert.NoError(t, err)
+	ctx.stub.MockTransactionEnd("txID2")
+
+	// The leader raising their own bid pays only the difference
+	ctx.stub.MockTransactionStart("txID3")
+	setTxTime(ctx, start)
+	err = auctionContract.PlaceBid(ctx, "auction1", "bidder1", 200.0)
+	assert.NoError(t, err)
+	ctx.stub.MockTransactionEnd("txID3")
+
+	ctx.stub.MockTransactionStart("txID4")
+	setTxTime(ctx, start)
+	bidder1, _ := assetContract.GetUserAssets(ctx, "bidder1")
+	assert.Equal(t, 800.0, bidder1.Balance)
+	auction, _ := auctionContract.GetAuction(ctx, "auction1")
+	assert.Equal(t, "bidder1", auction.HighestBidderID)
+	assert.Equal(t, 200.0, auction.HighestBid)
+	ctx.stub.MockTransactionEnd("txID4")
+
+	ctx.stub.MockTransactionStart("txID5")
+	setTxTime(ctx, start.Add(time.Hour))
+	err = auctionContract.CloseAuction(ctx, "auction1")
+	assert.NoError(t, err)
+	ctx.stub.MockTransactionEnd("txID5")
+
+	ctx.stub.MockTransactionStart("txID6")
+	setTxTime(ctx, start.Add(time.Hour))
+	seller, _ := assetContract.GetUserAssets(ctx, "seller")
+	assert.Equal(t, 200.0, seller.Balance)
+	bidder1, _ = assetContract.GetUserAssets(ctx, "bidder1")
+	assert.Equal(t, 800.0, bidder1.Balance)
+	ctx.stub.MockTransactionEnd("txID6")
+}
+
+func TestSealedBidDeposit(t *testing.T) {
+	ctx := NewMockContext()
+	assetContract := new(AssetContract)
+	auctionContract := &AuctionContract{AssetContract: assetContract}
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	ctx.stub.MockTransactionStart("txID1")
+	setTxTime(ctx, start)
+	assetContract.initUser(ctx, "seller", 0.0)
+	assetContract.initUser(ctx, "bidder1", 1000.0)
+	assetContract.initUser(ctx, "bidder2", 1000.0)
+	assetContract.initUser(ctx, "bidder3", 1000.0)
+	assetContract.updateInventory(ctx, "seller", "sword", 2, "add")
+
+	// Sealed auctions need a reserve price to take as the bid deposit
+	err := auctionContract.CreateAuction(ctx, "auction0", "seller", "sword", 1, 0.0, "sealed",
+		"2025-01-01T12:00:00Z", "2025-01-01T13:00:00Z", "2025-01-01T14:00:00Z")
+	assert.Error(t, err)
+	err = auctionContract.CreateAuction(ctx, "auction1", "seller", "sword", 1, 100.0, "sealed",
+		"2025-01-01T12:00:00Z", "2025-01-01T13:00:00Z", "2025-01-01T14:00:00Z")
+	assert.NoError(t, err)
+	ctx.stub.MockTransactionEnd("txID1")
+
+	bids := map[string][]byte{
+		"bidder1": []byte(`{"amount":300,"salt":"a1"}`),
+		"bidder2": []byte(`{"amount":200,"salt":"b2"}`),
+		"bidder3": []byte(`{"amount":500,"salt":"c3"}`),
+	}
+	for i, bidderID := range []string{"bidder1", "bidder2", "bidder3"} {
+		txID := fmt.Sprintf("txCommit%d", i)
+		ctx.stub.MockTransactionStart(txID)
+		setTxTime(ctx, start)
+		ctx.stub.TransientMap = map[string][]byte{"bid": bids[bidderID]}
+		err = auctionContract.CommitBid(ctx, "auction1", bidderID)
+		assert.NoError(t, err)
+		ctx.stub.MockTransactionEnd(txID)
+	}
+
+	ctx.stub.MockTransactionStart("txID2")
+	setTxTime(ctx, start)
+	bidder3, _ := assetContract.GetUserAssets(ctx, "bidder3")
+	assert.Equal(t, 900.0, bidder3.Balance) // deposit taken at commit
+	ctx.stub.MockTransactionEnd("txID2")
+
+	// bidder3 never reveals; the losing bidder2 gets the deposit back
+	for i, bidderID := range []string{"bidder1", "bidder2"} {
+		txID := fmt.Sprintf("txReveal%d", i)
+		ctx.stub.MockTransactionStart(txID)
+		setTxTime(ctx, start.Add(90*time.Minute))
+		ctx.stub.TransientMap = map[string][]byte{"bid": bids[bidderID]}
+		err = auctionContract.RevealBid(ctx, "auction1", bidderID)
+		assert.NoError(t, err)
+		ctx.stub.MockTransactionEnd(txID)
+	}
+
+	ctx.stub.MockTransactionStart("txID3")
+	setTxTime(ctx, start.Add(2*time.Hour))
+	err = auctionContract.CloseAuction(ctx, "auction1")
+	assert.NoError(t, err)
+	ctx.stub.MockTransactionEnd("txID3")
+
+	ctx.stub.MockTransactionStart("txID4")
+	setTxTime(ctx, start.Add(2*time.Hour))
+	bidder1, _ := assetContract.GetUserAssets(ctx, "bidder1")
+	assert.Equal(t, 700.0, bidder1.Balance)
+	bidder2, _ := assetContract.GetUserAssets(ctx, "bidder2")
+	assert.Equal(t, 1000.0, bidder2.Balance)
+	bidder3, _ = assetContract.GetUserAssets(ctx, "bidder3")
+	assert.Equal(t, 900.0, bidder3.Balance)
+	seller, _ := assetContract.GetUserAssets(ctx, "seller")
+	assert.Equal(t, 400.0, seller.Balance) // winning bid plus the forfeited deposit
+	ctx.stub.MockTransactionEnd("txID4")
+}
+
+// Test AMMContract
+func TestLiquidityPool(t *testing.T) {
+	ctx := NewMockContext()
+	assetContract := new(AssetContract)
+	commodityContract := new(CommodityContract)
+	ammContract := &AMMContract{AssetContract: assetContract, CommodityContract: commodityContract}
+
+	ctx.stub.MockTransactionStart("txID1")
+	commodityContract.CreateCommodity(ctx, "gold", "Gold", "")
+	assetContract.initUser(ctx, "lp", 10000.0)
+	assetContract.initUser(ctx, "trader", 1000.0)
+	assetContract.updateInventory(ctx, "lp", "gold", 100, "add")
+	assetContract.updateInventory(ctx, "trader", "gold", 10, "add")
+
+	// Pools can only be created for known commodities
+	err := ammContract.CreatePool(ctx, "unknown", 0.0)
+	assert.Error(t, err)
+
+	err = ammContract.CreatePool(ctx, "gold", 0.0)
+	assert.NoError(t, err)
+
+	// First provider sets the price at 10 per unit
+	err = ammContract.AddLiquidity(ctx, "gold", "lp", 100, 1000.0)
+	assert.NoError(t, err)
+
+	quotes, err := ammContract.GetMarketQuotes(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(quotes))
+	assert.Equal(t, 10.0, quotes[0].SpotPrice)
+
+	// Buying 50 of 100 units doubles the balance reserve: 1000 * 50 / 50
+	cost, err := ammContract.QuoteBuy(ctx, "gold", 50)
+	assert.NoError(t, err)
+	assert.Equal(t, 1000.0, cost)
+
+	// Slippage limit
+	err = ammContract.BuyFromPool(ctx, "gold", "trader", 20, 100.0)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "exceeds maximum")
+
+	// Buy 20 units: 1000 * 20 / 80 = 250
+	err = ammContract.BuyFromPool(ctx, "gold", "trader", 20, 300.0)
+	assert.NoError(t, err)
+
+	trader, _ := assetContract.GetUserAssets(ctx, "trader")
+	assert.Equal(t, 750.0, trader.Balance)
+	traderGold, _ := assetContract.GetInventory(ctx, "trader", "gold")
+	assert.Equal(t, 30, traderGold.Quantity)
+
+	pool, _ := ammContract.GetPool(ctx, "gold")
+	assert.Equal(t, 80, pool.ReserveCommodity)
+	assert.Equal(t, 1250.0, pool.ReserveBalance)
+
+	// Sell 20 units back: 1250 * 20 / 100 = 250
+	err = ammContract.SellToPool(ctx, "gold", "trader", 20, 250.0)
+	assert.NoError(t, err)
+	trader, _ = assetContract.GetUserAssets(ctx, "trader")
+	assert.Equal(t, 1000.0, trader.Balance)
+
+	// Withdraw all liquidity
+	position, _ := ammContract.GetLiquidityPosition(ctx, "gold", "lp")
+	err = ammContract.RemoveLiquidity(ctx, "gold", "lp", position.Shares, 100, 1000.0)
+	assert.NoError(t, err)
+
+	lp, _ := assetContract.GetUserAssets(ctx, "lp")
+	assert.Equal(t, 10000.0, lp.Balance)
+	lpGold, _ := assetContract.GetInventory(ctx, "lp", "gold")
+	assert.Equal(t, 100, lpGold.Quantity)
+	ctx.stub.MockTransactionEnd("txID1")
+}
+
+func TestAddLiquidityToDrainedPool(t *testing.T) {
+	ctx := NewMockContext()
+	assetContract := new(AssetContract)
+	commodityContract := new(CommodityContract)
+	ammContract := &AMMContract{AssetContract: assetContract, CommodityContract: commodityContract}
+
+	ctx.stub.MockTransactionStart("txID1")
+	commodityContract.CreateCommodity(ctx, "gold", "Gold", "")
+	assetContract.initUser(ctx, "lp", 10000.0)
+	assetContract.updateInventory(ctx, "lp", "gold", 100, "add")
+	err := ammContract.CreatePool(ctx, "gold", 0.0)
+	assert.NoError(t, err)
+
+	// Float residue left after the last withdrawal counts as an empty pool
+	pool, _ := ammContract.GetPool(ctx, "gold")
+	pool.TotalShares = 1e-12
+	pool.ReserveBalance = 1e-10
+	ammContract.putPool(ctx, pool)
+
+	err = ammContract.AddLiquidity(ctx, "gold", "lp", 10, 1000.0)
+	assert.NoError(t, err)
+	pool, _ = ammContract.GetPool(ctx, "gold")
+	assert.InDelta(t, 100.0, pool.TotalShares, 0.0001)
+
+	// Outstanding shares against a drained reserve cannot be priced
+	pool.ReserveCommodity = 0
+	ammContract.putPool(ctx, pool)
+	err = ammContract.AddLiquidity(ctx, "gold", "lp", 10, 1000.0)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "no reserves left")
+	ctx.stub.MockTransactionEnd("txID1")
+}
+
+func TestLiquidityPoolFees(t *testing.T) {
+	ctx := NewMockContext()
+	assetContract := new(AssetContract)
+	commodityContract := new(CommodityContract)
+	ammContract := &AMMContract{AssetContract: assetContract, CommodityContract: commodityContract}
+
+	ctx.stub.MockTransactionStart("txID1")
+	commodityContract.CreateCommodity(ctx, "gold", "Gold", "")
+	assetContract.initUser(ctx, "lp", 10000.0)
+	assetContract.updateInventory(ctx, "lp", "gold", 100, "add")
+	ammContract.CreatePool(ctx, "gold", 0.5)
+	ammContract.AddLiquidity(ctx, "gold", "lp", 100, 1000.0)
+
+	// Half of the input is kept as a fee: 1000 * 20 / 80 / 0.5
+	cost, err := ammContract.QuoteBuy(ctx, "gold", 20)
+	assert.NoError(t, err)
+	assert.Equal(t, 500.0, cost)
+
+	// Only 10 of 20 units count towards the price: 1000 * 10 / 110
+	proceeds, err := ammContract.QuoteSell(ctx, "gold", 20)
+	assert.NoError(t, err)
+	assert.InDelta(t, 90.909, proceeds, 0.001)
+
+	_, err = ammContract.QuoteBuy(ctx, "gold", 100)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "insufficient pool liquidity")
+	ctx.stub.MockTransactionEnd("txID1")
+}
+
+// Test AccessContract
+func TestRoles(t *testing.T) {
+	ctx := NewMockContext()
+	accessContract := new(AccessContract)
+
+	ctx.stub.MockTransactionStart("txID1")
+	// Before bootstrap, an ordinary client cannot claim admin
+	setCaller(ctx, "mallory")
+	err := accessContract.InitAdmin(ctx)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "only an admin identity")
+
+	ctx.identity = &MockClientIdentity{id: "admin", cert: orgAdminCert}
+	err = accessContract.InitAdmin(ctx)
+	assert.NoError(t, err)
+
+	// Only one bootstrap admin
+	setCaller(ctx, "mallory")
+	err = accessContract.InitAdmin(ctx)
+	assert.Error(t, err)
+	err = accessContract.GrantRole(ctx, "mallory", RolePriceFeeder)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "not authorized")
+
+	setCaller(ctx, "admin")
+	err = accessContract.GrantRole(ctx, "feeder1", RolePriceFeeder)
+	assert.NoError(t, err)
+	ok, _ := accessContract.HasRole(ctx, "feeder1", RolePriceFeeder)
+	assert.True(t, ok)
+
+	err = accessContract.RevokeRole(ctx, "feeder1", RolePriceFeeder)
+	assert.NoError(t, err)
+	ok, _ = accessContract.HasRole(ctx, "feeder1", RolePriceFeeder)
+	assert.False(t, ok)
+	ctx.stub.MockTransactionEnd("txID1")
+}
+
+// Test OracleContract
+func TestReferencePrice(t *testing.T) {
+	ctx := NewMockContext()
+	accessContract := new(AccessContract)
+	oracleContract := new(OracleContract)
+	base := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	ctx.stub.MockTransactionStart("txID1")
+	setTxTime(ctx, base)
+	accessContract.InitAdmin(ctx)
+	for _, feeder := range []string{"feeder1", "feeder2", "feeder3"} {
+		accessContract.GrantRole(ctx, feeder, RolePriceFeeder)
+	}
+	err := oracleContract.SetOracleConfig(ctx, 600, 2)
+	assert.NoError(t, err)
+
+	// Unauthorized feeders are rejected
+	setCaller(ctx, "mallory")
+	err = oracleContract.SubmitPrice(ctx, "gold", 1.0)
+	assert.Error(t, err)
+
+	setCaller(ctx, "feeder1")
+	oracleContract.SubmitPrice(ctx, "gold", 100.0)
+
+	// Not enough submissions yet
+	_, err = oracleContract.GetReferencePrice(ctx, "gold")
+	assert.Error(t, err)
+
+	setTxTime(ctx, base.Add(5*time.Minute))
+	setCaller(ctx, "feeder2")
+	oracleContract.SubmitPrice(ctx, "gold", 110.0)
+	setCaller(ctx, "feeder3")
+	oracleContract.SubmitPrice(ctx, "gold", 500.0)
+
+	price, err := oracleContract.GetReferencePrice(ctx, "gold")
+	assert.NoError(t, err)
+	assert.Equal(t, 110.0, price.Price) // median resists the outlier
+	assert.Equal(t, 3, price.Submissions)
+
+	// feeder1 becomes stale, leaving an even number of submissions
+	setTxTime(ctx, base.Add(11*time.Minute))
+	price, err = oracleContract.GetReferencePrice(ctx, "gold")
+	assert.NoError(t, err)
+	assert.Equal(t, 305.0, price.Price)
+	assert.Equal(t, 2, price.Submissions)
+	ctx.stub.MockTransactionEnd("txID1")
+}
+
+func TestOracleRedemption(t *testing.T) {
+	ctx := NewMockContext()
+	assetContract := new(AssetContract)
+	accessContract := new(AccessContract)
+	oracleContract := new(OracleContract)
+	redemptionContract := &RedemptionContract{AssetContract: assetContract, OracleContract: oracleContract}
+
+	ctx.stub.MockTransactionStart("txID1")
+	accessContract.InitAdmin(ctx)
+	oracleContract.SubmitPrice(ctx, "gold", 100.0)
+	oracleContract.SubmitPrice(ctx, "silver", 10.0)
+
+	assetContract.initUser(ctx, "user1", 0.0)
+	assetContract.updateInventory(ctx, "user1", "gold", 2, "add")
+	assetContract.updateInventory(ctx, "user1", "silver", 5, "add")
+
+	requiredItemsJSON := `[{"commodityId":"gold","quantity":2},{"commodityId":"silver","quantity":5}]`
+	err := redemptionContract.CreateOracleRedemptionRule(ctx, "user1", requiredItemsJSON, 0.5)
+	assert.NoError(t, err)
+
+	err = redemptionContract.ExecuteRedemption(ctx, "user1", "record1")
+	assert.NoError(t, err)
+
+	// 0.5 * (2 * 100 + 5 * 10)
+	asset, _ := assetContract.GetUserAssets(ctx, "user1")
+	assert.Equal(t, 125.0, asset.Balance)
+	ctx.stub.MockTransactionEnd("txID1")
+}
+
+// Test ValuationContract
+func TestNetWorth(t *testing.T) {
+	ctx := NewMockContext()
+	assetContract := new(AssetContract)
+	commodityContract := new(CommodityContract)
+	marketDataContract := new(MarketDataContract)
+	oracleContract := new(OracleContract)
+	ammContract := &AMMContract{AssetContract: assetContract, CommodityContract: commodityContract}
+	tradeContract := &TradeContract{AssetContract: assetContract, MarketDataContract: marketDataContract}
+	valuationContract := &ValuationContract{
+		AssetContract:      assetContract,
+		MarketDataContract: marketDataContract,
+		OracleContract:     oracleContract,
+		AMMContract:        ammContract,
+	}
+
+	ctx.stub.MockTransactionStart("txID1")
+	new(AccessContract).InitAdmin(ctx)
+	commodityContract.CreateCommodity(ctx, "gold", "Gold", "")
+	assetContract.initUser(ctx, "alice", 1000.0)
+	assetContract.initUser(ctx, "bob", 1000.0)
+	assetContract.updateInventory(ctx, "bob", "gold", 20, "add")
+	assetContract.updateInventory(ctx, "bob", "silver", 5, "add")
+
+	// Last trade price of gold is 10
+	tradeContract.CreateTrade(ctx, "trade1", "alice", "bob", "gold", 10, 10.0, "buy")
+	tradeContract.ExecuteTrade(ctx, "trade1")
+
+	valuation, err := valuationContract.GetNetWorth(ctx, "bob", PriceSourceLast)
+	assert.NoError(t, err)
+	assert.Equal(t, 1100.0, valuation.Balance)
+	assert.Equal(t, 2, len(valuation.Holdings))
+	assert.Equal(t, 100.0, valuation.HoldingsValue) // 10 gold * 10, silver never traded
+	assert.Equal(t, 1200.0, valuation.NetWorth)
+	assert.False(t, valuation.Holdings[1].Priced)
+
+	// Oracle prices
+	oracleContract.SubmitPrice(ctx, "gold", 20.0)
+	oracleContract.SubmitPrice(ctx, "silver", 4.0)
+	valuations, err := valuationContract.GetNetWorthBatch(ctx, `["alice","bob"]`, PriceSourceOracle)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(valuations))
+	assert.Equal(t, 1100.0, valuations[0].NetWorth) // 900 + 10 * 20
+	assert.Equal(t, 1320.0, valuations[1].NetWorth) // 1100 + 10 * 20 + 5 * 4
+
+	// AMM liquidation value: 1000 * 10 / (100 + 10)
+	assetContract.initUser(ctx, "lp", 1000.0)
+	assetContract.updateInventory(ctx, "lp", "gold", 100, "add")
+	ammContract.CreatePool(ctx, "gold", 0.0)
+	ammContract.AddLiquidity(ctx, "gold", "lp", 100, 1000.0)
+	valuation, err = valuationContract.GetNetWorth(ctx, "alice", PriceSourceAMM)
+	assert.NoError(t, err)
+	assert.InDelta(t, 90.909, valuation.HoldingsValue, 0.001)
+
+	_, err = valuationContract.GetNetWorth(ctx, "alice", "magic")
+	assert.Error(t, err)
+	ctx.stub.MockTransactionEnd("txID1")
+}
+
+// Test LeaderboardContract
+func TestLeaderboards(t *testing.T) {
+	ctx := NewMockContext()
+	leaderboardContract := new(LeaderboardContract)
+	assetContract := &AssetContract{LeaderboardContract: leaderboardContract}
+	tradeContract := &TradeContract{AssetContract: assetContract, LeaderboardContract: leaderboardContract}
+
+	ctx.stub.MockTransactionStart("txID1")
+	new(AccessContract).InitAdmin(ctx)
+	assetContract.initUser(ctx, "alice", 500.0)
+	assetContract.initUser(ctx, "bob", 1000.0)
+	assetContract.initUser(ctx, "carol", 750.25)
+
+	top, err := leaderboardContract.GetTopScores(ctx, BoardRichest, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(top))
+	assert.Equal(t, "bob", top[0].UserID)
+	assert.Equal(t, 1, top[0].Rank)
+	assert.Equal(t, "carol", top[1].UserID)
+	assert.Equal(t, 750.25, top[1].Score)
+
+	// Balance changes move users on the board
+	assetContract.updateBalance(ctx, "alice", 600.0, "add")
+	entry, err := leaderboardContract.GetUserRank(ctx, BoardRichest, "alice")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, entry.Rank)
+	assert.Equal(t, 1100.0, entry.Score)
+	entry, _ = leaderboardContract.GetUserRank(ctx, BoardRichest, "bob")
+	assert.Equal(t, 2, entry.Rank)
+
+	// Completed trades count once for both parties; partial fills do not count
+	assetContract.updateInventory(ctx, "bob", "gold", 10, "add")
+	tradeContract.CreateTrade(ctx, "trade1", "carol", "bob", "gold", 10, 1.0, "buy")
+	tradeContract.AcceptTrade(ctx, "trade1", 4)
+	_, err = leaderboardContract.GetUserRank(ctx, BoardTrades, "carol")
+	assert.Error(t, err)
+	tradeContract.ExecuteTrade(ctx, "trade1")
+	entry, err = leaderboardContract.GetUserRank(ctx, BoardTrades, "carol")
+	assert.NoError(t, err)
+	assert.Equal(t, 1.0, entry.Score)
+	entry, _ = leaderboardContract.GetUserRank(ctx, BoardTrades, "bob")
+	assert.Equal(t, 1.0, entry.Score)
+
+	// Custom boards are admin-only and built-in boards are protected
+	err = leaderboardContract.SubmitScore(ctx, BoardTrades, "alice", 99)
+	assert.Error(t, err)
+	err = leaderboardContract.SubmitScore(ctx, "arena", "alice", 42)
+	assert.NoError(t, err)
+
+	// A reset starts empty boards and keeps the old season
+	err = leaderboardContract.ResetLeaderboards(ctx, "season2")
+	assert.NoError(t, err)
+	top, _ = leaderboardContract.GetTopScores(ctx, BoardRichest, 10)
+	assert.Equal(t, 0, len(top))
+	old, _ := leaderboardContract.GetSeasonTopScores(ctx, DefaultLeaderboardSeason, "arena", 10)
+	assert.Equal(t, 1, len(old))
+
+	setCaller(ctx, "mallory")
+	err = leaderboardContract.ResetLeaderboards(ctx, "season3")
+	assert.Error(t, err)
+	ctx.stub.MockTransactionEnd("txID1")
+}
+
+// Test SeasonContract
+func TestSeasons(t *testing.T) {
+	ctx := NewMockContext()
+	leaderboardContract := new(LeaderboardContract)
+	assetContract := &AssetContract{LeaderboardContract: leaderboardContract}
+	tradeContract := &TradeContract{AssetContract: assetContract}
+	seasonContract := &SeasonContract{AssetContract: assetContract, LeaderboardContract: leaderboardContract}
+
+	ctx.stub.MockTransactionStart("txID1")
+	new(AccessContract).InitAdmin(ctx)
+	assetContract.initUser(ctx, "alice", 1000.0)
+	assetContract.initUser(ctx, "bob", 400.0)
+	assetContract.updateInventory(ctx, "bob", "gold", 5, "add")
+
+	// Trades outside a season are not scoped
+	tradeContract.CreateTrade(ctx, "trade0", "alice", "bob", "gold", 1, 10.0, "buy")
+
+	err := seasonContract.StartSeason(ctx, "s1", 0.5, true)
+	assert.NoError(t, err)
+	err = seasonContract.StartSeason(ctx, "s2", 1, false)
+	assert.Error(t, err)
+
+	// The leaderboards follow the season and the richest board is seeded
+	entry, err := leaderboardContract.GetUserRank(ctx, BoardRichest, "alice")
+	assert.NoError(t, err)
+	assert.Equal(t, "s1", entry.SeasonID)
+	assert.Equal(t, 1000.0, entry.Score)
+
+	tradeContract.CreateTrade(ctx, "trade1", "alice", "bob", "gold", 2, 50.0, "buy")
+	tradeContract.ExecuteTrade(ctx, "trade1")
+	trades, err := tradeContract.GetSeasonTradeHistory(ctx, "alice", "s1")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(trades))
+	assert.Equal(t, "trade1", trades[0].TradeID)
+
+	// Ending the season archives balances and inventory, then applies the rules
+	setCaller(ctx, "mallory")
+	err = seasonContract.EndSeason(ctx)
+	assert.Error(t, err)
+	setCaller(ctx, "admin")
+	err = seasonContract.EndSeason(ctx)
+	assert.NoError(t, err)
+
+	archive, err := seasonContract.GetSeasonArchive(ctx, "s1", "alice")
+	assert.NoError(t, err)
+	assert.Equal(t, 900.0, archive.Balance)
+	assert.Equal(t, 1, len(archive.Inventory))
+	assert.Equal(t, 2, archive.Inventory[0].Quantity)
+	archives, _ := seasonContract.GetSeasonArchives(ctx, "s1")
+	assert.Equal(t, 2, len(archives))
+
+	userAsset, _ := assetContract.GetUserAssets(ctx, "bob")
+	assert.Equal(t, 250.0, userAsset.Balance)
+	inventory, _ := assetContract.GetInventory(ctx, "bob", "gold")
+	assert.Equal(t, 0, inventory.Quantity)
+
+	// The ended season keeps its final standings
+	top, _ := leaderboardContract.GetSeasonTopScores(ctx, "s1", BoardRichest, 1)
+	assert.Equal(t, 900.0, top[0].Score)
+
+	season, _ := seasonContract.GetSeason(ctx, "s1")
+	assert.Equal(t, "ended", season.Status)
+	assert.Equal(t, 2, season.ArchivedUsers)
+	_, err = seasonContract.GetCurrentSeason(ctx)
+	assert.Error(t, err)
+	ctx.stub.MockTransactionEnd("txID1")
+}
+
+// Test QuestContract
+func TestQuests(t *testing.T) {
+	ctx := NewMockContext()
+	assetContract := new(AssetContract)
+	questContract := &QuestContract{AssetContract: assetContract}
+	assetContract.QuestContract = questContract
+	tradeContract := &TradeContract{AssetContract: assetContract, QuestContract: questContract}
+
+	ctx.stub.MockTransactionStart("txID1")
+	new(AccessContract).InitAdmin(ctx)
+	assetContract.initUser(ctx, "alice", 1000.0)
+	assetContract.initUser(ctx, "bob", 1000.0)
+
+	err := questContract.CreateQuest(ctx, "trader", "Complete 2 trades", QuestMetricTrades, "", 2, 100.0, "")
+	assert.NoError(t, err)
+	err = questContract.CreateQuest(ctx, "hoarder", "Hold 50 gold", QuestMetricHold, "gold", 50, 0, `[{"commodityId":"gem","quantity":1},{"commodityId":"gem","quantity":2}]`)
+	assert.NoError(t, err)
+	quest, _ := questContract.GetQuest(ctx, "hoarder")
+	assert.Equal(t, []models.RequiredItem{{CommodityID: "gem", Quantity: 3}}, quest.RewardItems)
+	err = questContract.CreateQuest(ctx, "bad", "Hold anything", QuestMetricHold, "", 1, 10.0, "")
+	assert.Error(t, err)
+
+	// Inventory changes drive hold quests
+	assetContract.updateInventory(ctx, "bob", "gold", 60, "add")
+	progress, err := questContract.GetQuestProgress(ctx, "hoarder", "bob")
+	assert.NoError(t, err)
+	assert.True(t, progress.Completed)
+
+	// Completed trades drive trade quests for both parties; partial fills do not count
+	tradeContract.CreateTrade(ctx, "trade1", "alice", "bob", "gold", 10, 1.0, "buy")
+	tradeContract.AcceptTrade(ctx, "trade1", 5)
+	progress, _ = questContract.GetQuestProgress(ctx, "trader", "alice")
+	assert.Equal(t, 0, progress.Progress)
+
+	tradeContract.ExecuteTrade(ctx, "trade1")
+	progress, _ = questContract.GetQuestProgress(ctx, "trader", "alice")
+	assert.Equal(t, 1, progress.Progress)
+	assert.False(t, progress.Completed)
+	err = questContract.ClaimQuestReward(ctx, "trader", "alice")
+	assert.Error(t, err)
+
+	tradeContract.CreateTrade(ctx, "trade2", "alice", "bob", "gold", 1, 1.0, "buy")
+	tradeContract.ExecuteTrade(ctx, "trade2")
+	progress, _ = questContract.GetQuestProgress(ctx, "trader", "bob")
+	assert.Equal(t, 2, progress.Progress)
+	progress, _ = questContract.GetQuestProgress(ctx, "trader", "alice")
+	assert.True(t, progress.Completed)
+
+	// Rewards are paid once
+	err = questContract.ClaimQuestReward(ctx, "trader", "alice")
+	assert.NoError(t, err)
+	userAsset, _ := assetContract.GetUserAssets(ctx, "alice")
+	assert.Equal(t, 1089.0, userAsset.Balance)
+	err = questContract.ClaimQuestReward(ctx, "trader", "alice")
+	assert.Error(t, err)
+
+	err = questContract.ClaimQuestReward(ctx, "hoarder", "bob")
+	assert.NoError(t, err)
+	inventory, _ := assetContract.GetInventory(ctx, "bob", "gem")
+	assert.Equal(t, 3, inventory.Quantity)
+
+	quests, _ := questContract.GetUserQuests(ctx, "bob")
+	assert.Equal(t, 2, len(quests))
+	ctx.stub.MockTransactionEnd("txID1")
+}
+
+// Test FaucetContract
+func TestFaucet(t *testing.T) {
+	ctx := NewMockContext()
+	assetContract := new(AssetContract)
+	faucetContract := &FaucetContract{AssetContract: assetContract}
+	start := time.Date(2025, 11, 7, 10, 0, 0, 0, time.UTC)
+
+	ctx.stub.MockTransactionStart("txID1")
+	setTxTime(ctx, start)
+	new(AccessContract).InitAdmin(ctx)
+	assetContract.initUser(ctx, "alice", 0)
+	assetContract.initUser(ctx, "bob", 0)
+
+	err := faucetContract.ClaimFaucet(ctx, "alice")
+	assert.Error(t, err)
+
+	err = faucetContract.SetFaucetConfig(ctx, 100.0, `[{"commodityId":"apple","quantity":1}]`, 86400, 0.5, 3, 300.0, 10)
+	assert.NoError(t, err)
+
+	err = faucetContract.ClaimFaucet(ctx, "alice")
+	assert.NoError(t, err)
+	userAsset, _ := assetContract.GetUserAssets(ctx, "alice")
+	assert.Equal(t, 100.0, userAsset.Balance)
+	inventory, _ := assetContract.GetInventory(ctx, "alice", "apple")
+	assert.Equal(t, 1, inventory.Quantity)
+
+	// Cooldown applies per user
+	setTxTime(ctx, start.Add(time.Hour))
+	err = faucetContract.ClaimFaucet(ctx, "alice")
+	assert.Error(t, err)
+	err = faucetContract.ClaimFaucet(ctx, "bob")
+	assert.NoError(t, err)
+
+	// Consecutive claims earn a streak bonus, within the daily budget
+	setTxTime(ctx, start.Add(25*time.Hour))
+	err = faucetContract.ClaimFaucet(ctx, "alice")
+	assert.NoError(t, err)
+	claim, _ := faucetContract.GetFaucetClaim(ctx, "alice")
+	assert.Equal(t, 2, claim.Streak)
+	assert.Equal(t, 150.0, claim.LastAmount)
+	err = faucetContract.ClaimFaucet(ctx, "bob")
+	assert.NoError(t, err)
+
+	// A missed day resets the streak
+	setTxTime(ctx, start.Add(100*time.Hour))
+	err = faucetContract.ClaimFaucet(ctx, "alice")
+	assert.NoError(t, err)
+	claim, _ = faucetContract.GetFaucetClaim(ctx, "alice")
+	assert.Equal(t, 1, claim.Streak)
+
+	day, err := faucetContract.GetFaucetDay(ctx, "2025-11-08")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, day.Claims)
+	assert.Equal(t, 300.0, day.Dispensed)
+	assert.Equal(t, map[string]int{"apple": 2}, day.ItemsDispensed)
+	ctx.stub.MockTransactionEnd("txID1")
+}
+
+func TestFaucetDailyBudget(t *testing.T) {
+	ctx := NewMockContext()
+	assetContract := new(AssetContract)
+	faucetContract := &FaucetContract{AssetContract: assetContract}
+
+	ctx.stub.MockTransactionStart("txID1")
+	setTxTime(ctx, time.Date(2025, 11, 7, 10, 0, 0, 0, time.UTC))
+	new(AccessContract).InitAdmin(ctx)
+	for _, userID := range []string{"alice", "bob", "carol"} {
+		assetContract.initUser(ctx, userID, 0)
+	}
+	faucetContract.SetFaucetConfig(ctx, 100.0, "", 86400, 0, 1, 200.0, 10)
+
+	assert.NoError(t, faucetContract.ClaimFaucet(ctx, "alice"))
+	assert.NoError(t, faucetContract.ClaimFaucet(ctx, "bob"))
+	err := faucetContract.ClaimFaucet(ctx, "carol")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "budget")
+
+	// The budget resets on the next UTC day
+	setTxTime(ctx, time.Date(2025, 11, 8, 0, 0, 1, 0, time.UTC))
+	assert.NoError(t, faucetContract.ClaimFaucet(ctx, "carol"))
+	ctx.stub.MockTransactionEnd("txID1")
+}
+
+func TestFaucetDailyClaimLimit(t *testing.T) {
+	ctx := NewMockContext()
+	assetContract := new(AssetContract)
+	faucetContract := &FaucetContract{AssetContract: assetContract}
+
+	ctx.stub.MockTransactionStart("txID1")
+	setTxTime(ctx, time.Date(2025, 11, 7, 10, 0, 0, 0, time.UTC))
+	new(AccessContract).InitAdmin(ctx)
+	for _, userID := range []string{"alice", "bob", "carol"} {
+		assetContract.initUser(ctx, userID, 0)
+	}
+
+	// An items-only faucet is bounded by the claim limit
+	err := faucetContract.SetFaucetConfig(ctx, 0, `[{"commodityId":"apple","quantity":5}]`, 86400, 0, 1, 1.0, 0)
+	assert.Error(t, err)
+	err = faucetContract.SetFaucetConfig(ctx, 0, `[{"commodityId":"apple","quantity":2},{"commodityId":"apple","quantity":3}]`, 86400, 0, 1, 1.0, 2)
+	assert.NoError(t, err)
+	config, _ := faucetContract.GetFaucetConfig(ctx)
+	assert.Equal(t, []models.RequiredItem{{CommodityID: "apple", Quantity: 5}}, config.DailyItems)
+
+	assert.NoError(t, faucetContract.ClaimFaucet(ctx, "alice"))
+	assert.NoError(t, faucetContract.ClaimFaucet(ctx, "bob"))
+	err = faucetContract.ClaimFaucet(ctx, "carol")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "claim limit")
+
+	day, _ := faucetContract.GetFaucetDay(ctx, "2025-11-07")
+	assert.Equal(t, map[string]int{"apple": 10}, day.ItemsDispensed)
+	ctx.stub.MockTransactionEnd("txID1")
+}
+
+// Test GuildContract
+func TestGuildMembership(t *testing.T) {
+	ctx := NewMockContext()
+	assetContract := new(AssetContract)
+	guildContract := &GuildContract{AssetContract: assetContract}
+
+	ctx.stub.MockTransactionStart("txID1")
+	for _, userID := range []string{"alice", "bob", "carol"} {
+		assetContract.initUser(ctx, userID, 1000.0)
+	}
+
+	err := guildContract.CreateGuild(ctx, "knights", "Knights", "alice")
+	assert.NoError(t, err)
+
+	// Joining requires an invitation
+	err = guildContract.JoinGuild(ctx, "knights", "bob")
+	assert.Error(t, err)
+	err = guildContract.InviteMember(ctx, "knights", "alice", "bob")
+	assert.NoError(t, err)
+	err = guildContract.JoinGuild(ctx, "knights", "bob")
+	assert.NoError(t, err)
+
+	// Members cannot invite; officers can
+	err = guildContract.InviteMember(ctx, "knights", "bob", "carol")
+	assert.Error(t, err)
+	err = guildContract.SetMemberRole(ctx, "knights", "alice", "bob", GuildRoleOfficer)
+	assert.NoError(t, err)
+	err = guildContract.InviteMember(ctx, "knights", "bob", "carol")
+	assert.NoError(t, err)
+	guildContract.JoinGuild(ctx, "knights", "carol")
+
+	// Officers cannot kick the leader; the leader must hand over before leaving
+	err = guildContract.KickMember(ctx, "knights", "bob", "alice")
+	assert.Error(t, err)
+	err = guildContract.LeaveGuild(ctx, "knights", "alice")
+	assert.Error(t, err)
+	err = guildContract.SetMemberRole(ctx, "knights", "alice", "bob", GuildRoleLeader)
+	assert.NoError(t, err)
+	guild, _ := guildContract.GetGuild(ctx, "knights")
+	assert.Equal(t, "bob", guild.LeaderID)
+	err = guildContract.KickMember(ctx, "knights", "bob", "carol")
+	assert.NoError(t, err)
+
+	members, _ := guildContract.GetGuildMembers(ctx, "knights")
+	assert.Equal(t, 2, len(members))
+	guild, _ = guildContract.GetGuild(ctx, "knights")
+	assert.Equal(t, 2, guild.MemberCount)
+	_, err = guildContract.GetUserGuild(ctx, "carol")
+	assert.Error(t, err)
+	ctx.stub.MockTransactionEnd("txID1")
+}
+
+func TestGuildTreasury(t *testing.T) {
+	ctx := NewMockContext()
+	assetContract := new(AssetContract)
+	guildContract := &GuildContract{AssetContract: assetContract}
+
+	ctx.stub.MockTransactionStart("txID1")
+	setTxTime(ctx, time.Date(2025, 11, 7, 10, 0, 0, 0, time.UTC))
+	assetContract.initUser(ctx, "alice", 1000.0)
+	assetContract.initUser(ctx, "bob", 1000.0)
+	assetContract.updateInventory(ctx, "bob", "iron", 10, "add")
+	guildContract.CreateGuild(ctx, "knights", "Knights", "alice")
+	guildContract.InviteMember(ctx, "knights", "alice", "bob")
+	guildContract.JoinGuild(ctx, "knights", "bob")
+
+	err := guildContract.DepositBalance(ctx, "knights", "alice", 500.0)
+	assert.NoError(t, err)
+	err = guildContract.DepositItems(ctx, "knights", "bob", "iron", 6)
+	assert.NoError(t, err)
+	treasury, _ := guildContract.GetGuildTreasury(ctx, "knights")
+	assert.Equal(t, 500.0, treasury.Balance)
+	inventories, _ := guildContract.GetGuildInventory(ctx, "knights")
+	assert.Equal(t, 1, len(inventories))
+	assert.Equal(t, 6, inventories[0].Quantity)
+
+	// Members withdraw within their daily limits
+	err = guildContract.WithdrawBalance(ctx, "knights", "bob", 50.0)
+	assert.Error(t, err)
+	err = guildContract.SetMemberLimits(ctx, "knights", "bob", "bob", 1000.0, 10)
+	assert.Error(t, err)
+	guildContract.SetMemberLimits(ctx, "knights", "alice", "bob", 100.0, 2)
+	err = guildContract.WithdrawBalance(ctx, "knights", "bob", 80.0)
+	assert.NoError(t, err)
+	err = guildContract.WithdrawBalance(ctx, "knights", "bob", 30.0)
+	assert.Error(t, err)
+	err = guildContract.WithdrawItems(ctx, "knights", "bob", "iron", 3)
+	assert.Error(t, err)
+
+	// Limits reset on the next UTC day; the leader is not limited
+	setTxTime(ctx, time.Date(2025, 11, 8, 10, 0, 0, 0, time.UTC))
+	err = guildContract.WithdrawBalance(ctx, "knights", "bob", 30.0)
+	assert.NoError(t, err)
+	err = guildContract.WithdrawBalance(ctx, "knights", "alice", 390.0)
+	assert.NoError(t, err)
+	treasury, _ = guildContract.GetGuildTreasury(ctx, "knights")
+	assert.Equal(t, 0.0, treasury.Balance)
+	userAsset, _ := assetContract.GetUserAssets(ctx, "bob")
+	assert.Equal(t, 1110.0, userAsset.Balance)
+
+	activities, err := guildContract.GetGuildActivity(ctx, "knights")
+	assert.NoError(t, err)
+	assert.Equal(t, 9, len(activities))
+	assert.Equal(t, "create", activities[0].Action)
+	assert.Equal(t, "withdraw", activities[8].Action)
+	ctx.stub.MockTransactionEnd("txID1")
+}
+
+// Test MultisigContract
+func TestMultisigProposal(t *testing.T) {
+	ctx := NewMockContext()
+	assetContract := new(AssetContract)
+	multisigContract := &MultisigContract{AssetContract: assetContract}
+	start := time.Date(2025, 11, 7, 10, 0, 0, 0, time.UTC)
+
+	ctx.stub.MockTransactionStart("txID1")
+	setTxTime(ctx, start)
+	new(AccessContract).InitAdmin(ctx)
+	assetContract.initUser(ctx, "alice", 100.0)
+	assetContract.initUser(ctx, "bob", 0)
+
+	err := multisigContract.InitMultisigPolicy(ctx, `["admin","ops1","ops2"]`, 2, 3600)
+	assert.NoError(t, err)
+	err = multisigContract.InitMultisigPolicy(ctx, `["admin"]`, 1, 3600)
+	assert.Error(t, err)
+
+	// Only approvers can propose, and arguments are validated up front
+	setCaller(ctx, "mallory")
+	err = multisigContract.CreateProposal(ctx, "p1", OperationMint, `{"userId":"alice","amount":500}`)
+	assert.Error(t, err)
+	setCaller(ctx, "ops1")
+	err = multisigContract.CreateProposal(ctx, "p0", OperationMint, `{"userId":"nobody","amount":500}`)
+	assert.Error(t, err)
+	err = multisigContract.CreateProposal(ctx, "p1", OperationMint, `{"userId":"alice","amount":500}`)
+	assert.NoError(t, err)
+
+	// The operation waits for the threshold
+	userAsset, _ := assetContract.GetUserAssets(ctx, "alice")
+	assert.Equal(t, 100.0, userAsset.Balance)
+	err = multisigContract.ApproveProposal(ctx, "p1")
+	assert.Error(t, err)
+
+	setCaller(ctx, "ops2")
+	err = multisigContract.ApproveProposal(ctx, "p1")
+	assert.NoError(t, err)
+	userAsset, _ = assetContract.GetUserAssets(ctx, "alice")
+	assert.Equal(t, 600.0, userAsset.Balance)
+	proposal, _ := multisigContract.GetProposal(ctx, "p1")
+	assert.Equal(t, "executed", proposal.Status)
+	assert.Equal(t, []string{"ops1", "ops2"}, proposal.Approvals)
+
+	err = multisigContract.ApproveProposal(ctx, "p1")
+	assert.Error(t, err)
+	ctx.stub.MockTransactionEnd("txID1")
+}
+
+func TestMultisigProposalExpiry(t *testing.T) {
+	ctx := NewMockContext()
+	assetContract := new(AssetContract)
+	multisigContract := &MultisigContract{AssetContract: assetContract}
+	start := time.Date(2025, 11, 7, 10, 0, 0, 0, time.UTC)
+
+	ctx.stub.MockTransactionStart("txID1")
+	setTxTime(ctx, start)
+	new(AccessContract).InitAdmin(ctx)
+	assetContract.initUser(ctx, "alice", 100.0)
+	assetContract.initUser(ctx, "bob", 0)
+	multisigContract.InitMultisigPolicy(ctx, `["admin","ops1"]`, 2, 3600)
+
+	err := multisigContract.CreateProposal(ctx, "p1", OperationTransfer, `{"fromUserId":"alice","toUserId":"bob","amount":60}`)
+	assert.NoError(t, err)
+	pending, _ := multisigContract.GetPendingProposals(ctx)
+	assert.Equal(t, 1, len(pending))
+
+	err = multisigContract.ExpireProposal(ctx, "p1")
+	assert.Error(t, err)
+
+	setTxTime(ctx, start.Add(2*time.Hour))
+	setCaller(ctx, "ops1")
+	err = multisigContract.ApproveProposal(ctx, "p1")
+	assert.Error(t, err)
+	err = multisigContract.ExpireProposal(ctx, "p1")
+	assert.NoError(t, err)
+
+	proposal, _ := multisigContract.GetProposal(ctx, "p1")
+	assert.Equal(t, "expired", proposal.Status)
+	userAsset, _ := assetContract.GetUserAssets(ctx, "bob")
+	assert.Equal(t, 0.0, userAsset.Balance)
+
+	// Policy changes are themselves proposals
+	setTxTime(ctx, start.Add(3*time.Hour))
+	setCaller(ctx, "admin")
+	err = multisigContract.CreateProposal(ctx, "p2", OperationSetPolicy, `{"approvers":["admin","ops1","ops2"],"threshold":3,"ttlSeconds":600}`)
+	assert.NoError(t, err)
+	setCaller(ctx, "ops1")
+	err = multisigContract.ApproveProposal(ctx, "p2")
+	assert.NoError(t, err)
+	policy, _ := multisigContract.GetMultisigPolicy(ctx)
+	assert.Equal(t, 3, policy.Threshold)
+	ctx.stub.MockTransactionEnd("txID1")
+}
+
+func TestMultisigGatesDirectOperations(t *testing.T) {
+	ctx := NewMockContext()
+	assetContract := new(AssetContract)
+	redemptionContract := &RedemptionContract{AssetContract: assetContract}
+	multisigContract := &MultisigContract{AssetContract: assetContract, RedemptionContract: redemptionContract}
+	itemsJSON := `[{"commodityId":"apple","quantity":1}]`
+
+	ctx.stub.MockTransactionStart("txID1")
+	setTxTime(ctx, time.Date(2025, 11, 7, 10, 0, 0, 0, time.UTC))
+	new(AccessContract).InitAdmin(ctx)
+	assetContract.initUser(ctx, "alice", 100.0)
+
+	// Non-admins can never call the direct entry points
+	setCaller(ctx, "alice")
+	assert.Error(t, assetContract.UpdateBalance(ctx, "alice", 1000.0, "add"))
+	assert.Error(t, assetContract.UpdateInventory(ctx, "alice", "apple", 10, "add"))
+	assert.Error(t, redemptionContract.CreateRedemptionRule(ctx, "alice", itemsJSON, 1000.0))
+	assert.Error(t, redemptionContract.CreateOracleRedemptionRule(ctx, "alice", itemsJSON, 10.0))
+
+	// The admin can until a multisig policy exists
+	setCaller(ctx, "admin")
+	assert.NoError(t, assetContract.UpdateBalance(ctx, "alice", 50.0, "add"))
+	assert.NoError(t, assetContract.UpdateInventory(ctx, "alice", "apple", 2, "add"))
+	multisigContract.InitMultisigPolicy(ctx, `["admin","ops1"]`, 2, 3600)
+
+	err := assetContract.UpdateBalance(ctx, "alice", 50.0, "add")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), OperationAdjustBalance)
+	assert.Error(t, assetContract.UpdateInventory(ctx, "alice", "apple", 2, "add"))
+	assert.Error(t, redemptionContract.CreateRedemptionRule(ctx, "alice", itemsJSON, 1000.0))
+	assert.Error(t, redemptionContract.CreateOracleRedemptionRule(ctx, "alice", itemsJSON, 10.0))
+
+	userAsset, _ := assetContract.GetUserAssets(ctx, "alice")
+	assert.Equal(t, 150.0, userAsset.Balance)
+	inventory, _ := assetContract.GetInventory(ctx, "alice", "apple")
+	assert.Equal(t, 2, inventory.Quantity)
+
+	// The same operations go through once approved
+	err = multisigContract.CreateProposal(ctx, "p1", OperationAdjustInventory, `{"userId":"alice","commodityId":"apple","quantity":1,"operation":"subtract"}`)
+	assert.NoError(t, err)
+	err = multisigContract.CreateProposal(ctx, "p2", OperationCreateOracleRedemptionRule, `{"userId":"alice","requiredItems":`+itemsJSON+`,"rewardRate":2}`)
+	assert.NoError(t, err)
+	setCaller(ctx, "ops1")
+	assert.NoError(t, multisigContract.ApproveProposal(ctx, "p1"))
+	assert.NoError(t, multisigContract.ApproveProposal(ctx, "p2"))
+
+	inventory, _ = assetContract.GetInventory(ctx, "alice", "apple")
+	assert.Equal(t, 1, inventory.Quantity)
+	rule, err := redemptionContract.GetRedemptionRule(ctx, "alice")
+	assert.NoError(t, err)
+	assert.Equal(t, 2.0, rule.RewardRate)
+	ctx.stub.MockTransactionEnd("txID1")
+}
+
+// Test AccountContract
+func TestAccountStatus(t *testing.T) {
+	ctx := NewMockContext()
+	assetContract := new(AssetContract)
+	accountContract := &AccountContract{AssetContract: assetContract}
+	tradeContract := &TradeContract{AssetContract: assetContract}
+	redemptionContract := &RedemptionContract{AssetContract: assetContract}
+
+	ctx.stub.MockTransactionStart("txID1")
+	new(AccessContract).InitAdmin(ctx)
+	assetContract.initUser(ctx, "alice", 1000.0)
+	assetContract.initUser(ctx, "bob", 1000.0)
+	assetContract.updateInventory(ctx, "bob", "apple", 10, "add")
+	redemptionContract.CreateRedemptionRule(ctx, "bob", `[{"commodityId":"apple","quantity":2}]`, 50.0)
+
+	// Only admins can sanction, and a reason is required
+	setCaller(ctx, "mallory")
+	err := accountContract.SetAccountStatus(ctx, "bob", AccountFrozen, "cheating")
+	assert.Error(t, err)
+	setCaller(ctx, "admin")
+	err = accountContract.SetAccountStatus(ctx, "bob", AccountFrozen, "")
+	assert.Error(t, err)
+
+	// Suspended accounts cannot trade but can still redeem
+	err = accountContract.SetAccountStatus(ctx, "bob", AccountSuspendedTrading, "wash trading")
+	assert.NoError(t, err)
+	err = tradeContract.CreateTrade(ctx, "trade1", "alice", "bob", "apple", 2, 10.0, "buy")
+	assert.Error(t, err)
+	err = redemptionContract.ExecuteRedemption(ctx, "bob", "record1")
+	assert.NoError(t, err)
+
+	// Frozen accounts cannot move anything
+	err = accountContract.SetAccountStatus(ctx, "bob", AccountFrozen, "chargeback")
+	assert.NoError(t, err)
+	err = redemptionContract.ExecuteRedemption(ctx, "bob", "record2")
+	assert.Error(t, err)
+	err = assetContract.updateBalance(ctx, "bob", 10.0, "add")
+	assert.Error(t, err)
+
+	// Pending trades cannot fill once a party is sanctioned
+	accountContract.SetAccountStatus(ctx, "bob", AccountActive, "appeal granted")
+	err = tradeContract.CreateTrade(ctx, "trade2", "alice", "bob", "apple", 2, 10.0, "buy")
+	assert.NoError(t, err)
+	accountContract.SetAccountStatus(ctx, "alice", AccountSuspendedTrading, "bot activity")
+	err = tradeContract.ExecuteTrade(ctx, "trade2")
+	assert.Error(t, err)
+
+	status, _ := accountContract.GetAccountStatus(ctx, "bob")
+	assert.Equal(t, AccountActive, status.Status)
+	history, err := accountContract.GetAccountStatusHistory(ctx, "bob")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(history))
+	assert.Equal(t, AccountFrozen, history[1].ToStatus)
+	assert.Equal(t, "chargeback", history[1].Reason)
+	assert.Equal(t, "admin", history[2].ChangedBy)
+	ctx.stub.MockTransactionEnd("txID1")
+}
+
+func TestCloseAccount(t *testing.T) {
+	ctx := NewMockContext()
+	assetContract := new(AssetContract)
+	tradeContract := &TradeContract{AssetContract: assetContract}
+	accountContract := &AccountContract{AssetContract: assetContract, TradeContract: tradeContract}
+
+	ctx.stub.MockTransactionStart("txID1")
+	new(AccessContract).InitAdmin(ctx)
+	assetContract.initUser(ctx, "alice", 1000.0)
+	assetContract.initUser(ctx, "bob", 300.0)
+	assetContract.initUser(ctx, "carol", 200.0)
+	assetContract.initUser(ctx, "treasury", 0.0)
+	assetContract.updateInventory(ctx, "bob", "apple", 10, "add")
+	assetContract.updateInventory(ctx, "carol", "apple", 4, "add")
+	tradeContract.CreateTrade(ctx, "trade1", "alice", "bob", "apple", 2, 10.0, "buy")
+
+	// Closing goes through CloseAccount, not SetAccountStatus
+	err := accountContract.SetAccountStatus(ctx, "bob", AccountClosed, "user request")
+	assert.Error(t, err)
+	err = accountContract.CloseAccount(ctx, "bob", "bob", "user request")
+	assert.Error(t, err)
+
+	// Frozen accounts can still be closed and swept
+	accountContract.SetAccountStatus(ctx, "bob", AccountFrozen, "chargeback")
+	err = accountContract.CloseAccount(ctx, "bob", "treasury", "user request")
+	assert.NoError(t, err)
+
+	treasury, _ := assetContract.GetUserAssets(ctx, "treasury")
+	assert.Equal(t, 300.0, treasury.Balance)
+	treasuryApples, _ := assetContract.GetInventory(ctx, "treasury", "apple")
+	assert.Equal(t, 10, treasuryApples.Quantity)
+
+	trade, _ := tradeContract.GetTradeStatus(ctx, "trade1")
+	assert.Equal(t, "cancelled", trade.Status)
+
+	_, err = assetContract.GetUserAssets(ctx, "bob")
+	assert.Error(t, err)
+	inventories, _ := assetContract.GetAllInventory(ctx, "bob")
+	assert.Equal(t, 0, len(inventories))
+
+	closure, err := accountContract.GetAccountClosure(ctx, "bob")
+	assert.NoError(t, err)
+	assert.Equal(t, "treasury", closure.SweptTo)
+	assert.Equal(t, []string{"trade1"}, closure.CancelledTrades)
+
+	// The tombstone stops the user ID being reused
+	err = assetContract.initUser(ctx, "bob", 100.0)
+	assert.Error(t, err)
+	err = accountContract.CloseAccount(ctx, "bob", "", "again")
+	assert.Error(t, err)
+
+	// Without a sweep target the holdings are burned
+	err = accountContract.CloseAccount(ctx, "carol", "", "inactive")
+	assert.NoError(t, err)
+	closure, _ = accountContract.GetAccountClosure(ctx, "carol")
+	assert.Equal(t, 200.0, closure.Balance)
+	assert.Equal(t, 4, closure.Items[0].Quantity)
+	treasury, _ = assetContract.GetUserAssets(ctx, "treasury")
+	assert.Equal(t, 300.0, treasury.Balance)
+	ctx.stub.MockTransactionEnd("txID1")
+}
+
+func TestCloseAccountOpenPositions(t *testing.T) {
+	ctx := NewMockContext()
+	assetContract := new(AssetContract)
+	commodityContract := new(CommodityContract)
+	auctionContract := &AuctionContract{AssetContract: assetContract}
+	guildContract := &GuildContract{AssetContract: assetContract}
+	ammContract := &AMMContract{AssetContract: assetContract, CommodityContract: commodityContract}
+	accountContract := &AccountContract{
+		AssetContract:   assetContract,
+		AuctionContract: auctionContract,
+		GuildContract:   guildContract,
+		AMMContract:     ammContract,
+	}
+
+	ctx.stub.MockTransactionStart("txID1")
+	setTxTime(ctx, time.Date(2025, 1, 1, 12, 30, 0, 0, time.UTC))
+	new(AccessContract).InitAdmin(ctx)
+	commodityContract.CreateCommodity(ctx, "gold", "Gold", "")
+	for _, userID := range []string{"seller", "bidder", "leader", "member", "lp"} {
+		assetContract.initUser(ctx, userID, 1000.0)
+	}
+	assetContract.updateInventory(ctx, "seller", "gold", 1, "add")
+	assetContract.updateInventory(ctx, "lp", "gold", 10, "add")
+
+	// Both parties to an open auction must wait for it to settle
+	auctionContract.CreateAuction(ctx, "auction1", "seller", "gold", 1, 100.0, "english",
+		"2025-01-01T12:00:00Z", "2025-01-01T13:00:00Z", "")
+	auctionContract.PlaceBid(ctx, "auction1", "bidder", 150.0)
+	err := accountContract.CloseAccount(ctx, "seller", "", "user request")
+	assert.Error(t, err)
+	err = accountContract.CloseAccount(ctx, "bidder", "", "user request")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "auction1")
+
+	// A guild leader must hand over leadership; members simply leave
+	guildContract.CreateGuild(ctx, "guild1", "Guild", "leader")
+	guildContract.InviteMember(ctx, "guild1", "leader", "member")
+	guildContract.JoinGuild(ctx, "guild1", "member")
+	err = accountContract.CloseAccount(ctx, "leader", "", "user request")
+	assert.Error(t, err)
+	err = accountContract.CloseAccount(ctx, "member", "", "user request")
+	assert.NoError(t, err)
+	guild, _ := guildContract.GetGuild(ctx, "guild1")
+	assert.Equal(t, 1, guild.MemberCount)
+	_, err = guildContract.GetUserGuild(ctx, "member")
+	assert.Error(t, err)
+
+	// Pool shares must be withdrawn first
+	ammContract.CreatePool(ctx, "gold", 0.0)
+	ammContract.AddLiquidity(ctx, "gold", "lp", 10, 100.0)
+	err = accountContract.CloseAccount(ctx, "lp", "", "user request")
+	assert.Error(t, err)
+	position, _ := ammContract.GetLiquidityPosition(ctx, "gold", "lp")
+	ammContract.RemoveLiquidity(ctx, "gold", "lp", position.Shares, 0, 0)
+	err = accountContract.CloseAccount(ctx, "lp", "", "user request")
+	assert.NoError(t, err)
+	ctx.stub.MockTransactionEnd("txID1")
+}
+
+func TestAuctionFrozenParticipants(t *testing.T) {
+	ctx := NewMockContext()
+	assetContract := new(AssetContract)
+	auctionContract := &AuctionContract{AssetContract: assetContract}
+	accountContract := &AccountContract{AssetContract: assetContract}
+	start := time.Date(2025, 1, 1, 12, 30, 0, 0, time.UTC)
+
+	ctx.stub.MockTransactionStart("txID1")
+	setTxTime(ctx, start)
+	new(AccessContract).InitAdmin(ctx)
+	assetContract.initUser(ctx, "seller", 0.0)
+	assetContract.initUser(ctx, "bidder1", 1000.0)
+	assetContract.initUser(ctx, "bidder2", 1000.0)
+	assetContract.updateInventory(ctx, "seller", "gold", 2, "add")
+	auctionContract.CreateAuction(ctx, "auction1", "seller", "gold", 1, 100.0, "english",
+		"2025-01-01T12:00:00Z", "2025-01-01T13:00:00Z", "")
+	auctionContract.CreateAuction(ctx, "auction2", "seller", "gold", 1, 100.0, "sealed",
+		"2025-01-01T12:00:00Z", "2025-01-01T13:00:00Z", "2025-01-01T14:00:00Z")
+	ctx.stub.MockTransactionEnd("txID1")
+
+	bids := map[string][]byte{
+		"bidder1": []byte(`{"amount":150,"salt":"a1"}`),
+		"bidder2": []byte(`{"amount":200,"salt":"b2"}`),
+	}
+	for i, bidderID := range []string{"bidder1", "bidder2"} {
+		txID := fmt.Sprintf("txCommit%d", i)
+		ctx.stub.MockTransactionStart(txID)
+		setTxTime(ctx, start)
+		ctx.stub.TransientMap = map[string][]byte{"bid": bids[bidderID]}
+		err := auctionContract.CommitBid(ctx, "auction2", bidderID)
+		assert.NoError(t, err)
+		ctx.stub.MockTransactionEnd(txID)
+	}
+
+	// A frozen bidder who is outbid gets the escrowed bid back
+	ctx.stub.MockTransactionStart("txID2")
+	setTxTime(ctx, start)
+	err := auctionContract.PlaceBid(ctx, "auction1", "bidder1", 150.0)
+	assert.NoError(t, err)
+	accountContract.SetAccountStatus(ctx, "bidder1", AccountFrozen, "chargeback")
+	ctx.stub.MockTransactionEnd("txID2")
+
+	ctx.stub.MockTransactionStart("txID3")
+	setTxTime(ctx, start)
+	err = auctionContract.PlaceBid(ctx, "auction1", "bidder2", 200.0)
+	assert.NoError(t, err)
+	bidder1, _ := assetContract.GetUserAssets(ctx, "bidder1")
+	assert.Equal(t, 900.0, bidder1.Balance) // only the sealed bid deposit is still escrowed
+	ctx.stub.MockTransactionEnd("txID3")
+
+	// A frozen bidder whose revealed bid loses gets the deposit back
+	for i, bidderID := range []string{"bidder2", "bidder1"} {
+		txID := fmt.Sprintf("txReveal%d", i)
+		ctx.stub.MockTransactionStart(txID)
+		setTxTime(ctx, start.Add(time.Hour))
+		ctx.stub.TransientMap = map[string][]byte{"bid": bids[bidderID]}
+		err = auctionContract.RevealBid(ctx, "auction2", bidderID)
+		assert.NoError(t, err)
+		ctx.stub.MockTransactionEnd(txID)
+	}
+
+	ctx.stub.MockTransactionStart("txID4")
+	setTxTime(ctx, start.Add(time.Hour))
+	bidder1, _ = assetContract.GetUserAssets(ctx, "bidder1")
+	assert.Equal(t, 1000.0, bidder1.Balance)
+
+	// Freezing the seller voids the sale and returns the escrow to both sides
+	accountContract.SetAccountStatus(ctx, "seller", AccountFrozen, "chargeback")
+	err = auctionContract.CloseAuction(ctx, "auction1")
+	assert.NoError(t, err)
+	auction, _ := auctionContract.GetAuction(ctx, "auction1")
+	assert.Equal(t, "voided", auction.Status)
+	sellerGold, _ := assetContract.GetInventory(ctx, "seller", "gold")
+	assert.Equal(t, 1, sellerGold.Quantity)
+	bidder2, _ := assetContract.GetUserAssets(ctx, "bidder2")
+	assert.Equal(t, 800.0, bidder2.Balance) // the sealed bid is still escrowed
+	ctx.stub.MockTransactionEnd("txID4")
+}
+
+func TestCloseAuctionFrozenWinner(t *testing.T) {
+	ctx := NewMockContext()
+	assetContract := new(AssetContract)
+	auctionContract := &AuctionContract{AssetContract: assetContract}
+	accountContract := &AccountContract{AssetContract: assetContract}
+
+	ctx.stub.MockTransactionStart("txID1")
+	setTxTime(ctx, time.Date(2025, 1, 1, 12, 30, 0, 0, time.UTC))
+	new(AccessContract).InitAdmin(ctx)
+	assetContract.initUser(ctx, "seller", 0.0)
+	assetContract.initUser(ctx, "bidder", 1000.0)
+	assetContract.updateInventory(ctx, "seller", "gold", 1, "add")
+	auctionContract.CreateAuction(ctx, "auction1", "seller", "gold", 1, 100.0, "english",
+		"2025-01-01T12:00:00Z", "2025-01-01T13:00:00Z", "")
+	auctionContract.PlaceBid(ctx, "auction1", "bidder", 150.0)
+	accountContract.SetAccountStatus(ctx, "bidder", AccountFrozen, "chargeback")
+
+	// Freezing the winner voids the sale instead of blocking the auction
+	setTxTime(ctx, time.Date(2025, 1, 1, 13, 0, 0, 0, time.UTC))
+	err := auctionContract.CloseAuction(ctx, "auction1")
+	assert.NoError(t, err)
+	auction, _ := auctionContract.GetAuction(ctx, "auction1")
+	assert.Equal(t, "voided", auction.Status)
+
+	seller, _ := assetContract.GetUserAssets(ctx, "seller")
+	assert.Equal(t, 0.0, seller.Balance)
+	sellerGold, _ := assetContract.GetInventory(ctx, "seller", "gold")
+	assert.Equal(t, 1, sellerGold.Quantity)
+	bidder, _ := assetContract.GetUserAssets(ctx, "bidder")
+	assert.Equal(t, 1000.0, bidder.Balance)
+
+	// The refund stays frozen with the account
+	err = assetContract.updateBalance(ctx, "bidder", 1.0, "subtract")
+	assert.Error(t, err)
+	ctx.stub.MockTransactionEnd("txID1")
+}
+
+// Test LendingContract
+func TestLending(t *testing.T) {
+	ctx := NewMockContext()
+	assetContract := new(AssetContract)
+	marketDataContract := new(MarketDataContract)
+	tradeContract := &TradeContract{AssetContract: assetContract, MarketDataContract: marketDataContract}
+	lendingContract := &LendingContract{
+		AssetContract:     assetContract,
+		ValuationContract: &ValuationContract{AssetContract: assetContract, MarketDataContract: marketDataContract},
+	}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	ctx.stub.MockTransactionStart("txID1")
+	setTxTime(ctx, start)
+	new(AccessContract).InitAdmin(ctx)
+	assetContract.initUser(ctx, "bank", 10000.0)
+	assetContract.initUser(ctx, "alice", 1000.0)
+	assetContract.initUser(ctx, "bob", 0.0)
+	assetContract.initUser(ctx, "carol", 1000.0)
+	assetContract.updateInventory(ctx, "bob", "gold", 10, "add")
+	assetContract.updateInventory(ctx, "carol", "gold", 5, "add")
+
+	// Last trade price of gold is 10
+	tradeContract.CreateTrade(ctx, "trade1", "alice", "carol", "gold", 1, 10.0, "buy")
+	tradeContract.ExecuteTrade(ctx, "trade1")
+
+	err := lendingContract.SetLendingConfig(ctx, "bank", PriceSourceLast, 0.1, 0.8, 0.5)
+	assert.Error(t, err)
+	err = lendingContract.SetLendingConfig(ctx, "bank", PriceSourceLast, 0.1, 0.5, 0.8)
+	assert.NoError(t, err)
+
+	// Borrow up to half the collateral value
+	err = lendingContract.OpenLoan(ctx, "loan1", "bob", `[{"commodityId":"gold","quantity":10}]`, 60.0)
+	assert.Error(t, err)
+	err = lendingContract.OpenLoan(ctx, "loan1", "bob", `[{"commodityId":"gold","quantity":10}]`, 50.0)
+	assert.NoError(t, err)
+	bob, _ := assetContract.GetUserAssets(ctx, "bob")
+	assert.Equal(t, 50.0, bob.Balance)
+	bobGold, _ := assetContract.GetInventory(ctx, "bob", "gold")
+	assert.Equal(t, 10, bobGold.Quantity)
+	assert.Equal(t, 10, bobGold.Locked)
+	ctx.stub.MockTransactionEnd("txID1")
+
+	// Half a year of 10% interest on 50, paid before principal
+	ctx.stub.MockTransactionStart("txID2")
+	setTxTime(ctx, start.Add(365*12*time.Hour))
+	err = lendingContract.RepayLoan(ctx, "loan1", 10.0)
+	assert.NoError(t, err)
+	loan, _ := lendingContract.GetLoan(ctx, "loan1")
+	assert.InDelta(t, 0.0, loan.AccruedInterest, 0.0001)
+	assert.InDelta(t, 42.5, loan.Principal, 0.0001)
+
+	health, err := lendingContract.GetLoanHealth(ctx, "loan1")
+	assert.NoError(t, err)
+	assert.InDelta(t, 0.425, health.LoanToValue, 0.0001)
+	assert.False(t, health.Liquidatable)
+	err = lendingContract.LiquidateLoan(ctx, "loan1", "carol")
+	assert.Error(t, err)
+
+	// A price drop makes the loan liquidatable
+	tradeContract.CreateTrade(ctx, "trade2", "alice", "carol", "gold", 1, 5.0, "buy")
+	tradeContract.ExecuteTrade(ctx, "trade2")
+	err = lendingContract.LiquidateLoan(ctx, "loan1", "bob")
+	assert.Error(t, err)
+	err = lendingContract.LiquidateLoan(ctx, "loan1", "bank")
+	assert.Error(t, err)
+	bank, _ := assetContract.GetUserAssets(ctx, "bank")
+	assert.InDelta(t, 9960.0, bank.Balance, 0.0001)
+	err = lendingContract.LiquidateLoan(ctx, "loan1", "carol")
+	assert.NoError(t, err)
+
+	loan, _ = lendingContract.GetLoan(ctx, "loan1")
+	assert.Equal(t, "liquidated", loan.Status)
+	assert.Equal(t, "carol", loan.LiquidatorID)
+	carolGold, _ := assetContract.GetInventory(ctx, "carol", "gold")
+	assert.Equal(t, 13, carolGold.Quantity)
+	bank, _ = assetContract.GetUserAssets(ctx, "bank")
+	assert.InDelta(t, 10002.5, bank.Balance, 0.0001)
+
+	// Paying off a loan in full returns the collateral
+	err = lendingContract.OpenLoan(ctx, "loan2", "carol", `[{"commodityId":"gold","quantity":4}]`, 10.0)
+	assert.NoError(t, err)
+	err = lendingContract.RepayLoan(ctx, "loan2", 100.0)
+	assert.NoError(t, err)
+	loan, _ = lendingContract.GetLoan(ctx, "loan2")
+	assert.Equal(t, "repaid", loan.Status)
+	assert.Equal(t, 10.0, loan.TotalRepaid)
+	carolGold, _ = assetContract.GetInventory(ctx, "carol", "gold")
+	assert.Equal(t, 13, carolGold.Quantity)
+
+	loans, _ := lendingContract.GetUserLoans(ctx, "carol")
+	assert.Equal(t, 1, len(loans))
+	ctx.stub.MockTransactionEnd("txID2")
+}
+
+// Test StakingContract
+func TestStaking(t *testing.T) {
+	ctx := NewMockContext()
+	assetContract := new(AssetContract)
+	tradeContract := &TradeContract{AssetContract: assetContract}
+	stakingContract := &StakingContract{AssetContract: assetContract}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	ctx.stub.MockTransactionStart("txID1")
+	setTxTime(ctx, start)
+	new(AccessContract).InitAdmin(ctx)
+	assetContract.initUser(ctx, "alice", 1000.0)
+	assetContract.initUser(ctx, "bob", 1000.0)
+	assetContract.updateInventory(ctx, "alice", "gold", 20, "add")
+
+	err := stakingContract.CreateStakingProgram(ctx, "gold-coins", "gold", "", 0.5, 7*secondsPerDay, 0.5)
+	assert.NoError(t, err)
+	err = stakingContract.CreateStakingProgram(ctx, "gold-gems", "gold", "gem", 0.1, 7*secondsPerDay, 0.0)
+	assert.NoError(t, err)
+
+	err = stakingContract.Stake(ctx, "stake1", "alice", "gold-coins", 10, secondsPerDay)
+	assert.Error(t, err)
+	err = stakingContract.Stake(ctx, "stake1", "alice", "gold-coins", 10, 10*secondsPerDay)
+	assert.NoError(t, err)
+	err = stakingContract.Stake(ctx, "stake2", "alice", "gold-gems", 10, 7*secondsPerDay)
+	assert.NoError(t, err)
+
+	// Staked items stay in the inventory but are locked out of trading
+	available, _ := assetContract.GetAvailableInventory(ctx, "alice", "gold")
+	assert.Equal(t, 0, available)
+	err = tradeContract.CreateTrade(ctx, "trade1", "bob", "alice", "gold", 1, 10.0, "buy")
+	assert.Error(t, err)
+	ctx.stub.MockTransactionEnd("txID1")
+
+	// Early unlock forfeits half of 4 days of rewards: 10 * 0.5 * 4 / 2
+	ctx.stub.MockTransactionStart("txID2")
+	setTxTime(ctx, start.Add(4*day))
+	reward, _ := stakingContract.GetStakeReward(ctx, "stake1")
+	assert.InDelta(t, 20.0, reward, 0.0001)
+	err = stakingContract.Unstake(ctx, "stake1")
+	assert.NoError(t, err)
+	stake, _ := stakingContract.GetStake(ctx, "stake1")
+	assert.True(t, stake.EarlyUnlock)
+	assert.InDelta(t, 10.0, stake.Reward, 0.0001)
+	alice, _ := assetContract.GetUserAssets(ctx, "alice")
+	assert.InDelta(t, 1010.0, alice.Balance, 0.0001)
+	aliceGold, _ := assetContract.GetInventory(ctx, "alice", "gold")
+	assert.Equal(t, 20, aliceGold.Quantity)
+	assert.Equal(t, 10, aliceGold.Locked)
+	err = stakingContract.Unstake(ctx, "stake1")
+	assert.Error(t, err)
+	ctx.stub.MockTransactionEnd("txID2")
+
+	// Rewards stop at the end of the term and are paid in whole gems: floor(10 * 0.1 * 7)
+	ctx.stub.MockTransactionStart("txID3")
+	setTxTime(ctx, start.Add(30*day))
+	err = stakingContract.Unstake(ctx, "stake2")
+	assert.NoError(t, err)
+	gems, _ := assetContract.GetInventory(ctx, "alice", "gem")
+	assert.Equal(t, 7, gems.Quantity)
+	stakes, _ := stakingContract.GetUserStakes(ctx, "alice")
+	assert.Equal(t, 2, len(stakes))
+	ctx.stub.MockTransactionEnd("txID3")
+}
+
+// Test StorageContract
+func TestStorageSkipsEscrowSettlements(t *testing.T) {
+	ctx := NewMockContext()
+	assetContract := new(AssetContract)
+	auctionContract := &AuctionContract{AssetContract: assetContract}
+	shopContract := &ShopContract{AssetContract: assetContract}
+	storageContract := &StorageContract{AssetContract: assetContract}
+	start := time.Date(2025, 1, 1, 12, 30, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	ctx.stub.MockTransactionStart("txID1")
+	setTxTime(ctx, start)
+	new(AccessContract).InitAdmin(ctx)
+	for _, userID := range []string{"seller", "bidder", "house", "carol"} {
+		assetContract.initUser(ctx, userID, 1000.0)
+	}
+	assetContract.updateInventory(ctx, "seller", "gold", 10, "add")
+	assetContract.updateInventory(ctx, "bidder", "silver", 10, "add")
+	assetContract.updateInventory(ctx, "house", "silver", 10, "add")
+	assetContract.updateInventory(ctx, "carol", "wood", 2, "add")
+	auctionContract.CreateAuction(ctx, "auction1", "seller", "gold", 5, 100.0, "english",
+		"2025-01-01T12:00:00Z", "2025-01-01T13:00:00Z", "")
+	auctionContract.CreateAuction(ctx, "auction2", "seller", "gold", 5, 100.0, "english",
+		"2025-01-01T12:00:00Z", "2025-01-01T13:00:00Z", "")
+	auctionContract.PlaceBid(ctx, "auction1", "bidder", 150.0)
+	shopContract.SetShopHouse(ctx, "house")
+	shopContract.SetShopListing(ctx, "wood", 10.0, 4.0, 10, 5, 5, secondsPerDay)
+	err := storageContract.SetStorageConfig(ctx, 10, 10, 50.0, 0.0, 0, "")
+	assert.NoError(t, err)
+	assetContract.updateInventory(ctx, "seller", "silver", 10, "add")
+	ctx.stub.MockTransactionEnd("txID1")
+
+	// Escrowed items are returned to a full seller and delivered to a full winner
+	ctx.stub.MockTransactionStart("txID2")
+	setTxTime(ctx, start.Add(time.Hour))
+	err = auctionContract.CancelAuction(ctx, "auction2")
+	assert.NoError(t, err)
+	err = auctionContract.CloseAuction(ctx, "auction1")
+	assert.NoError(t, err)
+	sellerGold, _ := assetContract.GetInventory(ctx, "seller", "gold")
+	assert.Equal(t, 5, sellerGold.Quantity)
+	bidderGold, _ := assetContract.GetInventory(ctx, "bidder", "gold")
+	assert.Equal(t, 5, bidderGold.Quantity)
+
+	// A full house account still buys back and restocks up to the listing's max stock
+	err = shopContract.SellToShop(ctx, "carol", "wood", 2)
+	assert.NoError(t, err)
+	ctx.stub.MockTransactionEnd("txID2")
+
+	ctx.stub.MockTransactionStart("txID3")
+	setTxTime(ctx, start.Add(day+time.Hour))
+	err = shopContract.BuyFromShop(ctx, "carol", "wood", 1)
+	assert.NoError(t, err)
+	ctx.stub.MockTransactionEnd("txID3")
+
+	ctx.stub.MockTransactionStart("txID4")
+	setTxTime(ctx, start.Add(day+time.Hour))
+	houseWood, _ := assetContract.GetInventory(ctx, "house", "wood")
+	assert.Equal(t, 6, houseWood.Quantity)
+
+	// Other deliveries are still checked
+	err = assetContract.updateInventory(ctx, "seller", "gold", 1, "add")
+	assert.Error(t, err)
+	ctx.stub.MockTransactionEnd("txID4")
+}
+
+func TestStorage(t *testing.T) {
+	ctx := NewMockContext()
+	assetContract := new(AssetContract)
+	tradeContract := &TradeContract{AssetContract: assetContract}
+	storageContract := &StorageContract{AssetContract: assetContract}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	ctx.stub.MockTransactionStart("txID1")
+	setTxTime(ctx, start)
+	new(AccessContract).InitAdmin(ctx)
+	new(CommodityContract).CreateCommodity(ctx, "crate", "Crate", `{"size": 5}`)
+	assetContract.initUser(ctx, "alice", 1000.0)
+	assetContract.initUser(ctx, "bob", 1000.0)
+	assetContract.initUser(ctx, "treasury", 0.0)
+	assetContract.updateInventory(ctx, "alice", "gold", 20, "add")
+
+	// Inventory is unlimited until storage is configured
+	_, err := storageContract.GetStorageUsage(ctx, "alice")
+	assert.Error(t, err)
+	err = storageContract.SetStorageConfig(ctx, 0, 10, 50.0, 0.0, 0, "")
+	assert.Error(t, err)
+	err = storageContract.SetStorageConfig(ctx, 10, 10, 50.0, 0.5, 0, "treasury")
+	assert.Error(t, err)
+	err = storageContract.SetStorageConfig(ctx, 30, 10, 50.0, 0.5, secondsPerDay, "treasury")
+	assert.NoError(t, err)
+	ctx.stub.MockTransactionEnd("txID1")
+
+	// Crates take five units each: 20 gold + 2 crates fills alice's 30 units
+	ctx.stub.MockTransactionStart("txID2")
+	setTxTime(ctx, start)
+	err = assetContract.updateInventory(ctx, "alice", "crate", 3, "add")
+	assert.Error(t, err)
+	err = assetContract.updateInventory(ctx, "alice", "crate", 2, "add")
+	assert.NoError(t, err)
+	ctx.stub.MockTransactionEnd("txID2")
+
+	ctx.stub.MockTransactionStart("txID3")
+	setTxTime(ctx, start)
+	usage, _ := storageContract.GetStorageUsage(ctx, "alice")
+	assert.Equal(t, 30, usage.Used)
+	assert.Equal(t, 30, usage.Capacity)
+
+	// Trades cannot deliver more than the buyer can store
+	assetContract.updateInventory(ctx, "bob", "gold", 20, "add")
+	tradeContract.CreateTrade(ctx, "trade1", "alice", "bob", "gold", 5, 10.0, "buy")
+	ctx.stub.MockTransactionEnd("txID3")
+
+	ctx.stub.MockTransactionStart("txID4")
+	setTxTime(ctx, start)
+	err = tradeContract.ExecuteTrade(ctx, "trade1")
+	assert.Error(t, err)
+	ctx.stub.MockTransactionEnd("txID4")
+
+	// Upgrades are paid to the fee collector
+	ctx.stub.MockTransactionStart("txID5")
+	setTxTime(ctx, start)
+	err = storageContract.PurchaseStorageUpgrade(ctx, "alice", 1)
+	assert.NoError(t, err)
+	ctx.stub.MockTransactionEnd("txID5")
+
+	ctx.stub.MockTransactionStart("txID6")
+	setTxTime(ctx, start)
+	alice, _ := assetContract.GetUserAssets(ctx, "alice")
+	assert.InDelta(t, 950.0, alice.Balance, 0.0001)
+	treasury, _ := assetContract.GetUserAssets(ctx, "treasury")
+	assert.InDelta(t, 50.0, treasury.Balance, 0.0001)
+	usage, _ = storageContract.GetStorageUsage(ctx, "alice")
+	assert.Equal(t, 40, usage.Capacity)
+	err = tradeContract.ExecuteTrade(ctx, "trade1")
+	assert.NoError(t, err)
+	ctx.stub.MockTransactionEnd("txID6")
+
+	// The first fee run starts billing; bob holds 15 gold
+	ctx.stub.MockTransactionStart("txID7")
+	setTxTime(ctx, start)
+	err = storageContract.ChargeStorageFees(ctx, `["alice", "bob"]`)
+	assert.NoError(t, err)
+	ctx.stub.MockTransactionEnd("txID7")
+
+	// Two and a half days later alice pays 2 periods of 35 units and bob 2 periods of 15
+	ctx.stub.MockTransactionStart("txID8")
+	setTxTime(ctx, start.Add(60*time.Hour))
+	setCaller(ctx, "bob")
+	err = storageContract.ChargeStorageFees(ctx, `["alice", "bob"]`)
+	assert.Error(t, err)
+	setCaller(ctx, "admin")
+	err = storageContract.ChargeStorageFees(ctx, `["alice", "bob", "alice"]`)
+	assert.NoError(t, err)
+	ctx.stub.MockTransactionEnd("txID8")
+
+	ctx.stub.MockTransactionStart("txID9")
+	setTxTime(ctx, start)
+	account, _ := storageContract.GetStorageAccount(ctx, "alice")
+	assert.InDelta(t, 35.0, account.FeesPaid, 0.0001)
+	assert.Equal(t, start.Add(2*day), account.LastFeeAt)
+	treasury, _ = assetContract.GetUserAssets(ctx, "treasury")
+	assert.InDelta(t, 100.0, treasury.Balance, 0.0001)
+	ctx.stub.MockTransactionEnd("txID9")
+
+	// Fees the available balance cannot cover are recorded as unpaid
+	ctx.stub.MockTransactionStart("txID10")
+	setTxTime(ctx, start.Add(3*day))
+	assetContract.updateBalance(ctx, "bob", 1030.0, "subtract")
+	ctx.stub.MockTransactionEnd("txID10")
+
+	ctx.stub.MockTransactionStart("txID11")
+	setTxTime(ctx, start.Add(4*day))
+	err = storageContract.ChargeStorageFees(ctx, `["bob"]`)
+	assert.NoError(t, err)
+	ctx.stub.MockTransactionEnd("txID11")
+
+	ctx.stub.MockTransactionStart("txID12")
+	setTxTime(ctx, start.Add(4*day))
+	account, _ = storageContract.GetStorageAccount(ctx, "bob")
+	assert.InDelta(t, 15.0+5.0, account.FeesPaid, 0.0001)
+	assert.InDelta(t, 10.0, account.UnpaidFees, 0.0001)
+	bob, _ := assetContract.GetUserAssets(ctx, "bob")
+	assert.InDelta(t, 0.0, bob.Balance, 0.0001)
+	ctx.stub.MockTransactionEnd("txID12")
+}
+
+// Test ShopContract
+func TestShop(t *testing.T) {
+	ctx := NewMockContext()
+	assetContract := new(AssetContract)
+	shopContract := &ShopContract{AssetContract: assetContract}
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	ctx.stub.MockTransactionStart("txID1")
+	setTxTime(ctx, start)
+	new(AccessContract).InitAdmin(ctx)
+	assetContract.initUser(ctx, "house", 1000.0)
+	assetContract.initUser(ctx, "alice", 100.0)
+	assetContract.initUser(ctx, "bob", 100.0)
+
+	err := shopContract.SetShopHouse(ctx, "nobody")
+	assert.Error(t, err)
+	err = shopContract.SetShopHouse(ctx, "house")
+	assert.NoError(t, err)
+	err = shopContract.SetShopListing(ctx, "wood", 10.0, 12.0, 10, 5, 5, secondsPerDay)
+	assert.Error(t, err)
+	err = shopContract.SetShopListing(ctx, "wood", 10.0, 4.0, 10, 5, 5, secondsPerDay)
+	assert.NoError(t, err)
+
+	// The shop starts empty and is restocked daily
+	err = shopContract.BuyFromShop(ctx, "alice", "wood", 1)
+	assert.Error(t, err)
+	ctx.stub.MockTransactionEnd("txID1")
+
+	ctx.stub.MockTransactionStart("txID2")
+	setTxTime(ctx, start.Add(day))
+	stock, _ := shopContract.GetShopStock(ctx, "wood")
+	assert.Equal(t, 5, stock)
+	err = shopContract.BuyFromShop(ctx, "alice", "wood", 3)
+	assert.NoError(t, err)
+	ctx.stub.MockTransactionEnd("txID2")
+
+	ctx.stub.MockTransactionStart("txID3")
+	setTxTime(ctx, start.Add(day+time.Hour))
+	alice, _ := assetContract.GetUserAssets(ctx, "alice")
+	assert.InDelta(t, 70.0, alice.Balance, 0.0001)
+	house, _ := assetContract.GetUserAssets(ctx, "house")
+	assert.InDelta(t, 1030.0, house.Balance, 0.0001)
+	houseWood, _ := assetContract.GetInventory(ctx, "house", "wood")
+	assert.Equal(t, 2, houseWood.Quantity)
+
+	// Purchases are limited to 5 units per user per day
+	err = shopContract.BuyFromShop(ctx, "alice", "wood", 3)
+	assert.Error(t, err)
+	purchases, _ := shopContract.GetShopPurchases(ctx, "alice", "wood", "2024-01-02")
+	assert.Equal(t, 3, purchases.Quantity)
+
+	// Selling back pays the sell price
+	err = shopContract.SellToShop(ctx, "alice", "wood", 2)
+	assert.NoError(t, err)
+	ctx.stub.MockTransactionEnd("txID3")
+
+	ctx.stub.MockTransactionStart("txID4")
+	setTxTime(ctx, start.Add(day+time.Hour))
+	alice, _ = assetContract.GetUserAssets(ctx, "alice")
+	assert.InDelta(t, 78.0, alice.Balance, 0.0001)
+	aliceWood, _ := assetContract.GetInventory(ctx, "alice", "wood")
+	assert.Equal(t, 1, aliceWood.Quantity)
+	houseWood, _ = assetContract.GetInventory(ctx, "house", "wood")
+	assert.Equal(t, 4, houseWood.Quantity)
+	ctx.stub.MockTransactionEnd("txID4")
+
+	// Restocking stops at the max stock, and the shop stops buying when full
+	ctx.stub.MockTransactionStart("txID5")
+	setTxTime(ctx, start.Add(3*day))
+	stock, _ = shopContract.GetShopStock(ctx, "wood")
+	assert.Equal(t, 10, stock)
+	err = shopContract.SellToShop(ctx, "alice", "wood", 1)
+	assert.Error(t, err)
+	err = shopContract.RemoveShopListing(ctx, "wood")
+	assert.NoError(t, err)
+	ctx.stub.MockTransactionEnd("txID5")
+
+	ctx.stub.MockTransactionStart("txID6")
+	setTxTime(ctx, start.Add(3*day))
+	err = shopContract.BuyFromShop(ctx, "bob", "wood", 1)
+	assert.Error(t, err)
+	listings, _ := shopContract.GetAllShopListings(ctx)
+	assert.Equal(t, 0, len(listings))
+	ctx.stub.MockTransactionEnd("txID6")
+}
+
+// Test LootContract
+func TestLoot(t *testing.T) {
+	ctx := NewMockContext()
+	assetContract := new(AssetContract)
+	lootContract := &LootContract{AssetContract: assetContract}
+	seedHash := sha256.Sum256([]byte("secret1"))
+
+	ctx.stub.MockTransactionStart("txID1")
+	new(AccessContract).InitAdmin(ctx)
+	assetContract.initUser(ctx, "alice", 100.0)
+	assetContract.updateInventory(ctx, "alice", "key", 1, "add")
+
+	err := lootContract.CreateLootBox(ctx, "crate", "Crate", 10.0, "", `[{"commodityId": "gem", "quantity": 1, "weight": 1}, {"commodityId": "gold", "quantity": 5, "weight": 3}]`)
+	assert.NoError(t, err)
+	err = lootContract.CreateLootBox(ctx, "chest", "Chest", 0.0, "key", `[{"commodityId": "gem", "quantity": 3, "weight": 1}]`)
+	assert.NoError(t, err)
+	err = lootContract.CreateLootBox(ctx, "empty", "Empty", 0.0, "", `[{"commodityId": "gem", "quantity": 1, "weight": 1}]`)
+	assert.Error(t, err)
+
+	// Boxes cannot be opened until a seed is committed
+	err = lootContract.OpenLootBox(ctx, "open1", "alice", "crate")
+	assert.Error(t, err)
+	err = lootContract.CommitLootSeed(ctx, "seed1", "not-a-hash", 3600)
+	assert.Error(t, err)
+	err = lootContract.CommitLootSeed(ctx, "seed1", hex.EncodeToString(seedHash[:]), 0)
+	assert.Error(t, err)
+	err = lootContract.CommitLootSeed(ctx, "seed1", hex.EncodeToString(seedHash[:]), 3600)
+	assert.NoError(t, err)
+	ctx.stub.MockTransactionEnd("txID1")
+
+	ctx.stub.MockTransactionStart("txID2")
+	err = lootContract.OpenLootBox(ctx, "open1", "alice", "crate")
+	assert.NoError(t, err)
+	ctx.stub.MockTransactionEnd("txID2")
+
+	ctx.stub.MockTransactionStart("txID3")
+	err = lootContract.OpenLootBox(ctx, "open2", "alice", "chest")
+	assert.NoError(t, err)
+	ctx.stub.MockTransactionEnd("txID3")
+
+	// Drops stay hidden until the seed is revealed
+	ctx.stub.MockTransactionStart("txID4")
+	alice, _ := assetContract.GetUserAssets(ctx, "alice")
+	assert.InDelta(t, 90.0, alice.Balance, 0.0001)
+	keys, _ := assetContract.GetInventory(ctx, "alice", "key")
+	assert.Equal(t, 0, keys.Quantity)
+	opening, _ := lootContract.GetLootOpening(ctx, "open1")
+	assert.Equal(t, "txID2", opening.TxID)
+	assert.Equal(t, "seed1", opening.SeedID)
+	err = lootContract.ClaimLootBox(ctx, "open1")
+	assert.Error(t, err)
+	err = lootContract.RefundLootOpening(ctx, "open1")
+	assert.Error(t, err)
+	_, err = lootContract.VerifyLootOpening(ctx, "open1")
+	assert.Error(t, err)
+	err = lootContract.RevealLootSeed(ctx, "seed1", "wrong")
+	assert.Error(t, err)
+	err = lootContract.RevealLootSeed(ctx, "seed1", "secret1")
+	assert.NoError(t, err)
+	ctx.stub.MockTransactionEnd("txID4")
+
+	// No more boxes are opened under a revealed seed
+	ctx.stub.MockTransactionStart("txID5")
+	err = lootContract.OpenLootBox(ctx, "open3", "alice", "crate")
+	assert.Error(t, err)
+	err = lootContract.ClaimLootBox(ctx, "open1")
+	assert.NoError(t, err)
+	ctx.stub.MockTransactionEnd("txID5")
+
+	ctx.stub.MockTransactionStart("txID6")
+	err = lootContract.ClaimLootBox(ctx, "open2")
+	assert.NoError(t, err)
+	ctx.stub.MockTransactionEnd("txID6")
+
+	// Claimed drops match the outcome recomputed from the revealed seed
+	ctx.stub.MockTransactionStart("txID7")
+	verification, err := lootContract.VerifyLootOpening(ctx, "open1")
+	assert.NoError(t, err)
+	assert.True(t, verification.SeedHashValid)
+	assert.True(t, verification.MatchesClaim)
+	opening, _ = lootContract.GetLootOpening(ctx, "open1")
+	assert.Equal(t, "claimed", opening.Status)
+	assert.Equal(t, verification.Drop, *opening.Drop)
+
+	gems, _ := assetContract.GetInventory(ctx, "alice", "gem")
+	gold, _ := assetContract.GetInventory(ctx, "alice", "gold")
+	if opening.Drop.CommodityID == "gem" {
+		assert.Equal(t, 4, gems.Quantity)
+		assert.Equal(t, 0, gold.Quantity)
+	} else {
+		assert.Equal(t, 3, gems.Quantity)
+		assert.Equal(t, 5, gold.Quantity)
+	}
+
+	err = lootContract.ClaimLootBox(ctx, "open1")
+	assert.Error(t, err)
+	openings, _ := lootContract.GetUserLootOpenings(ctx, "alice")
+	assert.Equal(t, 2, len(openings))
+	ctx.stub.MockTransactionEnd("txID7")
+}
+
+func TestLootRevealDeadline(t *testing.T) {
+	ctx := NewMockContext()
+	assetContract := new(AssetContract)
+	lootContract := &LootContract{AssetContract: assetContract}
+	seedHash := sha256.Sum256([]byte("secret1"))
+	start := time.Date(2025, 11, 7, 10, 0, 0, 0, time.UTC)
+
+	ctx.stub.MockTransactionStart("txID1")
+	setTxTime(ctx, start)
+	new(AccessContract).InitAdmin(ctx)
+	assetContract.initUser(ctx, "alice", 100.0)
+	assetContract.updateInventory(ctx, "alice", "key", 1, "add")
+	lootContract.CreateLootBox(ctx, "chest", "Chest", 10.0, "key", `[{"commodityId": "gem", "quantity": 1, "weight": 1}]`)
+	lootContract.CommitLootSeed(ctx, "seed1", hex.EncodeToString(seedHash[:]), 3600)
+	err := lootContract.OpenLootBox(ctx, "open1", "alice", "chest")
+	assert.NoError(t, err)
+	ctx.stub.MockTransactionEnd("txID1")
+
+	// Past the deadline the seed can neither open boxes nor be revealed
+	ctx.stub.MockTransactionStart("txID2")
+	setTxTime(ctx, start.Add(time.Hour))
+	assetContract.updateInventory(ctx, "alice", "key", 1, "add")
+	err = lootContract.OpenLootBox(ctx, "open2", "alice", "chest")
+	assert.Error(t, err)
+	err = lootContract.RevealLootSeed(ctx, "seed1", "secret1")
+	assert.Error(t, err)
+
+	// and its openings are refunded once
+	err = lootContract.RefundLootOpening(ctx, "open1")
+	assert.NoError(t, err)
+	err = lootContract.RefundLootOpening(ctx, "open1")
+	assert.Error(t, err)
+	opening, _ := lootContract.GetLootOpening(ctx, "open1")
+	assert.Equal(t, "refunded", opening.Status)
+	alice, _ := assetContract.GetUserAssets(ctx, "alice")
+	assert.Equal(t, 100.0, alice.Balance)
+	keys, _ := assetContract.GetInventory(ctx, "alice", "key")
+	assert.Equal(t, 2, keys.Quantity)
+	ctx.stub.MockTransactionEnd("txID2")
+}
+
+// Test SubscriptionContract
+func TestSubscriptions(t *testing.T) {
+	ctx := NewMockContext()
+	assetContract := new(AssetContract)
+	subscriptionContract := &SubscriptionContract{AssetContract: assetContract}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	ctx.stub.MockTransactionStart("txID1")
+	setTxTime(ctx, start)
+	assetContract.initUser(ctx, "alice", 25.0)
+	assetContract.initUser(ctx, "bob", 0.0)
+
+	err := subscriptionContract.CreateSubscription(ctx, "sub1", "alice", "alice", 10.0, secondsPerDay, 2)
+	assert.Error(t, err)
+	err = subscriptionContract.CreateSubscription(ctx, "sub1", "alice", "bob", 10.0, secondsPerDay, 2)
+	assert.NoError(t, err)
+	ctx.stub.MockTransactionEnd("txID1")
+
+	// The first payment falls due after one interval
+	ctx.stub.MockTransactionStart("txID2")
+	setTxTime(ctx, start.Add(12*time.Hour))
+	err = subscriptionContract.Collect(ctx, "sub1")
+	assert.Error(t, err)
+	setTxTime(ctx, start.Add(day))
+	err = subscriptionContract.Collect(ctx, "sub1")
+	assert.NoError(t, err)
+	ctx.stub.MockTransactionEnd("txID2")
+
+	ctx.stub.MockTransactionStart("txID3")
+	setTxTime(ctx, start.Add(day))
+	bob, _ := assetContract.GetUserAssets(ctx, "bob")
+	assert.InDelta(t, 10.0, bob.Balance, 0.0001)
+	err = subscriptionContract.Collect(ctx, "sub1")
+	assert.Error(t, err)
+	ctx.stub.MockTransactionEnd("txID3")
+
+	// Two payments are due but alice can only cover one
+	ctx.stub.MockTransactionStart("txID4")
+	setTxTime(ctx, start.Add(3*day))
+	err = subscriptionContract.Collect(ctx, "sub1")
+	assert.NoError(t, err)
+	ctx.stub.MockTransactionEnd("txID4")
+
+	ctx.stub.MockTransactionStart("txID5")
+	setTxTime(ctx, start.Add(3*day))
+	subscription, _ := subscriptionContract.GetSubscription(ctx, "sub1")
+	assert.Equal(t, 2, subscription.PaymentsMade)
+	assert.Equal(t, 1, subscription.FailedPayments)
+	assert.Equal(t, 1, subscription.ConsecutiveFailures)
+	assert.Equal(t, "active", subscription.Status)
+	assert.Equal(t, start.Add(4*day), subscription.NextPaymentAt)
+	alice, _ := assetContract.GetUserAssets(ctx, "alice")
+	assert.InDelta(t, 5.0, alice.Balance, 0.0001)
+	ctx.stub.MockTransactionEnd("txID5")
+
+	// Further failures suspend the subscription
+	ctx.stub.MockTransactionStart("txID6")
+	setTxTime(ctx, start.Add(5*day))
+	err = subscriptionContract.Collect(ctx, "sub1")
+	assert.NoError(t, err)
+	ctx.stub.MockTransactionEnd("txID6")
+
+	ctx.stub.MockTransactionStart("txID7")
+	setTxTime(ctx, start.Add(5*day))
+	subscription, _ = subscriptionContract.GetSubscription(ctx, "sub1")
+	assert.Equal(t, "suspended", subscription.Status)
+	assert.Equal(t, 3, subscription.ConsecutiveFailures)
+	err = subscriptionContract.Collect(ctx, "sub1")
+	assert.Error(t, err)
+	err = subscriptionContract.ResumeSubscription(ctx, "sub1")
+	assert.NoError(t, err)
+	ctx.stub.MockTransactionEnd("txID7")
+
+	ctx.stub.MockTransactionStart("txID8")
+	setTxTime(ctx, start.Add(5*day))
+	subscription, _ = subscriptionContract.GetSubscription(ctx, "sub1")
+	assert.Equal(t, "active", subscription.Status)
+	assert.Equal(t, 0, subscription.ConsecutiveFailures)
+	assert.Equal(t, start.Add(6*day), subscription.NextPaymentAt)
+	err = subscriptionContract.CancelSubscription(ctx, "sub1")
+	assert.NoError(t, err)
+	ctx.stub.MockTransactionEnd("txID8")
+
+	ctx.stub.MockTransactionStart("txID9")
+	setTxTime(ctx, start.Add(7*day))
+	err = subscriptionContract.Collect(ctx, "sub1")
+	assert.Error(t, err)
+	err = subscriptionContract.CancelSubscription(ctx, "sub1")
+	assert.Error(t, err)
+	subscriptions, _ := subscriptionContract.GetUserSubscriptions(ctx, "bob")
+	assert.Equal(t, 1, len(subscriptions))
+	ctx.stub.MockTransactionEnd("txID9")
+}
+
+// Test InterestContract
+func TestBalanceRate(t *testing.T) {
+	ctx := NewMockContext()
+	assetContract := new(AssetContract)
+	interestContract := &InterestContract{AssetContract: assetContract}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	year := time.Duration(secondsPerYear) * time.Second
+
+	ctx.stub.MockTransactionStart("txID1")
+	setTxTime(ctx, start)
+	new(AccessContract).InitAdmin(ctx)
+	assetContract.initUser(ctx, "alice", 1000.0)
+	assetContract.initUser(ctx, "bob", 1000.0)
+	err := assetContract.placeHold(ctx, "alice", "test", "ref1", 500.0, nil)
+	assert.NoError(t, err)
+	ctx.stub.MockTransactionEnd("txID1")
+
+	ctx.stub.MockTransactionStart("txID2")
+	setTxTime(ctx, start)
+	err = interestContract.SetBalanceRate(ctx, -1.5)
+	assert.Error(t, err)
+	setCaller(ctx, "bob")
+	err = interestContract.SetBalanceRate(ctx, 0.1)
+	assert.Error(t, err)
+	setCaller(ctx, "admin")
+	err = interestContract.SetBalanceRate(ctx, 0.1)
+	assert.NoError(t, err)
+	ctx.stub.MockTransactionEnd("txID2")
+
+	// Interest is applied lazily on reads, to the unlocked balance only
+	ctx.stub.MockTransactionStart("txID3")
+	setTxTime(ctx, start.Add(year))
+	alice, _ := assetContract.GetUserAssets(ctx, "alice")
+	assert.InDelta(t, 1050.0, alice.Balance, 0.0001)
+	bob, _ := assetContract.GetUserAssets(ctx, "bob")
+	assert.InDelta(t, 1100.0, bob.Balance, 0.0001)
+
+	// Changing the rate accrues every balance at the old rate first
+	err = interestContract.SetBalanceRate(ctx, -0.5)
+	assert.NoError(t, err)
+	ctx.stub.MockTransactionEnd("txID3")
+
+	ctx.stub.MockTransactionStart("txID4")
+	setTxTime(ctx, start.Add(2*year))
+	bob, _ = assetContract.GetUserAssets(ctx, "bob")
+	assert.InDelta(t, 550.0, bob.Balance, 0.0001)
+	err = interestContract.AccrueBalances(ctx)
+	assert.NoError(t, err)
+	ctx.stub.MockTransactionEnd("txID4")
+
+	// Batch accrual saves the balances
+	ctx.stub.MockTransactionStart("txID5")
+	setTxTime(ctx, start.Add(2*year))
+	aliceJSON, _ := ctx.stub.GetState(utils.GetUserAssetKey("alice"))
+	var stored models.UserAsset
+	json.Unmarshal(aliceJSON, &stored)
+	assert.InDelta(t, 775.0, stored.Balance, 0.0001)
+	assert.Equal(t, start.Add(2*year), stored.LastAccrualAt)
+	ctx.stub.MockTransactionEnd("txID5")
+
+	// A holding cost never takes more than the idle balance
+	ctx.stub.MockTransactionStart("txID6")
+	setTxTime(ctx, start.Add(5*year))
+	bob, _ = assetContract.GetUserAssets(ctx, "bob")
+	assert.InDelta(t, 0.0, bob.Balance, 0.0001)
+	alice, _ = assetContract.GetUserAssets(ctx, "alice")
+	assert.InDelta(t, 500.0, alice.Balance, 0.0001)
+	ctx.stub.MockTransactionEnd("txID6")
+}
+
+// Test EconomyContract
+func TestEconomy(t *testing.T) {
+	ctx := NewMockContext()
+	assetContract := new(AssetContract)
+	tradeContract := &TradeContract{AssetContract: assetContract}
+	redemptionContract := &RedemptionContract{AssetContract: assetContract}
+	accountContract := &AccountContract{AssetContract: assetContract}
+	economyContract := new(EconomyContract)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	ctx.stub.MockTransactionStart("txID1")
+	setTxTime(ctx, start)
+	new(AccessContract).InitAdmin(ctx)
+	assetContract.initUser(ctx, "alice", 1000.0)
+	assetContract.initUser(ctx, "bob", 1000.0)
+	assetContract.initUser(ctx, "carol", 100.0)
+	assetContract.updateInventory(ctx, "bob", "apple", 10, "add")
+	assetContract.updateInventory(ctx, "alice", "pear", 4, "add")
+	assetContract.updateBalance(ctx, "carol", 50.0, "subtract")
+	ctx.stub.MockTransactionEnd("txID1")
+
+	ctx.stub.MockTransactionStart("txID2")
+	setTxTime(ctx, start)
+	err := tradeContract.CreateTrade(ctx, "trade1", "alice", "bob", "apple", 5, 40.0, "buy")
+	assert.NoError(t, err)
+	ctx.stub.MockTransactionEnd("txID2")
+
+	ctx.stub.MockTransactionStart("txID3")
+	setTxTime(ctx, start)
+	err = tradeContract.ExecuteTrade(ctx, "trade1")
+	assert.NoError(t, err)
+	ctx.stub.MockTransactionEnd("txID3")
+
+	ctx.stub.MockTransactionStart("txID4")
+	setTxTime(ctx, start)
+	requiredItemsJSON, _ := json.Marshal([]models.RequiredItem{{CommodityID: "pear", Quantity: 4}})
+	redemptionContract.CreateRedemptionRule(ctx, "alice", string(requiredItemsJSON), 100.0)
+	err = redemptionContract.ExecuteRedemption(ctx, "alice", "record1")
+	assert.NoError(t, err)
+	ctx.stub.MockTransactionEnd("txID4")
+
+	// Running totals include the changes not yet compacted
+	ctx.stub.MockTransactionStart("txID5")
+	setTxTime(ctx, start)
+	stats, err := economyContract.GetEconomyStats(ctx)
+	assert.NoError(t, err)
+	assert.InDelta(t, 2150.0, stats.TotalBalance, 0.0001)
+	assert.Equal(t, 3, stats.Users)
+	assert.Equal(t, map[string]int{"apple": 10}, stats.Supply)
+	assert.Equal(t, 1, stats.Trades)
+	assert.Equal(t, 1, stats.Redemptions)
+	ctx.stub.MockTransactionEnd("txID5")
+
+	// Closing an account sweeps its balance and removes the user from the totals
+	ctx.stub.MockTransactionStart("txID6")
+	setTxTime(ctx, start)
+	err = accountContract.CloseAccount(ctx, "carol", "alice", "user request")
+	assert.NoError(t, err)
+	ctx.stub.MockTransactionEnd("txID6")
+
+	// Compaction is admin only and folds the deltas into the stored totals
+	ctx.stub.MockTransactionStart("txID7")
+	setTxTime(ctx, start)
+	setCaller(ctx, "bob")
+	err = economyContract.CompactEconomyStats(ctx)
+	assert.Error(t, err)
+	setCaller(ctx, "admin")
+	err = economyContract.CompactEconomyStats(ctx)
+	assert.NoError(t, err)
+	ctx.stub.MockTransactionEnd("txID7")
+
+	ctx.stub.MockTransactionStart("txID8")
+	setTxTime(ctx, start)
+	deltas, _ := ctx.stub.GetStateByPartialCompositeKey(utils.EconomyDeltaObjectType, []string{})
+	assert.False(t, deltas.HasNext())
+	deltas.Close()
+	stats, _ = economyContract.GetEconomyStats(ctx)
+	assert.InDelta(t, 2150.0, stats.TotalBalance, 0.0001)
+	assert.Equal(t, 2, stats.Users)
+	report, err := economyContract.VerifyInvariants(ctx)
+	assert.NoError(t, err)
+	assert.True(t, report.Valid)
+	assert.Empty(t, report.Mismatches)
+	ctx.stub.MockTransactionEnd("txID8")
+
+	// A write that bypasses the asset contract shows up as a mismatch
+	ctx.stub.MockTransactionStart("txID9")
+	setTxTime(ctx, start)
+	bobJSON, _ := ctx.stub.GetState(utils.GetUserAssetKey("bob"))
+	var bob models.UserAsset
+	json.Unmarshal(bobJSON, &bob)
+	bob.Balance += 500.0
+	bobJSON, _ = json.Marshal(bob)
+	ctx.stub.PutState(utils.GetUserAssetKey("bob"), bobJSON)
+	inventoryJSON, _ := json.Marshal(models.Inventory{UserID: "bob", CommodityID: "plum", Quantity: 3})
+	ctx.stub.PutState(utils.GetInventoryKey("bob", "plum"), inventoryJSON)
+	ctx.stub.MockTransactionEnd("txID9")
+
+	ctx.stub.MockTransactionStart("txID10")
+	setTxTime(ctx, start)
+	report, err = economyContract.VerifyInvariants(ctx)
+	assert.NoError(t, err)
+	assert.False(t, report.Valid)
+	assert.Equal(t, []string{
+		"total balance: expected 2150.000000, found 2650.000000",
+		"supply of plum: expected 0, found 3",
+	}, report.Mismatches)
+
+	// Rebuilding takes the totals from the ledger
+	err = economyContract.RebuildEconomyStats(ctx)
+	assert.NoError(t, err)
+	ctx.stub.MockTransactionEnd("txID10")
+
+	ctx.stub.MockTransactionStart("txID11")
+	setTxTime(ctx, start)
+	report, _ = economyContract.VerifyInvariants(ctx)
+	assert.True(t, report.Valid)
+	assert.InDelta(t, 2650.0, report.Expected.TotalBalance, 0.0001)
+	assert.Equal(t, map[string]int{"apple": 10, "plum": 3}, report.Expected.Supply)
+	ctx.stub.MockTransactionEnd("txID11")
+}
+
+// Test ReputationContract
+func TestReputation(t *testing.T) {
+	ctx := NewMockContext()
+	assetContract := new(AssetContract)
+	tradeContract := &TradeContract{AssetContract: assetContract}
+	reputationContract := &ReputationContract{TradeContract: tradeContract}
+	tradeContract.ReputationContract = reputationContract
+
+	ctx.stub.MockTransactionStart("txID1")
+	assetContract.initUser(ctx, "alice", 1000.0)
+	assetContract.initUser(ctx, "bob", 1000.0)
+	assetContract.initUser(ctx, "carol", 1000.0)
+	assetContract.updateInventory(ctx, "bob", "apple", 10, "add")
+	assetContract.updateInventory(ctx, "carol", "apple", 10, "add")
+	err := tradeContract.CreateTrade(ctx, "trade1", "alice", "bob", "apple", 2, 40.0, "buy")
+	assert.NoError(t, err)
+	err = tradeContract.CreateTrade(ctx, "trade2", "alice", "bob", "apple", 2, 40.0, "buy")
+	assert.NoError(t, err)
+	ctx.stub.MockTransactionEnd("txID1")
+
+	// Only completed trades can be rated
+	ctx.stub.MockTransactionStart("txID2")
+	err = reputationContract.RateTrade(ctx, "trade1", "alice", 5, "")
+	assert.Error(t, err)
+	err = tradeContract.ExecuteTrade(ctx, "trade1")
+	assert.NoError(t, err)
+	ctx.stub.MockTransactionEnd("txID2")
+
+	ctx.stub.MockTransactionStart("txID3")
+	err = tradeContract.RejectTrade(ctx, "trade2", "alice")
+	assert.Error(t, err)
+	err = tradeContract.RejectTrade(ctx, "trade2", "carol")
+	assert.Error(t, err)
+	err = tradeContract.RejectTrade(ctx, "trade2", "bob")
+	assert.NoError(t, err)
+	ctx.stub.MockTransactionEnd("txID3")
+
+	// Each party rates the counterparty once
+	ctx.stub.MockTransactionStart("txID4")
+	err = reputationContract.RateTrade(ctx, "trade1", "alice", 6, "")
+	assert.Error(t, err)
+	err = reputationContract.RateTrade(ctx, "trade1", "carol", 4, "")
+	assert.Error(t, err)
+	err = reputationContract.RateTrade(ctx, "trade1", "alice", 4, "fast delivery")
+	assert.NoError(t, err)
+	err = reputationContract.RateTrade(ctx, "trade1", "bob", 5, "")
+	assert.NoError(t, err)
+	err = reputationContract.RateTrade(ctx, "trade1", "alice", 5, "")
+	assert.Error(t, err)
+	err = reputationContract.RateTrade(ctx, "trade2", "alice", 1, "")
+	assert.Error(t, err)
+	ctx.stub.MockTransactionEnd("txID4")
+
+	ctx.stub.MockTransactionStart("txID5")
+	ratings, err := reputationContract.GetTradeRatings(ctx, "trade1")
+	assert.NoError(t, err)
+	assert.Len(t, ratings, 2)
+
+	bob, err := reputationContract.GetReputation(ctx, "bob")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, bob.RatingCount)
+	assert.Equal(t, 4.0, bob.AverageRating)
+	assert.Equal(t, 1, bob.SuccessfulTrades)
+	assert.Equal(t, 1, bob.RejectedTrades)
+	assert.Equal(t, 0.5, bob.CompletionRatio)
+	alice, _ := reputationContract.GetReputation(ctx, "alice")
+	assert.Equal(t, 5.0, alice.AverageRating)
+	assert.Equal(t, 0, alice.RejectedTrades)
+	assert.Equal(t, 1.0, alice.CompletionRatio)
+	carol, _ := reputationContract.GetReputation(ctx, "carol")
+	assert.Equal(t, 0, carol.RatingCount)
+	ctx.stub.MockTransactionEnd("txID5")
+
+	// A trade may require a minimum counterparty rating; unrated users have none
+	ctx.stub.MockTransactionStart("txID6")
+	err = tradeContract.CreateTradeWithMinReputation(ctx, "trade3", "alice", "carol", "apple", 1, 40.0, "buy", 3.0)
+	assert.Error(t, err)
+	err = tradeContract.CreateTradeWithMinReputation(ctx, "trade3", "alice", "bob", "apple", 1, 40.0, "buy", 4.5)
+	assert.Error(t, err)
+	err = tradeContract.CreateTradeWithMinReputation(ctx, "trade3", "alice", "bob", "apple", 1, 40.0, "buy", 6.0)
+	assert.Error(t, err)
+	err = tradeContract.CreateTradeWithMinReputation(ctx, "trade3", "alice", "bob", "apple", 1, 40.0, "buy", 4.0)
+	assert.NoError(t, err)
+	ctx.stub.MockTransactionEnd("txID6")
+}
+
+// Integration test: Complete trade flow
+func TestCompleteTradeFlow(t *testing.T) {
+	ctx := NewMockContext()
+	assetContract := new(AssetContract)
+	tradeContract := &TradeContract{AssetContract: assetContract}
+	commodityContract := new(CommodityContract)
+
+	ctx.stub.MockTransactionStart("txID1")
+	// Create commodity
+	commodityContract.CreateCommodity(ctx, "apple", "Apple", `{"type": "fruit"}`)
+
+	// Initialize users
+	assetContract.initUser(ctx, "alice", 1000.0)
+	assetContract.initUser(ctx, "bob", 1000.0)
+
+	// Give Bob some apples
+	assetContract.updateInventory(ctx, "bob", "apple", 10, "add")
+
+	fmt.Println("=== Initial State ===")
+	aliceAsset, _ := assetContract.GetUserAssets(ctx, "alice")
+	bobAsset, _ := assetContract.GetUserAssets(ctx, "bob")
+	bobInventory, _ := assetContract.GetInventory(ctx, "bob", "apple")
+	fmt.Printf("Alice Balance: %.2f\n", aliceAsset.Balance)
+	fmt.Printf("Bob Balance: %.2f, Apples: %d\n", bobAsset.Balance, bobInventory.Quantity)
+
+	// Alice wants to buy 5 apples from Bob for 200 (40 each)
+	err := tradeContract.CreateTrade(ctx, "trade123", "alice", "bob", "apple", 5, 40.0, "buy")
+	assert.NoError(t, err)
+	fmt.Println("\n=== Trade Created ===")
+
+	// Execute trade
+	err = tradeContract.ExecuteTrade(ctx, "trade123")
+	assert.NoError(t, err)
+	fmt.Println("=== Trade Executed ===")
+
+	// Verify final state
+	aliceAsset, _ = assetContract.GetUserAssets(ctx, "alice")
+	bobAsset, _ = assetContract.GetUserAssets(ctx, "bob")
+	aliceInventory, _ := assetContract.GetInventory(ctx, "alice", "apple")
+	bobInventory, _ = assetContract.GetInventory(ctx, "bob", "apple")
+
+	fmt.Println("\n=== Final State ===")
+	fmt.Printf("Alice Balance: %.2f, Apples: %d\n", aliceAsset.Balance, aliceInventory.Quantity)
+	fmt.Printf("Bob Balance: %.2f, Apples: %d\n", bobAsset.Balance, bobInventory.Quantity)
+
+	assert.Equal(t, 800.0, aliceAsset.Balance)  // 1000 - 200
+	assert.Equal(t, 1200.0, bobAsset.Balance)   // 1000 + 200
+	assert.Equal(t, 5, aliceInventory.Quantity) // 0 + 5
+	assert.Equal(t, 5, bobInventory.Quantity)   // 10 - 5
+	ctx.stub.MockTransactionEnd("txID1")
+}
+
+// Benchmark tests
+func BenchmarkInitUser(b *testing.B) {
 	ctx := NewMockContext()
 	contract := new(AssetContract)
 	ctx.stub.MockTransactionStart("txID")
@@ -465,7 +3433,7 @@ func BenchmarkInitUser(b *testing.B) {
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		userID := fmt.Sprintf("user%d", i)
-		contract.InitUser(ctx, userID, 1000.0)
+		contract.initUser(ctx, userID, 1000.0)
 	}
 	b.StopTimer()
 	ctx.stub.MockTransactionEnd("txID")
@@ -475,7 +3443,7 @@ func BenchmarkGetUserAssets(b *testing.B) {
 	ctx := NewMockContext()
 	contract := new(AssetContract)
 	ctx.stub.MockTransactionStart("setupTx")
-	contract.InitUser(ctx, "user1", 1000.0)
+	contract.initUser(ctx, "user1", 1000.0)
 	ctx.stub.MockTransactionEnd("setupTx")
 
 	b.ResetTimer()
@@ -493,9 +3461,9 @@ func BenchmarkExecuteTrade(b *testing.B) {
 		b.StopTimer()
 		ctx := NewMockContext()
 		ctx.stub.MockTransactionStart("txID")
-		assetContract.InitUser(ctx, "user1", 1000.0)
-		assetContract.InitUser(ctx, "user2", 1000.0)
-		assetContract.UpdateInventory(ctx, "user2", "commodity1", 10, "add")
+		assetContract.initUser(ctx, "user1", 1000.0)
+		assetContract.initUser(ctx, "user2", 1000.0)
+		assetContract.updateInventory(ctx, "user2", "commodity1", 10, "add")
 		tradeID := fmt.Sprintf("trade%d", i)
 		tradeContract.CreateTrade(ctx, tradeID, "user1", "user2", "commodity1", 5, 100.0, "buy")
 		b.StartTimer()
@@ -506,3 +3474,64 @@ func BenchmarkExecuteTrade(b *testing.B) {
 		ctx.stub.MockTransactionEnd("txID")
 	}
 }
+
+// Test GameplayContract
+func TestGameplayGrants(t *testing.T) {
+	ctx := NewMockContext()
+	assetContract := new(AssetContract)
+	gameplayContract := &GameplayContract{AssetContract: assetContract}
+
+	ctx.stub.MockTransactionStart("txID1")
+	setTxTime(ctx, time.Date(2025, 11, 7, 10, 0, 0, 0, time.UTC))
+	new(AccessContract).InitAdmin(ctx)
+	assert.NoError(t, new(CommodityContract).InitializeCommodities(ctx))
+
+	// New users start empty and the grants need no role
+	setCaller(ctx, "game_server")
+	assert.NoError(t, assetContract.InitUser(ctx, "alice", 0))
+	assert.NoError(t, assetContract.InitUser(ctx, "bob", 0))
+
+	kit, err := gameplayContract.ClaimStarterKit(ctx, "alice")
+	assert.NoError(t, err)
+	userAsset, _ := assetContract.GetUserAssets(ctx, "alice")
+	assert.Equal(t, StarterKitBalance, userAsset.Balance)
+	for _, item := range kit.Items {
+		assert.True(t, item.Quantity >= 1 && item.Quantity <= StarterKitMaxQuantity)
+		inventory, _ := assetContract.GetInventory(ctx, "alice", item.CommodityID)
+		assert.Equal(t, item.Quantity, inventory.Quantity)
+	}
+	_, err = gameplayContract.ClaimStarterKit(ctx, "alice")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "already received")
+
+	rule, err := gameplayContract.CreateStarterRule(ctx, "alice")
+	assert.NoError(t, err)
+	assert.True(t, len(rule.RequiredItems) >= StarterRuleMinItems && len(rule.RequiredItems) <= StarterRuleMinItems+1)
+	assert.True(t, rule.RewardAmount >= StarterRuleMinReward && rule.RewardAmount <= StarterRuleMaxReward)
+	seen := map[string]bool{}
+	for _, item := range rule.RequiredItems {
+		assert.False(t, seen[item.CommodityID])
+		seen[item.CommodityID] = true
+		assert.True(t, item.Quantity >= 1 && item.Quantity <= StarterRuleMaxQuantity)
+	}
+	stored, err := new(RedemptionContract).GetRedemptionRule(ctx, "alice")
+	assert.NoError(t, err)
+	assert.Equal(t, rule.RequiredItems, stored.RequiredItems)
+	_, err = gameplayContract.CreateStarterRule(ctx, "alice")
+	assert.Error(t, err)
+
+	// A refresh costs RefreshCost and grants RefreshItemCount units
+	items, err := gameplayContract.PurchaseRefresh(ctx, "alice")
+	assert.NoError(t, err)
+	total := 0
+	for _, item := range items {
+		total += item.Quantity
+	}
+	assert.Equal(t, RefreshItemCount, total)
+	userAsset, _ = assetContract.GetUserAssets(ctx, "alice")
+	assert.Equal(t, StarterKitBalance-RefreshCost, userAsset.Balance)
+
+	_, err = gameplayContract.PurchaseRefresh(ctx, "bob")
+	assert.Error(t, err)
+	ctx.stub.MockTransactionEnd("txID1")
+}
@@ -0,0 +1,389 @@
+package contracts
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-samples/game-chaincode/models"
+	"github.com/hyperledger/fabric-samples/game-chaincode/utils"
+)
+
+// economyTolerance is the relative difference between balance totals put down to rounding
+const economyTolerance = 1e-9
+
+// EconomyContract provides running totals of the economy and checks them
+// against the ledger. Every write of a user asset, inventory, trade or
+// redemption record stores its change as a delta keyed by the transaction, so
+// concurrent transactions never contend for a single totals record; the
+// deltas are folded into the stored totals by CompactEconomyStats.
+type EconomyContract struct {
+	contractapi.Contract
+}
+
+// GetEconomyStats returns the running totals, including changes not yet compacted.
+// Balance and items held in guild treasuries and AMM pools are not included.
+func (e *EconomyContract) GetEconomyStats(ctx contractapi.TransactionContextInterface) (*models.EconomyStats, error) {
+	stats, _, err := currentEconomyStats(ctx)
+	return stats, err
+}
+
+// CompactEconomyStats folds the pending changes into the stored totals (admin only)
+func (e *EconomyContract) CompactEconomyStats(ctx contractapi.TransactionContextInterface) error {
+	if _, err := requireRole(ctx, RoleAdmin); err != nil {
+		return err
+	}
+
+	stats, deltaKeys, err := currentEconomyStats(ctx)
+	if err != nil {
+		return err
+	}
+
+	return replaceEconomyStats(ctx, stats, deltaKeys)
+}
+
+// VerifyInvariants recomputes the totals from a full scan of the ledger and
+// reports any mismatch with the running totals (admin only)
+func (e *EconomyContract) VerifyInvariants(ctx contractapi.TransactionContextInterface) (*models.InvariantReport, error) {
+	if _, err := requireRole(ctx, RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	expected, _, err := currentEconomyStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+	actual, err := scanEconomyTotals(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.InvariantReport{
+		Expected:   *expected,
+		Actual:     *actual,
+		Mismatches: []string{},
+		CheckedAt:  timestamp,
+	}
+
+	// 1. Compare the balance and counts
+	tolerance := economyTolerance * math.Max(1, math.Abs(actual.TotalBalance))
+	if math.Abs(expected.TotalBalance-actual.TotalBalance) > tolerance {
+		report.Mismatches = append(report.Mismatches, fmt.Sprintf("total balance: expected %f, found %f", expected.TotalBalance, actual.TotalBalance))
+	}
+	if expected.Users != actual.Users {
+		report.Mismatches = append(report.Mismatches, fmt.Sprintf("users: expected %d, found %d", expected.Users, actual.Users))
+	}
+	if expected.Trades != actual.Trades {
+		report.Mismatches = append(report.Mismatches, fmt.Sprintf("trades: expected %d, found %d", expected.Trades, actual.Trades))
+	}
+	if expected.Redemptions != actual.Redemptions {
+		report.Mismatches = append(report.Mismatches, fmt.Sprintf("redemptions: expected %d, found %d", expected.Redemptions, actual.Redemptions))
+	}
+
+	// 2. Compare the supply of every commodity in either total, in a deterministic order
+	var commodityIDs []string
+	for commodityID := range expected.Supply {
+		commodityIDs = append(commodityIDs, commodityID)
+	}
+	for commodityID := range actual.Supply {
+		if _, ok := expected.Supply[commodityID]; !ok {
+			commodityIDs = append(commodityIDs, commodityID)
+		}
+	}
+	sort.Strings(commodityIDs)
+	for _, commodityID := range commodityIDs {
+		if expected.Supply[commodityID] != actual.Supply[commodityID] {
+			report.Mismatches = append(report.Mismatches, fmt.Sprintf("supply of %s: expected %d, found %d",
+				commodityID, expected.Supply[commodityID], actual.Supply[commodityID]))
+		}
+	}
+	report.Valid = len(report.Mismatches) == 0
+
+	// 3. Emit event
+	eventJSON, _ := json.Marshal(report)
+	ctx.GetStub().SetEvent("InvariantsVerified", eventJSON)
+
+	return report, nil
+}
+
+// RebuildEconomyStats replaces the running totals with totals recomputed from
+// a full scan of the ledger (admin only), for ledgers written before the
+// totals were kept or after a mismatch has been investigated
+func (e *EconomyContract) RebuildEconomyStats(ctx contractapi.TransactionContextInterface) error {
+	if _, err := requireRole(ctx, RoleAdmin); err != nil {
+		return err
+	}
+
+	_, deltaKeys, err := currentEconomyStats(ctx)
+	if err != nil {
+		return err
+	}
+	stats, err := scanEconomyTotals(ctx)
+	if err != nil {
+		return err
+	}
+
+	return replaceEconomyStats(ctx, stats, deltaKeys)
+}
+
+// currentEconomyStats returns the stored totals plus the pending deltas, and the keys of the deltas
+func currentEconomyStats(ctx contractapi.TransactionContextInterface) (*models.EconomyStats, []string, error) {
+	stats := &models.EconomyStats{Supply: map[string]int{}}
+
+	statsJSON, err := ctx.GetStub().GetState(utils.EconomyStatsKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read economy stats: %v", err)
+	}
+	if statsJSON != nil {
+		err = json.Unmarshal(statsJSON, stats)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to unmarshal economy stats: %v", err)
+		}
+		if stats.Supply == nil {
+			stats.Supply = map[string]int{}
+		}
+	}
+
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(utils.EconomyDeltaObjectType, []string{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get economy delta iterator: %v", err)
+	}
+	defer iterator.Close()
+
+	var deltaKeys []string
+	for iterator.HasNext() {
+		queryResponse, err := iterator.Next()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to iterate economy deltas: %v", err)
+		}
+
+		var delta models.EconomyDelta
+		err = json.Unmarshal(queryResponse.Value, &delta)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to unmarshal economy delta: %v", err)
+		}
+
+		stats.TotalBalance += delta.Balance
+		stats.Users += delta.Users
+		stats.Trades += delta.Trades
+		stats.Redemptions += delta.Redemptions
+		if delta.Supply != 0 {
+			stats.Supply[delta.CommodityID] += delta.Supply
+			if stats.Supply[delta.CommodityID] == 0 {
+				delete(stats.Supply, delta.CommodityID)
+			}
+		}
+		deltaKeys = append(deltaKeys, queryResponse.Key)
+	}
+
+	return stats, deltaKeys, nil
+}
+
+// replaceEconomyStats saves the stored totals and deletes the folded deltas
+func replaceEconomyStats(ctx contractapi.TransactionContextInterface, stats *models.EconomyStats, deltaKeys []string) error {
+	for _, key := range deltaKeys {
+		err := ctx.GetStub().DelState(key)
+		if err != nil {
+			return fmt.Errorf("failed to delete economy delta: %v", err)
+		}
+	}
+
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	stats.UpdatedAt = timestamp
+
+	statsJSON, err := json.Marshal(stats)
+	if err != nil {
+		return fmt.Errorf("failed to marshal economy stats: %v", err)
+	}
+	return ctx.GetStub().PutState(utils.EconomyStatsKey, statsJSON)
+}
+
+// scanEconomyTotals recomputes the totals from every user asset, inventory,
+// trade and redemption record. Balances are taken as stored, without accruing
+// the balance rate.
+func scanEconomyTotals(ctx contractapi.TransactionContextInterface) (*models.EconomyStats, error) {
+	stats := &models.EconomyStats{Supply: map[string]int{}}
+
+	// 1. Balances and users
+	iterator, err := ctx.GetStub().GetStateByRange(utils.UserAssetPrefix, utils.UserAssetPrefix+"\uffff")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user asset iterator: %v", err)
+	}
+	defer iterator.Close()
+
+	for iterator.HasNext() {
+		queryResponse, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate user assets: %v", err)
+		}
+
+		var userAsset models.UserAsset
+		err = json.Unmarshal(queryResponse.Value, &userAsset)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal user asset: %v", err)
+		}
+
+		stats.TotalBalance += userAsset.Balance
+		stats.Users++
+	}
+
+	// 2. Commodity supply
+	inventoryIterator, err := ctx.GetStub().GetStateByRange(utils.InventoryPrefix, utils.InventoryPrefix+"\uffff")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get inventory iterator: %v", err)
+	}
+	defer inventoryIterator.Close()
+
+	for inventoryIterator.HasNext() {
+		queryResponse, err := inventoryIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate inventory: %v", err)
+		}
+
+		var inventory models.Inventory
+		err = json.Unmarshal(queryResponse.Value, &inventory)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal inventory: %v", err)
+		}
+
+		if inventory.Quantity != 0 {
+			stats.Supply[inventory.CommodityID] += inventory.Quantity
+		}
+	}
+
+	// 3. Trades and redemptions
+	stats.Trades, err = countRecords(ctx, utils.TradePrefix)
+	if err != nil {
+		return nil, err
+	}
+	stats.Redemptions, err = countRecords(ctx, utils.RedemptionRecordPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// countRecords counts the records whose keys start with prefix
+func countRecords(ctx contractapi.TransactionContextInterface, prefix string) (int, error) {
+	iterator, err := ctx.GetStub().GetStateByRange(prefix, prefix+"\uffff")
+	if err != nil {
+		return 0, fmt.Errorf("failed to get %s iterator: %v", prefix, err)
+	}
+	defer iterator.Close()
+
+	count := 0
+	for iterator.HasNext() {
+		if _, err := iterator.Next(); err != nil {
+			return 0, fmt.Errorf("failed to iterate %s records: %v", prefix, err)
+		}
+		count++
+	}
+
+	return count, nil
+}
+
+// recordEconomyDelta adds a transaction's change to the totals from writing
+// the record at recordKey. Fabric reads do not see the transaction's own
+// writes, so a record written twice gets its change from the committed record
+// both times and the second delta replaces the first; where reads do see
+// earlier writes, the changes add up to the same delta.
+func recordEconomyDelta(ctx contractapi.TransactionContextInterface, recordKey string, delta models.EconomyDelta) error {
+	if delta == (models.EconomyDelta{CommodityID: delta.CommodityID}) {
+		return nil
+	}
+
+	key, err := utils.GetEconomyDeltaKey(ctx, ctx.GetStub().GetTxID(), recordKey)
+	if err != nil {
+		return fmt.Errorf("failed to create economy delta key: %v", err)
+	}
+
+	existingJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return fmt.Errorf("failed to read economy delta: %v", err)
+	}
+	if existingJSON != nil {
+		var existing models.EconomyDelta
+		err = json.Unmarshal(existingJSON, &existing)
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal economy delta: %v", err)
+		}
+		delta.Balance += existing.Balance
+		delta.Users += existing.Users
+		delta.Supply += existing.Supply
+		delta.Trades += existing.Trades
+		delta.Redemptions += existing.Redemptions
+	}
+
+	deltaJSON, err := json.Marshal(delta)
+	if err != nil {
+		return fmt.Errorf("failed to marshal economy delta: %v", err)
+	}
+	return ctx.GetStub().PutState(key, deltaJSON)
+}
+
+// recordBalanceChange records the change to the totals from saving a user's
+// asset record, or deleting it if userAsset is nil
+func recordBalanceChange(ctx contractapi.TransactionContextInterface, userID string, userAsset *models.UserAsset) error {
+	key := utils.GetUserAssetKey(userID)
+
+	var delta models.EconomyDelta
+	if userAsset != nil {
+		delta.Balance = userAsset.Balance
+		delta.Users = 1
+	}
+
+	previousJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return fmt.Errorf("failed to read user asset: %v", err)
+	}
+	if previousJSON != nil {
+		var previous models.UserAsset
+		err = json.Unmarshal(previousJSON, &previous)
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal user asset: %v", err)
+		}
+		delta.Balance -= previous.Balance
+		delta.Users--
+	}
+
+	return recordEconomyDelta(ctx, key, delta)
+}
+
+// recordSupplyChange records the change to the totals from saving a user's
+// inventory record for a commodity, or deleting it if inventory is nil
+func recordSupplyChange(ctx contractapi.TransactionContextInterface, userID, commodityID string, inventory *models.Inventory) error {
+	key := utils.GetInventoryKey(userID, commodityID)
+
+	delta := models.EconomyDelta{CommodityID: commodityID}
+	if inventory != nil {
+		delta.Supply = inventory.Quantity
+	}
+
+	previousJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return fmt.Errorf("failed to read inventory: %v", err)
+	}
+	if previousJSON != nil {
+		var previous models.Inventory
+		err = json.Unmarshal(previousJSON, &previous)
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal inventory: %v", err)
+		}
+		delta.Supply -= previous.Quantity
+	}
+
+	return recordEconomyDelta(ctx, key, delta)
+}
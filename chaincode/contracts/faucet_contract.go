@@ -0,0 +1,272 @@
+package contracts
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-samples/game-chaincode/models"
+	"github.com/hyperledger/fabric-samples/game-chaincode/utils"
+)
+
+// faucetDayFormat formats the UTC day a faucet budget applies to
+const faucetDayFormat = "2006-01-02"
+
+// FaucetContract provides time-gated daily rewards with streak bonuses and a global daily budget
+type FaucetContract struct {
+	contractapi.Contract
+	AssetContract *AssetContract
+}
+
+// SetFaucetConfig sets the faucet rules (admin only). A claim pays dailyAmount
+// plus streakBonusRate × dailyAmount for every consecutive claim beyond the
+// first, up to maxStreak, and the items in the optional dailyItemsJSON array.
+// A user may claim once per cooldown; missing a claim for two cooldowns resets
+// the streak. Balance payouts are capped at dailyBudget per UTC day, and at most
+// dailyClaimLimit claims are paid per UTC day, which caps the items paid out.
+func (f *FaucetContract) SetFaucetConfig(ctx contractapi.TransactionContextInterface, dailyAmount float64, dailyItemsJSON string, cooldownSeconds int, streakBonusRate float64, maxStreak int, dailyBudget float64, dailyClaimLimit int) error {
+	if _, err := requireRole(ctx, RoleAdmin); err != nil {
+		return err
+	}
+
+	if dailyAmount < 0 || streakBonusRate < 0 {
+		return fmt.Errorf("amounts cannot be negative")
+	}
+	if cooldownSeconds <= 0 {
+		return fmt.Errorf("cooldown must be positive")
+	}
+	if maxStreak <= 0 {
+		return fmt.Errorf("max streak must be positive")
+	}
+	if dailyBudget <= 0 {
+		return fmt.Errorf("daily budget must be positive")
+	}
+	if dailyClaimLimit <= 0 {
+		return fmt.Errorf("daily claim limit must be positive")
+	}
+
+	var dailyItems []models.RequiredItem
+	if dailyItemsJSON != "" {
+		err := json.Unmarshal([]byte(dailyItemsJSON), &dailyItems)
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal daily items: %v", err)
+		}
+		for _, item := range dailyItems {
+			if item.Quantity <= 0 {
+				return fmt.Errorf("daily item quantities must be positive")
+			}
+		}
+		dailyItems = mergeItems(dailyItems)
+	}
+	if dailyAmount == 0 && len(dailyItems) == 0 {
+		return fmt.Errorf("faucet must pay a balance or items")
+	}
+
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	config := models.FaucetConfig{
+		DailyAmount:     dailyAmount,
+		DailyItems:      dailyItems,
+		CooldownSeconds: cooldownSeconds,
+		StreakBonusRate: streakBonusRate,
+		MaxStreak:       maxStreak,
+		DailyBudget:     dailyBudget,
+		DailyClaimLimit: dailyClaimLimit,
+		UpdatedAt:       timestamp,
+	}
+
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("failed to marshal faucet config: %v", err)
+	}
+	return ctx.GetStub().PutState(utils.FaucetConfigKey, configJSON)
+}
+
+// GetFaucetConfig retrieves the faucet rules
+func (f *FaucetContract) GetFaucetConfig(ctx contractapi.TransactionContextInterface) (*models.FaucetConfig, error) {
+	configJSON, err := ctx.GetStub().GetState(utils.FaucetConfigKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read faucet config: %v", err)
+	}
+	if configJSON == nil {
+		return nil, fmt.Errorf("faucet is not configured")
+	}
+
+	var config models.FaucetConfig
+	err = json.Unmarshal(configJSON, &config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal faucet config: %v", err)
+	}
+
+	return &config, nil
+}
+
+// ClaimFaucet pays the user's daily faucet reward
+func (f *FaucetContract) ClaimFaucet(ctx contractapi.TransactionContextInterface, userID string) error {
+	config, err := f.GetFaucetConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	// 1. Enforce the cooldown and work out the streak
+	claim, err := f.GetFaucetClaim(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if timestamp.Before(claim.NextClaimAt) {
+		return fmt.Errorf("user %s cannot claim again until %s", userID, claim.NextClaimAt.Format(time.RFC3339))
+	}
+	if claim.TotalClaims > 0 && timestamp.Before(claim.StreakExpires) {
+		claim.Streak++
+	} else {
+		claim.Streak = 1
+	}
+
+	bonusStreak := claim.Streak
+	if bonusStreak > config.MaxStreak {
+		bonusStreak = config.MaxStreak
+	}
+	amount := config.DailyAmount * (1 + config.StreakBonusRate*float64(bonusStreak-1))
+
+	// 2. Enforce the global daily budget and claim limit
+	day, err := f.GetFaucetDay(ctx, timestamp.UTC().Format(faucetDayFormat))
+	if err != nil {
+		return err
+	}
+	if day.Dispensed+amount > config.DailyBudget {
+		return fmt.Errorf("faucet daily budget exhausted (%.2f of %.2f dispensed)", day.Dispensed, config.DailyBudget)
+	}
+	if config.DailyClaimLimit > 0 && day.Claims >= config.DailyClaimLimit {
+		return fmt.Errorf("faucet daily claim limit reached (%d claims)", config.DailyClaimLimit)
+	}
+
+	// 3. Pay the reward
+	// Initialize asset contract if not set
+	if f.AssetContract == nil {
+		f.AssetContract = &AssetContract{}
+	}
+
+	if amount > 0 {
+		err = f.AssetContract.updateBalance(ctx, userID, amount, "add")
+		if err != nil {
+			return fmt.Errorf("failed to add faucet balance: %v", err)
+		}
+	} else if _, err := f.AssetContract.GetUserAssets(ctx, userID); err != nil {
+		return err
+	}
+	// Configs set before daily items were merged may repeat a commodity
+	dailyItems := mergeItems(config.DailyItems)
+	err = checkStorageCapacity(ctx, f.AssetContract, userID, dailyItems)
+	if err != nil {
+		return err
+	}
+	for _, item := range dailyItems {
+		err = f.AssetContract.updateInventory(ctx, userID, item.CommodityID, item.Quantity, "add")
+		if err != nil {
+			return fmt.Errorf("failed to add faucet item %s: %v", item.CommodityID, err)
+		}
+	}
+
+	// 4. Record the claim and the day's payouts
+	cooldown := time.Duration(config.CooldownSeconds) * time.Second
+	claim.LastClaimAt = timestamp
+	claim.LastAmount = amount
+	claim.TotalClaims++
+	claim.TotalClaimed += amount
+	claim.NextClaimAt = timestamp.Add(cooldown)
+	claim.StreakExpires = timestamp.Add(2 * cooldown)
+
+	claimJSON, err := json.Marshal(claim)
+	if err != nil {
+		return fmt.Errorf("failed to marshal faucet claim: %v", err)
+	}
+	err = ctx.GetStub().PutState(utils.GetFaucetClaimKey(userID), claimJSON)
+	if err != nil {
+		return fmt.Errorf("failed to save faucet claim: %v", err)
+	}
+
+	day.Dispensed += amount
+	day.Claims++
+	for _, item := range dailyItems {
+		if day.ItemsDispensed == nil {
+			day.ItemsDispensed = map[string]int{}
+		}
+		day.ItemsDispensed[item.CommodityID] += item.Quantity
+	}
+
+	dayJSON, err := json.Marshal(day)
+	if err != nil {
+		return fmt.Errorf("failed to marshal faucet day: %v", err)
+	}
+	err = ctx.GetStub().PutState(utils.GetFaucetDayKey(day.Day), dayJSON)
+	if err != nil {
+		return fmt.Errorf("failed to save faucet day: %v", err)
+	}
+
+	// 5. Emit event
+	eventPayload := map[string]interface{}{
+		"userId":    userID,
+		"amount":    amount,
+		"items":     dailyItems,
+		"streak":    claim.Streak,
+		"timestamp": timestamp,
+	}
+	eventJSON, _ := json.Marshal(eventPayload)
+	ctx.GetStub().SetEvent("FaucetClaimed", eventJSON)
+
+	return nil
+}
+
+// GetFaucetClaim retrieves a user's faucet claim history
+func (f *FaucetContract) GetFaucetClaim(ctx contractapi.TransactionContextInterface, userID string) (*models.FaucetClaim, error) {
+	claimJSON, err := ctx.GetStub().GetState(utils.GetFaucetClaimKey(userID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read faucet claim: %v", err)
+	}
+	if claimJSON == nil {
+		// Return empty history instead of error
+		return &models.FaucetClaim{UserID: userID}, nil
+	}
+
+	var claim models.FaucetClaim
+	err = json.Unmarshal(claimJSON, &claim)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal faucet claim: %v", err)
+	}
+
+	return &claim, nil
+}
+
+// GetFaucetDay retrieves the faucet payouts of a UTC day (YYYY-MM-DD)
+func (f *FaucetContract) GetFaucetDay(ctx contractapi.TransactionContextInterface, day string) (*models.FaucetDay, error) {
+	if _, err := time.Parse(faucetDayFormat, day); err != nil {
+		return nil, fmt.Errorf("invalid day %s (expected YYYY-MM-DD)", day)
+	}
+
+	dayJSON, err := ctx.GetStub().GetState(utils.GetFaucetDayKey(day))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read faucet day: %v", err)
+	}
+	if dayJSON == nil {
+		return &models.FaucetDay{Day: day}, nil
+	}
+
+	var faucetDay models.FaucetDay
+	err = json.Unmarshal(dayJSON, &faucetDay)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal faucet day: %v", err)
+	}
+
+	return &faucetDay, nil
+}
@@ -0,0 +1,255 @@
+package contracts
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-samples/game-chaincode/models"
+	"github.com/hyperledger/fabric-samples/game-chaincode/utils"
+)
+
+// Bounds of the gameplay grants. Their contents are rolled on chain so that
+// callers cannot choose what they receive.
+const (
+	StarterKitBalance      = 100000.0 // starting balance paid with the starter kit
+	StarterKitMaxQuantity  = 9        // each commodity is granted 0 to this many units
+	StarterRuleMinItems    = 2        // a starter redemption rule requires this many commodities or one more
+	StarterRuleMaxQuantity = 7        // each required commodity needs 1 to this many units
+	StarterRuleMinReward   = 2000     // starter redemption rules pay between these amounts
+	StarterRuleMaxReward   = 5000
+	RefreshCost            = 500.0
+	RefreshItemCount       = 5 // single units of random commodities granted by a refresh
+)
+
+// GameplayContract provides the game's item grants: a one-time starter kit, a
+// starter redemption rule and paid refreshes. They are open to the game server
+// without an admin role because their contents and amounts are fixed or rolled
+// on chain within the bounds above.
+type GameplayContract struct {
+	contractapi.Contract
+	AssetContract      *AssetContract
+	RedemptionContract *RedemptionContract
+}
+
+// ClaimStarterKit pays a user the StarterKitBalance and grants 0 to
+// StarterKitMaxQuantity units of every commodity. Each user can receive the
+// starter kit once; new users are created with a zero balance and claim it.
+func (g *GameplayContract) ClaimStarterKit(ctx contractapi.TransactionContextInterface, userID string) (*models.StarterKit, error) {
+	existing, err := g.GetStarterKit(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, fmt.Errorf("user %s has already received the starter kit", userID)
+	}
+
+	// Initialize asset contract if not set
+	if g.AssetContract == nil {
+		g.AssetContract = &AssetContract{}
+	}
+
+	if _, err := g.AssetContract.GetUserAssets(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	commodities, err := new(CommodityContract).GetAllCommodities(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []models.RequiredItem
+	for _, commodity := range commodities {
+		quantity := gameplayRoll(ctx, "starter_kit", userID, commodity.CommodityID) % (StarterKitMaxQuantity + 1)
+		if quantity > 0 {
+			items = append(items, models.RequiredItem{CommodityID: commodity.CommodityID, Quantity: quantity})
+		}
+	}
+
+	err = checkStorageCapacity(ctx, g.AssetContract, userID, items)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		err = g.AssetContract.updateInventory(ctx, userID, item.CommodityID, item.Quantity, "add")
+		if err != nil {
+			return nil, fmt.Errorf("failed to add starter item %s: %v", item.CommodityID, err)
+		}
+	}
+	err = g.AssetContract.updateBalance(ctx, userID, StarterKitBalance, "add")
+	if err != nil {
+		return nil, fmt.Errorf("failed to add starter balance: %v", err)
+	}
+
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	kit := models.StarterKit{
+		UserID:    userID,
+		Balance:   StarterKitBalance,
+		Items:     items,
+		GrantedAt: timestamp,
+	}
+
+	kitJSON, err := json.Marshal(kit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal starter kit: %v", err)
+	}
+	err = ctx.GetStub().PutState(utils.GetStarterKitKey(userID), kitJSON)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save starter kit: %v", err)
+	}
+
+	return &kit, nil
+}
+
+// GetStarterKit retrieves the starter kit granted to a user, or nil if the user has not received it
+func (g *GameplayContract) GetStarterKit(ctx contractapi.TransactionContextInterface, userID string) (*models.StarterKit, error) {
+	kitJSON, err := ctx.GetStub().GetState(utils.GetStarterKitKey(userID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read starter kit: %v", err)
+	}
+	if kitJSON == nil {
+		return nil, nil
+	}
+
+	var kit models.StarterKit
+	err = json.Unmarshal(kitJSON, &kit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal starter kit: %v", err)
+	}
+
+	return &kit, nil
+}
+
+// CreateStarterRule creates a user's redemption rule with StarterRuleMinItems or
+// one more distinct commodities, 1 to StarterRuleMaxQuantity units of each, and
+// a reward between StarterRuleMinReward and StarterRuleMaxReward
+func (g *GameplayContract) CreateStarterRule(ctx contractapi.TransactionContextInterface, userID string) (*models.RedemptionRule, error) {
+	// Initialize asset contract if not set
+	if g.AssetContract == nil {
+		g.AssetContract = &AssetContract{}
+	}
+
+	if _, err := g.AssetContract.GetUserAssets(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	commodities, err := new(CommodityContract).GetAllCommodities(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(commodities) < StarterRuleMinItems {
+		return nil, fmt.Errorf("at least %d commodities are needed for a starter rule", StarterRuleMinItems)
+	}
+
+	numItems := StarterRuleMinItems
+	if len(commodities) > StarterRuleMinItems {
+		numItems += gameplayRoll(ctx, "starter_rule_items", userID) % 2
+	}
+
+	// Pick distinct commodities by removing each pick from the candidates
+	candidates := append([]*models.Commodity(nil), commodities...)
+	var requiredItems []models.RequiredItem
+	for i := 0; i < numItems; i++ {
+		index := gameplayRoll(ctx, "starter_rule_pick", userID, fmt.Sprint(i)) % len(candidates)
+		quantity := gameplayRoll(ctx, "starter_rule_quantity", userID, fmt.Sprint(i))%StarterRuleMaxQuantity + 1
+		requiredItems = append(requiredItems, models.RequiredItem{CommodityID: candidates[index].CommodityID, Quantity: quantity})
+		candidates = append(candidates[:index], candidates[index+1:]...)
+	}
+	reward := float64(StarterRuleMinReward + gameplayRoll(ctx, "starter_rule_reward", userID)%(StarterRuleMaxReward-StarterRuleMinReward+1))
+
+	requiredItemsJSON, err := json.Marshal(requiredItems)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal required items: %v", err)
+	}
+
+	// Initialize redemption contract if not set
+	if g.RedemptionContract == nil {
+		g.RedemptionContract = &RedemptionContract{AssetContract: g.AssetContract}
+	}
+
+	err = g.RedemptionContract.createRule(ctx, userID, string(requiredItemsJSON), reward, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.RedemptionRule{
+		RuleID:        fmt.Sprintf("rule_%s", userID),
+		UserID:        userID,
+		RequiredItems: requiredItems,
+		RewardAmount:  reward,
+	}, nil
+}
+
+// PurchaseRefresh charges a user RefreshCost and grants single units of
+// RefreshItemCount random commodities, returning the items granted
+func (g *GameplayContract) PurchaseRefresh(ctx contractapi.TransactionContextInterface, userID string) ([]models.RequiredItem, error) {
+	commodities, err := new(CommodityContract).GetAllCommodities(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(commodities) == 0 {
+		return nil, fmt.Errorf("no commodities available")
+	}
+
+	// Roll the items, merging repeated commodities so each inventory is written once
+	var items []models.RequiredItem
+	positions := map[string]int{}
+	for i := 0; i < RefreshItemCount; i++ {
+		commodityID := commodities[gameplayRoll(ctx, "refresh", userID, fmt.Sprint(i))%len(commodities)].CommodityID
+		if position, ok := positions[commodityID]; ok {
+			items[position].Quantity++
+			continue
+		}
+		positions[commodityID] = len(items)
+		items = append(items, models.RequiredItem{CommodityID: commodityID, Quantity: 1})
+	}
+
+	// Initialize asset contract if not set
+	if g.AssetContract == nil {
+		g.AssetContract = &AssetContract{}
+	}
+
+	err = g.AssetContract.updateBalance(ctx, userID, RefreshCost, "subtract")
+	if err != nil {
+		return nil, fmt.Errorf("failed to charge refresh: %v", err)
+	}
+
+	err = checkStorageCapacity(ctx, g.AssetContract, userID, items)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		err = g.AssetContract.updateInventory(ctx, userID, item.CommodityID, item.Quantity, "add")
+		if err != nil {
+			return nil, fmt.Errorf("failed to add refresh item %s: %v", item.CommodityID, err)
+		}
+	}
+
+	eventPayload := map[string]interface{}{
+		"userId": userID,
+		"cost":   RefreshCost,
+		"items":  items,
+	}
+	eventJSON, _ := json.Marshal(eventPayload)
+	ctx.GetStub().SetEvent("RefreshPurchased", eventJSON)
+
+	return items, nil
+}
+
+// gameplayRoll reads the first 8 bytes of the SHA-256 of "txID:label:..." as a
+// big-endian number, shifted right by one bit to keep it non-negative
+func gameplayRoll(ctx contractapi.TransactionContextInterface, labels ...string) int {
+	seed := ctx.GetStub().GetTxID()
+	for _, label := range labels {
+		seed += ":" + label
+	}
+	hash := sha256.Sum256([]byte(seed))
+	return int(binary.BigEndian.Uint64(hash[:8]) >> 1)
+}
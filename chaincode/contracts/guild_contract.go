@@ -0,0 +1,924 @@
+package contracts
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-samples/game-chaincode/models"
+	"github.com/hyperledger/fabric-samples/game-chaincode/utils"
+)
+
+// Guild member roles
+const (
+	GuildRoleLeader  = "leader"
+	GuildRoleOfficer = "officer"
+	GuildRoleMember  = "member"
+)
+
+// guildRoleRank orders the guild roles; higher ranks may manage lower ones
+var guildRoleRank = map[string]int{
+	GuildRoleMember:  1,
+	GuildRoleOfficer: 2,
+	GuildRoleLeader:  3,
+}
+
+// guildDayFormat formats the UTC day member withdrawal limits apply to
+const guildDayFormat = "2006-01-02"
+
+// GuildContract provides guilds with member roles and a shared treasury
+type GuildContract struct {
+	contractapi.Contract
+	AssetContract *AssetContract
+}
+
+// CreateGuild creates a guild led by leaderID
+func (g *GuildContract) CreateGuild(ctx contractapi.TransactionContextInterface, guildID, name, leaderID string) error {
+	if guildID == "" {
+		return fmt.Errorf("guild ID cannot be empty")
+	}
+
+	existing, err := g.GetGuild(ctx, guildID)
+	if err == nil && existing != nil {
+		return fmt.Errorf("guild %s already exists", guildID)
+	}
+
+	err = g.checkCanJoin(ctx, leaderID)
+	if err != nil {
+		return err
+	}
+
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	guild := models.Guild{
+		GuildID:     guildID,
+		Name:        name,
+		LeaderID:    leaderID,
+		MemberCount: 1,
+		CreatedAt:   timestamp,
+	}
+
+	// Create the empty treasury
+	treasury := models.UserAsset{
+		UserID:    guildID,
+		Balance:   0,
+		UpdatedAt: timestamp,
+	}
+	err = g.putTreasury(ctx, &treasury)
+	if err != nil {
+		return err
+	}
+
+	err = g.addMember(ctx, &guild, leaderID, GuildRoleLeader)
+	if err != nil {
+		return err
+	}
+
+	err = g.logActivity(ctx, &guild, models.GuildActivity{ActorID: leaderID, Action: "create"})
+	if err != nil {
+		return err
+	}
+	return g.putGuild(ctx, &guild)
+}
+
+// InviteMember invites a user to the guild (leader or officer)
+func (g *GuildContract) InviteMember(ctx contractapi.TransactionContextInterface, guildID, actorID, userID string) error {
+	guild, err := g.GetGuild(ctx, guildID)
+	if err != nil {
+		return err
+	}
+
+	_, err = g.requireGuildRole(ctx, guildID, actorID, GuildRoleOfficer)
+	if err != nil {
+		return err
+	}
+
+	err = g.checkCanJoin(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	invite := models.GuildInvite{
+		GuildID:   guildID,
+		UserID:    userID,
+		InvitedBy: actorID,
+		InvitedAt: timestamp,
+	}
+
+	inviteJSON, err := json.Marshal(invite)
+	if err != nil {
+		return fmt.Errorf("failed to marshal guild invite: %v", err)
+	}
+	inviteKey, err := utils.GetGuildInviteKey(ctx, guildID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to create guild invite key: %v", err)
+	}
+	err = ctx.GetStub().PutState(inviteKey, inviteJSON)
+	if err != nil {
+		return fmt.Errorf("failed to save guild invite: %v", err)
+	}
+
+	err = g.logActivity(ctx, guild, models.GuildActivity{ActorID: actorID, Action: "invite", TargetID: userID})
+	if err != nil {
+		return err
+	}
+	return g.putGuild(ctx, guild)
+}
+
+// JoinGuild accepts an invitation and joins the guild as a member
+func (g *GuildContract) JoinGuild(ctx contractapi.TransactionContextInterface, guildID, userID string) error {
+	guild, err := g.GetGuild(ctx, guildID)
+	if err != nil {
+		return err
+	}
+
+	inviteKey, err := utils.GetGuildInviteKey(ctx, guildID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to create guild invite key: %v", err)
+	}
+	inviteJSON, err := ctx.GetStub().GetState(inviteKey)
+	if err != nil {
+		return fmt.Errorf("failed to read guild invite: %v", err)
+	}
+	if inviteJSON == nil {
+		return fmt.Errorf("user %s has not been invited to guild %s", userID, guildID)
+	}
+
+	err = g.checkCanJoin(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().DelState(inviteKey)
+	if err != nil {
+		return fmt.Errorf("failed to delete guild invite: %v", err)
+	}
+
+	err = g.addMember(ctx, guild, userID, GuildRoleMember)
+	if err != nil {
+		return err
+	}
+	guild.MemberCount++
+
+	err = g.logActivity(ctx, guild, models.GuildActivity{ActorID: userID, Action: "join"})
+	if err != nil {
+		return err
+	}
+	return g.putGuild(ctx, guild)
+}
+
+// LeaveGuild removes the user from the guild. The leader must hand over
+// leadership before leaving.
+func (g *GuildContract) LeaveGuild(ctx contractapi.TransactionContextInterface, guildID, userID string) error {
+	guild, err := g.GetGuild(ctx, guildID)
+	if err != nil {
+		return err
+	}
+
+	member, err := g.GetGuildMember(ctx, guildID, userID)
+	if err != nil {
+		return err
+	}
+	if member.Role == GuildRoleLeader {
+		return fmt.Errorf("the guild leader must transfer leadership before leaving")
+	}
+
+	err = g.removeMember(ctx, guild, userID)
+	if err != nil {
+		return err
+	}
+
+	err = g.logActivity(ctx, guild, models.GuildActivity{ActorID: userID, Action: "leave"})
+	if err != nil {
+		return err
+	}
+	return g.putGuild(ctx, guild)
+}
+
+// KickMember removes a lower-ranked member from the guild (leader or officer)
+func (g *GuildContract) KickMember(ctx contractapi.TransactionContextInterface, guildID, actorID, userID string) error {
+	guild, err := g.GetGuild(ctx, guildID)
+	if err != nil {
+		return err
+	}
+
+	actor, err := g.requireGuildRole(ctx, guildID, actorID, GuildRoleOfficer)
+	if err != nil {
+		return err
+	}
+
+	member, err := g.GetGuildMember(ctx, guildID, userID)
+	if err != nil {
+		return err
+	}
+	if guildRoleRank[member.Role] >= guildRoleRank[actor.Role] {
+		return fmt.Errorf("%s cannot kick %s (role %s)", actorID, userID, member.Role)
+	}
+
+	err = g.removeMember(ctx, guild, userID)
+	if err != nil {
+		return err
+	}
+
+	err = g.logActivity(ctx, guild, models.GuildActivity{ActorID: actorID, Action: "kick", TargetID: userID})
+	if err != nil {
+		return err
+	}
+	return g.putGuild(ctx, guild)
+}
+
+// SetMemberRole changes a member's role (leader only). Making another member
+// leader transfers leadership; the previous leader becomes an officer.
+func (g *GuildContract) SetMemberRole(ctx contractapi.TransactionContextInterface, guildID, actorID, userID, role string) error {
+	guild, err := g.GetGuild(ctx, guildID)
+	if err != nil {
+		return err
+	}
+
+	actor, err := g.requireGuildRole(ctx, guildID, actorID, GuildRoleLeader)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := guildRoleRank[role]; !ok {
+		return fmt.Errorf("invalid guild role: %s (must be 'leader', 'officer' or 'member')", role)
+	}
+	if actorID == userID {
+		return fmt.Errorf("the guild leader cannot change their own role")
+	}
+
+	member, err := g.GetGuildMember(ctx, guildID, userID)
+	if err != nil {
+		return err
+	}
+
+	member.Role = role
+	err = g.putMember(ctx, member)
+	if err != nil {
+		return err
+	}
+
+	if role == GuildRoleLeader {
+		actor.Role = GuildRoleOfficer
+		err = g.putMember(ctx, actor)
+		if err != nil {
+			return err
+		}
+		guild.LeaderID = userID
+	}
+
+	err = g.logActivity(ctx, guild, models.GuildActivity{ActorID: actorID, Action: "set_role:" + role, TargetID: userID})
+	if err != nil {
+		return err
+	}
+	return g.putGuild(ctx, guild)
+}
+
+// SetMemberLimits sets how much balance and how many items a member may
+// withdraw from the treasury per UTC day (leader only)
+func (g *GuildContract) SetMemberLimits(ctx contractapi.TransactionContextInterface, guildID, actorID, userID string, withdrawLimit float64, itemWithdrawLimit int) error {
+	guild, err := g.GetGuild(ctx, guildID)
+	if err != nil {
+		return err
+	}
+
+	_, err = g.requireGuildRole(ctx, guildID, actorID, GuildRoleLeader)
+	if err != nil {
+		return err
+	}
+
+	if withdrawLimit < 0 || itemWithdrawLimit < 0 {
+		return fmt.Errorf("limits cannot be negative")
+	}
+
+	member, err := g.GetGuildMember(ctx, guildID, userID)
+	if err != nil {
+		return err
+	}
+
+	member.WithdrawLimit = withdrawLimit
+	member.ItemWithdrawLimit = itemWithdrawLimit
+	err = g.putMember(ctx, member)
+	if err != nil {
+		return err
+	}
+
+	err = g.logActivity(ctx, guild, models.GuildActivity{ActorID: actorID, Action: "set_limits", TargetID: userID, Amount: withdrawLimit, Quantity: itemWithdrawLimit})
+	if err != nil {
+		return err
+	}
+	return g.putGuild(ctx, guild)
+}
+
+// DepositBalance moves balance from a member to the guild treasury
+func (g *GuildContract) DepositBalance(ctx contractapi.TransactionContextInterface, guildID, userID string, amount float64) error {
+	guild, err := g.GetGuild(ctx, guildID)
+	if err != nil {
+		return err
+	}
+
+	if _, err := g.GetGuildMember(ctx, guildID, userID); err != nil {
+		return err
+	}
+
+	if amount <= 0 {
+		return fmt.Errorf("amount must be positive")
+	}
+
+	// Initialize asset contract if not set
+	if g.AssetContract == nil {
+		g.AssetContract = &AssetContract{}
+	}
+
+	err = g.AssetContract.updateBalance(ctx, userID, amount, "subtract")
+	if err != nil {
+		return fmt.Errorf("failed to debit member balance: %v", err)
+	}
+
+	treasury, err := g.GetGuildTreasury(ctx, guildID)
+	if err != nil {
+		return err
+	}
+	treasury.Balance += amount
+	err = g.putTreasury(ctx, treasury)
+	if err != nil {
+		return err
+	}
+
+	err = g.logActivity(ctx, guild, models.GuildActivity{ActorID: userID, Action: "deposit", Amount: amount})
+	if err != nil {
+		return err
+	}
+	return g.putGuild(ctx, guild)
+}
+
+// DepositItems moves items from a member's inventory to the guild treasury
+func (g *GuildContract) DepositItems(ctx contractapi.TransactionContextInterface, guildID, userID, commodityID string, quantity int) error {
+	guild, err := g.GetGuild(ctx, guildID)
+	if err != nil {
+		return err
+	}
+
+	if _, err := g.GetGuildMember(ctx, guildID, userID); err != nil {
+		return err
+	}
+
+	if quantity <= 0 {
+		return fmt.Errorf("quantity must be positive")
+	}
+
+	// Initialize asset contract if not set
+	if g.AssetContract == nil {
+		g.AssetContract = &AssetContract{}
+	}
+
+	lots, err := g.AssetContract.takeItems(ctx, userID, commodityID, quantity)
+	if err != nil {
+		return fmt.Errorf("failed to debit member inventory: %v", err)
+	}
+
+	// The treasury keeps the items' lots so they keep spoiling
+	inventory, err := g.getTreasuryInventory(ctx, guildID, commodityID)
+	if err != nil {
+		return err
+	}
+	inventory.Quantity += quantity
+	mergeLots(inventory, lots)
+	err = g.putTreasuryInventory(ctx, inventory)
+	if err != nil {
+		return err
+	}
+
+	err = g.logActivity(ctx, guild, models.GuildActivity{ActorID: userID, Action: "deposit_items", CommodityID: commodityID, Quantity: quantity})
+	if err != nil {
+		return err
+	}
+	return g.putGuild(ctx, guild)
+}
+
+// WithdrawBalance moves balance from the guild treasury to a member, within
+// the member's daily limit (the leader is not limited)
+func (g *GuildContract) WithdrawBalance(ctx contractapi.TransactionContextInterface, guildID, userID string, amount float64) error {
+	guild, err := g.GetGuild(ctx, guildID)
+	if err != nil {
+		return err
+	}
+
+	member, err := g.GetGuildMember(ctx, guildID, userID)
+	if err != nil {
+		return err
+	}
+
+	if amount <= 0 {
+		return fmt.Errorf("amount must be positive")
+	}
+
+	treasury, err := g.GetGuildTreasury(ctx, guildID)
+	if err != nil {
+		return err
+	}
+	if treasury.Balance < amount {
+		return fmt.Errorf("guild treasury has insufficient balance")
+	}
+
+	err = g.chargeWithdrawal(ctx, member, amount, 0)
+	if err != nil {
+		return err
+	}
+
+	treasury.Balance -= amount
+	err = g.putTreasury(ctx, treasury)
+	if err != nil {
+		return err
+	}
+
+	// Initialize asset contract if not set
+	if g.AssetContract == nil {
+		g.AssetContract = &AssetContract{}
+	}
+
+	err = g.AssetContract.updateBalance(ctx, userID, amount, "add")
+	if err != nil {
+		return fmt.Errorf("failed to credit member balance: %v", err)
+	}
+
+	err = g.logActivity(ctx, guild, models.GuildActivity{ActorID: userID, Action: "withdraw", Amount: amount})
+	if err != nil {
+		return err
+	}
+	return g.putGuild(ctx, guild)
+}
+
+// WithdrawItems moves items from the guild treasury to a member, within the
+// member's daily limit (the leader is not limited)
+func (g *GuildContract) WithdrawItems(ctx contractapi.TransactionContextInterface, guildID, userID, commodityID string, quantity int) error {
+	guild, err := g.GetGuild(ctx, guildID)
+	if err != nil {
+		return err
+	}
+
+	member, err := g.GetGuildMember(ctx, guildID, userID)
+	if err != nil {
+		return err
+	}
+
+	if quantity <= 0 {
+		return fmt.Errorf("quantity must be positive")
+	}
+
+	inventory, err := g.getTreasuryInventory(ctx, guildID, commodityID)
+	if err != nil {
+		return err
+	}
+	if inventory.Quantity < quantity {
+		return fmt.Errorf("guild treasury has insufficient inventory of %s", commodityID)
+	}
+
+	err = g.chargeWithdrawal(ctx, member, 0, quantity)
+	if err != nil {
+		return err
+	}
+
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	lots := takeFromLots(inventory, quantity, timestamp)
+	err = g.putTreasuryInventory(ctx, inventory)
+	if err != nil {
+		return err
+	}
+
+	// Initialize asset contract if not set
+	if g.AssetContract == nil {
+		g.AssetContract = &AssetContract{}
+	}
+
+	err = g.AssetContract.giveItems(ctx, userID, commodityID, quantity, lots)
+	if err != nil {
+		return fmt.Errorf("failed to credit member inventory: %v", err)
+	}
+
+	err = g.logActivity(ctx, guild, models.GuildActivity{ActorID: userID, Action: "withdraw_items", CommodityID: commodityID, Quantity: quantity})
+	if err != nil {
+		return err
+	}
+	return g.putGuild(ctx, guild)
+}
+
+// GetGuild retrieves a guild by ID
+func (g *GuildContract) GetGuild(ctx contractapi.TransactionContextInterface, guildID string) (*models.Guild, error) {
+	guildJSON, err := ctx.GetStub().GetState(utils.GetGuildKey(guildID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read guild: %v", err)
+	}
+	if guildJSON == nil {
+		return nil, fmt.Errorf("guild %s not found", guildID)
+	}
+
+	var guild models.Guild
+	err = json.Unmarshal(guildJSON, &guild)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal guild: %v", err)
+	}
+
+	return &guild, nil
+}
+
+// GetUserGuild retrieves the guild a user belongs to
+func (g *GuildContract) GetUserGuild(ctx contractapi.TransactionContextInterface, userID string) (*models.Guild, error) {
+	guild, err := g.findUserGuild(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if guild == nil {
+		return nil, fmt.Errorf("user %s is not in a guild", userID)
+	}
+
+	return guild, nil
+}
+
+// findUserGuild retrieves the guild a user belongs to, or nil if they are in none
+func (g *GuildContract) findUserGuild(ctx contractapi.TransactionContextInterface, userID string) (*models.Guild, error) {
+	guildID, err := ctx.GetStub().GetState(utils.GetGuildMembershipKey(userID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read guild membership: %v", err)
+	}
+	if guildID == nil {
+		return nil, nil
+	}
+
+	return g.GetGuild(ctx, string(guildID))
+}
+
+// GetGuildMember retrieves a member of a guild
+func (g *GuildContract) GetGuildMember(ctx contractapi.TransactionContextInterface, guildID, userID string) (*models.GuildMember, error) {
+	key, err := utils.GetGuildMemberKey(ctx, guildID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create guild member key: %v", err)
+	}
+	memberJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read guild member: %v", err)
+	}
+	if memberJSON == nil {
+		return nil, fmt.Errorf("user %s is not a member of guild %s", userID, guildID)
+	}
+
+	var member models.GuildMember
+	err = json.Unmarshal(memberJSON, &member)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal guild member: %v", err)
+	}
+
+	return &member, nil
+}
+
+// GetGuildMembers retrieves every member of a guild
+func (g *GuildContract) GetGuildMembers(ctx contractapi.TransactionContextInterface, guildID string) ([]*models.GuildMember, error) {
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(utils.GuildMemberObjectType, []string{guildID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get guild member iterator: %v", err)
+	}
+	defer iterator.Close()
+
+	var members []*models.GuildMember
+	for iterator.HasNext() {
+		queryResponse, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate guild members: %v", err)
+		}
+
+		var member models.GuildMember
+		err = json.Unmarshal(queryResponse.Value, &member)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal guild member: %v", err)
+		}
+
+		members = append(members, &member)
+	}
+
+	return members, nil
+}
+
+// GetGuildTreasury retrieves a guild's treasury balance
+func (g *GuildContract) GetGuildTreasury(ctx contractapi.TransactionContextInterface, guildID string) (*models.UserAsset, error) {
+	treasuryJSON, err := ctx.GetStub().GetState(utils.GetGuildAssetKey(guildID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read guild treasury: %v", err)
+	}
+	if treasuryJSON == nil {
+		return nil, fmt.Errorf("guild treasury not found for guild %s", guildID)
+	}
+
+	var treasury models.UserAsset
+	err = json.Unmarshal(treasuryJSON, &treasury)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal guild treasury: %v", err)
+	}
+
+	return &treasury, nil
+}
+
+// GetGuildInventory retrieves all items held in a guild's treasury
+func (g *GuildContract) GetGuildInventory(ctx contractapi.TransactionContextInterface, guildID string) ([]*models.Inventory, error) {
+	// Use range query to get all inventory items for a guild
+	startKey := fmt.Sprintf("%s%s_", utils.GuildInventoryPrefix, guildID)
+	endKey := fmt.Sprintf("%s%s_\uffff", utils.GuildInventoryPrefix, guildID)
+
+	iterator, err := ctx.GetStub().GetStateByRange(startKey, endKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get guild inventory iterator: %v", err)
+	}
+	defer iterator.Close()
+
+	var inventories []*models.Inventory
+	for iterator.HasNext() {
+		queryResponse, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate guild inventory: %v", err)
+		}
+
+		var inventory models.Inventory
+		err = json.Unmarshal(queryResponse.Value, &inventory)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal guild inventory: %v", err)
+		}
+
+		// Skip guilds whose ID extends this one
+		if inventory.UserID == guildID && inventory.Quantity > 0 {
+			inventories = append(inventories, &inventory)
+		}
+	}
+
+	return inventories, nil
+}
+
+// GetGuildActivity retrieves a guild's activity history, oldest first
+func (g *GuildContract) GetGuildActivity(ctx contractapi.TransactionContextInterface, guildID string) ([]*models.GuildActivity, error) {
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(utils.GuildActivityObjectType, []string{guildID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get guild activity iterator: %v", err)
+	}
+	defer iterator.Close()
+
+	var activities []*models.GuildActivity
+	for iterator.HasNext() {
+		queryResponse, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate guild activity: %v", err)
+		}
+
+		var activity models.GuildActivity
+		err = json.Unmarshal(queryResponse.Value, &activity)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal guild activity: %v", err)
+		}
+
+		activities = append(activities, &activity)
+	}
+
+	return activities, nil
+}
+
+// requireGuildRole verifies a member holds at least role and returns the member
+func (g *GuildContract) requireGuildRole(ctx contractapi.TransactionContextInterface, guildID, userID, role string) (*models.GuildMember, error) {
+	member, err := g.GetGuildMember(ctx, guildID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if guildRoleRank[member.Role] < guildRoleRank[role] {
+		return nil, fmt.Errorf("user %s is not authorized (requires guild role %s)", userID, role)
+	}
+	return member, nil
+}
+
+// checkCanJoin verifies a user exists and is not already in a guild
+func (g *GuildContract) checkCanJoin(ctx contractapi.TransactionContextInterface, userID string) error {
+	// Initialize asset contract if not set
+	if g.AssetContract == nil {
+		g.AssetContract = &AssetContract{}
+	}
+
+	if _, err := g.AssetContract.GetUserAssets(ctx, userID); err != nil {
+		return err
+	}
+
+	guildID, err := ctx.GetStub().GetState(utils.GetGuildMembershipKey(userID))
+	if err != nil {
+		return fmt.Errorf("failed to read guild membership: %v", err)
+	}
+	if guildID != nil {
+		return fmt.Errorf("user %s is already in guild %s", userID, string(guildID))
+	}
+	return nil
+}
+
+// chargeWithdrawal counts a withdrawal against the member's daily limits
+func (g *GuildContract) chargeWithdrawal(ctx contractapi.TransactionContextInterface, member *models.GuildMember, amount float64, quantity int) error {
+	if member.Role == GuildRoleLeader {
+		return nil
+	}
+
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	day := timestamp.UTC().Format(guildDayFormat)
+	if member.LimitDay != day {
+		member.LimitDay = day
+		member.Withdrawn = 0
+		member.ItemsWithdrawn = 0
+	}
+
+	if member.Withdrawn+amount > member.WithdrawLimit {
+		return fmt.Errorf("withdrawal exceeds daily limit for %s (%.2f of %.2f used)", member.UserID, member.Withdrawn, member.WithdrawLimit)
+	}
+	if member.ItemsWithdrawn+quantity > member.ItemWithdrawLimit {
+		return fmt.Errorf("withdrawal exceeds daily item limit for %s (%d of %d used)", member.UserID, member.ItemsWithdrawn, member.ItemWithdrawLimit)
+	}
+
+	member.Withdrawn += amount
+	member.ItemsWithdrawn += quantity
+	return g.putMember(ctx, member)
+}
+
+// addMember records a user's membership of a guild
+func (g *GuildContract) addMember(ctx contractapi.TransactionContextInterface, guild *models.Guild, userID, role string) error {
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	member := models.GuildMember{
+		GuildID:  guild.GuildID,
+		UserID:   userID,
+		Role:     role,
+		JoinedAt: timestamp,
+	}
+	err = g.putMember(ctx, &member)
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().PutState(utils.GetGuildMembershipKey(userID), []byte(guild.GuildID))
+	if err != nil {
+		return fmt.Errorf("failed to save guild membership: %v", err)
+	}
+	return nil
+}
+
+// removeMember deletes a user's membership of a guild
+func (g *GuildContract) removeMember(ctx contractapi.TransactionContextInterface, guild *models.Guild, userID string) error {
+	key, err := utils.GetGuildMemberKey(ctx, guild.GuildID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to create guild member key: %v", err)
+	}
+	err = ctx.GetStub().DelState(key)
+	if err != nil {
+		return fmt.Errorf("failed to delete guild member: %v", err)
+	}
+
+	err = ctx.GetStub().DelState(utils.GetGuildMembershipKey(userID))
+	if err != nil {
+		return fmt.Errorf("failed to delete guild membership: %v", err)
+	}
+
+	guild.MemberCount--
+	return nil
+}
+
+// logActivity appends an entry to the guild's history. The caller saves the guild.
+func (g *GuildContract) logActivity(ctx contractapi.TransactionContextInterface, guild *models.Guild, activity models.GuildActivity) error {
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	guild.ActivityCount++
+	activity.GuildID = guild.GuildID
+	activity.Seq = guild.ActivityCount
+	activity.Timestamp = timestamp
+
+	activityJSON, err := json.Marshal(activity)
+	if err != nil {
+		return fmt.Errorf("failed to marshal guild activity: %v", err)
+	}
+
+	key, err := utils.GetGuildActivityKey(ctx, guild.GuildID, activity.Seq)
+	if err != nil {
+		return fmt.Errorf("failed to create guild activity key: %v", err)
+	}
+	err = ctx.GetStub().PutState(key, activityJSON)
+	if err != nil {
+		return fmt.Errorf("failed to save guild activity: %v", err)
+	}
+	return nil
+}
+
+// putGuild writes a guild to the ledger
+func (g *GuildContract) putGuild(ctx contractapi.TransactionContextInterface, guild *models.Guild) error {
+	guildJSON, err := json.Marshal(guild)
+	if err != nil {
+		return fmt.Errorf("failed to marshal guild: %v", err)
+	}
+	err = ctx.GetStub().PutState(utils.GetGuildKey(guild.GuildID), guildJSON)
+	if err != nil {
+		return fmt.Errorf("failed to save guild: %v", err)
+	}
+	return nil
+}
+
+// putMember writes a guild member to the ledger
+func (g *GuildContract) putMember(ctx contractapi.TransactionContextInterface, member *models.GuildMember) error {
+	memberJSON, err := json.Marshal(member)
+	if err != nil {
+		return fmt.Errorf("failed to marshal guild member: %v", err)
+	}
+
+	key, err := utils.GetGuildMemberKey(ctx, member.GuildID, member.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to create guild member key: %v", err)
+	}
+	err = ctx.GetStub().PutState(key, memberJSON)
+	if err != nil {
+		return fmt.Errorf("failed to save guild member: %v", err)
+	}
+	return nil
+}
+
+// putTreasury writes a guild's treasury balance to the ledger
+func (g *GuildContract) putTreasury(ctx contractapi.TransactionContextInterface, treasury *models.UserAsset) error {
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	treasury.UpdatedAt = timestamp
+
+	treasuryJSON, err := json.Marshal(treasury)
+	if err != nil {
+		return fmt.Errorf("failed to marshal guild treasury: %v", err)
+	}
+	err = ctx.GetStub().PutState(utils.GetGuildAssetKey(treasury.UserID), treasuryJSON)
+	if err != nil {
+		return fmt.Errorf("failed to save guild treasury: %v", err)
+	}
+	return nil
+}
+
+// getTreasuryInventory retrieves a guild's treasury inventory of a commodity
+func (g *GuildContract) getTreasuryInventory(ctx contractapi.TransactionContextInterface, guildID, commodityID string) (*models.Inventory, error) {
+	inventoryJSON, err := ctx.GetStub().GetState(utils.GetGuildInventoryKey(guildID, commodityID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read guild inventory: %v", err)
+	}
+	if inventoryJSON == nil {
+		// Return empty inventory instead of error
+		return &models.Inventory{UserID: guildID, CommodityID: commodityID}, nil
+	}
+
+	var inventory models.Inventory
+	err = json.Unmarshal(inventoryJSON, &inventory)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal guild inventory: %v", err)
+	}
+
+	return &inventory, nil
+}
+
+// putTreasuryInventory writes a guild's treasury inventory of a commodity to the ledger
+func (g *GuildContract) putTreasuryInventory(ctx contractapi.TransactionContextInterface, inventory *models.Inventory) error {
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	inventory.UpdatedAt = timestamp
+
+	inventoryJSON, err := json.Marshal(inventory)
+	if err != nil {
+		return fmt.Errorf("failed to marshal guild inventory: %v", err)
+	}
+	err = ctx.GetStub().PutState(utils.GetGuildInventoryKey(inventory.UserID, inventory.CommodityID), inventoryJSON)
+	if err != nil {
+		return fmt.Errorf("failed to save guild inventory: %v", err)
+	}
+	return nil
+}
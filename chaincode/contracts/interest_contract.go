@@ -0,0 +1,198 @@
+package contracts
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-samples/game-chaincode/models"
+	"github.com/hyperledger/fabric-samples/game-chaincode/utils"
+)
+
+// InterestContract provides an optional rate applied to idle balances over time
+type InterestContract struct {
+	contractapi.Contract
+	AssetContract *AssetContract
+}
+
+// SetBalanceRate sets the annual rate applied to idle balances (admin only): a
+// positive rate pays interest and a negative rate charges a holding cost.
+// Every balance is first accrued at the previous rate, so the new rate only
+// applies from now on.
+func (i *InterestContract) SetBalanceRate(ctx contractapi.TransactionContextInterface, annualRate float64) error {
+	if _, err := requireRole(ctx, RoleAdmin); err != nil {
+		return err
+	}
+
+	if annualRate < -1 {
+		return fmt.Errorf("annual rate cannot be below -1")
+	}
+
+	// 1. Close the period of the previous rate
+	_, err := i.accrueAll(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	// 2. Save the new rate
+	config := models.BalanceRateConfig{
+		AnnualRate: annualRate,
+		UpdatedAt:  timestamp,
+	}
+
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("failed to marshal balance rate config: %v", err)
+	}
+	return ctx.GetStub().PutState(utils.BalanceRateConfigKey, configJSON)
+}
+
+// GetBalanceRate retrieves the rate applied to idle balances
+func (i *InterestContract) GetBalanceRate(ctx contractapi.TransactionContextInterface) (*models.BalanceRateConfig, error) {
+	config, err := getBalanceRateConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return nil, fmt.Errorf("balance rate is not configured")
+	}
+	return config, nil
+}
+
+// AccrueBalances applies the balance rate to every user's balance and saves
+// it (admin only), so stored balances and the total money supply are current
+// even for users who have not transacted
+func (i *InterestContract) AccrueBalances(ctx contractapi.TransactionContextInterface) error {
+	if _, err := requireRole(ctx, RoleAdmin); err != nil {
+		return err
+	}
+
+	total, err := i.accrueAll(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	eventPayload := map[string]interface{}{
+		"totalAccrued": total,
+		"timestamp":    timestamp,
+	}
+	eventJSON, _ := json.Marshal(eventPayload)
+	ctx.GetStub().SetEvent("BalancesAccrued", eventJSON)
+
+	return nil
+}
+
+// accrueAll applies the balance rate to every user's balance, saves it and
+// returns the total accrued
+func (i *InterestContract) accrueAll(ctx contractapi.TransactionContextInterface) (float64, error) {
+	// Initialize asset contract if not set
+	if i.AssetContract == nil {
+		i.AssetContract = &AssetContract{}
+	}
+
+	iterator, err := ctx.GetStub().GetStateByRange(utils.UserAssetPrefix, utils.UserAssetPrefix+"\uffff")
+	if err != nil {
+		return 0, fmt.Errorf("failed to get user asset iterator: %v", err)
+	}
+	defer iterator.Close()
+
+	var total float64
+	for iterator.HasNext() {
+		queryResponse, err := iterator.Next()
+		if err != nil {
+			return 0, fmt.Errorf("failed to iterate user assets: %v", err)
+		}
+
+		var userAsset models.UserAsset
+		err = json.Unmarshal(queryResponse.Value, &userAsset)
+		if err != nil {
+			return 0, fmt.Errorf("failed to unmarshal user asset: %v", err)
+		}
+
+		accrued, err := accrueBalance(ctx, &userAsset)
+		if err != nil {
+			return 0, err
+		}
+
+		// Frozen accounts accrue too, so the record is written directly
+		err = i.AssetContract.putUserAsset(ctx, &userAsset)
+		if err != nil {
+			return 0, err
+		}
+		if accrued != 0 {
+			err = i.AssetContract.updateRichest(ctx, &userAsset)
+			if err != nil {
+				return 0, err
+			}
+		}
+		total += accrued
+	}
+
+	return total, nil
+}
+
+// getBalanceRateConfig retrieves the balance rate, or nil if none is configured
+func getBalanceRateConfig(ctx contractapi.TransactionContextInterface) (*models.BalanceRateConfig, error) {
+	configJSON, err := ctx.GetStub().GetState(utils.BalanceRateConfigKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read balance rate config: %v", err)
+	}
+	if configJSON == nil {
+		return nil, nil
+	}
+
+	var config models.BalanceRateConfig
+	err = json.Unmarshal(configJSON, &config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal balance rate config: %v", err)
+	}
+
+	return &config, nil
+}
+
+// accrueBalance applies simple interest at the balance rate to a user's idle
+// (unlocked) balance since its last accrual and returns the amount accrued.
+// A holding cost never takes more than the idle balance. The user asset is
+// only updated in memory.
+func accrueBalance(ctx contractapi.TransactionContextInterface, userAsset *models.UserAsset) (float64, error) {
+	config, err := getBalanceRateConfig(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if !timestamp.After(userAsset.LastAccrualAt) {
+		return 0, nil
+	}
+
+	// Balances start accruing from the first time they are seen
+	var accrued float64
+	idle := userAsset.Balance - userAsset.Locked
+	if config != nil && !userAsset.LastAccrualAt.IsZero() && idle > 0 {
+		elapsed := timestamp.Sub(userAsset.LastAccrualAt).Seconds()
+		accrued = idle * config.AnnualRate * elapsed / secondsPerYear
+		if accrued < -idle {
+			accrued = -idle
+		}
+	}
+
+	userAsset.Balance += accrued
+	userAsset.LastAccrualAt = timestamp
+	return accrued, nil
+}
@@ -0,0 +1,317 @@
+package contracts
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-samples/game-chaincode/models"
+	"github.com/hyperledger/fabric-samples/game-chaincode/utils"
+)
+
+// Built-in leaderboards maintained by asset, trade and redemption operations
+const (
+	BoardRichest     = "richest"     // current balance
+	BoardTrades      = "trades"      // completed trades
+	BoardRedemptions = "redemptions" // executed redemptions
+)
+
+// DefaultLeaderboardSeason is the season used before the first reset
+const DefaultLeaderboardSeason = "default"
+
+// Scores are stored in rank keys as inverted fixed-point numbers so that a
+// partial composite key scan returns the highest score first
+const (
+	rankScale    = 100
+	maxRankValue = int64(1e17)
+)
+
+// LeaderboardContract provides ranked leaderboards stored as rank-sortable composite keys
+type LeaderboardContract struct {
+	contractapi.Contract
+}
+
+// SubmitScore sets a user's score on a custom board of the current season (admin only)
+func (l *LeaderboardContract) SubmitScore(ctx contractapi.TransactionContextInterface, board, userID string, score float64) error {
+	if _, err := requireRole(ctx, RoleAdmin); err != nil {
+		return err
+	}
+
+	switch board {
+	case BoardRichest, BoardTrades, BoardRedemptions:
+		return fmt.Errorf("board %s is maintained automatically", board)
+	case "":
+		return fmt.Errorf("board cannot be empty")
+	}
+
+	return l.setScore(ctx, board, userID, score)
+}
+
+// ResetLeaderboards starts a new leaderboard season; later updates go to fresh boards
+// while the previous season's boards remain queryable (admin only)
+func (l *LeaderboardContract) ResetLeaderboards(ctx contractapi.TransactionContextInterface, seasonID string) error {
+	if _, err := requireRole(ctx, RoleAdmin); err != nil {
+		return err
+	}
+
+	return l.startSeason(ctx, seasonID)
+}
+
+// GetCurrentLeaderboardSeason retrieves the season leaderboard updates currently go to
+func (l *LeaderboardContract) GetCurrentLeaderboardSeason(ctx contractapi.TransactionContextInterface) (*models.LeaderboardSeason, error) {
+	seasonJSON, err := ctx.GetStub().GetState(utils.LeaderboardSeasonKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read leaderboard season: %v", err)
+	}
+	if seasonJSON == nil {
+		return &models.LeaderboardSeason{SeasonID: DefaultLeaderboardSeason}, nil
+	}
+
+	var season models.LeaderboardSeason
+	err = json.Unmarshal(seasonJSON, &season)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal leaderboard season: %v", err)
+	}
+
+	return &season, nil
+}
+
+// GetTopScores retrieves the top n entries of a board in the current season
+func (l *LeaderboardContract) GetTopScores(ctx contractapi.TransactionContextInterface, board string, n int) ([]*models.LeaderboardEntry, error) {
+	season, err := l.GetCurrentLeaderboardSeason(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return l.GetSeasonTopScores(ctx, season.SeasonID, board, n)
+}
+
+// GetSeasonTopScores retrieves the top n entries of a board in any season
+func (l *LeaderboardContract) GetSeasonTopScores(ctx contractapi.TransactionContextInterface, seasonID, board string, n int) ([]*models.LeaderboardEntry, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("n must be positive")
+	}
+
+	var entries []*models.LeaderboardEntry
+	err := l.scanBoard(ctx, seasonID, board, func(entry *models.LeaderboardEntry) bool {
+		entries = append(entries, entry)
+		return len(entries) < n
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// GetUserRank retrieves a user's entry and 1-based rank on a board in the current season
+func (l *LeaderboardContract) GetUserRank(ctx contractapi.TransactionContextInterface, board, userID string) (*models.LeaderboardEntry, error) {
+	season, err := l.GetCurrentLeaderboardSeason(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var found *models.LeaderboardEntry
+	err = l.scanBoard(ctx, season.SeasonID, board, func(entry *models.LeaderboardEntry) bool {
+		if entry.UserID == userID {
+			found = entry
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if found == nil {
+		return nil, fmt.Errorf("user %s is not ranked on board %s", userID, board)
+	}
+
+	return found, nil
+}
+
+// scanBoard visits the entries of a board from the highest score down, assigning
+// ranks, until visit returns false. Rank keys that no longer match the user's
+// current score are skipped.
+func (l *LeaderboardContract) scanBoard(ctx contractapi.TransactionContextInterface, seasonID, board string, visit func(entry *models.LeaderboardEntry) bool) error {
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(utils.LeaderboardRankObjectType, []string{seasonID, board})
+	if err != nil {
+		return fmt.Errorf("failed to get leaderboard iterator: %v", err)
+	}
+	defer iterator.Close()
+
+	rank := 0
+	for iterator.HasNext() {
+		queryResponse, err := iterator.Next()
+		if err != nil {
+			return fmt.Errorf("failed to iterate leaderboard: %v", err)
+		}
+
+		var entry models.LeaderboardEntry
+		err = json.Unmarshal(queryResponse.Value, &entry)
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal leaderboard entry: %v", err)
+		}
+
+		current, err := l.getScore(ctx, seasonID, board, entry.UserID)
+		if err != nil {
+			return err
+		}
+		if current == nil || rankValue(current.Score) != rankValue(entry.Score) {
+			continue
+		}
+
+		rank++
+		current.Rank = rank
+		if !visit(current) {
+			break
+		}
+	}
+
+	return nil
+}
+
+// incrementScore adds delta to a user's score on a board of the current season
+func (l *LeaderboardContract) incrementScore(ctx contractapi.TransactionContextInterface, board, userID string, delta float64) error {
+	season, err := l.GetCurrentLeaderboardSeason(ctx)
+	if err != nil {
+		return err
+	}
+
+	entry, err := l.getScore(ctx, season.SeasonID, board, userID)
+	if err != nil {
+		return err
+	}
+
+	score := delta
+	if entry != nil {
+		score += entry.Score
+	}
+	return l.setScore(ctx, board, userID, score)
+}
+
+// setScore sets a user's score on a board of the current season, moving its rank key
+func (l *LeaderboardContract) setScore(ctx contractapi.TransactionContextInterface, board, userID string, score float64) error {
+	season, err := l.GetCurrentLeaderboardSeason(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Remove the previous rank key
+	previous, err := l.getScore(ctx, season.SeasonID, board, userID)
+	if err != nil {
+		return err
+	}
+	if previous != nil {
+		oldRankKey, err := utils.GetLeaderboardRankKey(ctx, season.SeasonID, board, rankValue(previous.Score), userID)
+		if err != nil {
+			return fmt.Errorf("failed to create leaderboard rank key: %v", err)
+		}
+		err = ctx.GetStub().DelState(oldRankKey)
+		if err != nil {
+			return fmt.Errorf("failed to delete leaderboard rank: %v", err)
+		}
+	}
+
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	entry := models.LeaderboardEntry{
+		SeasonID:  season.SeasonID,
+		Board:     board,
+		UserID:    userID,
+		Score:     score,
+		UpdatedAt: timestamp,
+	}
+
+	entryJSON, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal leaderboard entry: %v", err)
+	}
+
+	scoreKey, err := utils.GetLeaderboardScoreKey(ctx, season.SeasonID, board, userID)
+	if err != nil {
+		return fmt.Errorf("failed to create leaderboard score key: %v", err)
+	}
+	err = ctx.GetStub().PutState(scoreKey, entryJSON)
+	if err != nil {
+		return fmt.Errorf("failed to save leaderboard score: %v", err)
+	}
+
+	rankKey, err := utils.GetLeaderboardRankKey(ctx, season.SeasonID, board, rankValue(score), userID)
+	if err != nil {
+		return fmt.Errorf("failed to create leaderboard rank key: %v", err)
+	}
+	return ctx.GetStub().PutState(rankKey, entryJSON)
+}
+
+// getScore retrieves a user's entry on a board, or nil if the user has no score
+func (l *LeaderboardContract) getScore(ctx contractapi.TransactionContextInterface, seasonID, board, userID string) (*models.LeaderboardEntry, error) {
+	key, err := utils.GetLeaderboardScoreKey(ctx, seasonID, board, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create leaderboard score key: %v", err)
+	}
+	entryJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read leaderboard score: %v", err)
+	}
+	if entryJSON == nil {
+		return nil, nil
+	}
+
+	var entry models.LeaderboardEntry
+	err = json.Unmarshal(entryJSON, &entry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal leaderboard score: %v", err)
+	}
+
+	return &entry, nil
+}
+
+// startSeason switches leaderboard updates to a new season
+func (l *LeaderboardContract) startSeason(ctx contractapi.TransactionContextInterface, seasonID string) error {
+	if seasonID == "" {
+		return fmt.Errorf("season ID cannot be empty")
+	}
+
+	current, err := l.GetCurrentLeaderboardSeason(ctx)
+	if err != nil {
+		return err
+	}
+	if current.SeasonID == seasonID {
+		return fmt.Errorf("leaderboard season %s is already current", seasonID)
+	}
+
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	season := models.LeaderboardSeason{
+		SeasonID:  seasonID,
+		StartedAt: timestamp,
+	}
+
+	seasonJSON, err := json.Marshal(season)
+	if err != nil {
+		return fmt.Errorf("failed to marshal leaderboard season: %v", err)
+	}
+	return ctx.GetStub().PutState(utils.LeaderboardSeasonKey, seasonJSON)
+}
+
+// rankValue encodes a score as an inverted, zero-padded fixed-point string
+// so that ascending key order is descending score order
+func rankValue(score float64) string {
+	value := int64(math.Round(score * rankScale))
+	if value < 0 {
+		value = 0
+	}
+	if value > maxRankValue {
+		value = maxRankValue
+	}
+	return fmt.Sprintf("%018d", maxRankValue-value)
+}
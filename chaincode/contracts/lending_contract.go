@@ -0,0 +1,495 @@
+package contracts
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-samples/game-chaincode/models"
+	"github.com/hyperledger/fabric-samples/game-chaincode/utils"
+)
+
+// secondsPerYear is the year length simple interest accrues over
+const secondsPerYear = 365 * 24 * 60 * 60
+
+// loanHoldReason is the reason of the holds locking loan collateral
+const loanHoldReason = "loan"
+
+// LendingContract provides loans secured by held commodities
+type LendingContract struct {
+	contractapi.Contract
+	AssetContract     *AssetContract
+	ValuationContract *ValuationContract
+}
+
+// SetLendingConfig sets the lending rules (admin only). Loans are funded by
+// lenderID and may be opened up to maxLoanToValue of the collateral value at
+// priceSource; once the debt exceeds liquidationThreshold of the collateral
+// value anyone can liquidate the loan.
+func (l *LendingContract) SetLendingConfig(ctx contractapi.TransactionContextInterface, lenderID, priceSource string, annualInterestRate, maxLoanToValue, liquidationThreshold float64) error {
+	if _, err := requireRole(ctx, RoleAdmin); err != nil {
+		return err
+	}
+
+	if err := checkPriceSource(priceSource); err != nil {
+		return err
+	}
+	if annualInterestRate < 0 {
+		return fmt.Errorf("interest rate cannot be negative")
+	}
+	if maxLoanToValue <= 0 || maxLoanToValue >= liquidationThreshold || liquidationThreshold > 1 {
+		return fmt.Errorf("loan-to-value limits must satisfy 0 < maxLoanToValue < liquidationThreshold <= 1")
+	}
+
+	// Initialize asset contract if not set
+	if l.AssetContract == nil {
+		l.AssetContract = &AssetContract{}
+	}
+
+	if _, err := l.AssetContract.GetUserAssets(ctx, lenderID); err != nil {
+		return err
+	}
+
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	config := models.LendingConfig{
+		LenderID:             lenderID,
+		PriceSource:          priceSource,
+		AnnualInterestRate:   annualInterestRate,
+		MaxLoanToValue:       maxLoanToValue,
+		LiquidationThreshold: liquidationThreshold,
+		UpdatedAt:            timestamp,
+	}
+
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("failed to marshal lending config: %v", err)
+	}
+	return ctx.GetStub().PutState(utils.LendingConfigKey, configJSON)
+}
+
+// GetLendingConfig retrieves the lending rules
+func (l *LendingContract) GetLendingConfig(ctx contractapi.TransactionContextInterface) (*models.LendingConfig, error) {
+	configJSON, err := ctx.GetStub().GetState(utils.LendingConfigKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lending config: %v", err)
+	}
+	if configJSON == nil {
+		return nil, fmt.Errorf("lending is not configured")
+	}
+
+	var config models.LendingConfig
+	err = json.Unmarshal(configJSON, &config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal lending config: %v", err)
+	}
+
+	return &config, nil
+}
+
+// OpenLoan lends amount to the borrower against the items in collateralJSON
+// (a JSON array of {commodityId, quantity}), which are held until the loan is
+// repaid or liquidated
+func (l *LendingContract) OpenLoan(ctx contractapi.TransactionContextInterface, loanID, borrowerID, collateralJSON string, amount float64) error {
+	config, err := l.GetLendingConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	if amount <= 0 {
+		return fmt.Errorf("amount must be positive")
+	}
+	if borrowerID == config.LenderID {
+		return fmt.Errorf("lender cannot borrow from itself")
+	}
+
+	// Check if loan already exists
+	existing, err := l.GetLoan(ctx, loanID)
+	if err == nil && existing != nil {
+		return fmt.Errorf("loan %s already exists", loanID)
+	}
+
+	var collateral []models.RequiredItem
+	err = json.Unmarshal([]byte(collateralJSON), &collateral)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal collateral: %v", err)
+	}
+	if len(collateral) == 0 {
+		return fmt.Errorf("collateral cannot be empty")
+	}
+	for _, item := range collateral {
+		if item.Quantity <= 0 {
+			return fmt.Errorf("collateral quantities must be positive")
+		}
+	}
+
+	// 1. Check the loan-to-value limit
+	collateralValue, err := l.valueCollateral(ctx, collateral, config.PriceSource)
+	if err != nil {
+		return err
+	}
+	if amount > collateralValue*config.MaxLoanToValue {
+		return fmt.Errorf("amount %.2f exceeds the maximum loan of %.2f for collateral worth %.2f",
+			amount, collateralValue*config.MaxLoanToValue, collateralValue)
+	}
+
+	// Initialize asset contract if not set
+	if l.AssetContract == nil {
+		l.AssetContract = &AssetContract{}
+	}
+
+	// 2. Hold the collateral in the borrower's inventory
+	err = l.AssetContract.placeHold(ctx, borrowerID, loanHoldReason, loanID, 0, collateral)
+	if err != nil {
+		return fmt.Errorf("failed to hold collateral: %v", err)
+	}
+
+	// 3. Pay out the loan from the lender
+	err = l.AssetContract.updateBalance(ctx, config.LenderID, amount, "subtract")
+	if err != nil {
+		return fmt.Errorf("failed to fund loan: %v", err)
+	}
+	err = l.AssetContract.updateBalance(ctx, borrowerID, amount, "add")
+	if err != nil {
+		return fmt.Errorf("failed to pay out loan: %v", err)
+	}
+
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	loan := models.Loan{
+		LoanID:        loanID,
+		BorrowerID:    borrowerID,
+		LenderID:      config.LenderID,
+		Collateral:    collateral,
+		Borrowed:      amount,
+		Principal:     amount,
+		InterestRate:  config.AnnualInterestRate,
+		Status:        "active",
+		OpenedAt:      timestamp,
+		LastAccrualAt: timestamp,
+	}
+
+	err = l.putLoan(ctx, &loan)
+	if err != nil {
+		return err
+	}
+
+	// 4. Emit event
+	eventJSON, _ := json.Marshal(loan)
+	ctx.GetStub().SetEvent("LoanOpened", eventJSON)
+
+	return nil
+}
+
+// RepayLoan pays up to amount of a loan's debt, interest first. Paying off the
+// whole debt releases the hold on the collateral.
+func (l *LendingContract) RepayLoan(ctx contractapi.TransactionContextInterface, loanID string, amount float64) error {
+	if amount <= 0 {
+		return fmt.Errorf("amount must be positive")
+	}
+
+	loan, err := l.GetLoan(ctx, loanID)
+	if err != nil {
+		return err
+	}
+	if loan.Status != "active" {
+		return fmt.Errorf("loan is not active (status: %s)", loan.Status)
+	}
+
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	accrueLoanInterest(loan, timestamp)
+
+	// 1. Apply the payment to interest, then principal
+	debt := loan.AccruedInterest + loan.Principal
+	payment := amount
+	if payment >= debt {
+		payment = debt
+		loan.AccruedInterest = 0
+		loan.Principal = 0
+	} else if payment <= loan.AccruedInterest {
+		loan.AccruedInterest -= payment
+	} else {
+		loan.Principal -= payment - loan.AccruedInterest
+		loan.AccruedInterest = 0
+	}
+	loan.TotalRepaid += payment
+
+	// Initialize asset contract if not set
+	if l.AssetContract == nil {
+		l.AssetContract = &AssetContract{}
+	}
+
+	// 2. Transfer the payment from the borrower to the lender
+	err = l.AssetContract.updateBalance(ctx, loan.BorrowerID, payment, "subtract")
+	if err != nil {
+		return fmt.Errorf("failed to deduct repayment: %v", err)
+	}
+	err = l.AssetContract.updateBalance(ctx, loan.LenderID, payment, "add")
+	if err != nil {
+		return fmt.Errorf("failed to pay lender: %v", err)
+	}
+
+	// 3. Release the collateral once the loan is paid off
+	if loan.Principal == 0 && loan.AccruedInterest == 0 {
+		_, err = l.AssetContract.releaseHold(ctx, loan.BorrowerID, loanHoldReason, loanID, false)
+		if err != nil {
+			return fmt.Errorf("failed to release collateral: %v", err)
+		}
+		loan.Status = "repaid"
+		loan.ClosedAt = timestamp
+	}
+
+	err = l.putLoan(ctx, loan)
+	if err != nil {
+		return err
+	}
+
+	// 4. Emit event
+	eventPayload := map[string]interface{}{
+		"loanId":    loanID,
+		"payment":   payment,
+		"debt":      loan.AccruedInterest + loan.Principal,
+		"status":    loan.Status,
+		"timestamp": timestamp,
+	}
+	eventJSON, _ := json.Marshal(eventPayload)
+	ctx.GetStub().SetEvent("LoanRepaid", eventJSON)
+
+	return nil
+}
+
+// LiquidateLoan lets the liquidator pay off an under-collateralized loan's
+// whole debt to the lender in exchange for its collateral
+func (l *LendingContract) LiquidateLoan(ctx contractapi.TransactionContextInterface, loanID, liquidatorID string) error {
+	health, err := l.GetLoanHealth(ctx, loanID)
+	if err != nil {
+		return err
+	}
+	if !health.Liquidatable {
+		return fmt.Errorf("loan %s is not liquidatable (loan-to-value %.4f)", loanID, health.LoanToValue)
+	}
+
+	loan, err := l.GetLoan(ctx, loanID)
+	if err != nil {
+		return err
+	}
+	if liquidatorID == loan.BorrowerID {
+		return fmt.Errorf("borrower cannot liquidate their own loan")
+	}
+	// Paying the debt to oneself would credit the lender without debiting them
+	if liquidatorID == loan.LenderID {
+		return fmt.Errorf("lender cannot liquidate their own loan")
+	}
+	accrueLoanInterest(loan, health.ValuedAt)
+
+	// Initialize asset contract if not set
+	if l.AssetContract == nil {
+		l.AssetContract = &AssetContract{}
+	}
+
+	// 1. The liquidator pays off the debt
+	debt := loan.AccruedInterest + loan.Principal
+	err = l.AssetContract.updateBalance(ctx, liquidatorID, debt, "subtract")
+	if err != nil {
+		return fmt.Errorf("failed to deduct liquidation payment: %v", err)
+	}
+	err = l.AssetContract.updateBalance(ctx, loan.LenderID, debt, "add")
+	if err != nil {
+		return fmt.Errorf("failed to pay lender: %v", err)
+	}
+
+	// 2. The collateral is seized from the borrower and given to the liquidator
+	hold, err := l.AssetContract.releaseHold(ctx, loan.BorrowerID, loanHoldReason, loanID, true)
+	if err != nil {
+		return fmt.Errorf("failed to seize collateral: %v", err)
+	}
+	err = checkStorageCapacity(ctx, l.AssetContract, liquidatorID, hold.Items)
+	if err != nil {
+		return err
+	}
+	for _, item := range hold.Items {
+		err = l.AssetContract.giveItems(ctx, liquidatorID, item.CommodityID, item.Quantity, heldInventoryLots(hold.Lots, item.CommodityID))
+		if err != nil {
+			return fmt.Errorf("failed to transfer collateral %s: %v", item.CommodityID, err)
+		}
+	}
+
+	loan.TotalRepaid += debt
+	loan.Principal = 0
+	loan.AccruedInterest = 0
+	loan.Status = "liquidated"
+	loan.LiquidatorID = liquidatorID
+	loan.ClosedAt = health.ValuedAt
+
+	err = l.putLoan(ctx, loan)
+	if err != nil {
+		return err
+	}
+
+	// 3. Emit event
+	eventPayload := map[string]interface{}{
+		"loanId":          loanID,
+		"liquidatorId":    liquidatorID,
+		"debt":            debt,
+		"collateralValue": health.CollateralValue,
+		"timestamp":       health.ValuedAt,
+	}
+	eventJSON, _ := json.Marshal(eventPayload)
+	ctx.GetStub().SetEvent("LoanLiquidated", eventJSON)
+
+	return nil
+}
+
+// GetLoan retrieves a loan as of its last repayment
+func (l *LendingContract) GetLoan(ctx contractapi.TransactionContextInterface, loanID string) (*models.Loan, error) {
+	loanJSON, err := ctx.GetStub().GetState(utils.GetLoanKey(loanID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read loan: %v", err)
+	}
+	if loanJSON == nil {
+		return nil, fmt.Errorf("loan %s does not exist", loanID)
+	}
+
+	var loan models.Loan
+	err = json.Unmarshal(loanJSON, &loan)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal loan: %v", err)
+	}
+
+	return &loan, nil
+}
+
+// GetLoanHealth values an active loan's debt, including interest accrued up to
+// now, against its collateral at the configured price source
+func (l *LendingContract) GetLoanHealth(ctx contractapi.TransactionContextInterface, loanID string) (*models.LoanHealth, error) {
+	config, err := l.GetLendingConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	loan, err := l.GetLoan(ctx, loanID)
+	if err != nil {
+		return nil, err
+	}
+	if loan.Status != "active" {
+		return nil, fmt.Errorf("loan is not active (status: %s)", loan.Status)
+	}
+
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	accrueLoanInterest(loan, timestamp)
+
+	collateralValue, err := l.valueCollateral(ctx, loan.Collateral, config.PriceSource)
+	if err != nil {
+		return nil, err
+	}
+
+	health := &models.LoanHealth{
+		LoanID:          loanID,
+		PriceSource:     config.PriceSource,
+		Debt:            loan.AccruedInterest + loan.Principal,
+		CollateralValue: collateralValue,
+		ValuedAt:        timestamp,
+	}
+	if collateralValue > 0 {
+		health.LoanToValue = health.Debt / collateralValue
+	}
+	health.Liquidatable = collateralValue == 0 || health.LoanToValue > config.LiquidationThreshold
+
+	return health, nil
+}
+
+// GetUserLoans retrieves every loan taken out by a borrower
+func (l *LendingContract) GetUserLoans(ctx contractapi.TransactionContextInterface, borrowerID string) ([]*models.Loan, error) {
+	iterator, err := ctx.GetStub().GetStateByRange(utils.LoanPrefix, utils.LoanPrefix+"\uffff")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get loan iterator: %v", err)
+	}
+	defer iterator.Close()
+
+	var loans []*models.Loan
+	for iterator.HasNext() {
+		queryResponse, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate loans: %v", err)
+		}
+
+		var loan models.Loan
+		err = json.Unmarshal(queryResponse.Value, &loan)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal loan: %v", err)
+		}
+
+		if loan.BorrowerID == borrowerID {
+			loans = append(loans, &loan)
+		}
+	}
+
+	return loans, nil
+}
+
+// valueCollateral values a list of items at a price source; every item must have a price
+func (l *LendingContract) valueCollateral(ctx contractapi.TransactionContextInterface, items []models.RequiredItem, priceSource string) (float64, error) {
+	// Initialize valuation contract if not set
+	if l.ValuationContract == nil {
+		l.ValuationContract = &ValuationContract{AssetContract: l.AssetContract}
+	}
+
+	var total float64
+	for _, item := range items {
+		holding := &models.HoldingValuation{
+			CommodityID: item.CommodityID,
+			Quantity:    item.Quantity,
+		}
+
+		if priceSource == PriceSourceAMM {
+			l.ValuationContract.valueAtPool(ctx, holding)
+		} else if price := l.ValuationContract.unitPrice(ctx, item.CommodityID, priceSource); price != nil {
+			holding.Value = *price * float64(item.Quantity)
+			holding.Priced = true
+		}
+
+		if !holding.Priced {
+			return 0, fmt.Errorf("no %s price for commodity %s", priceSource, item.CommodityID)
+		}
+		total += holding.Value
+	}
+
+	return total, nil
+}
+
+// putLoan saves a loan
+func (l *LendingContract) putLoan(ctx contractapi.TransactionContextInterface, loan *models.Loan) error {
+	loanJSON, err := json.Marshal(loan)
+	if err != nil {
+		return fmt.Errorf("failed to marshal loan: %v", err)
+	}
+	return ctx.GetStub().PutState(utils.GetLoanKey(loan.LoanID), loanJSON)
+}
+
+// accrueLoanInterest adds the simple interest on the outstanding principal since the last accrual
+func accrueLoanInterest(loan *models.Loan, timestamp time.Time) {
+	if !timestamp.After(loan.LastAccrualAt) {
+		return
+	}
+
+	elapsed := timestamp.Sub(loan.LastAccrualAt).Seconds()
+	loan.AccruedInterest += loan.Principal * loan.InterestRate * elapsed / secondsPerYear
+	loan.LastAccrualAt = timestamp
+}
@@ -0,0 +1,572 @@
+package contracts
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-samples/game-chaincode/models"
+	"github.com/hyperledger/fabric-samples/game-chaincode/utils"
+)
+
+// LootContract provides loot boxes whose drops are decided by a committed
+// server seed and the opening transaction's ID. Every endorsing peer computes
+// the same drop, the operator cannot choose drops after seeing the openings,
+// and players cannot predict them before the seed is revealed.
+type LootContract struct {
+	contractapi.Contract
+	AssetContract *AssetContract
+}
+
+// CreateLootBox creates a loot box (admin only). Opening it burns price from
+// the user's balance and consumes one keyCommodityID item if set, and drops one
+// entry of the dropTableJSON array ([{"commodityId", "quantity", "weight"}])
+// with probability weight / total weight.
+func (l *LootContract) CreateLootBox(ctx contractapi.TransactionContextInterface, boxID, name string, price float64, keyCommodityID, dropTableJSON string) error {
+	if _, err := requireRole(ctx, RoleAdmin); err != nil {
+		return err
+	}
+
+	if price < 0 {
+		return fmt.Errorf("price cannot be negative")
+	}
+	if price == 0 && keyCommodityID == "" {
+		return fmt.Errorf("loot box must cost a balance or a key item")
+	}
+
+	var dropTable []models.LootDrop
+	err := json.Unmarshal([]byte(dropTableJSON), &dropTable)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal drop table: %v", err)
+	}
+	if len(dropTable) == 0 {
+		return fmt.Errorf("drop table cannot be empty")
+	}
+	for _, drop := range dropTable {
+		if drop.CommodityID == "" || drop.Quantity <= 0 || drop.Weight <= 0 {
+			return fmt.Errorf("drops must have a commodity, a positive quantity and a positive weight")
+		}
+	}
+
+	// Check if loot box already exists
+	existing, err := l.GetLootBox(ctx, boxID)
+	if err == nil && existing != nil {
+		return fmt.Errorf("loot box %s already exists", boxID)
+	}
+
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	box := models.LootBox{
+		BoxID:          boxID,
+		Name:           name,
+		Price:          price,
+		KeyCommodityID: keyCommodityID,
+		DropTable:      dropTable,
+		Active:         true,
+		CreatedAt:      timestamp,
+	}
+
+	return l.putLootBox(ctx, &box)
+}
+
+// DeactivateLootBox stops a loot box being opened (admin only). Pending
+// openings can still be claimed.
+func (l *LootContract) DeactivateLootBox(ctx contractapi.TransactionContextInterface, boxID string) error {
+	if _, err := requireRole(ctx, RoleAdmin); err != nil {
+		return err
+	}
+
+	box, err := l.GetLootBox(ctx, boxID)
+	if err != nil {
+		return err
+	}
+	if !box.Active {
+		return fmt.Errorf("loot box %s is already inactive", boxID)
+	}
+
+	box.Active = false
+	return l.putLootBox(ctx, box)
+}
+
+// GetLootBox retrieves a loot box
+func (l *LootContract) GetLootBox(ctx contractapi.TransactionContextInterface, boxID string) (*models.LootBox, error) {
+	boxJSON, err := ctx.GetStub().GetState(utils.GetLootBoxKey(boxID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read loot box: %v", err)
+	}
+	if boxJSON == nil {
+		return nil, fmt.Errorf("loot box %s does not exist", boxID)
+	}
+
+	var box models.LootBox
+	err = json.Unmarshal(boxJSON, &box)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal loot box: %v", err)
+	}
+
+	return &box, nil
+}
+
+// CommitLootSeed commits the hex SHA-256 hash of a secret server seed (admin
+// only). Boxes opened from now until the seed is revealed or replaced are
+// decided by it. The seed must be revealed within revealWindowSeconds; after
+// that no more boxes are opened under it and its openings can be refunded.
+func (l *LootContract) CommitLootSeed(ctx contractapi.TransactionContextInterface, seedID, seedHash string, revealWindowSeconds int) error {
+	if _, err := requireRole(ctx, RoleAdmin); err != nil {
+		return err
+	}
+
+	if hash, err := hex.DecodeString(seedHash); err != nil || len(hash) != sha256.Size {
+		return fmt.Errorf("seed hash must be a hex SHA-256 hash")
+	}
+	if revealWindowSeconds <= 0 {
+		return fmt.Errorf("reveal window must be positive")
+	}
+
+	// Check if seed already exists
+	existing, err := l.GetLootSeed(ctx, seedID)
+	if err == nil && existing != nil {
+		return fmt.Errorf("loot seed %s already exists", seedID)
+	}
+
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	seed := models.LootSeed{
+		SeedID:         seedID,
+		SeedHash:       seedHash,
+		Status:         "committed",
+		CommittedAt:    timestamp,
+		RevealDeadline: timestamp.Add(time.Duration(revealWindowSeconds) * time.Second),
+	}
+
+	err = l.putLootSeed(ctx, &seed)
+	if err != nil {
+		return err
+	}
+	err = ctx.GetStub().PutState(utils.CurrentLootSeedKey, []byte(seedID))
+	if err != nil {
+		return fmt.Errorf("failed to save current loot seed: %v", err)
+	}
+
+	return nil
+}
+
+// RevealLootSeed reveals a committed seed (admin only) before its deadline. It
+// must hash to the committed hash. No more boxes are opened under a revealed
+// seed, and the openings it decided can be claimed.
+func (l *LootContract) RevealLootSeed(ctx contractapi.TransactionContextInterface, seedID, seedValue string) error {
+	if _, err := requireRole(ctx, RoleAdmin); err != nil {
+		return err
+	}
+
+	seed, err := l.GetLootSeed(ctx, seedID)
+	if err != nil {
+		return err
+	}
+	if seed.Status != "committed" {
+		return fmt.Errorf("loot seed is not committed (status: %s)", seed.Status)
+	}
+
+	hash := sha256.Sum256([]byte(seedValue))
+	if hex.EncodeToString(hash[:]) != seed.SeedHash {
+		return fmt.Errorf("revealed seed does not match commitment")
+	}
+
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	// Openings may already have been refunded after the deadline
+	if revealDeadlinePassed(seed, timestamp) {
+		return fmt.Errorf("loot seed %s reveal deadline passed at %s", seedID, seed.RevealDeadline.Format(time.RFC3339))
+	}
+
+	seed.Seed = seedValue
+	seed.Status = "revealed"
+	seed.RevealedAt = timestamp
+
+	err = l.putLootSeed(ctx, seed)
+	if err != nil {
+		return err
+	}
+
+	// Stop opening boxes under the revealed seed
+	currentSeedID, err := ctx.GetStub().GetState(utils.CurrentLootSeedKey)
+	if err != nil {
+		return fmt.Errorf("failed to read current loot seed: %v", err)
+	}
+	if string(currentSeedID) == seedID {
+		err = ctx.GetStub().DelState(utils.CurrentLootSeedKey)
+		if err != nil {
+			return fmt.Errorf("failed to clear current loot seed: %v", err)
+		}
+	}
+
+	eventJSON, _ := json.Marshal(seed)
+	ctx.GetStub().SetEvent("LootSeedRevealed", eventJSON)
+
+	return nil
+}
+
+// GetLootSeed retrieves a loot seed
+func (l *LootContract) GetLootSeed(ctx contractapi.TransactionContextInterface, seedID string) (*models.LootSeed, error) {
+	seedJSON, err := ctx.GetStub().GetState(utils.GetLootSeedKey(seedID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read loot seed: %v", err)
+	}
+	if seedJSON == nil {
+		return nil, fmt.Errorf("loot seed %s does not exist", seedID)
+	}
+
+	var seed models.LootSeed
+	err = json.Unmarshal(seedJSON, &seed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal loot seed: %v", err)
+	}
+
+	return &seed, nil
+}
+
+// OpenLootBox pays for a loot box opening under the current seed. The drop is
+// fixed by this transaction but unknown until the seed is revealed, after
+// which it is delivered by ClaimLootBox.
+func (l *LootContract) OpenLootBox(ctx contractapi.TransactionContextInterface, openingID, userID, boxID string) error {
+	box, err := l.GetLootBox(ctx, boxID)
+	if err != nil {
+		return err
+	}
+	if !box.Active {
+		return fmt.Errorf("loot box %s is not active", boxID)
+	}
+
+	currentSeedID, err := ctx.GetStub().GetState(utils.CurrentLootSeedKey)
+	if err != nil {
+		return fmt.Errorf("failed to read current loot seed: %v", err)
+	}
+	if currentSeedID == nil {
+		return fmt.Errorf("no loot seed is committed")
+	}
+	seed, err := l.GetLootSeed(ctx, string(currentSeedID))
+	if err != nil {
+		return err
+	}
+
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	if revealDeadlinePassed(seed, timestamp) {
+		return fmt.Errorf("loot seed %s reveal deadline has passed", seed.SeedID)
+	}
+
+	// Check if opening already exists
+	existing, err := l.GetLootOpening(ctx, openingID)
+	if err == nil && existing != nil {
+		return fmt.Errorf("loot opening %s already exists", openingID)
+	}
+
+	// Initialize asset contract if not set
+	if l.AssetContract == nil {
+		l.AssetContract = &AssetContract{}
+	}
+
+	// 1. Pay for the opening
+	if box.Price > 0 {
+		err = l.AssetContract.updateBalance(ctx, userID, box.Price, "subtract")
+		if err != nil {
+			return fmt.Errorf("failed to pay for loot box: %v", err)
+		}
+	}
+	if box.KeyCommodityID != "" {
+		err = l.AssetContract.updateInventory(ctx, userID, box.KeyCommodityID, 1, "subtract")
+		if err != nil {
+			return fmt.Errorf("failed to use loot box key: %v", err)
+		}
+	}
+
+	// 2. Record the opening under the current seed. The seed is not written, so
+	// concurrent openings do not conflict on it.
+	opening := models.LootOpening{
+		OpeningID: openingID,
+		UserID:    userID,
+		BoxID:     boxID,
+		SeedID:    seed.SeedID,
+		TxID:      ctx.GetStub().GetTxID(),
+		Status:    "pending",
+		OpenedAt:  timestamp,
+	}
+
+	return l.putLootOpening(ctx, &opening)
+}
+
+// ClaimLootBox delivers the drop of an opening whose seed has been revealed
+func (l *LootContract) ClaimLootBox(ctx contractapi.TransactionContextInterface, openingID string) error {
+	opening, err := l.GetLootOpening(ctx, openingID)
+	if err != nil {
+		return err
+	}
+	if opening.Status != "pending" {
+		return fmt.Errorf("loot opening is not pending (status: %s)", opening.Status)
+	}
+
+	seed, err := l.GetLootSeed(ctx, opening.SeedID)
+	if err != nil {
+		return err
+	}
+	if seed.Status != "revealed" {
+		return fmt.Errorf("loot seed %s has not been revealed yet", seed.SeedID)
+	}
+
+	box, err := l.GetLootBox(ctx, opening.BoxID)
+	if err != nil {
+		return err
+	}
+
+	// 1. Deliver the drop
+	_, drop := lootRoll(seed.Seed, opening, box.DropTable)
+
+	// Initialize asset contract if not set
+	if l.AssetContract == nil {
+		l.AssetContract = &AssetContract{}
+	}
+
+	err = l.AssetContract.updateInventory(ctx, opening.UserID, drop.CommodityID, drop.Quantity, "add")
+	if err != nil {
+		return fmt.Errorf("failed to deliver loot: %v", err)
+	}
+
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	// 2. Record the claim
+	opening.Status = "claimed"
+	opening.Drop = &drop
+	opening.ClaimedAt = timestamp
+
+	err = l.putLootOpening(ctx, opening)
+	if err != nil {
+		return err
+	}
+
+	// 3. Emit event
+	eventJSON, _ := json.Marshal(opening)
+	ctx.GetStub().SetEvent("LootBoxClaimed", eventJSON)
+
+	return nil
+}
+
+// RefundLootOpening refunds the price and key of a pending opening whose seed
+// was not revealed by its deadline
+func (l *LootContract) RefundLootOpening(ctx contractapi.TransactionContextInterface, openingID string) error {
+	opening, err := l.GetLootOpening(ctx, openingID)
+	if err != nil {
+		return err
+	}
+	if opening.Status != "pending" {
+		return fmt.Errorf("loot opening is not pending (status: %s)", opening.Status)
+	}
+
+	seed, err := l.GetLootSeed(ctx, opening.SeedID)
+	if err != nil {
+		return err
+	}
+
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	if seed.Status != "committed" || !revealDeadlinePassed(seed, timestamp) {
+		return fmt.Errorf("loot opening %s can only be refunded after its seed misses the reveal deadline", openingID)
+	}
+
+	box, err := l.GetLootBox(ctx, opening.BoxID)
+	if err != nil {
+		return err
+	}
+
+	// Initialize asset contract if not set
+	if l.AssetContract == nil {
+		l.AssetContract = &AssetContract{}
+	}
+
+	// 1. Return the price and key
+	if box.Price > 0 {
+		err = l.AssetContract.updateBalance(ctx, opening.UserID, box.Price, "add")
+		if err != nil {
+			return fmt.Errorf("failed to refund loot box price: %v", err)
+		}
+	}
+	if box.KeyCommodityID != "" {
+		err = l.AssetContract.updateInventory(ctx, opening.UserID, box.KeyCommodityID, 1, "add")
+		if err != nil {
+			return fmt.Errorf("failed to refund loot box key: %v", err)
+		}
+	}
+
+	// 2. Record the refund
+	opening.Status = "refunded"
+	opening.RefundedAt = timestamp
+
+	return l.putLootOpening(ctx, opening)
+}
+
+// GetLootOpening retrieves a loot box opening
+func (l *LootContract) GetLootOpening(ctx contractapi.TransactionContextInterface, openingID string) (*models.LootOpening, error) {
+	openingJSON, err := ctx.GetStub().GetState(utils.GetLootOpeningKey(openingID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read loot opening: %v", err)
+	}
+	if openingJSON == nil {
+		return nil, fmt.Errorf("loot opening %s does not exist", openingID)
+	}
+
+	var opening models.LootOpening
+	err = json.Unmarshal(openingJSON, &opening)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal loot opening: %v", err)
+	}
+
+	return &opening, nil
+}
+
+// GetUserLootOpenings retrieves every loot box opening of a user
+func (l *LootContract) GetUserLootOpenings(ctx contractapi.TransactionContextInterface, userID string) ([]*models.LootOpening, error) {
+	iterator, err := ctx.GetStub().GetStateByRange(utils.LootOpeningPrefix, utils.LootOpeningPrefix+"\uffff")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get loot opening iterator: %v", err)
+	}
+	defer iterator.Close()
+
+	var openings []*models.LootOpening
+	for iterator.HasNext() {
+		queryResponse, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate loot openings: %v", err)
+		}
+
+		var opening models.LootOpening
+		err = json.Unmarshal(queryResponse.Value, &opening)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal loot opening: %v", err)
+		}
+
+		if opening.UserID == userID {
+			openings = append(openings, &opening)
+		}
+	}
+
+	return openings, nil
+}
+
+// VerifyLootOpening recomputes the drop of an opening from its revealed seed,
+// checking the seed against its commitment and the drop against any claim
+func (l *LootContract) VerifyLootOpening(ctx contractapi.TransactionContextInterface, openingID string) (*models.LootVerification, error) {
+	opening, err := l.GetLootOpening(ctx, openingID)
+	if err != nil {
+		return nil, err
+	}
+
+	seed, err := l.GetLootSeed(ctx, opening.SeedID)
+	if err != nil {
+		return nil, err
+	}
+	if seed.Status != "revealed" {
+		return nil, fmt.Errorf("loot seed %s has not been revealed yet", seed.SeedID)
+	}
+
+	box, err := l.GetLootBox(ctx, opening.BoxID)
+	if err != nil {
+		return nil, err
+	}
+
+	hash := sha256.Sum256([]byte(seed.Seed))
+	roll, drop := lootRoll(seed.Seed, opening, box.DropTable)
+
+	verification := &models.LootVerification{
+		OpeningID:     openingID,
+		SeedID:        seed.SeedID,
+		Seed:          seed.Seed,
+		SeedHash:      seed.SeedHash,
+		SeedHashValid: hex.EncodeToString(hash[:]) == seed.SeedHash,
+		TxID:          opening.TxID,
+		Roll:          roll,
+		Drop:          drop,
+		MatchesClaim:  opening.Drop == nil || *opening.Drop == drop,
+	}
+
+	return verification, nil
+}
+
+// putLootBox saves a loot box
+func (l *LootContract) putLootBox(ctx contractapi.TransactionContextInterface, box *models.LootBox) error {
+	boxJSON, err := json.Marshal(box)
+	if err != nil {
+		return fmt.Errorf("failed to marshal loot box: %v", err)
+	}
+	return ctx.GetStub().PutState(utils.GetLootBoxKey(box.BoxID), boxJSON)
+}
+
+// putLootSeed saves a loot seed
+func (l *LootContract) putLootSeed(ctx contractapi.TransactionContextInterface, seed *models.LootSeed) error {
+	seedJSON, err := json.Marshal(seed)
+	if err != nil {
+		return fmt.Errorf("failed to marshal loot seed: %v", err)
+	}
+	return ctx.GetStub().PutState(utils.GetLootSeedKey(seed.SeedID), seedJSON)
+}
+
+// putLootOpening saves a loot box opening
+func (l *LootContract) putLootOpening(ctx contractapi.TransactionContextInterface, opening *models.LootOpening) error {
+	openingJSON, err := json.Marshal(opening)
+	if err != nil {
+		return fmt.Errorf("failed to marshal loot opening: %v", err)
+	}
+	return ctx.GetStub().PutState(utils.GetLootOpeningKey(opening.OpeningID), openingJSON)
+}
+
+// revealDeadlinePassed reports whether a seed's reveal deadline has passed.
+// Seeds committed before deadlines existed have none.
+func revealDeadlinePassed(seed *models.LootSeed, now time.Time) bool {
+	return !seed.RevealDeadline.IsZero() && !now.Before(seed.RevealDeadline)
+}
+
+// lootRoll picks the drop of an opening: the first 8 bytes of the SHA-256 of
+// "seed:txID:openingID" are read as a big-endian number and reduced modulo the
+// total weight, and the roll falls into the entry whose cumulative weight
+// range contains it
+func lootRoll(seed string, opening *models.LootOpening, dropTable []models.LootDrop) (int, models.LootDrop) {
+	hash := sha256.Sum256([]byte(seed + ":" + opening.TxID + ":" + opening.OpeningID))
+
+	totalWeight := 0
+	for _, drop := range dropTable {
+		totalWeight += drop.Weight
+	}
+	roll := int(binary.BigEndian.Uint64(hash[:8]) % uint64(totalWeight))
+
+	cumulative := 0
+	for _, drop := range dropTable {
+		cumulative += drop.Weight
+		if roll < cumulative {
+			return roll, drop
+		}
+	}
+	return roll, dropTable[len(dropTable)-1]
+}
@@ -0,0 +1,196 @@
+package contracts
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-samples/game-chaincode/models"
+	"github.com/hyperledger/fabric-samples/game-chaincode/utils"
+)
+
+// candleIntervals lists the candle sizes maintained for every commodity
+var candleIntervals = []struct {
+	Name     string
+	Duration time.Duration
+}{
+	{"1m", time.Minute},
+	{"1h", time.Hour},
+	{"1d", 24 * time.Hour},
+}
+
+// MarketDataContract provides price statistics and OHLC candles built from executed trades
+type MarketDataContract struct {
+	contractapi.Contract
+}
+
+// GetPriceStats retrieves the running price statistics for a commodity
+func (d *MarketDataContract) GetPriceStats(ctx contractapi.TransactionContextInterface, commodityID string) (*models.PriceStats, error) {
+	key := utils.GetPriceStatsKey(commodityID)
+	statsJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read price stats: %v", err)
+	}
+	if statsJSON == nil {
+		return nil, fmt.Errorf("no trades recorded for commodity %s", commodityID)
+	}
+
+	var stats models.PriceStats
+	err = json.Unmarshal(statsJSON, &stats)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal price stats: %v", err)
+	}
+
+	return &stats, nil
+}
+
+// GetPriceCandles retrieves a commodity's candles of the given interval ("1m", "1h" or "1d")
+// whose bucket starts within [startTime, endTime), in chronological order
+func (d *MarketDataContract) GetPriceCandles(ctx contractapi.TransactionContextInterface, commodityID, interval, startTime, endTime string) ([]*models.PriceCandle, error) {
+	if err := checkCandleInterval(interval); err != nil {
+		return nil, err
+	}
+
+	start, err := utils.ParseTimestamp(startTime)
+	if err != nil {
+		return nil, err
+	}
+	end, err := utils.ParseTimestamp(endTime)
+	if err != nil {
+		return nil, err
+	}
+
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(utils.PriceCandleObjectType, []string{commodityID, interval})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get price candle iterator: %v", err)
+	}
+	defer iterator.Close()
+
+	var candles []*models.PriceCandle
+	for iterator.HasNext() {
+		queryResponse, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate price candles: %v", err)
+		}
+
+		var candle models.PriceCandle
+		err = json.Unmarshal(queryResponse.Value, &candle)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal price candle: %v", err)
+		}
+
+		if candle.BucketStart.Before(start) {
+			continue
+		}
+		if !candle.BucketStart.Before(end) {
+			// Candles are sorted by bucket start
+			break
+		}
+		candles = append(candles, &candle)
+	}
+
+	return candles, nil
+}
+
+// recordExecution folds an executed trade into the commodity's price statistics and candles
+func (d *MarketDataContract) recordExecution(ctx contractapi.TransactionContextInterface, commodityID string, quantity int, unitPrice float64) error {
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	turnover := unitPrice * float64(quantity)
+
+	// 1. Update running statistics
+	statsJSON, err := ctx.GetStub().GetState(utils.GetPriceStatsKey(commodityID))
+	if err != nil {
+		return fmt.Errorf("failed to read price stats: %v", err)
+	}
+
+	stats := models.PriceStats{CommodityID: commodityID}
+	if statsJSON != nil {
+		err = json.Unmarshal(statsJSON, &stats)
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal price stats: %v", err)
+		}
+	}
+	stats.LastPrice = unitPrice
+	stats.Volume += quantity
+	stats.Turnover += turnover
+	stats.VWAP = stats.Turnover / float64(stats.Volume)
+	stats.TradeCount++
+	stats.UpdatedAt = timestamp
+
+	statsJSON, err = json.Marshal(stats)
+	if err != nil {
+		return fmt.Errorf("failed to marshal price stats: %v", err)
+	}
+	err = ctx.GetStub().PutState(utils.GetPriceStatsKey(commodityID), statsJSON)
+	if err != nil {
+		return fmt.Errorf("failed to save price stats: %v", err)
+	}
+
+	// 2. Update the candle of every interval
+	for _, interval := range candleIntervals {
+		bucketStart := timestamp.UTC().Truncate(interval.Duration)
+		key, err := utils.GetPriceCandleKey(ctx, commodityID, interval.Name, bucketStart)
+		if err != nil {
+			return fmt.Errorf("failed to create price candle key: %v", err)
+		}
+
+		candleJSON, err := ctx.GetStub().GetState(key)
+		if err != nil {
+			return fmt.Errorf("failed to read price candle: %v", err)
+		}
+
+		var candle models.PriceCandle
+		if candleJSON == nil {
+			candle = models.PriceCandle{
+				CommodityID: commodityID,
+				Interval:    interval.Name,
+				BucketStart: bucketStart,
+				Open:        unitPrice,
+				High:        unitPrice,
+				Low:         unitPrice,
+			}
+		} else {
+			err = json.Unmarshal(candleJSON, &candle)
+			if err != nil {
+				return fmt.Errorf("failed to unmarshal price candle: %v", err)
+			}
+		}
+
+		if unitPrice > candle.High {
+			candle.High = unitPrice
+		}
+		if unitPrice < candle.Low {
+			candle.Low = unitPrice
+		}
+		candle.Close = unitPrice
+		candle.Volume += quantity
+		candle.Turnover += turnover
+		candle.TradeCount++
+
+		candleJSON, err = json.Marshal(candle)
+		if err != nil {
+			return fmt.Errorf("failed to marshal price candle: %v", err)
+		}
+		err = ctx.GetStub().PutState(key, candleJSON)
+		if err != nil {
+			return fmt.Errorf("failed to save price candle: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// checkCandleInterval verifies interval is one of the maintained candle sizes
+func checkCandleInterval(interval string) error {
+	for _, candidate := range candleIntervals {
+		if candidate.Name == interval {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid candle interval: %s (must be '1m', '1h' or '1d')", interval)
+}
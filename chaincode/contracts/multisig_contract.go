@@ -0,0 +1,506 @@
+package contracts
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-samples/game-chaincode/models"
+	"github.com/hyperledger/fabric-samples/game-chaincode/utils"
+)
+
+// Operations that can be executed through multisig proposals
+const (
+	OperationMint                 = "mint"                   // {"userId","amount"}
+	OperationMintItems            = "mint_items"             // {"userId","commodityId","quantity"}
+	OperationAdjustBalance        = "adjust_balance"         // {"userId","amount","operation"}
+	OperationAdjustInventory      = "adjust_inventory"       // {"userId","commodityId","quantity","operation"}
+	OperationTransfer             = "transfer"               // {"fromUserId","toUserId","amount"}
+	OperationCreateRedemptionRule = "create_redemption_rule" // {"userId","requiredItems","rewardAmount"}
+	OperationSetPolicy            = "set_policy"             // {"approvers","threshold","ttlSeconds"}
+
+	OperationCreateOracleRedemptionRule = "create_oracle_redemption_rule" // {"userId","requiredItems","rewardRate"}
+)
+
+// proposalArgs holds the union of the arguments of every multisig operation
+type proposalArgs struct {
+	UserID        string          `json:"userId"`
+	FromUserID    string          `json:"fromUserId"`
+	ToUserID      string          `json:"toUserId"`
+	CommodityID   string          `json:"commodityId"`
+	Amount        float64         `json:"amount"`
+	Quantity      int             `json:"quantity"`
+	Operation     string          `json:"operation"`
+	RequiredItems json.RawMessage `json:"requiredItems"`
+	RewardAmount  float64         `json:"rewardAmount"`
+	RewardRate    float64         `json:"rewardRate"`
+	Approvers     []string        `json:"approvers"`
+	Threshold     int             `json:"threshold"`
+	TTLSeconds    int             `json:"ttlSeconds"`
+}
+
+// MultisigContract provides M-of-N approval of high-value operations. An
+// operation is proposed with its serialized arguments and executes in the
+// transaction that records the threshold-th approval.
+type MultisigContract struct {
+	contractapi.Contract
+	AssetContract      *AssetContract
+	RedemptionContract *RedemptionContract
+}
+
+// InitMultisigPolicy sets the first approval policy (admin only). Later policy
+// changes go through a set_policy proposal.
+func (m *MultisigContract) InitMultisigPolicy(ctx contractapi.TransactionContextInterface, approversJSON string, threshold int, ttlSeconds int) error {
+	if _, err := requireRole(ctx, RoleAdmin); err != nil {
+		return err
+	}
+
+	existing, err := ctx.GetStub().GetState(utils.MultisigPolicyKey)
+	if err != nil {
+		return fmt.Errorf("failed to read multisig policy: %v", err)
+	}
+	if existing != nil {
+		return fmt.Errorf("multisig policy already initialized; propose %s to change it", OperationSetPolicy)
+	}
+
+	var approvers []string
+	err = json.Unmarshal([]byte(approversJSON), &approvers)
+	if err != nil {
+		return fmt.Errorf("failed to parse approvers: %v", err)
+	}
+
+	return m.setPolicy(ctx, approvers, threshold, ttlSeconds)
+}
+
+// GetMultisigPolicy retrieves the approval policy
+func (m *MultisigContract) GetMultisigPolicy(ctx contractapi.TransactionContextInterface) (*models.MultisigPolicy, error) {
+	policyJSON, err := ctx.GetStub().GetState(utils.MultisigPolicyKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read multisig policy: %v", err)
+	}
+	if policyJSON == nil {
+		return nil, fmt.Errorf("multisig policy is not initialized")
+	}
+
+	var policy models.MultisigPolicy
+	err = json.Unmarshal(policyJSON, &policy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal multisig policy: %v", err)
+	}
+
+	return &policy, nil
+}
+
+// CreateProposal proposes an operation with its arguments as JSON. The caller
+// must be an approver and counts as the first approval.
+func (m *MultisigContract) CreateProposal(ctx contractapi.TransactionContextInterface, proposalID, operation, argsJSON string) error {
+	policy, err := m.GetMultisigPolicy(ctx)
+	if err != nil {
+		return err
+	}
+
+	callerID, err := utils.GetCallerID(ctx)
+	if err != nil {
+		return err
+	}
+	if !containsString(policy.Approvers, callerID) {
+		return fmt.Errorf("caller is not a multisig approver")
+	}
+
+	existing, err := m.GetProposal(ctx, proposalID)
+	if err == nil && existing != nil {
+		return fmt.Errorf("proposal %s already exists", proposalID)
+	}
+
+	// Validate the operation without executing it
+	err = m.runOperation(ctx, operation, argsJSON, false)
+	if err != nil {
+		return fmt.Errorf("invalid proposal: %v", err)
+	}
+
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	proposal := models.Proposal{
+		ProposalID: proposalID,
+		Operation:  operation,
+		Args:       argsJSON,
+		ProposerID: callerID,
+		Approvers:  policy.Approvers,
+		Threshold:  policy.Threshold,
+		Approvals:  []string{},
+		Status:     "pending",
+		CreatedAt:  timestamp,
+		ExpiresAt:  timestamp.Add(time.Duration(policy.TTLSeconds) * time.Second),
+	}
+
+	return m.approve(ctx, &proposal, callerID)
+}
+
+// ApproveProposal records the caller's approval, executing the operation once
+// the threshold is met. If execution fails the approval is not recorded.
+func (m *MultisigContract) ApproveProposal(ctx contractapi.TransactionContextInterface, proposalID string) error {
+	proposal, err := m.GetProposal(ctx, proposalID)
+	if err != nil {
+		return err
+	}
+
+	if proposal.Status != "pending" {
+		return fmt.Errorf("proposal is not pending (status: %s)", proposal.Status)
+	}
+
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	if !timestamp.Before(proposal.ExpiresAt) {
+		return fmt.Errorf("proposal %s expired at %s", proposalID, proposal.ExpiresAt.Format(time.RFC3339))
+	}
+
+	callerID, err := utils.GetCallerID(ctx)
+	if err != nil {
+		return err
+	}
+	if !containsString(proposal.Approvers, callerID) {
+		return fmt.Errorf("caller is not an approver of proposal %s", proposalID)
+	}
+	if containsString(proposal.Approvals, callerID) {
+		return fmt.Errorf("caller already approved proposal %s", proposalID)
+	}
+
+	return m.approve(ctx, proposal, callerID)
+}
+
+// ExpireProposal marks a pending proposal past its expiry as expired
+func (m *MultisigContract) ExpireProposal(ctx contractapi.TransactionContextInterface, proposalID string) error {
+	proposal, err := m.GetProposal(ctx, proposalID)
+	if err != nil {
+		return err
+	}
+
+	if proposal.Status != "pending" {
+		return fmt.Errorf("proposal is not pending (status: %s)", proposal.Status)
+	}
+
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	if timestamp.Before(proposal.ExpiresAt) {
+		return fmt.Errorf("proposal %s has not expired yet", proposalID)
+	}
+
+	proposal.Status = "expired"
+	return m.putProposal(ctx, proposal)
+}
+
+// GetProposal retrieves a proposal by ID
+func (m *MultisigContract) GetProposal(ctx contractapi.TransactionContextInterface, proposalID string) (*models.Proposal, error) {
+	proposalJSON, err := ctx.GetStub().GetState(utils.GetProposalKey(proposalID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read proposal: %v", err)
+	}
+	if proposalJSON == nil {
+		return nil, fmt.Errorf("proposal %s not found", proposalID)
+	}
+
+	var proposal models.Proposal
+	err = json.Unmarshal(proposalJSON, &proposal)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal proposal: %v", err)
+	}
+
+	return &proposal, nil
+}
+
+// GetPendingProposals retrieves every pending proposal that has not expired
+func (m *MultisigContract) GetPendingProposals(ctx contractapi.TransactionContextInterface) ([]*models.Proposal, error) {
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	iterator, err := ctx.GetStub().GetStateByRange(utils.ProposalPrefix, utils.ProposalPrefix+"\uffff")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get proposal iterator: %v", err)
+	}
+	defer iterator.Close()
+
+	var proposals []*models.Proposal
+	for iterator.HasNext() {
+		queryResponse, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate proposals: %v", err)
+		}
+
+		var proposal models.Proposal
+		err = json.Unmarshal(queryResponse.Value, &proposal)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal proposal: %v", err)
+		}
+
+		if proposal.Status == "pending" && timestamp.Before(proposal.ExpiresAt) {
+			proposals = append(proposals, &proposal)
+		}
+	}
+
+	return proposals, nil
+}
+
+// approve adds an approval to a proposal and executes it once the threshold is met
+func (m *MultisigContract) approve(ctx contractapi.TransactionContextInterface, proposal *models.Proposal, approverID string) error {
+	proposal.Approvals = append(proposal.Approvals, approverID)
+
+	if len(proposal.Approvals) >= proposal.Threshold {
+		err := m.runOperation(ctx, proposal.Operation, proposal.Args, true)
+		if err != nil {
+			return fmt.Errorf("failed to execute proposal %s: %v", proposal.ProposalID, err)
+		}
+
+		// Get deterministic timestamp
+		timestamp, err := utils.GetTxTimestamp(ctx)
+		if err != nil {
+			return err
+		}
+		proposal.Status = "executed"
+		proposal.ExecutedAt = timestamp
+
+		eventPayload := map[string]interface{}{
+			"proposalId": proposal.ProposalID,
+			"operation":  proposal.Operation,
+			"args":       proposal.Args,
+			"approvals":  proposal.Approvals,
+			"timestamp":  timestamp,
+		}
+		eventJSON, _ := json.Marshal(eventPayload)
+		ctx.GetStub().SetEvent("ProposalExecuted", eventJSON)
+	}
+
+	return m.putProposal(ctx, proposal)
+}
+
+// runOperation decodes and validates an operation's arguments and, if execute
+// is set, performs it
+func (m *MultisigContract) runOperation(ctx contractapi.TransactionContextInterface, operation, argsJSON string, execute bool) error {
+	var args proposalArgs
+	err := json.Unmarshal([]byte(argsJSON), &args)
+	if err != nil {
+		return fmt.Errorf("failed to parse arguments: %v", err)
+	}
+
+	// Initialize asset contract if not set
+	if m.AssetContract == nil {
+		m.AssetContract = &AssetContract{}
+	}
+	// Initialize redemption contract if not set
+	if m.RedemptionContract == nil {
+		m.RedemptionContract = &RedemptionContract{AssetContract: m.AssetContract}
+	}
+
+	switch operation {
+	case OperationMint:
+		if args.Amount <= 0 {
+			return fmt.Errorf("amount must be positive")
+		}
+		if _, err := m.AssetContract.GetUserAssets(ctx, args.UserID); err != nil {
+			return err
+		}
+		if execute {
+			return m.AssetContract.updateBalance(ctx, args.UserID, args.Amount, "add")
+		}
+
+	case OperationMintItems:
+		if args.Quantity <= 0 || args.CommodityID == "" {
+			return fmt.Errorf("commodity and a positive quantity are required")
+		}
+		if _, err := m.AssetContract.GetUserAssets(ctx, args.UserID); err != nil {
+			return err
+		}
+		if execute {
+			return m.AssetContract.updateInventory(ctx, args.UserID, args.CommodityID, args.Quantity, "add")
+		}
+
+	case OperationAdjustBalance:
+		if args.Amount <= 0 {
+			return fmt.Errorf("amount must be positive")
+		}
+		if args.Operation != "add" && args.Operation != "subtract" {
+			return fmt.Errorf("invalid operation: %s", args.Operation)
+		}
+		if _, err := m.AssetContract.GetUserAssets(ctx, args.UserID); err != nil {
+			return err
+		}
+		if execute {
+			return m.AssetContract.updateBalance(ctx, args.UserID, args.Amount, args.Operation)
+		}
+
+	case OperationAdjustInventory:
+		if args.Quantity <= 0 || args.CommodityID == "" {
+			return fmt.Errorf("commodity and a positive quantity are required")
+		}
+		if args.Operation != "add" && args.Operation != "subtract" {
+			return fmt.Errorf("invalid operation: %s", args.Operation)
+		}
+		if _, err := m.AssetContract.GetUserAssets(ctx, args.UserID); err != nil {
+			return err
+		}
+		if execute {
+			return m.AssetContract.updateInventory(ctx, args.UserID, args.CommodityID, args.Quantity, args.Operation)
+		}
+
+	case OperationTransfer:
+		if args.Amount <= 0 {
+			return fmt.Errorf("amount must be positive")
+		}
+		if args.FromUserID == args.ToUserID {
+			return fmt.Errorf("cannot transfer to the same user")
+		}
+		for _, userID := range []string{args.FromUserID, args.ToUserID} {
+			if _, err := m.AssetContract.GetUserAssets(ctx, userID); err != nil {
+				return err
+			}
+		}
+		if execute {
+			err = m.AssetContract.updateBalance(ctx, args.FromUserID, args.Amount, "subtract")
+			if err != nil {
+				return err
+			}
+			return m.AssetContract.updateBalance(ctx, args.ToUserID, args.Amount, "add")
+		}
+
+	case OperationCreateRedemptionRule:
+		if args.RewardAmount <= 0 {
+			return fmt.Errorf("reward amount must be positive")
+		}
+		var requiredItems []models.RequiredItem
+		err = json.Unmarshal(args.RequiredItems, &requiredItems)
+		if err != nil || len(requiredItems) == 0 {
+			return fmt.Errorf("required items must be a non-empty array")
+		}
+		if execute {
+			return m.RedemptionContract.createRule(ctx, args.UserID, string(args.RequiredItems), args.RewardAmount, 0)
+		}
+
+	case OperationCreateOracleRedemptionRule:
+		if args.RewardRate <= 0 {
+			return fmt.Errorf("reward rate must be positive")
+		}
+		var requiredItems []models.RequiredItem
+		err = json.Unmarshal(args.RequiredItems, &requiredItems)
+		if err != nil || len(requiredItems) == 0 {
+			return fmt.Errorf("required items must be a non-empty array")
+		}
+		if execute {
+			return m.RedemptionContract.createRule(ctx, args.UserID, string(args.RequiredItems), 0, args.RewardRate)
+		}
+
+	case OperationSetPolicy:
+		err = checkPolicy(args.Approvers, args.Threshold, args.TTLSeconds)
+		if err != nil {
+			return err
+		}
+		if execute {
+			return m.setPolicy(ctx, args.Approvers, args.Threshold, args.TTLSeconds)
+		}
+
+	default:
+		return fmt.Errorf("unknown operation: %s", operation)
+	}
+
+	return nil
+}
+
+// setPolicy validates and stores the approval policy
+func (m *MultisigContract) setPolicy(ctx contractapi.TransactionContextInterface, approvers []string, threshold, ttlSeconds int) error {
+	err := checkPolicy(approvers, threshold, ttlSeconds)
+	if err != nil {
+		return err
+	}
+
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	policy := models.MultisigPolicy{
+		Approvers:  approvers,
+		Threshold:  threshold,
+		TTLSeconds: ttlSeconds,
+		UpdatedAt:  timestamp,
+	}
+
+	policyJSON, err := json.Marshal(policy)
+	if err != nil {
+		return fmt.Errorf("failed to marshal multisig policy: %v", err)
+	}
+	return ctx.GetStub().PutState(utils.MultisigPolicyKey, policyJSON)
+}
+
+// putProposal writes a proposal to the ledger
+func (m *MultisigContract) putProposal(ctx contractapi.TransactionContextInterface, proposal *models.Proposal) error {
+	proposalJSON, err := json.Marshal(proposal)
+	if err != nil {
+		return fmt.Errorf("failed to marshal proposal: %v", err)
+	}
+	err = ctx.GetStub().PutState(utils.GetProposalKey(proposal.ProposalID), proposalJSON)
+	if err != nil {
+		return fmt.Errorf("failed to save proposal: %v", err)
+	}
+	return nil
+}
+
+// requireDirectOperation allows an admin to perform a multisig operation
+// directly only while no multisig policy exists; afterwards it must be proposed
+func requireDirectOperation(ctx contractapi.TransactionContextInterface, operation string) error {
+	if _, err := requireRole(ctx, RoleAdmin); err != nil {
+		return err
+	}
+
+	policyJSON, err := ctx.GetStub().GetState(utils.MultisigPolicyKey)
+	if err != nil {
+		return fmt.Errorf("failed to read multisig policy: %v", err)
+	}
+	if policyJSON != nil {
+		return fmt.Errorf("multisig policy is active; propose %s instead", operation)
+	}
+	return nil
+}
+
+// checkPolicy verifies approvers are distinct and the threshold is reachable
+func checkPolicy(approvers []string, threshold, ttlSeconds int) error {
+	if len(approvers) == 0 {
+		return fmt.Errorf("approvers cannot be empty")
+	}
+	seen := make(map[string]bool)
+	for _, approver := range approvers {
+		if approver == "" || seen[approver] {
+			return fmt.Errorf("approvers must be distinct and non-empty")
+		}
+		seen[approver] = true
+	}
+	if threshold <= 0 || threshold > len(approvers) {
+		return fmt.Errorf("threshold must be between 1 and %d", len(approvers))
+	}
+	if ttlSeconds <= 0 {
+		return fmt.Errorf("TTL must be positive")
+	}
+	return nil
+}
+
+// containsString reports whether values contains value
+func containsString(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,214 @@
+package contracts
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-samples/game-chaincode/models"
+	"github.com/hyperledger/fabric-samples/game-chaincode/utils"
+)
+
+// Default oracle rules used until SetOracleConfig is called
+const (
+	defaultOracleMaxAgeSeconds  = 3600
+	defaultOracleMinSubmissions = 1
+)
+
+// OracleContract provides reference prices aggregated from authorized price feeders
+type OracleContract struct {
+	contractapi.Contract
+}
+
+// SetOracleConfig sets the staleness window and the minimum number of fresh submissions (admin only)
+func (o *OracleContract) SetOracleConfig(ctx contractapi.TransactionContextInterface, maxAgeSeconds int, minSubmissions int) error {
+	if _, err := requireRole(ctx, RoleAdmin); err != nil {
+		return err
+	}
+
+	if maxAgeSeconds <= 0 {
+		return fmt.Errorf("max age must be positive")
+	}
+	if minSubmissions <= 0 {
+		return fmt.Errorf("minimum submissions must be positive")
+	}
+
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	config := models.OracleConfig{
+		MaxAgeSeconds:  maxAgeSeconds,
+		MinSubmissions: minSubmissions,
+		UpdatedAt:      timestamp,
+	}
+
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("failed to marshal oracle config: %v", err)
+	}
+	return ctx.GetStub().PutState(utils.OracleConfigKey, configJSON)
+}
+
+// GetOracleConfig retrieves the oracle rules, falling back to the defaults
+func (o *OracleContract) GetOracleConfig(ctx contractapi.TransactionContextInterface) (*models.OracleConfig, error) {
+	configJSON, err := ctx.GetStub().GetState(utils.OracleConfigKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read oracle config: %v", err)
+	}
+	if configJSON == nil {
+		return &models.OracleConfig{
+			MaxAgeSeconds:  defaultOracleMaxAgeSeconds,
+			MinSubmissions: defaultOracleMinSubmissions,
+		}, nil
+	}
+
+	var config models.OracleConfig
+	err = json.Unmarshal(configJSON, &config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal oracle config: %v", err)
+	}
+
+	return &config, nil
+}
+
+// SubmitPrice records the calling price feeder's price for a commodity,
+// replacing the feeder's previous submission
+func (o *OracleContract) SubmitPrice(ctx contractapi.TransactionContextInterface, commodityID string, price float64) error {
+	feederID, err := requireRole(ctx, RolePriceFeeder)
+	if err != nil {
+		return err
+	}
+
+	if price <= 0 {
+		return fmt.Errorf("price must be positive")
+	}
+
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	submission := models.PriceSubmission{
+		CommodityID: commodityID,
+		FeederID:    feederID,
+		Price:       price,
+		SubmittedAt: timestamp,
+	}
+
+	submissionJSON, err := json.Marshal(submission)
+	if err != nil {
+		return fmt.Errorf("failed to marshal price submission: %v", err)
+	}
+
+	key, err := utils.GetPriceSubmissionKey(ctx, commodityID, feederID)
+	if err != nil {
+		return fmt.Errorf("failed to create price submission key: %v", err)
+	}
+	err = ctx.GetStub().PutState(key, submissionJSON)
+	if err != nil {
+		return fmt.Errorf("failed to save price submission: %v", err)
+	}
+
+	eventJSON, _ := json.Marshal(submission)
+	ctx.GetStub().SetEvent("PriceSubmitted", eventJSON)
+
+	return nil
+}
+
+// GetPriceSubmissions retrieves every feeder's latest submission for a commodity
+func (o *OracleContract) GetPriceSubmissions(ctx contractapi.TransactionContextInterface, commodityID string) ([]*models.PriceSubmission, error) {
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(utils.PriceSubmissionObjectType, []string{commodityID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get price submission iterator: %v", err)
+	}
+	defer iterator.Close()
+
+	var submissions []*models.PriceSubmission
+	for iterator.HasNext() {
+		queryResponse, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate price submissions: %v", err)
+		}
+
+		var submission models.PriceSubmission
+		err = json.Unmarshal(queryResponse.Value, &submission)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal price submission: %v", err)
+		}
+
+		submissions = append(submissions, &submission)
+	}
+
+	return submissions, nil
+}
+
+// GetReferencePrice returns the median of the fresh submissions for a commodity.
+// It fails if fewer than the configured minimum of submissions are fresh.
+func (o *OracleContract) GetReferencePrice(ctx contractapi.TransactionContextInterface, commodityID string) (*models.ReferencePrice, error) {
+	config, err := o.GetOracleConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	submissions, err := o.GetPriceSubmissions(ctx, commodityID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cutoff := timestamp.Add(-time.Duration(config.MaxAgeSeconds) * time.Second)
+
+	var prices []float64
+	var oldest time.Time
+	for _, submission := range submissions {
+		if submission.SubmittedAt.Before(cutoff) {
+			continue
+		}
+		prices = append(prices, submission.Price)
+		if oldest.IsZero() || submission.SubmittedAt.Before(oldest) {
+			oldest = submission.SubmittedAt
+		}
+	}
+
+	if len(prices) < config.MinSubmissions {
+		return nil, fmt.Errorf("no reference price for commodity %s (%d fresh submissions, %d required)",
+			commodityID, len(prices), config.MinSubmissions)
+	}
+
+	sort.Float64s(prices)
+	median := prices[len(prices)/2]
+	if len(prices)%2 == 0 {
+		median = (prices[len(prices)/2-1] + median) / 2
+	}
+
+	return &models.ReferencePrice{
+		CommodityID: commodityID,
+		Price:       median,
+		Submissions: len(prices),
+		OldestAt:    oldest,
+		ComputedAt:  timestamp,
+	}, nil
+}
+
+// valueItems returns the reference value of a list of items
+func (o *OracleContract) valueItems(ctx contractapi.TransactionContextInterface, items []models.RequiredItem) (float64, error) {
+	var total float64
+	for _, item := range items {
+		price, err := o.GetReferencePrice(ctx, item.CommodityID)
+		if err != nil {
+			return 0, err
+		}
+		total += price.Price * float64(item.Quantity)
+	}
+	return total, nil
+}
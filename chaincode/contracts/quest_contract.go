@@ -0,0 +1,417 @@
+package contracts
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-samples/game-chaincode/models"
+	"github.com/hyperledger/fabric-samples/game-chaincode/utils"
+)
+
+// Activities a quest can track
+const (
+	QuestMetricTrades      = "trades"      // completed trades the user took part in
+	QuestMetricRedemptions = "redemptions" // executed redemptions
+	QuestMetricHold        = "hold"        // quantity of a commodity held
+)
+
+// QuestContract provides achievements and quests whose progress is driven by ledger activity
+type QuestContract struct {
+	contractapi.Contract
+	AssetContract *AssetContract
+}
+
+// CreateQuest defines a quest (admin only). rewardItemsJSON is an optional JSON
+// array of {"commodityId","quantity"} items granted on top of rewardAmount.
+// Progress is counted from the quest's creation.
+func (q *QuestContract) CreateQuest(ctx contractapi.TransactionContextInterface, questID, name, metric, commodityID string, target int, rewardAmount float64, rewardItemsJSON string) error {
+	if _, err := requireRole(ctx, RoleAdmin); err != nil {
+		return err
+	}
+
+	switch metric {
+	case QuestMetricTrades, QuestMetricRedemptions:
+		if commodityID != "" {
+			return fmt.Errorf("%s quests do not take a commodity", metric)
+		}
+	case QuestMetricHold:
+		if commodityID == "" {
+			return fmt.Errorf("hold quests require a commodity")
+		}
+	default:
+		return fmt.Errorf("invalid quest metric: %s (must be 'trades', 'redemptions' or 'hold')", metric)
+	}
+
+	if target <= 0 {
+		return fmt.Errorf("target must be positive")
+	}
+	if rewardAmount < 0 {
+		return fmt.Errorf("reward amount cannot be negative")
+	}
+
+	var rewardItems []models.RequiredItem
+	if rewardItemsJSON != "" {
+		err := json.Unmarshal([]byte(rewardItemsJSON), &rewardItems)
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal reward items: %v", err)
+		}
+		for _, item := range rewardItems {
+			if item.Quantity <= 0 {
+				return fmt.Errorf("reward item quantities must be positive")
+			}
+		}
+		rewardItems = mergeItems(rewardItems)
+	}
+
+	existing, err := q.GetQuest(ctx, questID)
+	if err == nil && existing != nil {
+		return fmt.Errorf("quest %s already exists", questID)
+	}
+
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	quest := models.Quest{
+		QuestID:      questID,
+		Name:         name,
+		Metric:       metric,
+		CommodityID:  commodityID,
+		Target:       target,
+		RewardAmount: rewardAmount,
+		RewardItems:  rewardItems,
+		Active:       true,
+		CreatedAt:    timestamp,
+	}
+
+	err = q.putQuest(ctx, &quest)
+	if err != nil {
+		return err
+	}
+
+	indexKey, err := utils.GetQuestIndexKey(ctx, metric, commodityID, questID)
+	if err != nil {
+		return fmt.Errorf("failed to create quest index key: %v", err)
+	}
+	return ctx.GetStub().PutState(indexKey, []byte{0x00})
+}
+
+// DeactivateQuest stops tracking progress on a quest (admin only). Completed
+// quests can still be claimed.
+func (q *QuestContract) DeactivateQuest(ctx contractapi.TransactionContextInterface, questID string) error {
+	if _, err := requireRole(ctx, RoleAdmin); err != nil {
+		return err
+	}
+
+	quest, err := q.GetQuest(ctx, questID)
+	if err != nil {
+		return err
+	}
+	if !quest.Active {
+		return fmt.Errorf("quest %s is not active", questID)
+	}
+
+	quest.Active = false
+	err = q.putQuest(ctx, quest)
+	if err != nil {
+		return err
+	}
+
+	indexKey, err := utils.GetQuestIndexKey(ctx, quest.Metric, quest.CommodityID, questID)
+	if err != nil {
+		return fmt.Errorf("failed to create quest index key: %v", err)
+	}
+	return ctx.GetStub().DelState(indexKey)
+}
+
+// GetQuest retrieves a quest by ID
+func (q *QuestContract) GetQuest(ctx contractapi.TransactionContextInterface, questID string) (*models.Quest, error) {
+	questJSON, err := ctx.GetStub().GetState(utils.GetQuestKey(questID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read quest: %v", err)
+	}
+	if questJSON == nil {
+		return nil, fmt.Errorf("quest %s not found", questID)
+	}
+
+	var quest models.Quest
+	err = json.Unmarshal(questJSON, &quest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal quest: %v", err)
+	}
+
+	return &quest, nil
+}
+
+// GetAllQuests retrieves every quest
+func (q *QuestContract) GetAllQuests(ctx contractapi.TransactionContextInterface) ([]*models.Quest, error) {
+	iterator, err := ctx.GetStub().GetStateByRange(utils.QuestPrefix, utils.QuestPrefix+"\uffff")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get quest iterator: %v", err)
+	}
+	defer iterator.Close()
+
+	var quests []*models.Quest
+	for iterator.HasNext() {
+		queryResponse, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate quests: %v", err)
+		}
+
+		var quest models.Quest
+		err = json.Unmarshal(queryResponse.Value, &quest)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal quest: %v", err)
+		}
+
+		quests = append(quests, &quest)
+	}
+
+	return quests, nil
+}
+
+// GetQuestProgress retrieves a user's progress on a quest
+func (q *QuestContract) GetQuestProgress(ctx contractapi.TransactionContextInterface, questID, userID string) (*models.QuestProgress, error) {
+	if _, err := q.GetQuest(ctx, questID); err != nil {
+		return nil, err
+	}
+
+	progress, err := q.getProgress(ctx, questID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if progress == nil {
+		return &models.QuestProgress{QuestID: questID, UserID: userID}, nil
+	}
+
+	return progress, nil
+}
+
+// GetUserQuests retrieves a user's progress on every quest they have started
+func (q *QuestContract) GetUserQuests(ctx contractapi.TransactionContextInterface, userID string) ([]*models.QuestProgress, error) {
+	quests, err := q.GetAllQuests(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var progresses []*models.QuestProgress
+	for _, quest := range quests {
+		progress, err := q.getProgress(ctx, quest.QuestID, userID)
+		if err != nil {
+			return nil, err
+		}
+		if progress != nil {
+			progresses = append(progresses, progress)
+		}
+	}
+
+	return progresses, nil
+}
+
+// ClaimQuestReward pays the reward of a completed quest to the user
+func (q *QuestContract) ClaimQuestReward(ctx contractapi.TransactionContextInterface, questID, userID string) error {
+	quest, err := q.GetQuest(ctx, questID)
+	if err != nil {
+		return err
+	}
+
+	progress, err := q.getProgress(ctx, questID, userID)
+	if err != nil {
+		return err
+	}
+	if progress == nil || !progress.Completed {
+		return fmt.Errorf("user %s has not completed quest %s", userID, questID)
+	}
+	if progress.Claimed {
+		return fmt.Errorf("user %s already claimed quest %s", userID, questID)
+	}
+
+	// Initialize asset contract if not set
+	if q.AssetContract == nil {
+		q.AssetContract = &AssetContract{QuestContract: q}
+	}
+
+	// 1. Pay the reward
+	if quest.RewardAmount > 0 {
+		err = q.AssetContract.updateBalance(ctx, userID, quest.RewardAmount, "add")
+		if err != nil {
+			return fmt.Errorf("failed to add reward balance: %v", err)
+		}
+	}
+	// Quests created before reward items were merged may repeat a commodity
+	rewardItems := mergeItems(quest.RewardItems)
+	err = checkStorageCapacity(ctx, q.AssetContract, userID, rewardItems)
+	if err != nil {
+		return err
+	}
+	for _, item := range rewardItems {
+		err = q.AssetContract.updateInventory(ctx, userID, item.CommodityID, item.Quantity, "add")
+		if err != nil {
+			return fmt.Errorf("failed to add reward item %s: %v", item.CommodityID, err)
+		}
+	}
+
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	// 2. Mark the quest claimed
+	progress.Claimed = true
+	progress.ClaimedAt = timestamp
+	progress.UpdatedAt = timestamp
+	err = q.putProgress(ctx, progress)
+	if err != nil {
+		return err
+	}
+
+	// 3. Emit event
+	eventPayload := map[string]interface{}{
+		"questId":      quest.QuestID,
+		"userId":       userID,
+		"rewardAmount": quest.RewardAmount,
+		"rewardItems":  rewardItems,
+		"timestamp":    timestamp,
+	}
+	eventJSON, _ := json.Marshal(eventPayload)
+	ctx.GetStub().SetEvent("QuestRewardClaimed", eventJSON)
+
+	return nil
+}
+
+// recordActivity adds delta to the user's progress on every active quest counting metric
+func (q *QuestContract) recordActivity(ctx contractapi.TransactionContextInterface, metric, userID string, delta int) error {
+	return q.updateQuests(ctx, metric, "", userID, func(progress int) int {
+		return progress + delta
+	})
+}
+
+// recordHolding sets the user's progress on every active quest for holding commodityID
+func (q *QuestContract) recordHolding(ctx contractapi.TransactionContextInterface, userID, commodityID string, quantity int) error {
+	return q.updateQuests(ctx, QuestMetricHold, commodityID, userID, func(int) int {
+		return quantity
+	})
+}
+
+// updateQuests applies next to the user's progress on every active quest
+// indexed under metric and commodityID, completing quests that reach their target
+func (q *QuestContract) updateQuests(ctx contractapi.TransactionContextInterface, metric, commodityID, userID string, next func(progress int) int) error {
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(utils.QuestIndexObjectType, []string{metric, commodityID})
+	if err != nil {
+		return fmt.Errorf("failed to get quest index iterator: %v", err)
+	}
+	defer iterator.Close()
+
+	var questIDs []string
+	for iterator.HasNext() {
+		queryResponse, err := iterator.Next()
+		if err != nil {
+			return fmt.Errorf("failed to iterate quest index: %v", err)
+		}
+
+		_, attributes, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil {
+			return fmt.Errorf("failed to split quest index key: %v", err)
+		}
+		questIDs = append(questIDs, attributes[2])
+	}
+	if len(questIDs) == 0 {
+		return nil
+	}
+
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, questID := range questIDs {
+		quest, err := q.GetQuest(ctx, questID)
+		if err != nil {
+			return err
+		}
+
+		progress, err := q.getProgress(ctx, questID, userID)
+		if err != nil {
+			return err
+		}
+		if progress == nil {
+			progress = &models.QuestProgress{QuestID: questID, UserID: userID}
+		}
+		if progress.Completed {
+			continue
+		}
+
+		progress.Progress = next(progress.Progress)
+		if progress.Progress >= quest.Target {
+			progress.Completed = true
+			progress.CompletedAt = timestamp
+		}
+		progress.UpdatedAt = timestamp
+
+		err = q.putProgress(ctx, progress)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getProgress retrieves a user's progress on a quest, or nil if the user has none
+func (q *QuestContract) getProgress(ctx contractapi.TransactionContextInterface, questID, userID string) (*models.QuestProgress, error) {
+	key, err := utils.GetQuestProgressKey(ctx, questID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create quest progress key: %v", err)
+	}
+	progressJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read quest progress: %v", err)
+	}
+	if progressJSON == nil {
+		return nil, nil
+	}
+
+	var progress models.QuestProgress
+	err = json.Unmarshal(progressJSON, &progress)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal quest progress: %v", err)
+	}
+
+	return &progress, nil
+}
+
+// putProgress writes a user's quest progress to the ledger
+func (q *QuestContract) putProgress(ctx contractapi.TransactionContextInterface, progress *models.QuestProgress) error {
+	progressJSON, err := json.Marshal(progress)
+	if err != nil {
+		return fmt.Errorf("failed to marshal quest progress: %v", err)
+	}
+
+	key, err := utils.GetQuestProgressKey(ctx, progress.QuestID, progress.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to create quest progress key: %v", err)
+	}
+	err = ctx.GetStub().PutState(key, progressJSON)
+	if err != nil {
+		return fmt.Errorf("failed to save quest progress: %v", err)
+	}
+	return nil
+}
+
+// putQuest writes a quest to the ledger
+func (q *QuestContract) putQuest(ctx contractapi.TransactionContextInterface, quest *models.Quest) error {
+	questJSON, err := json.Marshal(quest)
+	if err != nil {
+		return fmt.Errorf("failed to marshal quest: %v", err)
+	}
+	err = ctx.GetStub().PutState(utils.GetQuestKey(quest.QuestID), questJSON)
+	if err != nil {
+		return fmt.Errorf("failed to save quest: %v", err)
+	}
+	return nil
+}
@@ -12,11 +12,39 @@ import (
 // RedemptionContract provides functions for managing redemptions
 type RedemptionContract struct {
 	contractapi.Contract
-	AssetContract *AssetContract
+	AssetContract       *AssetContract
+	OracleContract      *OracleContract
+	LeaderboardContract *LeaderboardContract
+	QuestContract       *QuestContract
 }
 
 // CreateRedemptionRule creates a new redemption rule for a user
 func (r *RedemptionContract) CreateRedemptionRule(ctx contractapi.TransactionContextInterface, userID string, requiredItemsJSON string, rewardAmount float64) error {
+	if err := requireDirectOperation(ctx, OperationCreateRedemptionRule); err != nil {
+		return err
+	}
+	if rewardAmount <= 0 {
+		return fmt.Errorf("reward amount must be positive")
+	}
+
+	return r.createRule(ctx, userID, requiredItemsJSON, rewardAmount, 0)
+}
+
+// CreateOracleRedemptionRule creates a redemption rule for a user whose reward is
+// rewardRate times the oracle reference value of the required items at redemption time
+func (r *RedemptionContract) CreateOracleRedemptionRule(ctx contractapi.TransactionContextInterface, userID string, requiredItemsJSON string, rewardRate float64) error {
+	if err := requireDirectOperation(ctx, OperationCreateOracleRedemptionRule); err != nil {
+		return err
+	}
+	if rewardRate <= 0 {
+		return fmt.Errorf("reward rate must be positive")
+	}
+
+	return r.createRule(ctx, userID, requiredItemsJSON, 0, rewardRate)
+}
+
+// createRule validates and stores a redemption rule
+func (r *RedemptionContract) createRule(ctx contractapi.TransactionContextInterface, userID string, requiredItemsJSON string, rewardAmount, rewardRate float64) error {
 	// Parse required items
 	var requiredItems []models.RequiredItem
 	err := json.Unmarshal([]byte(requiredItemsJSON), &requiredItems)
@@ -28,10 +56,6 @@ func (r *RedemptionContract) CreateRedemptionRule(ctx contractapi.TransactionCon
 		return fmt.Errorf("required items cannot be empty")
 	}
 
-	if rewardAmount <= 0 {
-		return fmt.Errorf("reward amount must be positive")
-	}
-
 	// Check if rule already exists (one rule per user)
 	existing, _ := r.GetRedemptionRule(ctx, userID)
 	if existing != nil {
@@ -51,6 +75,7 @@ func (r *RedemptionContract) CreateRedemptionRule(ctx contractapi.TransactionCon
 		UserID:        userID,
 		RequiredItems: requiredItems,
 		RewardAmount:  rewardAmount,
+		RewardRate:    rewardRate,
 		CreatedAt:     timestamp,
 	}
 
@@ -85,6 +110,16 @@ func (r *RedemptionContract) GetRedemptionRule(ctx contractapi.TransactionContex
 
 // ExecuteRedemption executes a redemption for a user
 func (r *RedemptionContract) ExecuteRedemption(ctx contractapi.TransactionContextInterface, userID, recordID string) error {
+	// Check if redemption record already exists
+	key := utils.GetRedemptionRecordKey(recordID)
+	existing, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return fmt.Errorf("failed to read redemption record: %v", err)
+	}
+	if existing != nil {
+		return fmt.Errorf("redemption record %s already exists", recordID)
+	}
+
 	// Get redemption rule
 	rule, err := r.GetRedemptionRule(ctx, userID)
 	if err != nil {
@@ -98,27 +133,42 @@ func (r *RedemptionContract) ExecuteRedemption(ctx contractapi.TransactionContex
 
 	// Verify user has all required items
 	for _, item := range rule.RequiredItems {
-		inventory, err := r.AssetContract.GetInventory(ctx, userID, item.CommodityID)
+		available, err := r.AssetContract.GetAvailableInventory(ctx, userID, item.CommodityID)
 		if err != nil {
 			return fmt.Errorf("failed to get inventory for commodity %s: %v", item.CommodityID, err)
 		}
-		if inventory.Quantity < item.Quantity {
+		if available < item.Quantity {
 			return fmt.Errorf("insufficient inventory for commodity %s (required: %d, available: %d)",
-				item.CommodityID, item.Quantity, inventory.Quantity)
+				item.CommodityID, item.Quantity, available)
 		}
 	}
 
+	// Price oracle-based rules at the current reference prices
+	rewardAmount := rule.RewardAmount
+	if rule.RewardRate > 0 {
+		// Initialize oracle contract if not set
+		if r.OracleContract == nil {
+			r.OracleContract = &OracleContract{}
+		}
+
+		value, err := r.OracleContract.valueItems(ctx, rule.RequiredItems)
+		if err != nil {
+			return fmt.Errorf("failed to price required items: %v", err)
+		}
+		rewardAmount = rule.RewardRate * value
+	}
+
 	// Execute redemption atomically
 	// 1. Deduct required items from inventory
 	for _, item := range rule.RequiredItems {
-		err = r.AssetContract.UpdateInventory(ctx, userID, item.CommodityID, item.Quantity, "subtract")
+		err = r.AssetContract.updateInventory(ctx, userID, item.CommodityID, item.Quantity, "subtract")
 		if err != nil {
 			return fmt.Errorf("failed to deduct inventory for commodity %s: %v", item.CommodityID, err)
 		}
 	}
 
 	// 2. Add reward to user balance
-	err = r.AssetContract.UpdateBalance(ctx, userID, rule.RewardAmount, "add")
+	err = r.AssetContract.updateBalance(ctx, userID, rewardAmount, "add")
 	if err != nil {
 		return fmt.Errorf("failed to add reward balance: %v", err)
 	}
@@ -129,13 +179,19 @@ func (r *RedemptionContract) ExecuteRedemption(ctx contractapi.TransactionContex
 		return err
 	}
 
+	seasonID, err := currentSeasonID(ctx)
+	if err != nil {
+		return err
+	}
+
 	// 3. Record the redemption
 	record := models.RedemptionRecord{
 		RecordID:      recordID,
 		UserID:        userID,
 		RuleID:        rule.RuleID,
-		RewardAmount:  rule.RewardAmount,
+		RewardAmount:  rewardAmount,
 		ConsumedItems: rule.RequiredItems,
+		SeasonID:      seasonID,
 		Timestamp:     timestamp,
 	}
 
@@ -144,13 +200,35 @@ func (r *RedemptionContract) ExecuteRedemption(ctx contractapi.TransactionContex
 		return fmt.Errorf("failed to marshal redemption record: %v", err)
 	}
 
-	key := utils.GetRedemptionRecordKey(recordID)
+	err = recordEconomyDelta(ctx, key, models.EconomyDelta{Redemptions: 1})
+	if err != nil {
+		return err
+	}
 	err = ctx.GetStub().PutState(key, recordJSON)
 	if err != nil {
 		return fmt.Errorf("failed to save redemption record: %v", err)
 	}
 
-	// 4. Emit event
+	// 4. Count the redemption towards the redemption leaderboard and quests
+	// Initialize leaderboard contract if not set
+	if r.LeaderboardContract == nil {
+		r.LeaderboardContract = &LeaderboardContract{}
+	}
+	err = r.LeaderboardContract.incrementScore(ctx, BoardRedemptions, userID, 1)
+	if err != nil {
+		return fmt.Errorf("failed to update leaderboard: %v", err)
+	}
+
+	// Initialize quest contract if not set
+	if r.QuestContract == nil {
+		r.QuestContract = &QuestContract{AssetContract: r.AssetContract}
+	}
+	err = r.QuestContract.recordActivity(ctx, QuestMetricRedemptions, userID, 1)
+	if err != nil {
+		return fmt.Errorf("failed to update quest progress: %v", err)
+	}
+
+	// 5. Emit event
 	eventPayload := map[string]interface{}{
 		"recordId":     record.RecordID,
 		"userId":       record.UserID,
@@ -194,3 +272,20 @@ func (r *RedemptionContract) GetRedemptionHistory(ctx contractapi.TransactionCon
 
 	return records, nil
 }
+
+// GetSeasonRedemptionHistory retrieves a user's redemption records from a season
+func (r *RedemptionContract) GetSeasonRedemptionHistory(ctx contractapi.TransactionContextInterface, userID, seasonID string) ([]*models.RedemptionRecord, error) {
+	records, err := r.GetRedemptionHistory(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var seasonRecords []*models.RedemptionRecord
+	for _, record := range records {
+		if record.SeasonID == seasonID {
+			seasonRecords = append(seasonRecords, record)
+		}
+	}
+
+	return seasonRecords, nil
+}
@@ -0,0 +1,206 @@
+package contracts
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-samples/game-chaincode/models"
+	"github.com/hyperledger/fabric-samples/game-chaincode/utils"
+)
+
+// Bounds of a trade rating score
+const (
+	MinRatingScore = 1
+	MaxRatingScore = 5
+)
+
+// ReputationContract provides trade ratings and aggregated user reputation
+type ReputationContract struct {
+	contractapi.Contract
+	TradeContract *TradeContract
+}
+
+// RateTrade records raterID's rating of the counterparty to a completed trade.
+// Each party may rate a trade once.
+func (r *ReputationContract) RateTrade(ctx contractapi.TransactionContextInterface, tradeID, raterID string, score int, comment string) error {
+	if score < MinRatingScore || score > MaxRatingScore {
+		return fmt.Errorf("score must be between %d and %d", MinRatingScore, MaxRatingScore)
+	}
+
+	// Initialize trade contract if not set
+	if r.TradeContract == nil {
+		r.TradeContract = &TradeContract{}
+	}
+
+	trade, err := r.TradeContract.GetTradeStatus(ctx, tradeID)
+	if err != nil {
+		return err
+	}
+	if trade.Status != "successful" {
+		return fmt.Errorf("trade is not completed (status: %s)", trade.Status)
+	}
+
+	// 1. The rater must be a party and rates the other party
+	var rateeID string
+	switch raterID {
+	case trade.FromUserID:
+		rateeID = trade.ToUserID
+	case trade.ToUserID:
+		rateeID = trade.FromUserID
+	default:
+		return fmt.Errorf("user %s is not a party to trade %s", raterID, tradeID)
+	}
+	if rateeID == raterID {
+		return fmt.Errorf("users cannot rate themselves")
+	}
+
+	key, err := utils.GetTradeRatingKey(ctx, tradeID, raterID)
+	if err != nil {
+		return fmt.Errorf("failed to create trade rating key: %v", err)
+	}
+	existing, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return fmt.Errorf("failed to read trade rating: %v", err)
+	}
+	if existing != nil {
+		return fmt.Errorf("user %s has already rated trade %s", raterID, tradeID)
+	}
+
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	// 2. Save the rating
+	rating := models.TradeRating{
+		TradeID:   tradeID,
+		RaterID:   raterID,
+		RateeID:   rateeID,
+		Score:     score,
+		Comment:   comment,
+		CreatedAt: timestamp,
+	}
+	ratingJSON, err := json.Marshal(rating)
+	if err != nil {
+		return fmt.Errorf("failed to marshal trade rating: %v", err)
+	}
+	err = ctx.GetStub().PutState(key, ratingJSON)
+	if err != nil {
+		return fmt.Errorf("failed to save trade rating: %v", err)
+	}
+
+	// 3. Add it to the ratee's reputation
+	reputation, err := r.GetReputation(ctx, rateeID)
+	if err != nil {
+		return err
+	}
+	reputation.RatingCount++
+	reputation.RatingTotal += score
+	reputation.AverageRating = float64(reputation.RatingTotal) / float64(reputation.RatingCount)
+	reputation.UpdatedAt = timestamp
+
+	err = putReputation(ctx, reputation)
+	if err != nil {
+		return err
+	}
+
+	// 4. Emit event
+	eventJSON, _ := json.Marshal(rating)
+	ctx.GetStub().SetEvent("TradeRated", eventJSON)
+
+	return nil
+}
+
+// GetReputation retrieves a user's reputation; users never rated or traded have an empty one
+func (r *ReputationContract) GetReputation(ctx contractapi.TransactionContextInterface, userID string) (*models.Reputation, error) {
+	reputationJSON, err := ctx.GetStub().GetState(utils.GetReputationKey(userID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read reputation: %v", err)
+	}
+	if reputationJSON == nil {
+		return &models.Reputation{UserID: userID}, nil
+	}
+
+	var reputation models.Reputation
+	err = json.Unmarshal(reputationJSON, &reputation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal reputation: %v", err)
+	}
+
+	return &reputation, nil
+}
+
+// GetTradeRatings retrieves the ratings given for a trade
+func (r *ReputationContract) GetTradeRatings(ctx contractapi.TransactionContextInterface, tradeID string) ([]*models.TradeRating, error) {
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(utils.TradeRatingObjectType, []string{tradeID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get trade rating iterator: %v", err)
+	}
+	defer iterator.Close()
+
+	var ratings []*models.TradeRating
+	for iterator.HasNext() {
+		queryResponse, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate trade ratings: %v", err)
+		}
+
+		var rating models.TradeRating
+		err = json.Unmarshal(queryResponse.Value, &rating)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal trade rating: %v", err)
+		}
+		ratings = append(ratings, &rating)
+	}
+
+	return ratings, nil
+}
+
+// recordTradeOutcome counts a completed trade towards both parties' completion
+// ratio, and a rejected trade towards the ratio of the party who rejected it
+func (r *ReputationContract) recordTradeOutcome(ctx contractapi.TransactionContextInterface, trade *models.Trade, successful bool) error {
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	userIDs := []string{trade.FromUserID}
+	if trade.ToUserID != trade.FromUserID {
+		userIDs = append(userIDs, trade.ToUserID)
+	}
+	if !successful {
+		userIDs = []string{trade.RejectedBy}
+	}
+	for _, userID := range userIDs {
+		reputation, err := r.GetReputation(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if successful {
+			reputation.SuccessfulTrades++
+		} else {
+			reputation.RejectedTrades++
+		}
+		reputation.CompletionRatio = float64(reputation.SuccessfulTrades) / float64(reputation.SuccessfulTrades+reputation.RejectedTrades)
+		reputation.UpdatedAt = timestamp
+
+		err = putReputation(ctx, reputation)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// putReputation saves a user's reputation
+func putReputation(ctx contractapi.TransactionContextInterface, reputation *models.Reputation) error {
+	reputationJSON, err := json.Marshal(reputation)
+	if err != nil {
+		return fmt.Errorf("failed to marshal reputation: %v", err)
+	}
+	return ctx.GetStub().PutState(utils.GetReputationKey(reputation.UserID), reputationJSON)
+}
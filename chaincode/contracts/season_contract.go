@@ -0,0 +1,390 @@
+package contracts
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-samples/game-chaincode/models"
+	"github.com/hyperledger/fabric-samples/game-chaincode/utils"
+)
+
+// SeasonContract provides game seasons with end-of-season archives and economy resets
+type SeasonContract struct {
+	contractapi.Contract
+	AssetContract       *AssetContract
+	LeaderboardContract *LeaderboardContract
+}
+
+// StartSeason starts a new season (admin only). When it ends, each balance is
+// multiplied by carryOverRate (1 keeps everything, 0 resets to zero) and, if
+// resetInventory is set, all inventory is cleared. The leaderboards switch to
+// the new season.
+func (s *SeasonContract) StartSeason(ctx contractapi.TransactionContextInterface, seasonID string, carryOverRate float64, resetInventory bool) error {
+	if _, err := requireRole(ctx, RoleAdmin); err != nil {
+		return err
+	}
+
+	if seasonID == "" {
+		return fmt.Errorf("season ID cannot be empty")
+	}
+	if carryOverRate < 0 || carryOverRate > 1 {
+		return fmt.Errorf("carry-over rate must be between 0 and 1")
+	}
+
+	currentID, err := currentSeasonID(ctx)
+	if err != nil {
+		return err
+	}
+	if currentID != "" {
+		return fmt.Errorf("season %s is still active", currentID)
+	}
+
+	existing, err := ctx.GetStub().GetState(utils.GetSeasonKey(seasonID))
+	if err != nil {
+		return fmt.Errorf("failed to read season: %v", err)
+	}
+	if existing != nil {
+		return fmt.Errorf("season %s already exists", seasonID)
+	}
+
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	season := models.Season{
+		SeasonID:       seasonID,
+		Status:         "active",
+		CarryOverRate:  carryOverRate,
+		ResetInventory: resetInventory,
+		StartedAt:      timestamp,
+	}
+
+	err = s.putSeason(ctx, &season)
+	if err != nil {
+		return err
+	}
+	err = ctx.GetStub().PutState(utils.CurrentSeasonKey, []byte(seasonID))
+	if err != nil {
+		return fmt.Errorf("failed to save current season: %v", err)
+	}
+
+	// Switch the leaderboards to the new season and seed the richest board
+	// Initialize leaderboard contract if not set
+	if s.LeaderboardContract == nil {
+		s.LeaderboardContract = &LeaderboardContract{}
+	}
+	// Initialize asset contract if not set
+	if s.AssetContract == nil {
+		s.AssetContract = &AssetContract{LeaderboardContract: s.LeaderboardContract}
+	}
+
+	leaderboardSeason, err := s.LeaderboardContract.GetCurrentLeaderboardSeason(ctx)
+	if err != nil {
+		return err
+	}
+	if leaderboardSeason.SeasonID != seasonID {
+		err = s.LeaderboardContract.startSeason(ctx, seasonID)
+		if err != nil {
+			return fmt.Errorf("failed to reset leaderboards: %v", err)
+		}
+	}
+
+	userAssets, err := s.getAllUserAssets(ctx)
+	if err != nil {
+		return err
+	}
+	for _, userAsset := range userAssets {
+		err = s.AssetContract.updateRichest(ctx, userAsset)
+		if err != nil {
+			return err
+		}
+	}
+
+	eventJSON, _ := json.Marshal(season)
+	ctx.GetStub().SetEvent("SeasonStarted", eventJSON)
+
+	return nil
+}
+
+// EndSeason ends the active season (admin only): every user's balance and
+// inventory is archived under the season, then the season's carry-over rules
+// are applied
+func (s *SeasonContract) EndSeason(ctx contractapi.TransactionContextInterface) error {
+	if _, err := requireRole(ctx, RoleAdmin); err != nil {
+		return err
+	}
+
+	season, err := s.GetCurrentSeason(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	userAssets, err := s.getAllUserAssets(ctx)
+	if err != nil {
+		return err
+	}
+	inventories, err := s.getAllInventories(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Initialize asset contract if not set
+	if s.AssetContract == nil {
+		s.AssetContract = &AssetContract{}
+	}
+
+	for _, userAsset := range userAssets {
+		// 1. Archive the user's balance and inventory
+		archive := models.SeasonArchive{
+			SeasonID:   season.SeasonID,
+			UserID:     userAsset.UserID,
+			Balance:    userAsset.Balance,
+			Inventory:  []models.Inventory{},
+			ArchivedAt: timestamp,
+		}
+		for _, inventory := range inventories[userAsset.UserID] {
+			if inventory.Quantity > 0 {
+				archive.Inventory = append(archive.Inventory, *inventory)
+			}
+		}
+
+		archiveJSON, err := json.Marshal(archive)
+		if err != nil {
+			return fmt.Errorf("failed to marshal season archive: %v", err)
+		}
+		archiveKey, err := utils.GetSeasonArchiveKey(ctx, season.SeasonID, userAsset.UserID)
+		if err != nil {
+			return fmt.Errorf("failed to create season archive key: %v", err)
+		}
+		err = ctx.GetStub().PutState(archiveKey, archiveJSON)
+		if err != nil {
+			return fmt.Errorf("failed to save season archive: %v", err)
+		}
+
+		// 2. Carry over part of the balance. The asset is saved without going through
+		// updateBalance so the ended season's leaderboards keep their final standings.
+		// Held balance is always carried over.
+		if season.CarryOverRate < 1 {
+			userAsset.Balance *= season.CarryOverRate
+			if userAsset.Balance < userAsset.Locked {
+				userAsset.Balance = userAsset.Locked
+			}
+
+			err = s.AssetContract.putUserAsset(ctx, userAsset)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// 3. Clear inventory if the season resets it, keeping held items
+	if season.ResetInventory {
+		for _, userInventories := range inventories {
+			for _, inventory := range userInventories {
+				if inventory.Locked == 0 {
+					err = s.AssetContract.deleteInventory(ctx, inventory.UserID, inventory.CommodityID)
+					if err != nil {
+						return err
+					}
+					continue
+				}
+
+				takeFromLots(inventory, inventory.Quantity-inventory.Locked, timestamp)
+				err = s.AssetContract.putInventory(ctx, inventory)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	// 4. Close the season
+	season.Status = "ended"
+	season.EndedAt = timestamp
+	season.ArchivedUsers = len(userAssets)
+
+	err = s.putSeason(ctx, season)
+	if err != nil {
+		return err
+	}
+	err = ctx.GetStub().DelState(utils.CurrentSeasonKey)
+	if err != nil {
+		return fmt.Errorf("failed to clear current season: %v", err)
+	}
+
+	eventJSON, _ := json.Marshal(season)
+	ctx.GetStub().SetEvent("SeasonEnded", eventJSON)
+
+	return nil
+}
+
+// GetSeason retrieves a season by ID
+func (s *SeasonContract) GetSeason(ctx contractapi.TransactionContextInterface, seasonID string) (*models.Season, error) {
+	seasonJSON, err := ctx.GetStub().GetState(utils.GetSeasonKey(seasonID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read season: %v", err)
+	}
+	if seasonJSON == nil {
+		return nil, fmt.Errorf("season %s not found", seasonID)
+	}
+
+	var season models.Season
+	err = json.Unmarshal(seasonJSON, &season)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal season: %v", err)
+	}
+
+	return &season, nil
+}
+
+// GetCurrentSeason retrieves the active season
+func (s *SeasonContract) GetCurrentSeason(ctx contractapi.TransactionContextInterface) (*models.Season, error) {
+	seasonID, err := currentSeasonID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if seasonID == "" {
+		return nil, fmt.Errorf("no season is active")
+	}
+
+	return s.GetSeason(ctx, seasonID)
+}
+
+// GetSeasonArchive retrieves a user's end-of-season archive
+func (s *SeasonContract) GetSeasonArchive(ctx contractapi.TransactionContextInterface, seasonID, userID string) (*models.SeasonArchive, error) {
+	key, err := utils.GetSeasonArchiveKey(ctx, seasonID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create season archive key: %v", err)
+	}
+	archiveJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read season archive: %v", err)
+	}
+	if archiveJSON == nil {
+		return nil, fmt.Errorf("no archive for user %s in season %s", userID, seasonID)
+	}
+
+	var archive models.SeasonArchive
+	err = json.Unmarshal(archiveJSON, &archive)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal season archive: %v", err)
+	}
+
+	return &archive, nil
+}
+
+// GetSeasonArchives retrieves every user's archive of a season
+func (s *SeasonContract) GetSeasonArchives(ctx contractapi.TransactionContextInterface, seasonID string) ([]*models.SeasonArchive, error) {
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(utils.SeasonArchiveObjectType, []string{seasonID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get season archive iterator: %v", err)
+	}
+	defer iterator.Close()
+
+	var archives []*models.SeasonArchive
+	for iterator.HasNext() {
+		queryResponse, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate season archives: %v", err)
+		}
+
+		var archive models.SeasonArchive
+		err = json.Unmarshal(queryResponse.Value, &archive)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal season archive: %v", err)
+		}
+
+		archives = append(archives, &archive)
+	}
+
+	return archives, nil
+}
+
+// putSeason writes a season to the ledger
+func (s *SeasonContract) putSeason(ctx contractapi.TransactionContextInterface, season *models.Season) error {
+	seasonJSON, err := json.Marshal(season)
+	if err != nil {
+		return fmt.Errorf("failed to marshal season: %v", err)
+	}
+	err = ctx.GetStub().PutState(utils.GetSeasonKey(season.SeasonID), seasonJSON)
+	if err != nil {
+		return fmt.Errorf("failed to save season: %v", err)
+	}
+	return nil
+}
+
+// getAllUserAssets retrieves every user's asset record with the balance rate applied
+func (s *SeasonContract) getAllUserAssets(ctx contractapi.TransactionContextInterface) ([]*models.UserAsset, error) {
+	iterator, err := ctx.GetStub().GetStateByRange(utils.UserAssetPrefix, utils.UserAssetPrefix+"\uffff")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user asset iterator: %v", err)
+	}
+	defer iterator.Close()
+
+	var userAssets []*models.UserAsset
+	for iterator.HasNext() {
+		queryResponse, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate user assets: %v", err)
+		}
+
+		var userAsset models.UserAsset
+		err = json.Unmarshal(queryResponse.Value, &userAsset)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal user asset: %v", err)
+		}
+		_, err = accrueBalance(ctx, &userAsset)
+		if err != nil {
+			return nil, err
+		}
+
+		userAssets = append(userAssets, &userAsset)
+	}
+
+	return userAssets, nil
+}
+
+// getAllInventories retrieves every inventory record, grouped by user
+func (s *SeasonContract) getAllInventories(ctx contractapi.TransactionContextInterface) (map[string][]*models.Inventory, error) {
+	iterator, err := ctx.GetStub().GetStateByRange(utils.InventoryPrefix, utils.InventoryPrefix+"\uffff")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get inventory iterator: %v", err)
+	}
+	defer iterator.Close()
+
+	inventories := make(map[string][]*models.Inventory)
+	for iterator.HasNext() {
+		queryResponse, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate inventory: %v", err)
+		}
+
+		var inventory models.Inventory
+		err = json.Unmarshal(queryResponse.Value, &inventory)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal inventory: %v", err)
+		}
+
+		inventories[inventory.UserID] = append(inventories[inventory.UserID], &inventory)
+	}
+
+	return inventories, nil
+}
+
+// currentSeasonID returns the ID of the active season, or "" between seasons
+func currentSeasonID(ctx contractapi.TransactionContextInterface) (string, error) {
+	seasonID, err := ctx.GetStub().GetState(utils.CurrentSeasonKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to read current season: %v", err)
+	}
+	return string(seasonID), nil
+}
@@ -0,0 +1,529 @@
+package contracts
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-samples/game-chaincode/models"
+	"github.com/hyperledger/fabric-samples/game-chaincode/utils"
+)
+
+// shopDayFormat formats the UTC day a daily purchase limit applies to
+const shopDayFormat = "2006-01-02"
+
+// ShopContract provides fixed-price buying from and selling to a house account
+type ShopContract struct {
+	contractapi.Contract
+	AssetContract *AssetContract
+}
+
+// SetShopHouse sets the house account the shop trades from (admin only). The
+// house receives payments for items it sells and pays for items it buys.
+func (s *ShopContract) SetShopHouse(ctx contractapi.TransactionContextInterface, houseAccountID string) error {
+	if _, err := requireRole(ctx, RoleAdmin); err != nil {
+		return err
+	}
+
+	// Initialize asset contract if not set
+	if s.AssetContract == nil {
+		s.AssetContract = &AssetContract{}
+	}
+
+	if _, err := s.AssetContract.GetUserAssets(ctx, houseAccountID); err != nil {
+		return err
+	}
+
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	config := models.ShopConfig{
+		HouseAccountID: houseAccountID,
+		UpdatedAt:      timestamp,
+	}
+
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("failed to marshal shop config: %v", err)
+	}
+	return ctx.GetStub().PutState(utils.ShopConfigKey, configJSON)
+}
+
+// GetShopConfig retrieves the shop's house account
+func (s *ShopContract) GetShopConfig(ctx contractapi.TransactionContextInterface) (*models.ShopConfig, error) {
+	configJSON, err := ctx.GetStub().GetState(utils.ShopConfigKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read shop config: %v", err)
+	}
+	if configJSON == nil {
+		return nil, fmt.Errorf("shop is not configured")
+	}
+
+	var config models.ShopConfig
+	err = json.Unmarshal(configJSON, &config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal shop config: %v", err)
+	}
+
+	return &config, nil
+}
+
+// SetShopListing lists a commodity in the shop or updates its listing (admin
+// only). Players buy at buyPrice and sell at sellPrice; a zero price disables
+// that side. The house holds at most maxStock units and is restocked with
+// restockAmount units every restockPeriodSeconds. A user may buy at most
+// dailyLimit units per UTC day, or any amount if it is 0.
+func (s *ShopContract) SetShopListing(ctx contractapi.TransactionContextInterface, commodityID string, buyPrice, sellPrice float64, maxStock, dailyLimit, restockAmount, restockPeriodSeconds int) error {
+	if _, err := requireRole(ctx, RoleAdmin); err != nil {
+		return err
+	}
+
+	if buyPrice < 0 || sellPrice < 0 {
+		return fmt.Errorf("prices cannot be negative")
+	}
+	if buyPrice == 0 && sellPrice == 0 {
+		return fmt.Errorf("listing must have a buy or sell price")
+	}
+	// Buying from and selling back to the shop must not make a profit
+	if buyPrice > 0 && sellPrice > buyPrice {
+		return fmt.Errorf("sell price cannot exceed buy price")
+	}
+	if maxStock <= 0 {
+		return fmt.Errorf("max stock must be positive")
+	}
+	if dailyLimit < 0 || restockAmount < 0 {
+		return fmt.Errorf("daily limit and restock amount cannot be negative")
+	}
+	if restockAmount > 0 && restockPeriodSeconds <= 0 {
+		return fmt.Errorf("restock period must be positive when restocking")
+	}
+
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Keep the restock schedule of an existing listing
+	lastRestockAt := timestamp
+	existing, err := s.GetShopListing(ctx, commodityID)
+	if err == nil && existing != nil {
+		lastRestockAt = existing.LastRestockAt
+	}
+
+	listing := models.ShopListing{
+		CommodityID:          commodityID,
+		BuyPrice:             buyPrice,
+		SellPrice:            sellPrice,
+		MaxStock:             maxStock,
+		DailyLimit:           dailyLimit,
+		RestockAmount:        restockAmount,
+		RestockPeriodSeconds: restockPeriodSeconds,
+		LastRestockAt:        lastRestockAt,
+		Active:               true,
+		UpdatedAt:            timestamp,
+	}
+
+	return s.putShopListing(ctx, &listing)
+}
+
+// RemoveShopListing stops the shop trading a commodity (admin only)
+func (s *ShopContract) RemoveShopListing(ctx contractapi.TransactionContextInterface, commodityID string) error {
+	if _, err := requireRole(ctx, RoleAdmin); err != nil {
+		return err
+	}
+
+	listing, err := s.GetShopListing(ctx, commodityID)
+	if err != nil {
+		return err
+	}
+	if !listing.Active {
+		return fmt.Errorf("commodity %s is not listed in the shop", commodityID)
+	}
+
+	listing.Active = false
+	return s.putShopListing(ctx, listing)
+}
+
+// GetShopListing retrieves the shop listing of a commodity
+func (s *ShopContract) GetShopListing(ctx contractapi.TransactionContextInterface, commodityID string) (*models.ShopListing, error) {
+	listingJSON, err := ctx.GetStub().GetState(utils.GetShopListingKey(commodityID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read shop listing: %v", err)
+	}
+	if listingJSON == nil {
+		return nil, fmt.Errorf("shop listing for commodity %s does not exist", commodityID)
+	}
+
+	var listing models.ShopListing
+	err = json.Unmarshal(listingJSON, &listing)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal shop listing: %v", err)
+	}
+
+	return &listing, nil
+}
+
+// GetAllShopListings retrieves every active shop listing
+func (s *ShopContract) GetAllShopListings(ctx contractapi.TransactionContextInterface) ([]*models.ShopListing, error) {
+	iterator, err := ctx.GetStub().GetStateByRange(utils.ShopListingPrefix, utils.ShopListingPrefix+"\uffff")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get shop listing iterator: %v", err)
+	}
+	defer iterator.Close()
+
+	var listings []*models.ShopListing
+	for iterator.HasNext() {
+		queryResponse, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate shop listings: %v", err)
+		}
+
+		var listing models.ShopListing
+		err = json.Unmarshal(queryResponse.Value, &listing)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal shop listing: %v", err)
+		}
+
+		if listing.Active {
+			listings = append(listings, &listing)
+		}
+	}
+
+	return listings, nil
+}
+
+// GetShopStock returns the units of a commodity the shop can sell, including restocks due
+func (s *ShopContract) GetShopStock(ctx contractapi.TransactionContextInterface, commodityID string) (int, error) {
+	listing, err := s.GetShopListing(ctx, commodityID)
+	if err != nil {
+		return 0, err
+	}
+
+	config, err := s.GetShopConfig(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	// Initialize asset contract if not set
+	if s.AssetContract == nil {
+		s.AssetContract = &AssetContract{}
+	}
+
+	inventory, err := s.AssetContract.GetInventory(ctx, config.HouseAccountID, commodityID)
+	if err != nil {
+		return 0, err
+	}
+	available, err := s.AssetContract.GetAvailableInventory(ctx, config.HouseAccountID, commodityID)
+	if err != nil {
+		return 0, err
+	}
+
+	restocked, _ := shopRestock(listing, inventory.Quantity, timestamp)
+	return available + restocked, nil
+}
+
+// BuyFromShop sells quantity units of a listed commodity to a user from the house account
+func (s *ShopContract) BuyFromShop(ctx contractapi.TransactionContextInterface, userID, commodityID string, quantity int) error {
+	if quantity <= 0 {
+		return fmt.Errorf("quantity must be positive")
+	}
+
+	listing, err := s.GetShopListing(ctx, commodityID)
+	if err != nil {
+		return err
+	}
+	if !listing.Active || listing.BuyPrice == 0 {
+		return fmt.Errorf("shop does not sell commodity %s", commodityID)
+	}
+
+	config, err := s.GetShopConfig(ctx)
+	if err != nil {
+		return err
+	}
+	houseID := config.HouseAccountID
+	if userID == houseID {
+		return fmt.Errorf("house account cannot trade with the shop")
+	}
+	if err := checkAccountCanTrade(ctx, userID); err != nil {
+		return err
+	}
+
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	// 1. Enforce the daily purchase limit
+	purchases, err := s.GetShopPurchases(ctx, userID, commodityID, timestamp.UTC().Format(shopDayFormat))
+	if err != nil {
+		return err
+	}
+	if listing.DailyLimit > 0 && purchases.Quantity+quantity > listing.DailyLimit {
+		return fmt.Errorf("daily purchase limit of %d exceeded for commodity %s (%d bought today)",
+			listing.DailyLimit, commodityID, purchases.Quantity)
+	}
+
+	// Initialize asset contract if not set
+	if s.AssetContract == nil {
+		s.AssetContract = &AssetContract{}
+	}
+
+	// 2. Check the stock, including restocks due
+	houseInventory, err := s.AssetContract.GetInventory(ctx, houseID, commodityID)
+	if err != nil {
+		return err
+	}
+	available, err := s.AssetContract.GetAvailableInventory(ctx, houseID, commodityID)
+	if err != nil {
+		return err
+	}
+	restocked, restockedAt := shopRestock(listing, houseInventory.Quantity, timestamp)
+	if available+restocked < quantity {
+		return fmt.Errorf("shop has insufficient stock of commodity %s (%d available)", commodityID, available+restocked)
+	}
+
+	// 3. The user pays the house
+	cost := listing.BuyPrice * float64(quantity)
+	err = s.AssetContract.updateBalance(ctx, userID, cost, "subtract")
+	if err != nil {
+		return fmt.Errorf("failed to deduct purchase payment: %v", err)
+	}
+	err = s.AssetContract.updateBalance(ctx, houseID, cost, "add")
+	if err != nil {
+		return fmt.Errorf("failed to pay house account: %v", err)
+	}
+
+	// 4. Restock and deliver the items; the house inventory is written once.
+	// Restocked units are delivered first, then units from the house's lots.
+	lots, err := s.updateHouseInventory(ctx, houseID, commodityID, restocked-quantity, nil)
+	if err != nil {
+		return err
+	}
+	err = s.AssetContract.giveItems(ctx, userID, commodityID, quantity, lots)
+	if err != nil {
+		return fmt.Errorf("failed to deliver items: %v", err)
+	}
+
+	// 5. Record the restock and the purchase
+	if !restockedAt.Equal(listing.LastRestockAt) {
+		listing.LastRestockAt = restockedAt
+		err = s.putShopListing(ctx, listing)
+		if err != nil {
+			return err
+		}
+	}
+
+	purchases.Quantity += quantity
+	err = s.putShopPurchases(ctx, purchases)
+	if err != nil {
+		return err
+	}
+
+	// 6. Emit event
+	eventPayload := map[string]interface{}{
+		"userId":      userID,
+		"commodityId": commodityID,
+		"quantity":    quantity,
+		"unitPrice":   listing.BuyPrice,
+		"total":       cost,
+		"timestamp":   timestamp,
+	}
+	eventJSON, _ := json.Marshal(eventPayload)
+	ctx.GetStub().SetEvent("ShopItemsBought", eventJSON)
+
+	return nil
+}
+
+// SellToShop buys quantity units of a listed commodity from a user into the house account
+func (s *ShopContract) SellToShop(ctx contractapi.TransactionContextInterface, userID, commodityID string, quantity int) error {
+	if quantity <= 0 {
+		return fmt.Errorf("quantity must be positive")
+	}
+
+	listing, err := s.GetShopListing(ctx, commodityID)
+	if err != nil {
+		return err
+	}
+	if !listing.Active || listing.SellPrice == 0 {
+		return fmt.Errorf("shop does not buy commodity %s", commodityID)
+	}
+
+	config, err := s.GetShopConfig(ctx)
+	if err != nil {
+		return err
+	}
+	houseID := config.HouseAccountID
+	if userID == houseID {
+		return fmt.Errorf("house account cannot trade with the shop")
+	}
+	if err := checkAccountCanTrade(ctx, userID); err != nil {
+		return err
+	}
+
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Initialize asset contract if not set
+	if s.AssetContract == nil {
+		s.AssetContract = &AssetContract{}
+	}
+
+	// 1. The house does not buy beyond its max stock
+	houseInventory, err := s.AssetContract.GetInventory(ctx, houseID, commodityID)
+	if err != nil {
+		return err
+	}
+	restocked, restockedAt := shopRestock(listing, houseInventory.Quantity, timestamp)
+	held := houseInventory.Quantity + restocked
+	if held+quantity > listing.MaxStock {
+		return fmt.Errorf("shop stock of commodity %s is full (%d of %d)", commodityID, held, listing.MaxStock)
+	}
+
+	// 2. Move the items to the house
+	lots, err := s.AssetContract.takeItems(ctx, userID, commodityID, quantity)
+	if err != nil {
+		return fmt.Errorf("failed to deduct items: %v", err)
+	}
+	_, err = s.updateHouseInventory(ctx, houseID, commodityID, restocked+quantity, lots)
+	if err != nil {
+		return err
+	}
+
+	// 3. The house pays the user
+	payment := listing.SellPrice * float64(quantity)
+	err = s.AssetContract.updateBalance(ctx, houseID, payment, "subtract")
+	if err != nil {
+		return fmt.Errorf("house account cannot pay for items: %v", err)
+	}
+	err = s.AssetContract.updateBalance(ctx, userID, payment, "add")
+	if err != nil {
+		return fmt.Errorf("failed to pay user: %v", err)
+	}
+
+	// 4. Record the restock
+	if !restockedAt.Equal(listing.LastRestockAt) {
+		listing.LastRestockAt = restockedAt
+		err = s.putShopListing(ctx, listing)
+		if err != nil {
+			return err
+		}
+	}
+
+	// 5. Emit event
+	eventPayload := map[string]interface{}{
+		"userId":      userID,
+		"commodityId": commodityID,
+		"quantity":    quantity,
+		"unitPrice":   listing.SellPrice,
+		"total":       payment,
+		"timestamp":   timestamp,
+	}
+	eventJSON, _ := json.Marshal(eventPayload)
+	ctx.GetStub().SetEvent("ShopItemsSold", eventJSON)
+
+	return nil
+}
+
+// GetShopPurchases retrieves the units of a commodity a user bought from the shop on a UTC day (YYYY-MM-DD)
+func (s *ShopContract) GetShopPurchases(ctx contractapi.TransactionContextInterface, userID, commodityID, day string) (*models.ShopPurchases, error) {
+	if _, err := time.Parse(shopDayFormat, day); err != nil {
+		return nil, fmt.Errorf("invalid day %s (expected YYYY-MM-DD)", day)
+	}
+
+	key, err := utils.GetShopPurchasesKey(ctx, userID, commodityID, day)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create shop purchases key: %v", err)
+	}
+
+	purchasesJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read shop purchases: %v", err)
+	}
+	if purchasesJSON == nil {
+		return &models.ShopPurchases{UserID: userID, CommodityID: commodityID, Day: day}, nil
+	}
+
+	var purchases models.ShopPurchases
+	err = json.Unmarshal(purchasesJSON, &purchases)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal shop purchases: %v", err)
+	}
+
+	return &purchases, nil
+}
+
+// updateHouseInventory adds a positive change to the house inventory under the
+// given lots, or subtracts a negative change and returns the lots it was taken from
+func (s *ShopContract) updateHouseInventory(ctx contractapi.TransactionContextInterface, houseID, commodityID string, change int, lots []models.InventoryLot) ([]models.InventoryLot, error) {
+	var taken []models.InventoryLot
+	var err error
+	if change > 0 {
+		err = s.AssetContract.settleItems(ctx, houseID, commodityID, change, lots)
+	} else if change < 0 {
+		taken, err = s.AssetContract.takeItems(ctx, houseID, commodityID, -change)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update house inventory: %v", err)
+	}
+	return taken, nil
+}
+
+// putShopListing saves a shop listing
+func (s *ShopContract) putShopListing(ctx contractapi.TransactionContextInterface, listing *models.ShopListing) error {
+	listingJSON, err := json.Marshal(listing)
+	if err != nil {
+		return fmt.Errorf("failed to marshal shop listing: %v", err)
+	}
+	return ctx.GetStub().PutState(utils.GetShopListingKey(listing.CommodityID), listingJSON)
+}
+
+// putShopPurchases saves a user's shop purchases of a day
+func (s *ShopContract) putShopPurchases(ctx contractapi.TransactionContextInterface, purchases *models.ShopPurchases) error {
+	key, err := utils.GetShopPurchasesKey(ctx, purchases.UserID, purchases.CommodityID, purchases.Day)
+	if err != nil {
+		return fmt.Errorf("failed to create shop purchases key: %v", err)
+	}
+
+	purchasesJSON, err := json.Marshal(purchases)
+	if err != nil {
+		return fmt.Errorf("failed to marshal shop purchases: %v", err)
+	}
+	return ctx.GetStub().PutState(key, purchasesJSON)
+}
+
+// shopRestock returns the units due to restock a house holding held units by
+// timestamp, capped at the listing's max stock, and when the restock schedule
+// has caught up to
+func shopRestock(listing *models.ShopListing, held int, timestamp time.Time) (int, time.Time) {
+	if listing.RestockAmount == 0 || !timestamp.After(listing.LastRestockAt) {
+		return 0, listing.LastRestockAt
+	}
+
+	period := time.Duration(listing.RestockPeriodSeconds) * time.Second
+	periods := int(timestamp.Sub(listing.LastRestockAt) / period)
+
+	units := periods * listing.RestockAmount
+	if room := listing.MaxStock - held; units > room {
+		units = room
+	}
+	if units < 0 {
+		units = 0
+	}
+
+	return units, listing.LastRestockAt.Add(time.Duration(periods) * period)
+}
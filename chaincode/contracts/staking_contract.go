@@ -0,0 +1,346 @@
+package contracts
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-samples/game-chaincode/models"
+	"github.com/hyperledger/fabric-samples/game-chaincode/utils"
+)
+
+// secondsPerDay is the period staking reward rates are quoted over
+const secondsPerDay = 24 * 60 * 60
+
+// stakingHoldReason is the reason of the holds locking staked items
+const stakingHoldReason = "staking"
+
+// StakingContract provides staking of commodities for a term in exchange for rewards
+type StakingContract struct {
+	contractapi.Contract
+	AssetContract *AssetContract
+}
+
+// CreateStakingProgram creates a program for staking commodityID (admin only).
+// Stakes earn rewardRate per unit per day until their term ends, paid in
+// rewardCommodityID or in balance if it is empty. Unlocking before the term
+// ends forfeits earlyUnlockPenalty of the reward.
+func (s *StakingContract) CreateStakingProgram(ctx contractapi.TransactionContextInterface, programID, commodityID, rewardCommodityID string, rewardRate float64, minTermSeconds int, earlyUnlockPenalty float64) error {
+	if _, err := requireRole(ctx, RoleAdmin); err != nil {
+		return err
+	}
+
+	if rewardRate <= 0 {
+		return fmt.Errorf("reward rate must be positive")
+	}
+	if minTermSeconds <= 0 {
+		return fmt.Errorf("minimum term must be positive")
+	}
+	if earlyUnlockPenalty < 0 || earlyUnlockPenalty > 1 {
+		return fmt.Errorf("early unlock penalty must be between 0 and 1")
+	}
+	// Unlocking and paying the reward would both write the same inventory record
+	if rewardCommodityID == commodityID {
+		return fmt.Errorf("reward commodity must differ from the staked commodity")
+	}
+
+	// Check if program already exists
+	existing, err := s.GetStakingProgram(ctx, programID)
+	if err == nil && existing != nil {
+		return fmt.Errorf("staking program %s already exists", programID)
+	}
+
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	program := models.StakingProgram{
+		ProgramID:          programID,
+		CommodityID:        commodityID,
+		RewardCommodityID:  rewardCommodityID,
+		RewardRate:         rewardRate,
+		MinTermSeconds:     minTermSeconds,
+		EarlyUnlockPenalty: earlyUnlockPenalty,
+		Active:             true,
+		CreatedAt:          timestamp,
+	}
+
+	return s.putStakingProgram(ctx, &program)
+}
+
+// DeactivateStakingProgram stops a program accepting new stakes (admin only).
+// Existing stakes keep earning until they are unlocked.
+func (s *StakingContract) DeactivateStakingProgram(ctx contractapi.TransactionContextInterface, programID string) error {
+	if _, err := requireRole(ctx, RoleAdmin); err != nil {
+		return err
+	}
+
+	program, err := s.GetStakingProgram(ctx, programID)
+	if err != nil {
+		return err
+	}
+	if !program.Active {
+		return fmt.Errorf("staking program %s is already inactive", programID)
+	}
+
+	program.Active = false
+	return s.putStakingProgram(ctx, program)
+}
+
+// GetStakingProgram retrieves a staking program
+func (s *StakingContract) GetStakingProgram(ctx contractapi.TransactionContextInterface, programID string) (*models.StakingProgram, error) {
+	programJSON, err := ctx.GetStub().GetState(utils.GetStakingProgramKey(programID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read staking program: %v", err)
+	}
+	if programJSON == nil {
+		return nil, fmt.Errorf("staking program %s does not exist", programID)
+	}
+
+	var program models.StakingProgram
+	err = json.Unmarshal(programJSON, &program)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal staking program: %v", err)
+	}
+
+	return &program, nil
+}
+
+// Stake locks a quantity of the program's commodity for termSeconds. The items
+// stay in the user's inventory under a hold, so they are not available to
+// trade or spend until the stake is unlocked.
+func (s *StakingContract) Stake(ctx contractapi.TransactionContextInterface, stakeID, userID, programID string, quantity int, termSeconds int) error {
+	if quantity <= 0 {
+		return fmt.Errorf("quantity must be positive")
+	}
+
+	program, err := s.GetStakingProgram(ctx, programID)
+	if err != nil {
+		return err
+	}
+	if !program.Active {
+		return fmt.Errorf("staking program %s is not active", programID)
+	}
+	if termSeconds < program.MinTermSeconds {
+		return fmt.Errorf("term must be at least %d seconds", program.MinTermSeconds)
+	}
+
+	// Check if stake already exists
+	existing, err := s.GetStake(ctx, stakeID)
+	if err == nil && existing != nil {
+		return fmt.Errorf("stake %s already exists", stakeID)
+	}
+
+	// Initialize asset contract if not set
+	if s.AssetContract == nil {
+		s.AssetContract = &AssetContract{}
+	}
+
+	// Hold the staked items
+	items := []models.RequiredItem{{CommodityID: program.CommodityID, Quantity: quantity}}
+	err = s.AssetContract.placeHold(ctx, userID, stakingHoldReason, stakeID, 0, items)
+	if err != nil {
+		return fmt.Errorf("failed to lock staked items: %v", err)
+	}
+
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	stake := models.Stake{
+		StakeID:     stakeID,
+		ProgramID:   programID,
+		UserID:      userID,
+		CommodityID: program.CommodityID,
+		Quantity:    quantity,
+		Status:      "locked",
+		StakedAt:    timestamp,
+		UnlocksAt:   timestamp.Add(time.Duration(termSeconds) * time.Second),
+	}
+
+	return s.putStake(ctx, &stake)
+}
+
+// Unstake releases the hold on a stake's items and pays the reward earned so
+// far, less the early unlock penalty if the term has not ended. Rewards in a
+// commodity are rounded down to whole units.
+func (s *StakingContract) Unstake(ctx contractapi.TransactionContextInterface, stakeID string) error {
+	stake, err := s.GetStake(ctx, stakeID)
+	if err != nil {
+		return err
+	}
+	if stake.Status != "locked" {
+		return fmt.Errorf("stake is not locked (status: %s)", stake.Status)
+	}
+
+	program, err := s.GetStakingProgram(ctx, stake.ProgramID)
+	if err != nil {
+		return err
+	}
+
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	// 1. Work out the reward and any penalty
+	reward := stakingReward(stake, program, timestamp)
+	if timestamp.Before(stake.UnlocksAt) {
+		stake.EarlyUnlock = true
+		stake.Penalty = reward * program.EarlyUnlockPenalty
+		reward -= stake.Penalty
+	}
+	if program.RewardCommodityID != "" {
+		reward = math.Floor(reward)
+	}
+
+	// Initialize asset contract if not set
+	if s.AssetContract == nil {
+		s.AssetContract = &AssetContract{}
+	}
+
+	// 2. Release the staked items
+	_, err = s.AssetContract.releaseHold(ctx, stake.UserID, stakingHoldReason, stakeID, false)
+	if err != nil {
+		return fmt.Errorf("failed to unlock staked items: %v", err)
+	}
+
+	// 3. Pay the reward
+	if program.RewardCommodityID == "" && reward > 0 {
+		err = s.AssetContract.updateBalance(ctx, stake.UserID, reward, "add")
+		if err != nil {
+			return fmt.Errorf("failed to pay staking reward: %v", err)
+		}
+	} else if reward > 0 {
+		err = s.AssetContract.updateInventory(ctx, stake.UserID, program.RewardCommodityID, int(reward), "add")
+		if err != nil {
+			return fmt.Errorf("failed to pay staking reward: %v", err)
+		}
+	}
+
+	stake.Status = "unlocked"
+	stake.UnlockedAt = timestamp
+	stake.Reward = reward
+
+	err = s.putStake(ctx, stake)
+	if err != nil {
+		return err
+	}
+
+	// 4. Emit event
+	eventJSON, _ := json.Marshal(stake)
+	ctx.GetStub().SetEvent("StakeUnlocked", eventJSON)
+
+	return nil
+}
+
+// GetStake retrieves a stake
+func (s *StakingContract) GetStake(ctx contractapi.TransactionContextInterface, stakeID string) (*models.Stake, error) {
+	stakeJSON, err := ctx.GetStub().GetState(utils.GetStakeKey(stakeID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stake: %v", err)
+	}
+	if stakeJSON == nil {
+		return nil, fmt.Errorf("stake %s does not exist", stakeID)
+	}
+
+	var stake models.Stake
+	err = json.Unmarshal(stakeJSON, &stake)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal stake: %v", err)
+	}
+
+	return &stake, nil
+}
+
+// GetStakeReward returns the reward a locked stake has earned so far, before any early unlock penalty
+func (s *StakingContract) GetStakeReward(ctx contractapi.TransactionContextInterface, stakeID string) (float64, error) {
+	stake, err := s.GetStake(ctx, stakeID)
+	if err != nil {
+		return 0, err
+	}
+	if stake.Status != "locked" {
+		return stake.Reward, nil
+	}
+
+	program, err := s.GetStakingProgram(ctx, stake.ProgramID)
+	if err != nil {
+		return 0, err
+	}
+
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	return stakingReward(stake, program, timestamp), nil
+}
+
+// GetUserStakes retrieves every stake of a user
+func (s *StakingContract) GetUserStakes(ctx contractapi.TransactionContextInterface, userID string) ([]*models.Stake, error) {
+	iterator, err := ctx.GetStub().GetStateByRange(utils.StakePrefix, utils.StakePrefix+"\uffff")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stake iterator: %v", err)
+	}
+	defer iterator.Close()
+
+	var stakes []*models.Stake
+	for iterator.HasNext() {
+		queryResponse, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate stakes: %v", err)
+		}
+
+		var stake models.Stake
+		err = json.Unmarshal(queryResponse.Value, &stake)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal stake: %v", err)
+		}
+
+		if stake.UserID == userID {
+			stakes = append(stakes, &stake)
+		}
+	}
+
+	return stakes, nil
+}
+
+// putStakingProgram saves a staking program
+func (s *StakingContract) putStakingProgram(ctx contractapi.TransactionContextInterface, program *models.StakingProgram) error {
+	programJSON, err := json.Marshal(program)
+	if err != nil {
+		return fmt.Errorf("failed to marshal staking program: %v", err)
+	}
+	return ctx.GetStub().PutState(utils.GetStakingProgramKey(program.ProgramID), programJSON)
+}
+
+// putStake saves a stake
+func (s *StakingContract) putStake(ctx contractapi.TransactionContextInterface, stake *models.Stake) error {
+	stakeJSON, err := json.Marshal(stake)
+	if err != nil {
+		return fmt.Errorf("failed to marshal stake: %v", err)
+	}
+	return ctx.GetStub().PutState(utils.GetStakeKey(stake.StakeID), stakeJSON)
+}
+
+// stakingReward returns the reward a stake has earned by timestamp; stakes stop earning when their term ends
+func stakingReward(stake *models.Stake, program *models.StakingProgram, timestamp time.Time) float64 {
+	end := timestamp
+	if end.After(stake.UnlocksAt) {
+		end = stake.UnlocksAt
+	}
+	if !end.After(stake.StakedAt) {
+		return 0
+	}
+
+	days := end.Sub(stake.StakedAt).Seconds() / secondsPerDay
+	return float64(stake.Quantity) * program.RewardRate * days
+}
@@ -0,0 +1,388 @@
+package contracts
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-samples/game-chaincode/models"
+	"github.com/hyperledger/fabric-samples/game-chaincode/utils"
+)
+
+// StorageContract provides inventory capacity limits, capacity upgrades and storage fees
+type StorageContract struct {
+	contractapi.Contract
+	AssetContract *AssetContract
+}
+
+// SetStorageConfig sets the storage rules (admin only). Every user can store
+// baseCapacity units and buy upgrades of upgradeCapacity units for
+// upgradePrice each. If feePerUnit is positive, ChargeStorageFees bills each
+// used unit feePerUnit per feePeriodSeconds. Payments go to feeCollectorID, or
+// are burned if it is empty.
+func (s *StorageContract) SetStorageConfig(ctx contractapi.TransactionContextInterface, baseCapacity, upgradeCapacity int, upgradePrice, feePerUnit float64, feePeriodSeconds int, feeCollectorID string) error {
+	if _, err := requireRole(ctx, RoleAdmin); err != nil {
+		return err
+	}
+
+	if baseCapacity <= 0 {
+		return fmt.Errorf("base capacity must be positive")
+	}
+	if upgradeCapacity < 0 || upgradePrice < 0 || feePerUnit < 0 {
+		return fmt.Errorf("upgrade and fee settings cannot be negative")
+	}
+	if feePerUnit > 0 && feePeriodSeconds <= 0 {
+		return fmt.Errorf("fee period must be positive when fees are charged")
+	}
+
+	// Initialize asset contract if not set
+	if s.AssetContract == nil {
+		s.AssetContract = &AssetContract{}
+	}
+
+	if feeCollectorID != "" {
+		if _, err := s.AssetContract.GetUserAssets(ctx, feeCollectorID); err != nil {
+			return err
+		}
+	}
+
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	config := models.StorageConfig{
+		BaseCapacity:     baseCapacity,
+		UpgradeCapacity:  upgradeCapacity,
+		UpgradePrice:     upgradePrice,
+		FeePerUnit:       feePerUnit,
+		FeePeriodSeconds: feePeriodSeconds,
+		FeeCollectorID:   feeCollectorID,
+		UpdatedAt:        timestamp,
+	}
+
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("failed to marshal storage config: %v", err)
+	}
+	return ctx.GetStub().PutState(utils.StorageConfigKey, configJSON)
+}
+
+// GetStorageConfig retrieves the storage rules
+func (s *StorageContract) GetStorageConfig(ctx contractapi.TransactionContextInterface) (*models.StorageConfig, error) {
+	config, err := getStorageConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return nil, fmt.Errorf("storage is not configured")
+	}
+	return config, nil
+}
+
+// PurchaseStorageUpgrade buys count capacity upgrades for a user
+func (s *StorageContract) PurchaseStorageUpgrade(ctx contractapi.TransactionContextInterface, userID string, count int) error {
+	config, err := s.GetStorageConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	if count <= 0 {
+		return fmt.Errorf("count must be positive")
+	}
+	if config.UpgradeCapacity == 0 {
+		return fmt.Errorf("storage upgrades are not available")
+	}
+
+	// Initialize asset contract if not set
+	if s.AssetContract == nil {
+		s.AssetContract = &AssetContract{}
+	}
+
+	// 1. Pay for the upgrades
+	cost := config.UpgradePrice * float64(count)
+	if cost > 0 {
+		err = s.AssetContract.updateBalance(ctx, userID, cost, "subtract")
+		if err != nil {
+			return fmt.Errorf("failed to pay for storage upgrade: %v", err)
+		}
+		if config.FeeCollectorID != "" && config.FeeCollectorID != userID {
+			err = s.AssetContract.updateBalance(ctx, config.FeeCollectorID, cost, "add")
+			if err != nil {
+				return fmt.Errorf("failed to pay fee collector: %v", err)
+			}
+		}
+	} else if _, err := s.AssetContract.GetUserAssets(ctx, userID); err != nil {
+		return err
+	}
+
+	// 2. Record the upgrades
+	account, err := getStorageAccount(ctx, userID)
+	if err != nil {
+		return err
+	}
+	account.Upgrades += count
+
+	return putStorageAccount(ctx, account)
+}
+
+// GetStorageAccount retrieves a user's storage upgrades and fee billing
+func (s *StorageContract) GetStorageAccount(ctx contractapi.TransactionContextInterface, userID string) (*models.StorageAccount, error) {
+	return getStorageAccount(ctx, userID)
+}
+
+// GetStorageUsage returns the storage units a user is using and their capacity
+func (s *StorageContract) GetStorageUsage(ctx contractapi.TransactionContextInterface, userID string) (*models.StorageUsage, error) {
+	config, err := s.GetStorageConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Initialize asset contract if not set
+	if s.AssetContract == nil {
+		s.AssetContract = &AssetContract{}
+	}
+
+	return storageUsage(ctx, s.AssetContract, config, userID)
+}
+
+// ChargeStorageFees bills storage fees to the users in userIDsJSON (admin
+// only) for every whole fee period since they were last billed. Billing for a
+// user starts the first time they are included. Fees the user's available
+// balance cannot cover are recorded as unpaid.
+func (s *StorageContract) ChargeStorageFees(ctx contractapi.TransactionContextInterface, userIDsJSON string) error {
+	if _, err := requireRole(ctx, RoleAdmin); err != nil {
+		return err
+	}
+
+	config, err := s.GetStorageConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if config.FeePerUnit == 0 {
+		return fmt.Errorf("storage fees are disabled")
+	}
+
+	var userIDs []string
+	err = json.Unmarshal([]byte(userIDsJSON), &userIDs)
+	if err != nil {
+		return fmt.Errorf("failed to parse user IDs: %v", err)
+	}
+
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	period := time.Duration(config.FeePeriodSeconds) * time.Second
+
+	// Initialize asset contract if not set
+	if s.AssetContract == nil {
+		s.AssetContract = &AssetContract{}
+	}
+
+	type storageFee struct {
+		UserID  string  `json:"userId"`
+		Used    int     `json:"used"`
+		Periods int     `json:"periods"`
+		Fee     float64 `json:"fee"`
+		Paid    float64 `json:"paid"`
+	}
+	var fees []storageFee
+	var collected float64
+	seen := map[string]bool{}
+
+	for _, userID := range userIDs {
+		// The collector does not pay itself, and each user is billed once
+		if seen[userID] || userID == config.FeeCollectorID {
+			continue
+		}
+		seen[userID] = true
+
+		account, err := getStorageAccount(ctx, userID)
+		if err != nil {
+			return err
+		}
+
+		// 1. Start billing users seen for the first time
+		if account.LastFeeAt.IsZero() {
+			if _, err := s.AssetContract.GetUserAssets(ctx, userID); err != nil {
+				return err
+			}
+			account.LastFeeAt = timestamp
+			err = putStorageAccount(ctx, account)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		periods := int(timestamp.Sub(account.LastFeeAt) / period)
+		if periods == 0 {
+			continue
+		}
+
+		// 2. Work out the fee for the elapsed periods
+		usage, err := storageUsage(ctx, s.AssetContract, config, userID)
+		if err != nil {
+			return err
+		}
+		fee := float64(usage.Used) * config.FeePerUnit * float64(periods)
+		account.LastFeeAt = account.LastFeeAt.Add(time.Duration(periods) * period)
+
+		// 3. Charge what the available balance covers; frozen accounts pay nothing
+		paid := 0.0
+		if checkAccountCanTransfer(ctx, userID) == nil {
+			available, err := s.AssetContract.GetAvailableBalance(ctx, userID)
+			if err != nil {
+				return err
+			}
+			paid = fee
+			if paid > available {
+				paid = available
+			}
+		}
+		if paid > 0 {
+			err = s.AssetContract.updateBalance(ctx, userID, paid, "subtract")
+			if err != nil {
+				return fmt.Errorf("failed to charge storage fee: %v", err)
+			}
+		}
+
+		account.FeesPaid += paid
+		account.UnpaidFees += fee - paid
+		err = putStorageAccount(ctx, account)
+		if err != nil {
+			return err
+		}
+
+		fees = append(fees, storageFee{UserID: userID, Used: usage.Used, Periods: periods, Fee: fee, Paid: paid})
+		collected += paid
+	}
+
+	// 4. Pay the collector once for the whole batch
+	if config.FeeCollectorID != "" && collected > 0 {
+		err = s.AssetContract.updateBalance(ctx, config.FeeCollectorID, collected, "add")
+		if err != nil {
+			return fmt.Errorf("failed to pay fee collector: %v", err)
+		}
+	}
+
+	// 5. Emit event
+	eventPayload := map[string]interface{}{
+		"fees":      fees,
+		"collected": collected,
+		"timestamp": timestamp,
+	}
+	eventJSON, _ := json.Marshal(eventPayload)
+	ctx.GetStub().SetEvent("StorageFeesCharged", eventJSON)
+
+	return nil
+}
+
+// getStorageConfig retrieves the storage rules, or nil if storage is not configured
+func getStorageConfig(ctx contractapi.TransactionContextInterface) (*models.StorageConfig, error) {
+	configJSON, err := ctx.GetStub().GetState(utils.StorageConfigKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read storage config: %v", err)
+	}
+	if configJSON == nil {
+		return nil, nil
+	}
+
+	var config models.StorageConfig
+	err = json.Unmarshal(configJSON, &config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal storage config: %v", err)
+	}
+
+	return &config, nil
+}
+
+// getStorageAccount retrieves a user's storage account; users without one have no upgrades
+func getStorageAccount(ctx contractapi.TransactionContextInterface, userID string) (*models.StorageAccount, error) {
+	accountJSON, err := ctx.GetStub().GetState(utils.GetStorageAccountKey(userID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read storage account: %v", err)
+	}
+	if accountJSON == nil {
+		return &models.StorageAccount{UserID: userID}, nil
+	}
+
+	var account models.StorageAccount
+	err = json.Unmarshal(accountJSON, &account)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal storage account: %v", err)
+	}
+
+	return &account, nil
+}
+
+// putStorageAccount saves a user's storage account
+func putStorageAccount(ctx contractapi.TransactionContextInterface, account *models.StorageAccount) error {
+	accountJSON, err := json.Marshal(account)
+	if err != nil {
+		return fmt.Errorf("failed to marshal storage account: %v", err)
+	}
+	return ctx.GetStub().PutState(utils.GetStorageAccountKey(account.UserID), accountJSON)
+}
+
+// storageUsage adds up the storage units a user's inventory takes up
+func storageUsage(ctx contractapi.TransactionContextInterface, assetContract *AssetContract, config *models.StorageConfig, userID string) (*models.StorageUsage, error) {
+	account, err := getStorageAccount(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	inventories, err := assetContract.scanInventory(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	usage := &models.StorageUsage{
+		UserID:   userID,
+		Capacity: config.BaseCapacity + account.Upgrades*config.UpgradeCapacity,
+	}
+	for _, inventory := range inventories {
+		size, err := commoditySize(ctx, inventory.CommodityID)
+		if err != nil {
+			return nil, err
+		}
+		usage.Used += inventory.Quantity * size
+	}
+
+	return usage, nil
+}
+
+// checkStorageCapacity verifies a user has room for items on top of their
+// inventory; there is no limit until storage is configured. Callers adding
+// several items in one transaction check them together, as the inventory
+// written earlier in the transaction is not visible yet.
+func checkStorageCapacity(ctx contractapi.TransactionContextInterface, assetContract *AssetContract, userID string, items []models.RequiredItem) error {
+	config, err := getStorageConfig(ctx)
+	if err != nil || config == nil {
+		return err
+	}
+
+	usage, err := storageUsage(ctx, assetContract, config, userID)
+	if err != nil {
+		return err
+	}
+
+	needed := 0
+	for _, item := range items {
+		size, err := commoditySize(ctx, item.CommodityID)
+		if err != nil {
+			return err
+		}
+		needed += item.Quantity * size
+	}
+
+	if usage.Used+needed > usage.Capacity {
+		return fmt.Errorf("storage capacity exceeded for user %s (%d of %d units used, %d more needed)",
+			userID, usage.Used, usage.Capacity, needed)
+	}
+	return nil
+}
@@ -0,0 +1,284 @@
+package contracts
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-samples/game-chaincode/models"
+	"github.com/hyperledger/fabric-samples/game-chaincode/utils"
+)
+
+// SubscriptionContract provides recurring payments between users
+type SubscriptionContract struct {
+	contractapi.Contract
+	AssetContract *AssetContract
+}
+
+// CreateSubscription authorizes payerID to pay amount to payeeID every
+// intervalSeconds, starting one interval from now. The subscription is
+// suspended after maxFailures consecutive failed payments.
+func (s *SubscriptionContract) CreateSubscription(ctx contractapi.TransactionContextInterface, subscriptionID, payerID, payeeID string, amount float64, intervalSeconds, maxFailures int) error {
+	if payerID == payeeID {
+		return fmt.Errorf("payer and payee must differ")
+	}
+	if amount <= 0 {
+		return fmt.Errorf("amount must be positive")
+	}
+	if intervalSeconds <= 0 {
+		return fmt.Errorf("interval must be positive")
+	}
+	if maxFailures <= 0 {
+		return fmt.Errorf("max failures must be positive")
+	}
+
+	// Check if subscription already exists
+	existing, err := s.GetSubscription(ctx, subscriptionID)
+	if err == nil && existing != nil {
+		return fmt.Errorf("subscription %s already exists", subscriptionID)
+	}
+
+	// Initialize asset contract if not set
+	if s.AssetContract == nil {
+		s.AssetContract = &AssetContract{}
+	}
+
+	for _, userID := range []string{payerID, payeeID} {
+		if _, err := s.AssetContract.GetUserAssets(ctx, userID); err != nil {
+			return err
+		}
+	}
+	if err := checkAccountCanTransfer(ctx, payerID); err != nil {
+		return err
+	}
+
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	subscription := models.Subscription{
+		SubscriptionID:  subscriptionID,
+		PayerID:         payerID,
+		PayeeID:         payeeID,
+		Amount:          amount,
+		IntervalSeconds: intervalSeconds,
+		MaxFailures:     maxFailures,
+		Status:          "active",
+		NextPaymentAt:   timestamp.Add(time.Duration(intervalSeconds) * time.Second),
+		CreatedAt:       timestamp,
+		UpdatedAt:       timestamp,
+	}
+
+	return s.putSubscription(ctx, &subscription)
+}
+
+// Collect pulls every payment of a subscription that has fallen due. Anyone
+// may call it. Due payments the payer's available balance cannot cover are
+// skipped and counted as failures.
+func (s *SubscriptionContract) Collect(ctx contractapi.TransactionContextInterface, subscriptionID string) error {
+	subscription, err := s.GetSubscription(ctx, subscriptionID)
+	if err != nil {
+		return err
+	}
+	if subscription.Status != "active" {
+		return fmt.Errorf("subscription is not active (status: %s)", subscription.Status)
+	}
+
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	if timestamp.Before(subscription.NextPaymentAt) {
+		return fmt.Errorf("no payment is due until %s", subscription.NextPaymentAt.Format(time.RFC3339))
+	}
+
+	// A payee who cannot receive payments is not the payer's failure
+	if err := checkAccountCanTransfer(ctx, subscription.PayeeID); err != nil {
+		return err
+	}
+
+	// 1. Work out how many due payments the payer can cover
+	interval := time.Duration(subscription.IntervalSeconds) * time.Second
+	due := int(timestamp.Sub(subscription.NextPaymentAt)/interval) + 1
+
+	// Initialize asset contract if not set
+	if s.AssetContract == nil {
+		s.AssetContract = &AssetContract{}
+	}
+
+	paid := 0
+	if checkAccountCanTransfer(ctx, subscription.PayerID) == nil {
+		available, err := s.AssetContract.GetAvailableBalance(ctx, subscription.PayerID)
+		if err != nil {
+			return err
+		}
+		for paid < due && float64(paid+1)*subscription.Amount <= available {
+			paid++
+		}
+	}
+	failed := due - paid
+
+	// 2. Move the payments in a single transfer
+	amount := float64(paid) * subscription.Amount
+	if paid > 0 {
+		err = s.AssetContract.updateBalance(ctx, subscription.PayerID, amount, "subtract")
+		if err != nil {
+			return fmt.Errorf("failed to deduct subscription payment: %v", err)
+		}
+		err = s.AssetContract.updateBalance(ctx, subscription.PayeeID, amount, "add")
+		if err != nil {
+			return fmt.Errorf("failed to pay subscription payee: %v", err)
+		}
+	}
+
+	// 3. Record the payments and failures; the failed payments are the latest ones
+	subscription.PaymentsMade += paid
+	subscription.TotalPaid += amount
+	subscription.NextPaymentAt = subscription.NextPaymentAt.Add(time.Duration(due) * interval)
+	if paid > 0 {
+		subscription.ConsecutiveFailures = 0
+	}
+	if failed > 0 {
+		subscription.FailedPayments += failed
+		subscription.ConsecutiveFailures += failed
+		subscription.LastFailureAt = timestamp
+	}
+	eventName := "SubscriptionCollected"
+	if subscription.ConsecutiveFailures >= subscription.MaxFailures {
+		subscription.Status = "suspended"
+		eventName = "SubscriptionSuspended"
+	}
+	subscription.UpdatedAt = timestamp
+
+	err = s.putSubscription(ctx, subscription)
+	if err != nil {
+		return err
+	}
+
+	// 4. Emit event
+	eventPayload := map[string]interface{}{
+		"subscriptionId": subscriptionID,
+		"payerId":        subscription.PayerID,
+		"payeeId":        subscription.PayeeID,
+		"paid":           paid,
+		"failed":         failed,
+		"amount":         amount,
+		"status":         subscription.Status,
+		"timestamp":      timestamp,
+	}
+	eventJSON, _ := json.Marshal(eventPayload)
+	ctx.GetStub().SetEvent(eventName, eventJSON)
+
+	return nil
+}
+
+// ResumeSubscription reactivates a suspended subscription. Its failures are
+// cleared and the next payment falls due one interval from now.
+func (s *SubscriptionContract) ResumeSubscription(ctx contractapi.TransactionContextInterface, subscriptionID string) error {
+	subscription, err := s.GetSubscription(ctx, subscriptionID)
+	if err != nil {
+		return err
+	}
+	if subscription.Status != "suspended" {
+		return fmt.Errorf("subscription is not suspended (status: %s)", subscription.Status)
+	}
+	if err := checkAccountCanTransfer(ctx, subscription.PayerID); err != nil {
+		return err
+	}
+
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	subscription.Status = "active"
+	subscription.ConsecutiveFailures = 0
+	subscription.NextPaymentAt = timestamp.Add(time.Duration(subscription.IntervalSeconds) * time.Second)
+	subscription.UpdatedAt = timestamp
+
+	return s.putSubscription(ctx, subscription)
+}
+
+// CancelSubscription stops a subscription; no further payments are collected
+func (s *SubscriptionContract) CancelSubscription(ctx contractapi.TransactionContextInterface, subscriptionID string) error {
+	subscription, err := s.GetSubscription(ctx, subscriptionID)
+	if err != nil {
+		return err
+	}
+	if subscription.Status == "cancelled" {
+		return fmt.Errorf("subscription %s is already cancelled", subscriptionID)
+	}
+
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	subscription.Status = "cancelled"
+	subscription.UpdatedAt = timestamp
+
+	return s.putSubscription(ctx, subscription)
+}
+
+// GetSubscription retrieves a subscription
+func (s *SubscriptionContract) GetSubscription(ctx contractapi.TransactionContextInterface, subscriptionID string) (*models.Subscription, error) {
+	subscriptionJSON, err := ctx.GetStub().GetState(utils.GetSubscriptionKey(subscriptionID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read subscription: %v", err)
+	}
+	if subscriptionJSON == nil {
+		return nil, fmt.Errorf("subscription %s does not exist", subscriptionID)
+	}
+
+	var subscription models.Subscription
+	err = json.Unmarshal(subscriptionJSON, &subscription)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal subscription: %v", err)
+	}
+
+	return &subscription, nil
+}
+
+// GetUserSubscriptions retrieves every subscription a user pays or is paid by
+func (s *SubscriptionContract) GetUserSubscriptions(ctx contractapi.TransactionContextInterface, userID string) ([]*models.Subscription, error) {
+	iterator, err := ctx.GetStub().GetStateByRange(utils.SubscriptionPrefix, utils.SubscriptionPrefix+"\uffff")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get subscription iterator: %v", err)
+	}
+	defer iterator.Close()
+
+	var subscriptions []*models.Subscription
+	for iterator.HasNext() {
+		queryResponse, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate subscriptions: %v", err)
+		}
+
+		var subscription models.Subscription
+		err = json.Unmarshal(queryResponse.Value, &subscription)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal subscription: %v", err)
+		}
+
+		if subscription.PayerID == userID || subscription.PayeeID == userID {
+			subscriptions = append(subscriptions, &subscription)
+		}
+	}
+
+	return subscriptions, nil
+}
+
+// putSubscription saves a subscription
+func (s *SubscriptionContract) putSubscription(ctx contractapi.TransactionContextInterface, subscription *models.Subscription) error {
+	subscriptionJSON, err := json.Marshal(subscription)
+	if err != nil {
+		return fmt.Errorf("failed to marshal subscription: %v", err)
+	}
+	return ctx.GetStub().PutState(utils.GetSubscriptionKey(subscription.SubscriptionID), subscriptionJSON)
+}
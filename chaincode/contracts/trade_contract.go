@@ -12,16 +12,48 @@ import (
 // TradeContract provides functions for managing trades
 type TradeContract struct {
 	contractapi.Contract
-	AssetContract *AssetContract
+	AssetContract       *AssetContract
+	MarketDataContract  *MarketDataContract
+	LeaderboardContract *LeaderboardContract
+	QuestContract       *QuestContract
+	ReputationContract  *ReputationContract
 }
 
-// CreateTrade creates a new trade proposal
-func (t *TradeContract) CreateTrade(ctx contractapi.TransactionContextInterface, tradeID, fromUserID, toUserID, commodityID string, quantity int, price float64, action string) error {
+// CreateTrade creates a new trade proposal for quantity units at unitPrice each
+func (t *TradeContract) CreateTrade(ctx contractapi.TransactionContextInterface, tradeID, fromUserID, toUserID, commodityID string, quantity int, unitPrice float64, action string) error {
+	return t.createTrade(ctx, tradeID, fromUserID, toUserID, commodityID, quantity, unitPrice, action, 0)
+}
+
+// CreateTradeWithMinReputation creates a new trade proposal like CreateTrade, provided
+// the counterparty toUserID has an average rating of at least minRating
+func (t *TradeContract) CreateTradeWithMinReputation(ctx contractapi.TransactionContextInterface, tradeID, fromUserID, toUserID, commodityID string, quantity int, unitPrice float64, action string, minRating float64) error {
+	if minRating < 0 || minRating > MaxRatingScore {
+		return fmt.Errorf("minimum rating must be between 0 and %d", MaxRatingScore)
+	}
+
+	return t.createTrade(ctx, tradeID, fromUserID, toUserID, commodityID, quantity, unitPrice, action, minRating)
+}
+
+// createTrade validates and stores a trade proposal
+func (t *TradeContract) createTrade(ctx contractapi.TransactionContextInterface, tradeID, fromUserID, toUserID, commodityID string, quantity int, unitPrice float64, action string, minRating float64) error {
 	// Validate action
 	if action != "buy" && action != "sell" {
 		return fmt.Errorf("invalid action: %s (must be 'buy' or 'sell')", action)
 	}
 
+	// A self-trade moves nothing but would still count towards quests and leaderboards
+	if fromUserID == toUserID {
+		return fmt.Errorf("cannot trade with yourself")
+	}
+
+	if quantity <= 0 {
+		return fmt.Errorf("quantity must be positive")
+	}
+	if unitPrice < 0 {
+		return fmt.Errorf("unit price cannot be negative")
+	}
+	totalPrice := unitPrice * float64(quantity)
+
 	// Check if trade already exists
 	existing, err := t.GetTradeStatus(ctx, tradeID)
 	if err == nil && existing != nil {
@@ -40,17 +72,40 @@ func (t *TradeContract) CreateTrade(ctx contractapi.TransactionContextInterface,
 		buyerID = toUserID
 	}
 
+	// Verify neither party is barred from trading
+	for _, userID := range []string{sellerID, buyerID} {
+		if err := checkAccountCanTrade(ctx, userID); err != nil {
+			return err
+		}
+	}
+
+	// Verify the counterparty meets the required reputation
+	if minRating > 0 {
+		// Initialize reputation contract if not set
+		if t.ReputationContract == nil {
+			t.ReputationContract = &ReputationContract{TradeContract: t}
+		}
+
+		reputation, err := t.ReputationContract.GetReputation(ctx, toUserID)
+		if err != nil {
+			return err
+		}
+		if reputation.AverageRating < minRating {
+			return fmt.Errorf("counterparty %s has an average rating of %.2f (requires %.2f)", toUserID, reputation.AverageRating, minRating)
+		}
+	}
+
 	// Initialize asset contract if not set
 	if t.AssetContract == nil {
 		t.AssetContract = &AssetContract{}
 	}
 
 	// Verify seller has enough inventory
-	inventory, err := t.AssetContract.GetInventory(ctx, sellerID, commodityID)
+	available, err := t.AssetContract.GetAvailableInventory(ctx, sellerID, commodityID)
 	if err != nil {
 		return fmt.Errorf("failed to get seller inventory: %v", err)
 	}
-	if inventory.Quantity < quantity {
+	if available < quantity {
 		return fmt.Errorf("seller has insufficient inventory")
 	}
 
@@ -59,7 +114,7 @@ func (t *TradeContract) CreateTrade(ctx contractapi.TransactionContextInterface,
 	if err != nil {
 		return fmt.Errorf("failed to get buyer assets: %v", err)
 	}
-	if buyerAsset.Balance < price {
+	if buyerAsset.Balance-buyerAsset.Locked < totalPrice {
 		return fmt.Errorf("buyer has insufficient balance")
 	}
 
@@ -69,6 +124,11 @@ func (t *TradeContract) CreateTrade(ctx contractapi.TransactionContextInterface,
 		return err
 	}
 
+	seasonID, err := currentSeasonID(ctx)
+	if err != nil {
+		return err
+	}
+
 	// Create trade
 	trade := models.Trade{
 		TradeID:     tradeID,
@@ -76,9 +136,11 @@ func (t *TradeContract) CreateTrade(ctx contractapi.TransactionContextInterface,
 		ToUserID:    toUserID,
 		CommodityID: commodityID,
 		Quantity:    quantity,
-		Price:       price,
+		UnitPrice:   unitPrice,
+		TotalPrice:  totalPrice,
 		Action:      action,
 		Status:      "pending",
+		SeasonID:    seasonID,
 		CreatedAt:   timestamp,
 	}
 
@@ -88,10 +150,14 @@ func (t *TradeContract) CreateTrade(ctx contractapi.TransactionContextInterface,
 	}
 
 	key := utils.GetTradeKey(tradeID)
+	err = recordEconomyDelta(ctx, key, models.EconomyDelta{Trades: 1})
+	if err != nil {
+		return err
+	}
 	return ctx.GetStub().PutState(key, tradeJSON)
 }
 
-// ExecuteTrade executes a pending trade
+// ExecuteTrade executes the whole pending remainder of a trade
 func (t *TradeContract) ExecuteTrade(ctx contractapi.TransactionContextInterface, tradeID string) error {
 	// Get trade
 	trade, err := t.GetTradeStatus(ctx, tradeID)
@@ -99,19 +165,47 @@ func (t *TradeContract) ExecuteTrade(ctx contractapi.TransactionContextInterface
 		return err
 	}
 
+	return t.fillTrade(ctx, trade, trade.Quantity)
+}
+
+// AcceptTrade accepts part of a pending trade, leaving the remainder pending
+func (t *TradeContract) AcceptTrade(ctx contractapi.TransactionContextInterface, tradeID string, quantity int) error {
+	// Get trade
+	trade, err := t.GetTradeStatus(ctx, tradeID)
+	if err != nil {
+		return err
+	}
+
+	return t.fillTrade(ctx, trade, quantity)
+}
+
+// fillTrade settles quantity units of a pending trade at its unit price
+func (t *TradeContract) fillTrade(ctx contractapi.TransactionContextInterface, trade *models.Trade, quantity int) error {
 	// Check trade status
 	if trade.Status != "pending" {
 		return fmt.Errorf("trade is not pending (status: %s)", trade.Status)
 	}
 
+	if quantity <= 0 {
+		return fmt.Errorf("quantity must be positive")
+	}
+	if quantity > trade.Quantity {
+		return fmt.Errorf("quantity %d exceeds pending quantity %d", quantity, trade.Quantity)
+	}
+	fillPrice := trade.UnitPrice * float64(quantity)
+	if quantity == trade.Quantity {
+		// The whole remainder settles at the total, which is exact for legacy trades
+		fillPrice = trade.TotalPrice
+	}
+
 	// Determine seller and buyer
-	var sellerID, buyerID string
-	if trade.Action == "buy" {
-		sellerID = trade.ToUserID
-		buyerID = trade.FromUserID
-	} else {
-		sellerID = trade.FromUserID
-		buyerID = trade.ToUserID
+	sellerID, buyerID := tradeParties(trade)
+
+	// Verify neither party is barred from trading
+	for _, userID := range []string{sellerID, buyerID} {
+		if err := checkAccountCanTrade(ctx, userID); err != nil {
+			return err
+		}
 	}
 
 	// Initialize asset contract if not set
@@ -120,11 +214,11 @@ func (t *TradeContract) ExecuteTrade(ctx contractapi.TransactionContextInterface
 	}
 
 	// Verify seller still has enough inventory
-	sellerInventory, err := t.AssetContract.GetInventory(ctx, sellerID, trade.CommodityID)
+	available, err := t.AssetContract.GetAvailableInventory(ctx, sellerID, trade.CommodityID)
 	if err != nil {
 		return fmt.Errorf("failed to get seller inventory: %v", err)
 	}
-	if sellerInventory.Quantity < trade.Quantity {
+	if available < quantity {
 		return fmt.Errorf("seller has insufficient inventory")
 	}
 
@@ -133,64 +227,150 @@ func (t *TradeContract) ExecuteTrade(ctx contractapi.TransactionContextInterface
 	if err != nil {
 		return fmt.Errorf("failed to get buyer assets: %v", err)
 	}
-	if buyerAsset.Balance < trade.Price {
+	if buyerAsset.Balance-buyerAsset.Locked < fillPrice {
 		return fmt.Errorf("buyer has insufficient balance")
 	}
 
+	// Verify buyer has room to store the items
+	items := []models.RequiredItem{{CommodityID: trade.CommodityID, Quantity: quantity}}
+	err = checkStorageCapacity(ctx, t.AssetContract, buyerID, items)
+	if err != nil {
+		return err
+	}
+
 	// Execute trade atomically
 	// 1. Update seller inventory (subtract)
-	err = t.AssetContract.UpdateInventory(ctx, sellerID, trade.CommodityID, trade.Quantity, "subtract")
+	lots, err := t.AssetContract.takeItems(ctx, sellerID, trade.CommodityID, quantity)
 	if err != nil {
 		return fmt.Errorf("failed to update seller inventory: %v", err)
 	}
 
-	// 2. Update buyer inventory (add)
-	err = t.AssetContract.UpdateInventory(ctx, buyerID, trade.CommodityID, trade.Quantity, "add")
+	// 2. Update buyer inventory (add), keeping the items' expiry
+	err = t.AssetContract.giveItems(ctx, buyerID, trade.CommodityID, quantity, lots)
 	if err != nil {
 		return fmt.Errorf("failed to update buyer inventory: %v", err)
 	}
 
 	// 3. Update buyer balance (subtract)
-	err = t.AssetContract.UpdateBalance(ctx, buyerID, trade.Price, "subtract")
+	err = t.AssetContract.updateBalance(ctx, buyerID, fillPrice, "subtract")
 	if err != nil {
 		return fmt.Errorf("failed to update buyer balance: %v", err)
 	}
 
 	// 4. Update seller balance (add)
-	err = t.AssetContract.UpdateBalance(ctx, sellerID, trade.Price, "add")
+	err = t.AssetContract.updateBalance(ctx, sellerID, fillPrice, "add")
 	if err != nil {
 		return fmt.Errorf("failed to update seller balance: %v", err)
 	}
 
-	// 5. Update trade status
 	// Get deterministic timestamp
 	timestamp, err := utils.GetTxTimestamp(ctx)
 	if err != nil {
 		return err
 	}
-	trade.Status = "successful"
-	trade.CompletedAt = timestamp
+
+	// 5. Record the fill
+	trade.FillCount++
+	fillKey, err := utils.GetTradeFillKey(ctx, trade.TradeID, trade.FillCount)
+	if err != nil {
+		return fmt.Errorf("failed to create trade fill key: %v", err)
+	}
+	fill := models.TradeFill{
+		FillID:      fmt.Sprintf("%s_%d", trade.TradeID, trade.FillCount),
+		TradeID:     trade.TradeID,
+		SellerID:    sellerID,
+		BuyerID:     buyerID,
+		CommodityID: trade.CommodityID,
+		Quantity:    quantity,
+		UnitPrice:   trade.UnitPrice,
+		TotalPrice:  fillPrice,
+		Timestamp:   timestamp,
+	}
+	fillJSON, err := json.Marshal(fill)
+	if err != nil {
+		return fmt.Errorf("failed to marshal trade fill: %v", err)
+	}
+	err = ctx.GetStub().PutState(fillKey, fillJSON)
+	if err != nil {
+		return fmt.Errorf("failed to save trade fill: %v", err)
+	}
+
+	// 6. Update market price history (gifts at zero price are not market prices)
+	if trade.UnitPrice > 0 {
+		// Initialize market data contract if not set
+		if t.MarketDataContract == nil {
+			t.MarketDataContract = &MarketDataContract{}
+		}
+
+		err = t.MarketDataContract.recordExecution(ctx, trade.CommodityID, quantity, trade.UnitPrice)
+		if err != nil {
+			return fmt.Errorf("failed to record price history: %v", err)
+		}
+	}
+
+	// 7. Update trade remainder and status
+	trade.Quantity -= quantity
+	trade.FilledQuantity += quantity
+	trade.TotalPrice = trade.UnitPrice * float64(trade.Quantity)
+	if trade.Quantity == 0 {
+		trade.Status = "successful"
+		trade.CompletedAt = timestamp
+
+		// Count the completed trade towards both parties' reputation
+		// Initialize reputation contract if not set
+		if t.ReputationContract == nil {
+			t.ReputationContract = &ReputationContract{TradeContract: t}
+		}
+		err = t.ReputationContract.recordTradeOutcome(ctx, trade, true)
+		if err != nil {
+			return fmt.Errorf("failed to update reputation: %v", err)
+		}
+
+		// Count the completed trade once towards both parties' trade leaderboard scores and quests
+		// Initialize leaderboard contract if not set
+		if t.LeaderboardContract == nil {
+			t.LeaderboardContract = &LeaderboardContract{}
+		}
+		// Initialize quest contract if not set
+		if t.QuestContract == nil {
+			t.QuestContract = &QuestContract{AssetContract: t.AssetContract}
+		}
+		for _, userID := range []string{sellerID, buyerID} {
+			err = t.LeaderboardContract.incrementScore(ctx, BoardTrades, userID, 1)
+			if err != nil {
+				return fmt.Errorf("failed to update leaderboard: %v", err)
+			}
+			err = t.QuestContract.recordActivity(ctx, QuestMetricTrades, userID, 1)
+			if err != nil {
+				return fmt.Errorf("failed to update quest progress: %v", err)
+			}
+		}
+	}
 
 	tradeJSON, err := json.Marshal(trade)
 	if err != nil {
 		return fmt.Errorf("failed to marshal trade: %v", err)
 	}
 
-	key := utils.GetTradeKey(tradeID)
+	key := utils.GetTradeKey(trade.TradeID)
 	err = ctx.GetStub().PutState(key, tradeJSON)
 	if err != nil {
 		return fmt.Errorf("failed to update trade: %v", err)
 	}
 
-	// 6. Emit event
+	// 8. Emit event
 	eventPayload := map[string]interface{}{
-		"tradeId":     trade.TradeID,
-		"fromUserId":  trade.FromUserID,
-		"toUserId":    trade.ToUserID,
-		"commodityId": trade.CommodityID,
-		"quantity":    trade.Quantity,
-		"price":       trade.Price,
-		"timestamp":   trade.CompletedAt,
+		"tradeId":           trade.TradeID,
+		"fillId":            fill.FillID,
+		"fromUserId":        trade.FromUserID,
+		"toUserId":          trade.ToUserID,
+		"commodityId":       trade.CommodityID,
+		"quantity":          quantity,
+		"unitPrice":         trade.UnitPrice,
+		"totalPrice":        fillPrice,
+		"remainingQuantity": trade.Quantity,
+		"status":            trade.Status,
+		"timestamp":         timestamp,
 	}
 	eventJSON, _ := json.Marshal(eventPayload)
 	ctx.GetStub().SetEvent("TradeExecuted", eventJSON)
@@ -198,8 +378,8 @@ func (t *TradeContract) ExecuteTrade(ctx contractapi.TransactionContextInterface
 	return nil
 }
 
-// RejectTrade rejects a pending trade
-func (t *TradeContract) RejectTrade(ctx contractapi.TransactionContextInterface, tradeID string) error {
+// RejectTrade lets the counterparty of a pending trade reject it
+func (t *TradeContract) RejectTrade(ctx contractapi.TransactionContextInterface, tradeID, userID string) error {
 	// Get trade
 	trade, err := t.GetTradeStatus(ctx, tradeID)
 	if err != nil {
@@ -210,6 +390,9 @@ func (t *TradeContract) RejectTrade(ctx contractapi.TransactionContextInterface,
 	if trade.Status != "pending" {
 		return fmt.Errorf("trade is not pending (status: %s)", trade.Status)
 	}
+	if userID != trade.ToUserID {
+		return fmt.Errorf("only the counterparty %s can reject trade %s", trade.ToUserID, tradeID)
+	}
 
 	// Get deterministic timestamp
 	timestamp, err := utils.GetTxTimestamp(ctx)
@@ -219,8 +402,19 @@ func (t *TradeContract) RejectTrade(ctx contractapi.TransactionContextInterface,
 
 	// Update trade status
 	trade.Status = "rejected"
+	trade.RejectedBy = userID
 	trade.CompletedAt = timestamp
 
+	// Count the rejected trade against the rejecting party's completion ratio
+	// Initialize reputation contract if not set
+	if t.ReputationContract == nil {
+		t.ReputationContract = &ReputationContract{TradeContract: t}
+	}
+	err = t.ReputationContract.recordTradeOutcome(ctx, trade, false)
+	if err != nil {
+		return fmt.Errorf("failed to update reputation: %v", err)
+	}
+
 	tradeJSON, err := json.Marshal(trade)
 	if err != nil {
 		return fmt.Errorf("failed to marshal trade: %v", err)
@@ -246,6 +440,7 @@ func (t *TradeContract) GetTradeStatus(ctx contractapi.TransactionContextInterfa
 	if err != nil {
 		return nil, fmt.Errorf("failed to unmarshal trade: %v", err)
 	}
+	upgradeLegacyTrade(&trade)
 
 	return &trade, nil
 }
@@ -271,6 +466,7 @@ func (t *TradeContract) GetTradeHistory(ctx contractapi.TransactionContextInterf
 		if err != nil {
 			return nil, fmt.Errorf("failed to unmarshal trade: %v", err)
 		}
+		upgradeLegacyTrade(&trade)
 
 		// Filter trades involving the user
 		if trade.FromUserID == userID || trade.ToUserID == userID {
@@ -280,3 +476,71 @@ func (t *TradeContract) GetTradeHistory(ctx contractapi.TransactionContextInterf
 
 	return trades, nil
 }
+
+// GetSeasonTradeHistory retrieves the trades a user created during a season
+func (t *TradeContract) GetSeasonTradeHistory(ctx contractapi.TransactionContextInterface, userID, seasonID string) ([]*models.Trade, error) {
+	trades, err := t.GetTradeHistory(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var seasonTrades []*models.Trade
+	for _, trade := range trades {
+		if trade.SeasonID == seasonID {
+			seasonTrades = append(seasonTrades, trade)
+		}
+	}
+
+	return seasonTrades, nil
+}
+
+// GetTradeFills retrieves every fill recorded against a trade, oldest first
+func (t *TradeContract) GetTradeFills(ctx contractapi.TransactionContextInterface, tradeID string) ([]*models.TradeFill, error) {
+	iterator, err := ctx.GetStub().GetStateByPartialCompositeKey(utils.TradeFillObjectType, []string{tradeID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get trade fill iterator: %v", err)
+	}
+	defer iterator.Close()
+
+	var fills []*models.TradeFill
+	for iterator.HasNext() {
+		queryResponse, err := iterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate trade fills: %v", err)
+		}
+
+		var fill models.TradeFill
+		err = json.Unmarshal(queryResponse.Value, &fill)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal trade fill: %v", err)
+		}
+
+		fills = append(fills, &fill)
+	}
+
+	return fills, nil
+}
+
+// tradeParties returns the seller and buyer of a trade based on its action
+func tradeParties(trade *models.Trade) (sellerID, buyerID string) {
+	if trade.Action == "buy" {
+		// fromUser wants to buy, so toUser is the seller
+		return trade.ToUserID, trade.FromUserID
+	}
+	// fromUser wants to sell, so toUser is the buyer
+	return trade.FromUserID, trade.ToUserID
+}
+
+// upgradeLegacyTrade converts a trade created before unit pricing, which stored
+// only its total price, to unit pricing. The legacy field is dropped when the
+// trade is next saved.
+func upgradeLegacyTrade(trade *models.Trade) {
+	if trade.LegacyPrice == 0 {
+		return
+	}
+	if trade.UnitPrice == 0 && trade.FilledQuantity == 0 && trade.Quantity > 0 {
+		trade.TotalPrice = trade.LegacyPrice
+		trade.UnitPrice = trade.LegacyPrice / float64(trade.Quantity)
+	}
+	trade.LegacyPrice = 0
+}
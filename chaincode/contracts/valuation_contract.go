@@ -0,0 +1,174 @@
+package contracts
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-samples/game-chaincode/models"
+	"github.com/hyperledger/fabric-samples/game-chaincode/utils"
+)
+
+// Price sources supported by portfolio valuation
+const (
+	PriceSourceLast   = "last"   // last executed trade price
+	PriceSourceOracle = "oracle" // oracle reference price
+	PriceSourceAMM    = "amm"    // proceeds of selling the holding to the AMM pool
+)
+
+// ValuationContract provides net worth queries over user balances and inventories
+type ValuationContract struct {
+	contractapi.Contract
+	AssetContract      *AssetContract
+	MarketDataContract *MarketDataContract
+	OracleContract     *OracleContract
+	AMMContract        *AMMContract
+}
+
+// GetNetWorth values a user's balance and every holding at the given price source
+func (v *ValuationContract) GetNetWorth(ctx contractapi.TransactionContextInterface, userID, priceSource string) (*models.PortfolioValuation, error) {
+	if err := checkPriceSource(priceSource); err != nil {
+		return nil, err
+	}
+
+	return v.valuePortfolio(ctx, userID, priceSource, map[string]*float64{})
+}
+
+// GetNetWorthBatch values many users at once; userIDsJSON is a JSON array of user IDs
+func (v *ValuationContract) GetNetWorthBatch(ctx contractapi.TransactionContextInterface, userIDsJSON, priceSource string) ([]*models.PortfolioValuation, error) {
+	if err := checkPriceSource(priceSource); err != nil {
+		return nil, err
+	}
+
+	var userIDs []string
+	err := json.Unmarshal([]byte(userIDsJSON), &userIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse user IDs: %v", err)
+	}
+
+	// Unit prices are shared across users, except AMM quotes which depend on quantity
+	unitPrices := map[string]*float64{}
+
+	var valuations []*models.PortfolioValuation
+	for _, userID := range userIDs {
+		valuation, err := v.valuePortfolio(ctx, userID, priceSource, unitPrices)
+		if err != nil {
+			return nil, err
+		}
+		valuations = append(valuations, valuation)
+	}
+
+	return valuations, nil
+}
+
+// valuePortfolio values one user, caching unit prices by commodity in unitPrices
+func (v *ValuationContract) valuePortfolio(ctx contractapi.TransactionContextInterface, userID, priceSource string, unitPrices map[string]*float64) (*models.PortfolioValuation, error) {
+	// Initialize asset contract if not set
+	if v.AssetContract == nil {
+		v.AssetContract = &AssetContract{}
+	}
+
+	userAsset, err := v.AssetContract.GetUserAssets(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	inventories, err := v.AssetContract.GetAllInventory(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get deterministic timestamp
+	timestamp, err := utils.GetTxTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	valuation := &models.PortfolioValuation{
+		UserID:      userID,
+		PriceSource: priceSource,
+		Balance:     userAsset.Balance,
+		Holdings:    []*models.HoldingValuation{},
+		ValuedAt:    timestamp,
+	}
+
+	for _, inventory := range inventories {
+		holding := &models.HoldingValuation{
+			CommodityID: inventory.CommodityID,
+			Quantity:    inventory.Quantity,
+		}
+
+		if priceSource == PriceSourceAMM {
+			v.valueAtPool(ctx, holding)
+		} else {
+			price, cached := unitPrices[inventory.CommodityID]
+			if !cached {
+				price = v.unitPrice(ctx, inventory.CommodityID, priceSource)
+				unitPrices[inventory.CommodityID] = price
+			}
+			if price != nil {
+				holding.UnitPrice = *price
+				holding.Value = *price * float64(inventory.Quantity)
+				holding.Priced = true
+			}
+		}
+
+		valuation.Holdings = append(valuation.Holdings, holding)
+		valuation.HoldingsValue += holding.Value
+	}
+	valuation.NetWorth = valuation.Balance + valuation.HoldingsValue
+
+	return valuation, nil
+}
+
+// unitPrice returns the unit price of a commodity at a price source, or nil if it has none
+func (v *ValuationContract) unitPrice(ctx contractapi.TransactionContextInterface, commodityID, priceSource string) *float64 {
+	switch priceSource {
+	case PriceSourceLast:
+		// Initialize market data contract if not set
+		if v.MarketDataContract == nil {
+			v.MarketDataContract = &MarketDataContract{}
+		}
+		stats, err := v.MarketDataContract.GetPriceStats(ctx, commodityID)
+		if err != nil {
+			return nil
+		}
+		return &stats.LastPrice
+	case PriceSourceOracle:
+		// Initialize oracle contract if not set
+		if v.OracleContract == nil {
+			v.OracleContract = &OracleContract{}
+		}
+		price, err := v.OracleContract.GetReferencePrice(ctx, commodityID)
+		if err != nil {
+			return nil
+		}
+		return &price.Price
+	}
+	return nil
+}
+
+// valueAtPool values a holding at what selling all of it to the AMM pool would return
+func (v *ValuationContract) valueAtPool(ctx contractapi.TransactionContextInterface, holding *models.HoldingValuation) {
+	// Initialize AMM contract if not set
+	if v.AMMContract == nil {
+		v.AMMContract = &AMMContract{}
+	}
+
+	proceeds, err := v.AMMContract.QuoteSell(ctx, holding.CommodityID, holding.Quantity)
+	if err != nil {
+		return
+	}
+	holding.Value = proceeds
+	holding.UnitPrice = proceeds / float64(holding.Quantity)
+	holding.Priced = true
+}
+
+// checkPriceSource verifies priceSource is a supported price source
+func checkPriceSource(priceSource string) error {
+	switch priceSource {
+	case PriceSourceLast, PriceSourceOracle, PriceSourceAMM:
+		return nil
+	}
+	return fmt.Errorf("invalid price source: %s (must be 'last', 'oracle' or 'amm')", priceSource)
+}
@@ -9,28 +9,175 @@ import (
 )
 
 func main() {
-	// Create asset contract
-	assetContract := new(contracts.AssetContract)
+	// Create leaderboard contract
+	leaderboardContract := new(contracts.LeaderboardContract)
+
+	// Create asset contract with leaderboard contract reference
+	assetContract := &contracts.AssetContract{
+		LeaderboardContract: leaderboardContract,
+	}
+
+	// Create quest contract and let asset changes drive quest progress
+	questContract := &contracts.QuestContract{
+		AssetContract: assetContract,
+	}
+	assetContract.QuestContract = questContract
 
 	// Create commodity contract
 	commodityContract := new(contracts.CommodityContract)
 
-	// Create trade contract with asset contract reference
+	// Create market data contract
+	marketDataContract := new(contracts.MarketDataContract)
+
+	// Create reputation contract
+	reputationContract := new(contracts.ReputationContract)
+
+	// Create trade contract with asset, market data, leaderboard, quest and reputation contract references
 	tradeContract := &contracts.TradeContract{
-		AssetContract: assetContract,
+		AssetContract:       assetContract,
+		MarketDataContract:  marketDataContract,
+		LeaderboardContract: leaderboardContract,
+		QuestContract:       questContract,
+		ReputationContract:  reputationContract,
 	}
+	reputationContract.TradeContract = tradeContract
+
+	// Create access and oracle contracts
+	accessContract := new(contracts.AccessContract)
+	oracleContract := new(contracts.OracleContract)
 
-	// Create redemption contract with asset contract reference
+	// Create redemption contract with asset, oracle, leaderboard and quest contract references
 	redemptionContract := &contracts.RedemptionContract{
+		AssetContract:       assetContract,
+		OracleContract:      oracleContract,
+		LeaderboardContract: leaderboardContract,
+		QuestContract:       questContract,
+	}
+
+	// Create auction contract with asset contract reference
+	auctionContract := &contracts.AuctionContract{
+		AssetContract: assetContract,
+	}
+
+	// Create AMM contract with asset and commodity contract references
+	ammContract := &contracts.AMMContract{
+		AssetContract:     assetContract,
+		CommodityContract: commodityContract,
+	}
+
+	// Create valuation contract with references to every price source
+	valuationContract := &contracts.ValuationContract{
+		AssetContract:      assetContract,
+		MarketDataContract: marketDataContract,
+		OracleContract:     oracleContract,
+		AMMContract:        ammContract,
+	}
+
+	// Create season contract with asset and leaderboard contract references
+	seasonContract := &contracts.SeasonContract{
+		AssetContract:       assetContract,
+		LeaderboardContract: leaderboardContract,
+	}
+
+	// Create faucet contract with asset contract reference
+	faucetContract := &contracts.FaucetContract{
+		AssetContract: assetContract,
+	}
+
+	// Create guild contract with asset contract reference
+	guildContract := &contracts.GuildContract{
+		AssetContract: assetContract,
+	}
+
+	// Create multisig contract with asset and redemption contract references
+	multisigContract := &contracts.MultisigContract{
+		AssetContract:      assetContract,
+		RedemptionContract: redemptionContract,
+	}
+
+	// Create account contract with references to every contract holding user positions
+	accountContract := &contracts.AccountContract{
+		AssetContract:   assetContract,
+		TradeContract:   tradeContract,
+		AuctionContract: auctionContract,
+		GuildContract:   guildContract,
+		AMMContract:     ammContract,
+	}
+
+	// Create lending contract with asset and valuation contract references
+	lendingContract := &contracts.LendingContract{
+		AssetContract:     assetContract,
+		ValuationContract: valuationContract,
+	}
+
+	// Create staking contract with asset contract reference
+	stakingContract := &contracts.StakingContract{
+		AssetContract: assetContract,
+	}
+
+	// Create storage contract with asset contract reference
+	storageContract := &contracts.StorageContract{
+		AssetContract: assetContract,
+	}
+
+	// Create shop contract with asset contract reference
+	shopContract := &contracts.ShopContract{
 		AssetContract: assetContract,
 	}
 
+	// Create loot contract with asset contract reference
+	lootContract := &contracts.LootContract{
+		AssetContract: assetContract,
+	}
+
+	// Create subscription contract with asset contract reference
+	subscriptionContract := &contracts.SubscriptionContract{
+		AssetContract: assetContract,
+	}
+
+	// Create interest contract with asset contract reference
+	interestContract := &contracts.InterestContract{
+		AssetContract: assetContract,
+	}
+
+	// Create gameplay contract with asset and redemption contract references
+	gameplayContract := &contracts.GameplayContract{
+		AssetContract:      assetContract,
+		RedemptionContract: redemptionContract,
+	}
+
+	// Create economy contract
+	economyContract := new(contracts.EconomyContract)
+
 	// Create chaincode
 	chaincode, err := contractapi.NewChaincode(
 		assetContract,
 		commodityContract,
 		tradeContract,
+		marketDataContract,
 		redemptionContract,
+		auctionContract,
+		ammContract,
+		accessContract,
+		oracleContract,
+		valuationContract,
+		leaderboardContract,
+		seasonContract,
+		questContract,
+		faucetContract,
+		guildContract,
+		multisigContract,
+		accountContract,
+		lendingContract,
+		stakingContract,
+		storageContract,
+		shopContract,
+		lootContract,
+		subscriptionContract,
+		interestContract,
+		economyContract,
+		reputationContract,
+		gameplayContract,
 	)
 
 	if err != nil {
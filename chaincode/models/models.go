@@ -4,9 +4,11 @@ import "time"
 
 // UserAsset represents a user's balance
 type UserAsset struct {
-	UserID    string    `json:"userId"`
-	Balance   float64   `json:"balance"`
-	UpdatedAt time.Time `json:"updatedAt"`
+	UserID        string    `json:"userId"`
+	Balance       float64   `json:"balance"`
+	Locked        float64   `json:"locked"`                  // part of the balance held by holds; available = balance - locked
+	LastAccrualAt time.Time `json:"lastAccrualAt,omitempty"` // the balance rate has been applied up to this time
+	UpdatedAt     time.Time `json:"updatedAt"`
 }
 
 // Inventory represents user's commodity holdings
@@ -14,29 +16,61 @@ type Inventory struct {
 	UserID      string    `json:"userId"`
 	CommodityID string    `json:"commodityId"`
 	Quantity    int       `json:"quantity"`
+	Locked      int       `json:"locked"` // part of the quantity held by holds; available = quantity - locked
 	UpdatedAt   time.Time `json:"updatedAt"`
+	// Lots track perishable items by acquisition; spoiled lots are not available
+	Lots []InventoryLot `json:"lots,omitempty"`
+}
+
+// InventoryLot represents a quantity of a perishable commodity acquired at the same time
+type InventoryLot struct {
+	Quantity   int       `json:"quantity"`
+	Held       int       `json:"held,omitempty"` // part of the lot held by holds
+	AcquiredAt time.Time `json:"acquiredAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
 }
 
 // Trade represents a trade transaction
 type Trade struct {
+	TradeID        string    `json:"tradeId"`
+	FromUserID     string    `json:"fromUserId"`
+	ToUserID       string    `json:"toUserId"`
+	CommodityID    string    `json:"commodityId"`
+	Quantity       int       `json:"quantity"`       // quantity still pending
+	FilledQuantity int       `json:"filledQuantity"` // quantity already accepted
+	UnitPrice      float64   `json:"unitPrice"`
+	TotalPrice     float64   `json:"totalPrice"` // UnitPrice * Quantity for the pending remainder
+	FillCount      int       `json:"fillCount"`
+	Action         string    `json:"action"`               // "buy" or "sell"
+	Status         string    `json:"status"`               // "pending", "successful", "rejected", "cancelled"
+	RejectedBy     string    `json:"rejectedBy,omitempty"` // the counterparty who rejected the trade
+	SeasonID       string    `json:"seasonId,omitempty"`
+	CreatedAt      time.Time `json:"createdAt"`
+	CompletedAt    time.Time `json:"completedAt,omitempty"`
+	LegacyPrice    float64   `json:"price,omitempty"` // total price of trades created before unit pricing
+}
+
+// TradeFill represents one (possibly partial) acceptance of a trade
+type TradeFill struct {
+	FillID      string    `json:"fillId"`
 	TradeID     string    `json:"tradeId"`
-	FromUserID  string    `json:"fromUserId"`
-	ToUserID    string    `json:"toUserId"`
+	SellerID    string    `json:"sellerId"`
+	BuyerID     string    `json:"buyerId"`
 	CommodityID string    `json:"commodityId"`
 	Quantity    int       `json:"quantity"`
-	Price       float64   `json:"price"`
-	Action      string    `json:"action"` // "buy" or "sell"
-	Status      string    `json:"status"` // "pending", "successful", "rejected"
-	CreatedAt   time.Time `json:"createdAt"`
-	CompletedAt time.Time `json:"completedAt,omitempty"`
+	UnitPrice   float64   `json:"unitPrice"`
+	TotalPrice  float64   `json:"totalPrice"`
+	Timestamp   time.Time `json:"timestamp"`
 }
 
 // Commodity represents a game commodity/item
 type Commodity struct {
-	CommodityID string                 `json:"commodityId"`
-	Name        string                 `json:"name"`
-	Metadata    map[string]interface{} `json:"metadata,omitempty"`
-	CreatedAt   time.Time              `json:"createdAt"`
+	CommodityID      string                 `json:"commodityId"`
+	Name             string                 `json:"name"`
+	Metadata         map[string]interface{} `json:"metadata,omitempty"`
+	ShelfLifeSeconds int                    `json:"shelfLifeSeconds,omitempty"` // items spoil this long after they are acquired; 0 never spoils
+	Size             int                    `json:"size,omitempty"`             // storage units each item takes up; 0 counts as 1
+	CreatedAt        time.Time              `json:"createdAt"`
 }
 
 // RedemptionRule represents the redemption rule for a user
@@ -45,6 +79,7 @@ type RedemptionRule struct {
 	UserID        string         `json:"userId"`
 	RequiredItems []RequiredItem `json:"requiredItems"`
 	RewardAmount  float64        `json:"rewardAmount"`
+	RewardRate    float64        `json:"rewardRate,omitempty"` // if set, reward = rate * oracle value of the items
 	CreatedAt     time.Time      `json:"createdAt"`
 }
 
@@ -61,6 +96,638 @@ type RedemptionRecord struct {
 	RuleID        string         `json:"ruleId"`
 	RewardAmount  float64        `json:"rewardAmount"`
 	ConsumedItems []RequiredItem `json:"consumedItems"`
+	SeasonID      string         `json:"seasonId,omitempty"`
 	Timestamp     time.Time      `json:"timestamp"`
 }
 
+// Auction represents a commodity lot listed for auction
+type Auction struct {
+	AuctionID       string    `json:"auctionId"`
+	SellerID        string    `json:"sellerId"`
+	CommodityID     string    `json:"commodityId"`
+	Quantity        int       `json:"quantity"`
+	ReservePrice    float64   `json:"reservePrice"`
+	AuctionType     string    `json:"auctionType"` // "english" or "sealed"
+	StartTime       time.Time `json:"startTime"`
+	EndTime         time.Time `json:"endTime"`
+	RevealEndTime   time.Time `json:"revealEndTime,omitempty"` // sealed auctions only
+	HighestBidderID string    `json:"highestBidderId,omitempty"`
+	HighestBid      float64   `json:"highestBid"`
+	BidCount        int       `json:"bidCount"`
+	Status          string    `json:"status"` // "open", "settled", "unsold", "voided", "cancelled"
+	CreatedAt       time.Time `json:"createdAt"`
+	SettledAt       time.Time `json:"settledAt,omitempty"`
+	// Lots are the perishable lots the escrowed items were taken from
+	Lots []InventoryLot `json:"lots,omitempty"`
+}
+
+// SealedBid represents the public commitment of a sealed auction bid
+type SealedBid struct {
+	AuctionID   string    `json:"auctionId"`
+	BidderID    string    `json:"bidderId"`
+	BidHash     string    `json:"bidHash"`
+	Revealed    bool      `json:"revealed"`
+	Amount      float64   `json:"amount"`
+	Deposit     float64   `json:"deposit"` // taken at commit, forfeited to the seller if never revealed
+	CommittedAt time.Time `json:"committedAt"`
+	RevealedAt  time.Time `json:"revealedAt,omitempty"`
+}
+
+// LiquidityPool represents a constant-product pool pairing a commodity with balance
+type LiquidityPool struct {
+	CommodityID      string    `json:"commodityId"`
+	ReserveCommodity int       `json:"reserveCommodity"`
+	ReserveBalance   float64   `json:"reserveBalance"`
+	TotalShares      float64   `json:"totalShares"`
+	FeeRate          float64   `json:"feeRate"` // fraction of each swap input kept by the pool
+	CreatedAt        time.Time `json:"createdAt"`
+	UpdatedAt        time.Time `json:"updatedAt"`
+	// ReserveLots track perishable reserve units by acquisition, so they keep spoiling in the pool
+	ReserveLots []InventoryLot `json:"reserveLots,omitempty"`
+}
+
+// LiquidityPosition represents a user's share of a liquidity pool
+type LiquidityPosition struct {
+	CommodityID string    `json:"commodityId"`
+	UserID      string    `json:"userId"`
+	Shares      float64   `json:"shares"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// PoolQuote represents the current AMM price of a commodity
+type PoolQuote struct {
+	CommodityID      string  `json:"commodityId"`
+	Name             string  `json:"name"`
+	SpotPrice        float64 `json:"spotPrice"`
+	ReserveCommodity int     `json:"reserveCommodity"`
+	ReserveBalance   float64 `json:"reserveBalance"`
+	FeeRate          float64 `json:"feeRate"`
+}
+
+// PriceStats represents running market statistics for a commodity
+type PriceStats struct {
+	CommodityID string    `json:"commodityId"`
+	LastPrice   float64   `json:"lastPrice"` // unit price of the latest execution
+	Volume      int       `json:"volume"`    // total units traded
+	Turnover    float64   `json:"turnover"`  // total balance traded
+	VWAP        float64   `json:"vwap"`      // Turnover / Volume
+	TradeCount  int       `json:"tradeCount"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// PriceCandle represents OHLC prices of a commodity for one time bucket
+type PriceCandle struct {
+	CommodityID string    `json:"commodityId"`
+	Interval    string    `json:"interval"` // "1m", "1h" or "1d"
+	BucketStart time.Time `json:"bucketStart"`
+	Open        float64   `json:"open"`
+	High        float64   `json:"high"`
+	Low         float64   `json:"low"`
+	Close       float64   `json:"close"`
+	Volume      int       `json:"volume"`
+	Turnover    float64   `json:"turnover"`
+	TradeCount  int       `json:"tradeCount"`
+}
+
+// RoleGrant represents a role granted to a client identity
+type RoleGrant struct {
+	IdentityID string    `json:"identityId"`
+	Role       string    `json:"role"`
+	GrantedBy  string    `json:"grantedBy"`
+	GrantedAt  time.Time `json:"grantedAt"`
+}
+
+// OracleConfig represents the aggregation rules of the price oracle
+type OracleConfig struct {
+	MaxAgeSeconds  int       `json:"maxAgeSeconds"`  // submissions older than this are stale
+	MinSubmissions int       `json:"minSubmissions"` // fresh submissions required for a price
+	UpdatedAt      time.Time `json:"updatedAt"`
+}
+
+// PriceSubmission represents a price feeder's latest price for a commodity
+type PriceSubmission struct {
+	CommodityID string    `json:"commodityId"`
+	FeederID    string    `json:"feederId"`
+	Price       float64   `json:"price"`
+	SubmittedAt time.Time `json:"submittedAt"`
+}
+
+// ReferencePrice represents the oracle's aggregated price for a commodity
+type ReferencePrice struct {
+	CommodityID string    `json:"commodityId"`
+	Price       float64   `json:"price"` // median of fresh submissions
+	Submissions int       `json:"submissions"`
+	OldestAt    time.Time `json:"oldestAt"`
+	ComputedAt  time.Time `json:"computedAt"`
+}
+
+// HoldingValuation represents the value of one commodity holding
+type HoldingValuation struct {
+	CommodityID string  `json:"commodityId"`
+	Quantity    int     `json:"quantity"`
+	UnitPrice   float64 `json:"unitPrice"`
+	Value       float64 `json:"value"`
+	Priced      bool    `json:"priced"` // false if the price source has no price for the commodity
+}
+
+// PortfolioValuation represents a user's net worth at a price source
+type PortfolioValuation struct {
+	UserID        string              `json:"userId"`
+	PriceSource   string              `json:"priceSource"` // "last", "oracle" or "amm"
+	Balance       float64             `json:"balance"`
+	Holdings      []*HoldingValuation `json:"holdings"`
+	HoldingsValue float64             `json:"holdingsValue"`
+	NetWorth      float64             `json:"netWorth"`
+	ValuedAt      time.Time           `json:"valuedAt"`
+}
+
+// LeaderboardEntry represents a user's score on a leaderboard
+type LeaderboardEntry struct {
+	SeasonID  string    `json:"seasonId"`
+	Board     string    `json:"board"`
+	UserID    string    `json:"userId"`
+	Score     float64   `json:"score"`
+	Rank      int       `json:"rank,omitempty"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// LeaderboardSeason represents the season that leaderboard updates currently go to
+type LeaderboardSeason struct {
+	SeasonID  string    `json:"seasonId"`
+	StartedAt time.Time `json:"startedAt"`
+}
+
+// Season represents a game season and the economy reset applied when it ends
+type Season struct {
+	SeasonID       string    `json:"seasonId"`
+	Status         string    `json:"status"`         // "active" or "ended"
+	CarryOverRate  float64   `json:"carryOverRate"`  // fraction of balance kept into the next season
+	ResetInventory bool      `json:"resetInventory"` // clear all inventory when the season ends
+	StartedAt      time.Time `json:"startedAt"`
+	EndedAt        time.Time `json:"endedAt,omitempty"`
+	ArchivedUsers  int       `json:"archivedUsers"`
+}
+
+// SeasonArchive represents a user's balance and inventory at the end of a season
+type SeasonArchive struct {
+	SeasonID   string      `json:"seasonId"`
+	UserID     string      `json:"userId"`
+	Balance    float64     `json:"balance"`
+	Inventory  []Inventory `json:"inventory"`
+	ArchivedAt time.Time   `json:"archivedAt"`
+}
+
+// Quest represents a designer-defined goal and its reward
+type Quest struct {
+	QuestID      string         `json:"questId"`
+	Name         string         `json:"name"`
+	Metric       string         `json:"metric"`                // "trades", "redemptions" or "hold"
+	CommodityID  string         `json:"commodityId,omitempty"` // commodity held, for "hold" quests
+	Target       int            `json:"target"`
+	RewardAmount float64        `json:"rewardAmount"`
+	RewardItems  []RequiredItem `json:"rewardItems,omitempty"`
+	Active       bool           `json:"active"`
+	CreatedAt    time.Time      `json:"createdAt"`
+}
+
+// QuestProgress represents a user's progress towards a quest
+type QuestProgress struct {
+	QuestID     string    `json:"questId"`
+	UserID      string    `json:"userId"`
+	Progress    int       `json:"progress"`
+	Completed   bool      `json:"completed"`
+	CompletedAt time.Time `json:"completedAt,omitempty"`
+	Claimed     bool      `json:"claimed"`
+	ClaimedAt   time.Time `json:"claimedAt,omitempty"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// FaucetConfig represents the daily faucet rules
+type FaucetConfig struct {
+	DailyAmount     float64        `json:"dailyAmount"`
+	DailyItems      []RequiredItem `json:"dailyItems,omitempty"`
+	CooldownSeconds int            `json:"cooldownSeconds"`
+	StreakBonusRate float64        `json:"streakBonusRate"` // extra fraction of dailyAmount per consecutive claim
+	MaxStreak       int            `json:"maxStreak"`       // streak at which the bonus stops growing
+	DailyBudget     float64        `json:"dailyBudget"`     // balance the faucet may pay out per UTC day
+	DailyClaimLimit int            `json:"dailyClaimLimit"` // claims the faucet pays per UTC day, bounding item payouts
+	UpdatedAt       time.Time      `json:"updatedAt"`
+}
+
+// FaucetClaim represents a user's faucet claim history
+type FaucetClaim struct {
+	UserID        string    `json:"userId"`
+	Streak        int       `json:"streak"`
+	LastClaimAt   time.Time `json:"lastClaimAt"`
+	LastAmount    float64   `json:"lastAmount"`
+	TotalClaims   int       `json:"totalClaims"`
+	TotalClaimed  float64   `json:"totalClaimed"`
+	NextClaimAt   time.Time `json:"nextClaimAt"`
+	StreakExpires time.Time `json:"streakExpiresAt"`
+}
+
+// StarterKit records the starting balance and items granted to a new user
+type StarterKit struct {
+	UserID    string         `json:"userId"`
+	Balance   float64        `json:"balance"`
+	Items     []RequiredItem `json:"items"`
+	GrantedAt time.Time      `json:"grantedAt"`
+}
+
+// FaucetDay represents the faucet payouts of one UTC day
+type FaucetDay struct {
+	Day            string         `json:"day"` // YYYY-MM-DD
+	Dispensed      float64        `json:"dispensed"`
+	ItemsDispensed map[string]int `json:"itemsDispensed,omitempty"` // units paid out per commodity
+	Claims         int            `json:"claims"`
+}
+
+// Guild represents a player guild. Its treasury is stored as a UserAsset and
+// Inventory records whose userId is the guild ID.
+type Guild struct {
+	GuildID       string    `json:"guildId"`
+	Name          string    `json:"name"`
+	LeaderID      string    `json:"leaderId"`
+	MemberCount   int       `json:"memberCount"`
+	ActivityCount int       `json:"activityCount"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+// GuildMember represents a user's membership of a guild
+type GuildMember struct {
+	GuildID           string    `json:"guildId"`
+	UserID            string    `json:"userId"`
+	Role              string    `json:"role"`              // "leader", "officer" or "member"
+	WithdrawLimit     float64   `json:"withdrawLimit"`     // balance the member may withdraw per UTC day
+	ItemWithdrawLimit int       `json:"itemWithdrawLimit"` // items the member may withdraw per UTC day
+	LimitDay          string    `json:"limitDay"`          // UTC day the withdrawn totals apply to
+	Withdrawn         float64   `json:"withdrawn"`
+	ItemsWithdrawn    int       `json:"itemsWithdrawn"`
+	JoinedAt          time.Time `json:"joinedAt"`
+}
+
+// GuildInvite represents a pending invitation to join a guild
+type GuildInvite struct {
+	GuildID   string    `json:"guildId"`
+	UserID    string    `json:"userId"`
+	InvitedBy string    `json:"invitedBy"`
+	InvitedAt time.Time `json:"invitedAt"`
+}
+
+// GuildActivity represents an entry in a guild's activity history
+type GuildActivity struct {
+	GuildID     string    `json:"guildId"`
+	Seq         int       `json:"seq"`
+	ActorID     string    `json:"actorId"`
+	Action      string    `json:"action"`
+	TargetID    string    `json:"targetId,omitempty"`
+	CommodityID string    `json:"commodityId,omitempty"`
+	Amount      float64   `json:"amount,omitempty"`
+	Quantity    int       `json:"quantity,omitempty"`
+	Timestamp   time.Time `json:"timestamp"`
+}
+
+// MultisigPolicy represents the identities that approve proposals and how many must agree
+type MultisigPolicy struct {
+	Approvers  []string  `json:"approvers"`
+	Threshold  int       `json:"threshold"`
+	TTLSeconds int       `json:"ttlSeconds"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+// Proposal represents an operation awaiting M-of-N approval
+type Proposal struct {
+	ProposalID string    `json:"proposalId"`
+	Operation  string    `json:"operation"`
+	Args       string    `json:"args"` // operation arguments as JSON
+	ProposerID string    `json:"proposerId"`
+	Approvers  []string  `json:"approvers"`
+	Threshold  int       `json:"threshold"`
+	Approvals  []string  `json:"approvals"`
+	Status     string    `json:"status"` // "pending", "executed" or "expired"
+	CreatedAt  time.Time `json:"createdAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+	ExecutedAt time.Time `json:"executedAt,omitempty"`
+}
+
+// AccountStatus represents the sanction status of a user account
+type AccountStatus struct {
+	UserID      string    `json:"userId"`
+	Status      string    `json:"status"` // "active", "frozen", "suspended-trading" or "closed"
+	Reason      string    `json:"reason"`
+	UpdatedBy   string    `json:"updatedBy"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+	ChangeCount int       `json:"changeCount"`
+}
+
+// AccountStatusChange represents an entry in the audit trail of a user's account status
+type AccountStatusChange struct {
+	UserID     string    `json:"userId"`
+	Seq        int       `json:"seq"`
+	FromStatus string    `json:"fromStatus"`
+	ToStatus   string    `json:"toStatus"`
+	Reason     string    `json:"reason"`
+	ChangedBy  string    `json:"changedBy"`
+	ChangedAt  time.Time `json:"changedAt"`
+}
+
+// AccountClosure records what happened to a closed account's holdings
+type AccountClosure struct {
+	UserID          string         `json:"userId"`
+	SweptTo         string         `json:"sweptTo,omitempty"` // empty if the holdings were burned
+	Balance         float64        `json:"balance"`
+	Items           []RequiredItem `json:"items"`
+	CancelledTrades []string       `json:"cancelledTrades"`
+	Reason          string         `json:"reason"`
+	ClosedBy        string         `json:"closedBy"`
+	ClosedAt        time.Time      `json:"closedAt"`
+}
+
+// LendingConfig represents the rules for collateralized loans
+type LendingConfig struct {
+	LenderID             string    `json:"lenderId"`             // account that funds loans and receives repayments
+	PriceSource          string    `json:"priceSource"`          // "last", "oracle" or "amm"
+	AnnualInterestRate   float64   `json:"annualInterestRate"`   // simple interest on the outstanding principal
+	MaxLoanToValue       float64   `json:"maxLoanToValue"`       // largest debt/collateral ratio a loan may be opened at
+	LiquidationThreshold float64   `json:"liquidationThreshold"` // debt/collateral ratio above which a loan can be liquidated
+	UpdatedAt            time.Time `json:"updatedAt"`
+}
+
+// Loan represents a loan secured by escrowed commodities
+type Loan struct {
+	LoanID          string         `json:"loanId"`
+	BorrowerID      string         `json:"borrowerId"`
+	LenderID        string         `json:"lenderId"`
+	Collateral      []RequiredItem `json:"collateral"`
+	Borrowed        float64        `json:"borrowed"`
+	Principal       float64        `json:"principal"` // outstanding principal
+	InterestRate    float64        `json:"interestRate"`
+	AccruedInterest float64        `json:"accruedInterest"` // unpaid interest
+	TotalRepaid     float64        `json:"totalRepaid"`
+	Status          string         `json:"status"` // "active", "repaid" or "liquidated"
+	LiquidatorID    string         `json:"liquidatorId,omitempty"`
+	OpenedAt        time.Time      `json:"openedAt"`
+	LastAccrualAt   time.Time      `json:"lastAccrualAt"`
+	ClosedAt        time.Time      `json:"closedAt,omitempty"`
+}
+
+// LoanHealth represents a loan's debt against the current value of its collateral
+type LoanHealth struct {
+	LoanID          string    `json:"loanId"`
+	PriceSource     string    `json:"priceSource"`
+	Debt            float64   `json:"debt"`
+	CollateralValue float64   `json:"collateralValue"`
+	LoanToValue     float64   `json:"loanToValue"`
+	Liquidatable    bool      `json:"liquidatable"`
+	ValuedAt        time.Time `json:"valuedAt"`
+}
+
+// StakingProgram represents the terms for staking a commodity
+type StakingProgram struct {
+	ProgramID          string    `json:"programId"`
+	CommodityID        string    `json:"commodityId"`
+	RewardCommodityID  string    `json:"rewardCommodityId,omitempty"` // empty pays rewards in balance
+	RewardRate         float64   `json:"rewardRate"`                  // reward per staked unit per day
+	MinTermSeconds     int       `json:"minTermSeconds"`
+	EarlyUnlockPenalty float64   `json:"earlyUnlockPenalty"` // share of the reward forfeited by unlocking before the term ends
+	Active             bool      `json:"active"`
+	CreatedAt          time.Time `json:"createdAt"`
+}
+
+// Stake represents a quantity of a commodity locked in a staking program
+type Stake struct {
+	StakeID     string    `json:"stakeId"`
+	ProgramID   string    `json:"programId"`
+	UserID      string    `json:"userId"`
+	CommodityID string    `json:"commodityId"`
+	Quantity    int       `json:"quantity"`
+	Status      string    `json:"status"` // "locked" or "unlocked"
+	StakedAt    time.Time `json:"stakedAt"`
+	UnlocksAt   time.Time `json:"unlocksAt"`
+	UnlockedAt  time.Time `json:"unlockedAt,omitempty"`
+	EarlyUnlock bool      `json:"earlyUnlock"`
+	Reward      float64   `json:"reward"`  // reward paid on unlock
+	Penalty     float64   `json:"penalty"` // reward forfeited on early unlock
+}
+
+// Hold represents balance and items locked in a user's account for a reason,
+// such as loan collateral or a stake, identified by a reference ID
+type Hold struct {
+	UserID      string         `json:"userId"`
+	Reason      string         `json:"reason"`
+	ReferenceID string         `json:"referenceId"`
+	Amount      float64        `json:"amount"`
+	Items       []RequiredItem `json:"items"`
+	Lots        []HeldLot      `json:"lots,omitempty"` // the perishable lots the held items belong to
+	CreatedAt   time.Time      `json:"createdAt"`
+}
+
+// HeldLot represents the part of an inventory lot covered by a hold
+type HeldLot struct {
+	CommodityID string    `json:"commodityId"`
+	Quantity    int       `json:"quantity"`
+	AcquiredAt  time.Time `json:"acquiredAt"`
+	ExpiresAt   time.Time `json:"expiresAt"`
+}
+
+// StorageConfig represents the inventory capacity and storage fee rules.
+// Storage is measured in units: an item takes up its commodity's size.
+type StorageConfig struct {
+	BaseCapacity     int       `json:"baseCapacity"`    // storage units every user has
+	UpgradeCapacity  int       `json:"upgradeCapacity"` // storage units added by each upgrade
+	UpgradePrice     float64   `json:"upgradePrice"`
+	FeePerUnit       float64   `json:"feePerUnit"` // fee per used storage unit per fee period; 0 disables fees
+	FeePeriodSeconds int       `json:"feePeriodSeconds"`
+	FeeCollectorID   string    `json:"feeCollectorId,omitempty"` // receives upgrade payments and fees; empty burns them
+	UpdatedAt        time.Time `json:"updatedAt"`
+}
+
+// StorageAccount represents a user's storage upgrades and fee billing
+type StorageAccount struct {
+	UserID     string    `json:"userId"`
+	Upgrades   int       `json:"upgrades"`
+	LastFeeAt  time.Time `json:"lastFeeAt,omitempty"` // fees are billed for whole periods since this time
+	FeesPaid   float64   `json:"feesPaid"`
+	UnpaidFees float64   `json:"unpaidFees"` // fees the user's available balance could not cover
+}
+
+// StorageUsage represents how much of a user's storage capacity is in use
+type StorageUsage struct {
+	UserID   string `json:"userId"`
+	Used     int    `json:"used"`
+	Capacity int    `json:"capacity"`
+}
+
+// ShopConfig represents the house account the shop trades from
+type ShopConfig struct {
+	HouseAccountID string    `json:"houseAccountId"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+}
+
+// ShopListing represents a commodity the shop trades at fixed prices. The
+// stock is the house account's inventory of the commodity; restocking adds
+// restockAmount units every restock period, up to maxStock.
+type ShopListing struct {
+	CommodityID          string    `json:"commodityId"`
+	BuyPrice             float64   `json:"buyPrice"`   // unit price players pay; 0 if the shop does not sell
+	SellPrice            float64   `json:"sellPrice"`  // unit price the shop pays players; 0 if it does not buy
+	MaxStock             int       `json:"maxStock"`   // most units the house holds
+	DailyLimit           int       `json:"dailyLimit"` // units a user may buy per UTC day; 0 is unlimited
+	RestockAmount        int       `json:"restockAmount"`
+	RestockPeriodSeconds int       `json:"restockPeriodSeconds"`
+	LastRestockAt        time.Time `json:"lastRestockAt"`
+	Active               bool      `json:"active"`
+	UpdatedAt            time.Time `json:"updatedAt"`
+}
+
+// ShopPurchases represents the units of a commodity a user bought from the shop on one UTC day
+type ShopPurchases struct {
+	UserID      string `json:"userId"`
+	CommodityID string `json:"commodityId"`
+	Day         string `json:"day"` // YYYY-MM-DD
+	Quantity    int    `json:"quantity"`
+}
+
+// LootDrop represents an entry of a loot box drop table, chosen with
+// probability weight / total weight
+type LootDrop struct {
+	CommodityID string `json:"commodityId"`
+	Quantity    int    `json:"quantity"`
+	Weight      int    `json:"weight"`
+}
+
+// LootBox represents a loot box opened for a balance price, a key item or both
+type LootBox struct {
+	BoxID          string     `json:"boxId"`
+	Name           string     `json:"name"`
+	Price          float64    `json:"price"`                    // balance burned per opening
+	KeyCommodityID string     `json:"keyCommodityId,omitempty"` // one unit consumed per opening
+	DropTable      []LootDrop `json:"dropTable"`
+	Active         bool       `json:"active"`
+	CreatedAt      time.Time  `json:"createdAt"`
+}
+
+// LootSeed represents a server seed committed by its hash before the openings
+// it decides, and revealed afterwards so outcomes can be verified
+type LootSeed struct {
+	SeedID         string    `json:"seedId"`
+	SeedHash       string    `json:"seedHash"`       // hex SHA-256 of the seed
+	Seed           string    `json:"seed,omitempty"` // empty until revealed
+	Status         string    `json:"status"`         // "committed", "revealed"
+	CommittedAt    time.Time `json:"committedAt"`
+	RevealDeadline time.Time `json:"revealDeadline"` // openings are refundable if the seed is not revealed by then
+	RevealedAt     time.Time `json:"revealedAt,omitempty"`
+}
+
+// LootOpening represents a paid loot box opening. Its drop is decided by the
+// seed it was opened under and its transaction ID, so it can only be claimed
+// once the seed is revealed.
+type LootOpening struct {
+	OpeningID  string    `json:"openingId"`
+	UserID     string    `json:"userId"`
+	BoxID      string    `json:"boxId"`
+	SeedID     string    `json:"seedId"`
+	TxID       string    `json:"txId"`
+	Status     string    `json:"status"` // "pending", "claimed", "refunded"
+	Drop       *LootDrop `json:"drop,omitempty"`
+	OpenedAt   time.Time `json:"openedAt"`
+	ClaimedAt  time.Time `json:"claimedAt,omitempty"`
+	RefundedAt time.Time `json:"refundedAt,omitempty"`
+}
+
+// LootVerification represents a recomputation of a loot box opening's outcome
+// from the revealed seed
+type LootVerification struct {
+	OpeningID     string   `json:"openingId"`
+	SeedID        string   `json:"seedId"`
+	Seed          string   `json:"seed"`
+	SeedHash      string   `json:"seedHash"`
+	SeedHashValid bool     `json:"seedHashValid"` // the seed hashes to the committed hash
+	TxID          string   `json:"txId"`
+	Roll          int      `json:"roll"` // position in the total weight of the drop table
+	Drop          LootDrop `json:"drop"`
+	MatchesClaim  bool     `json:"matchesClaim"` // the claimed drop, if any, is the recomputed one
+}
+
+// Subscription represents a recurring payment a payer authorizes to a payee.
+// A payment falls due every interval; payments the payer cannot cover are
+// skipped and counted as failures, and the subscription is suspended after
+// maxFailures consecutive failures.
+type Subscription struct {
+	SubscriptionID      string    `json:"subscriptionId"`
+	PayerID             string    `json:"payerId"`
+	PayeeID             string    `json:"payeeId"`
+	Amount              float64   `json:"amount"`
+	IntervalSeconds     int       `json:"intervalSeconds"`
+	MaxFailures         int       `json:"maxFailures"`
+	Status              string    `json:"status"` // "active", "suspended", "cancelled"
+	NextPaymentAt       time.Time `json:"nextPaymentAt"`
+	PaymentsMade        int       `json:"paymentsMade"`
+	TotalPaid           float64   `json:"totalPaid"`
+	FailedPayments      int       `json:"failedPayments"`
+	ConsecutiveFailures int       `json:"consecutiveFailures"`
+	LastFailureAt       time.Time `json:"lastFailureAt,omitempty"`
+	CreatedAt           time.Time `json:"createdAt"`
+	UpdatedAt           time.Time `json:"updatedAt"`
+}
+
+// BalanceRateConfig represents the annual rate applied to idle balances:
+// interest if positive, a holding cost (demurrage) if negative
+type BalanceRateConfig struct {
+	AnnualRate float64   `json:"annualRate"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+// EconomyStats represents running totals of the economy: the balance and
+// commodity supply held by users, and counts of users, trades and redemptions
+type EconomyStats struct {
+	TotalBalance float64        `json:"totalBalance"`
+	Supply       map[string]int `json:"supply"` // units held per commodity
+	Users        int            `json:"users"`
+	Trades       int            `json:"trades"`
+	Redemptions  int            `json:"redemptions"`
+	UpdatedAt    time.Time      `json:"updatedAt"`
+}
+
+// EconomyDelta represents a transaction's change to the economy totals from
+// writing one record. Deltas are folded into the stored totals by compaction.
+type EconomyDelta struct {
+	Balance     float64 `json:"balance,omitempty"`
+	Users       int     `json:"users,omitempty"`
+	CommodityID string  `json:"commodityId,omitempty"`
+	Supply      int     `json:"supply,omitempty"`
+	Trades      int     `json:"trades,omitempty"`
+	Redemptions int     `json:"redemptions,omitempty"`
+}
+
+// InvariantReport represents a check of the running economy totals against
+// totals recomputed from a full scan of the ledger
+type InvariantReport struct {
+	Expected   EconomyStats `json:"expected"` // running totals
+	Actual     EconomyStats `json:"actual"`   // recomputed totals
+	Mismatches []string     `json:"mismatches"`
+	Valid      bool         `json:"valid"`
+	CheckedAt  time.Time    `json:"checkedAt"`
+}
+
+// TradeRating represents one party's rating of the counterparty to a completed trade
+type TradeRating struct {
+	TradeID   string    `json:"tradeId"`
+	RaterID   string    `json:"raterId"`
+	RateeID   string    `json:"rateeId"`
+	Score     int       `json:"score"` // 1 to 5
+	Comment   string    `json:"comment,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Reputation represents a user's aggregated trade ratings and trade completion record
+type Reputation struct {
+	UserID           string    `json:"userId"`
+	RatingCount      int       `json:"ratingCount"`
+	RatingTotal      int       `json:"ratingTotal"`
+	AverageRating    float64   `json:"averageRating"`
+	SuccessfulTrades int       `json:"successfulTrades"`
+	RejectedTrades   int       `json:"rejectedTrades"`
+	CompletionRatio  float64   `json:"completionRatio"` // successful / (successful + rejected)
+	UpdatedAt        time.Time `json:"updatedAt"`
+}
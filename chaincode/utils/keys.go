@@ -15,6 +15,70 @@ const (
 	CommodityPrefix        = "commodity_"
 	RedemptionRulePrefix   = "redemption_rule_"
 	RedemptionRecordPrefix = "redemption_record_"
+	AuctionPrefix          = "auction_"
+	LiquidityPoolPrefix    = "amm_pool_"
+	PriceStatsPrefix       = "price_stats_"
+	OracleConfigKey        = "oracle_config"
+	LeaderboardSeasonKey   = "leaderboard_season"
+	SeasonPrefix           = "season_"
+	CurrentSeasonKey       = "current_season"
+	QuestPrefix            = "quest_"
+	FaucetConfigKey        = "faucet_config"
+	FaucetClaimPrefix      = "faucet_claim_"
+	FaucetDayPrefix        = "faucet_day_"
+	GuildPrefix            = "guild_info_"
+	GuildAssetPrefix       = "guild_asset_"
+	GuildInventoryPrefix   = "guild_inventory_"
+	GuildMembershipPrefix  = "guild_membership_"
+	MultisigPolicyKey      = "multisig_policy"
+	ProposalPrefix         = "proposal_"
+	AccountStatusPrefix    = "account_status_"
+	AccountClosurePrefix   = "account_closure_"
+	LendingConfigKey       = "lending_config"
+	LoanPrefix             = "loan_"
+	StakingProgramPrefix   = "staking_program_"
+	StakePrefix            = "stake_"
+	StorageConfigKey       = "storage_config"
+	StorageAccountPrefix   = "storage_account_"
+	ShopConfigKey          = "shop_config"
+	ShopListingPrefix      = "shop_listing_"
+	LootBoxPrefix          = "loot_box_"
+	LootSeedPrefix         = "loot_seed_"
+	CurrentLootSeedKey     = "loot_current_seed"
+	LootOpeningPrefix      = "loot_opening_"
+	SubscriptionPrefix     = "subscription_"
+	BalanceRateConfigKey   = "balance_rate_config"
+	EconomyStatsKey        = "economy_stats"
+	ReputationPrefix       = "reputation_"
+	StarterKitPrefix       = "starter_kit_"
+)
+
+// Object types for composite keys
+const (
+	TradeFillObjectType        = "trade_fill"
+	SealedBidObjectType        = "sealed_bid"
+	LiquidityObjectType        = "amm_liquidity"
+	PriceCandleObjectType      = "price_candle"
+	RoleObjectType             = "access_role"
+	PriceSubmissionObjectType  = "oracle_submission"
+	LeaderboardScoreObjectType = "lb_score"
+	LeaderboardRankObjectType  = "lb_rank"
+	SeasonArchiveObjectType    = "season_archive"
+	QuestIndexObjectType       = "quest_index"
+	QuestProgressObjectType    = "quest_progress"
+	GuildMemberObjectType      = "guild_member"
+	GuildInviteObjectType      = "guild_invite"
+	GuildActivityObjectType    = "guild_activity"
+	AccountStatusLogObjectType = "account_status_log"
+	HoldObjectType             = "asset_hold"
+	ShopPurchasesObjectType    = "shop_purchases"
+	EconomyDeltaObjectType     = "economy_delta"
+	TradeRatingObjectType      = "trade_rating"
+)
+
+// Private data collections
+const (
+	AuctionBidsCollection = "auctionBidsCollection"
 )
 
 // GetUserAssetKey returns the key for a user's asset
@@ -32,6 +96,11 @@ func GetTradeKey(tradeID string) string {
 	return fmt.Sprintf("%s%s", TradePrefix, tradeID)
 }
 
+// GetTradeFillKey returns the composite key for the n-th fill of a trade
+func GetTradeFillKey(ctx contractapi.TransactionContextInterface, tradeID string, seq int) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(TradeFillObjectType, []string{tradeID, fmt.Sprintf("%06d", seq)})
+}
+
 // GetCommodityKey returns the key for a commodity
 func GetCommodityKey(commodityID string) string {
 	return fmt.Sprintf("%s%s", CommodityPrefix, commodityID)
@@ -47,6 +116,232 @@ func GetRedemptionRecordKey(recordID string) string {
 	return fmt.Sprintf("%s%s", RedemptionRecordPrefix, recordID)
 }
 
+// GetAuctionKey returns the key for an auction
+func GetAuctionKey(auctionID string) string {
+	return fmt.Sprintf("%s%s", AuctionPrefix, auctionID)
+}
+
+// GetSealedBidKey returns the composite key for a bidder's sealed bid in an auction
+func GetSealedBidKey(ctx contractapi.TransactionContextInterface, auctionID, bidderID string) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(SealedBidObjectType, []string{auctionID, bidderID})
+}
+
+// GetLiquidityPoolKey returns the key for a commodity's liquidity pool
+func GetLiquidityPoolKey(commodityID string) string {
+	return fmt.Sprintf("%s%s", LiquidityPoolPrefix, commodityID)
+}
+
+// GetLiquidityPositionKey returns the composite key for a user's share of a pool
+func GetLiquidityPositionKey(ctx contractapi.TransactionContextInterface, commodityID, userID string) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(LiquidityObjectType, []string{commodityID, userID})
+}
+
+// GetPriceStatsKey returns the key for a commodity's price statistics
+func GetPriceStatsKey(commodityID string) string {
+	return fmt.Sprintf("%s%s", PriceStatsPrefix, commodityID)
+}
+
+// GetPriceCandleKey returns the composite key for a commodity's candle in a time bucket.
+// The bucket start is zero-padded so candles sort chronologically.
+func GetPriceCandleKey(ctx contractapi.TransactionContextInterface, commodityID, interval string, bucketStart time.Time) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(PriceCandleObjectType, []string{commodityID, interval, fmt.Sprintf("%020d", bucketStart.Unix())})
+}
+
+// GetRoleKey returns the composite key for a role granted to an identity
+func GetRoleKey(ctx contractapi.TransactionContextInterface, role, identityID string) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(RoleObjectType, []string{role, identityID})
+}
+
+// GetPriceSubmissionKey returns the composite key for a feeder's price of a commodity
+func GetPriceSubmissionKey(ctx contractapi.TransactionContextInterface, commodityID, feederID string) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(PriceSubmissionObjectType, []string{commodityID, feederID})
+}
+
+// GetLeaderboardScoreKey returns the composite key for a user's score on a board
+func GetLeaderboardScoreKey(ctx contractapi.TransactionContextInterface, seasonID, board, userID string) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(LeaderboardScoreObjectType, []string{seasonID, board, userID})
+}
+
+// GetLeaderboardRankKey returns the rank-sortable composite key for a user's score on a board.
+// rankValue must already be inverted and zero-padded so higher scores sort first.
+func GetLeaderboardRankKey(ctx contractapi.TransactionContextInterface, seasonID, board, rankValue, userID string) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(LeaderboardRankObjectType, []string{seasonID, board, rankValue, userID})
+}
+
+// GetSeasonKey returns the key for a season
+func GetSeasonKey(seasonID string) string {
+	return fmt.Sprintf("%s%s", SeasonPrefix, seasonID)
+}
+
+// GetSeasonArchiveKey returns the composite key for a user's end-of-season archive
+func GetSeasonArchiveKey(ctx contractapi.TransactionContextInterface, seasonID, userID string) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(SeasonArchiveObjectType, []string{seasonID, userID})
+}
+
+// GetQuestKey returns the key for a quest
+func GetQuestKey(questID string) string {
+	return fmt.Sprintf("%s%s", QuestPrefix, questID)
+}
+
+// GetQuestIndexKey returns the composite key indexing a quest by the activity it tracks
+func GetQuestIndexKey(ctx contractapi.TransactionContextInterface, metric, commodityID, questID string) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(QuestIndexObjectType, []string{metric, commodityID, questID})
+}
+
+// GetQuestProgressKey returns the composite key for a user's progress on a quest
+func GetQuestProgressKey(ctx contractapi.TransactionContextInterface, questID, userID string) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(QuestProgressObjectType, []string{questID, userID})
+}
+
+// GetFaucetClaimKey returns the key for a user's faucet claim history
+func GetFaucetClaimKey(userID string) string {
+	return fmt.Sprintf("%s%s", FaucetClaimPrefix, userID)
+}
+
+// GetFaucetDayKey returns the key for a day's faucet payouts
+func GetFaucetDayKey(day string) string {
+	return fmt.Sprintf("%s%s", FaucetDayPrefix, day)
+}
+
+// GetGuildKey returns the key for a guild
+func GetGuildKey(guildID string) string {
+	return fmt.Sprintf("%s%s", GuildPrefix, guildID)
+}
+
+// GetGuildAssetKey returns the key for a guild's treasury balance
+func GetGuildAssetKey(guildID string) string {
+	return fmt.Sprintf("%s%s", GuildAssetPrefix, guildID)
+}
+
+// GetGuildInventoryKey returns the key for a guild's treasury inventory of a commodity
+func GetGuildInventoryKey(guildID, commodityID string) string {
+	return fmt.Sprintf("%s%s_%s", GuildInventoryPrefix, guildID, commodityID)
+}
+
+// GetGuildMembershipKey returns the key recording which guild a user belongs to
+func GetGuildMembershipKey(userID string) string {
+	return fmt.Sprintf("%s%s", GuildMembershipPrefix, userID)
+}
+
+// GetGuildMemberKey returns the composite key for a guild member
+func GetGuildMemberKey(ctx contractapi.TransactionContextInterface, guildID, userID string) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(GuildMemberObjectType, []string{guildID, userID})
+}
+
+// GetGuildInviteKey returns the composite key for a guild invitation
+func GetGuildInviteKey(ctx contractapi.TransactionContextInterface, guildID, userID string) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(GuildInviteObjectType, []string{guildID, userID})
+}
+
+// GetGuildActivityKey returns the composite key for a guild activity entry
+func GetGuildActivityKey(ctx contractapi.TransactionContextInterface, guildID string, seq int) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(GuildActivityObjectType, []string{guildID, fmt.Sprintf("%010d", seq)})
+}
+
+// GetProposalKey returns the key for a multisig proposal
+func GetProposalKey(proposalID string) string {
+	return fmt.Sprintf("%s%s", ProposalPrefix, proposalID)
+}
+
+// GetAccountStatusKey returns the key for a user's account status
+func GetAccountStatusKey(userID string) string {
+	return fmt.Sprintf("%s%s", AccountStatusPrefix, userID)
+}
+
+// GetAccountClosureKey returns the key for a closed account's closure record
+func GetAccountClosureKey(userID string) string {
+	return fmt.Sprintf("%s%s", AccountClosurePrefix, userID)
+}
+
+// GetAccountStatusLogKey returns the composite key for the n-th account status change of a user
+func GetAccountStatusLogKey(ctx contractapi.TransactionContextInterface, userID string, seq int) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(AccountStatusLogObjectType, []string{userID, fmt.Sprintf("%06d", seq)})
+}
+
+// GetLoanKey returns the key for a loan
+func GetLoanKey(loanID string) string {
+	return fmt.Sprintf("%s%s", LoanPrefix, loanID)
+}
+
+// GetHoldKey returns the composite key for a hold on a user's assets
+func GetHoldKey(ctx contractapi.TransactionContextInterface, userID, reason, referenceID string) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(HoldObjectType, []string{userID, reason, referenceID})
+}
+
+// GetStakingProgramKey returns the key for a staking program
+func GetStakingProgramKey(programID string) string {
+	return fmt.Sprintf("%s%s", StakingProgramPrefix, programID)
+}
+
+// GetStakeKey returns the key for a stake
+func GetStakeKey(stakeID string) string {
+	return fmt.Sprintf("%s%s", StakePrefix, stakeID)
+}
+
+// GetStorageAccountKey returns the key for a user's storage account
+func GetStorageAccountKey(userID string) string {
+	return fmt.Sprintf("%s%s", StorageAccountPrefix, userID)
+}
+
+// GetShopListingKey returns the key for a shop listing
+func GetShopListingKey(commodityID string) string {
+	return fmt.Sprintf("%s%s", ShopListingPrefix, commodityID)
+}
+
+// GetShopPurchasesKey returns the composite key for a user's shop purchases of a commodity on a day
+func GetShopPurchasesKey(ctx contractapi.TransactionContextInterface, userID, commodityID, day string) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(ShopPurchasesObjectType, []string{userID, commodityID, day})
+}
+
+// GetLootBoxKey returns the key for a loot box
+func GetLootBoxKey(boxID string) string {
+	return fmt.Sprintf("%s%s", LootBoxPrefix, boxID)
+}
+
+// GetLootSeedKey returns the key for a loot seed
+func GetLootSeedKey(seedID string) string {
+	return fmt.Sprintf("%s%s", LootSeedPrefix, seedID)
+}
+
+// GetLootOpeningKey returns the key for a loot box opening
+func GetLootOpeningKey(openingID string) string {
+	return fmt.Sprintf("%s%s", LootOpeningPrefix, openingID)
+}
+
+// GetSubscriptionKey returns the key for a subscription
+func GetSubscriptionKey(subscriptionID string) string {
+	return fmt.Sprintf("%s%s", SubscriptionPrefix, subscriptionID)
+}
+
+// GetEconomyDeltaKey returns the composite key for a transaction's change to the economy totals from a record
+func GetEconomyDeltaKey(ctx contractapi.TransactionContextInterface, txID, recordKey string) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(EconomyDeltaObjectType, []string{txID, recordKey})
+}
+
+// GetReputationKey returns the key for a user's reputation
+func GetReputationKey(userID string) string {
+	return fmt.Sprintf("%s%s", ReputationPrefix, userID)
+}
+
+// GetTradeRatingKey returns the composite key for a party's rating of a trade
+func GetTradeRatingKey(ctx contractapi.TransactionContextInterface, tradeID, raterID string) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(TradeRatingObjectType, []string{tradeID, raterID})
+}
+
+// GetStarterKitKey returns the key for a user's starter kit grant
+func GetStarterKitKey(userID string) string {
+	return fmt.Sprintf("%s%s", StarterKitPrefix, userID)
+}
+
+// ParseTimestamp parses an RFC3339 timestamp passed as a transaction argument
+func ParseTimestamp(value string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid timestamp %q (expected RFC3339): %v", value, err)
+	}
+	return t, nil
+}
+
 // GetTxTimestamp returns the deterministic transaction timestamp
 // This ensures all endorsing peers return the same timestamp
 func GetTxTimestamp(ctx contractapi.TransactionContextInterface) (time.Time, error) {
@@ -56,3 +351,16 @@ func GetTxTimestamp(ctx contractapi.TransactionContextInterface) (time.Time, err
 	}
 	return time.Unix(txTimestamp.Seconds, int64(txTimestamp.Nanos)), nil
 }
+
+// GetCallerID returns the unique ID of the client identity submitting the transaction
+func GetCallerID(ctx contractapi.TransactionContextInterface) (string, error) {
+	identity := ctx.GetClientIdentity()
+	if identity == nil {
+		return "", fmt.Errorf("client identity not available")
+	}
+	id, err := identity.GetID()
+	if err != nil {
+		return "", fmt.Errorf("failed to get client identity: %v", err)
+	}
+	return id, nil
+}
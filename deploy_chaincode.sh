@@ -79,7 +79,8 @@ peer lifecycle chaincode approveformyorg \
   --name game-chaincode \
   --version 1.0 \
   --package-id "$PACKAGE_ID" \
-  --sequence 1
+  --sequence 1 \
+  --collections-config ./chaincode/collections_config.json
 
 echo -e "${GREEN}✓ Chaincode approved for Org2${NC}\n"
 
@@ -98,7 +99,8 @@ peer lifecycle chaincode approveformyorg \
   --name game-chaincode \
   --version 1.0 \
   --package-id "$PACKAGE_ID" \
-  --sequence 1
+  --sequence 1 \
+  --collections-config ./chaincode/collections_config.json
 
 echo -e "${GREEN}✓ Chaincode approved for Org1${NC}\n"
 
@@ -109,6 +111,7 @@ peer lifecycle chaincode checkcommitreadiness \
   --name game-chaincode \
   --version 1.0 \
   --sequence 1 \
+  --collections-config ./chaincode/collections_config.json \
   --output json
 
 # Step 11: Commit chaincode definition
@@ -121,6 +124,7 @@ peer lifecycle chaincode commit \
   --name game-chaincode \
   --version 1.0 \
   --sequence 1 \
+  --collections-config ./chaincode/collections_config.json \
   --peerAddresses localhost:7051 \
   --tlsRootCertFiles "${TEST_NETWORK_DIR}/organizations/peerOrganizations/org1.example.com/peers/peer0.org1.example.com/tls/ca.crt" \
   --peerAddresses localhost:9051 \
@@ -132,8 +136,26 @@ echo -e "${GREEN}✓ Chaincode committed successfully${NC}\n"
 echo -e "${RED}Step 12: Verifying chaincode deployment...${NC}"
 peer lifecycle chaincode querycommitted --channelID mychannel --name game-chaincode
 
-# Step 13: Initialize the chaincode
-echo -e "${RED}Step 13: Initializing chaincode data...${NC}"
+# Step 13: Claim the chaincode admin role with the Org1 admin identity before
+# any other client can call InitAdmin
+echo -e "${RED}Step 13: Bootstrapping the chaincode admin...${NC}"
+peer chaincode invoke \
+  -o localhost:7050 \
+  --ordererTLSHostnameOverride orderer.example.com \
+  --tls --cafile "$ORDERER_CA" \
+  -C mychannel \
+  -n game-chaincode \
+  --peerAddresses localhost:7051 \
+  --tlsRootCertFiles "${TEST_NETWORK_DIR}/organizations/peerOrganizations/org1.example.com/peers/peer0.org1.example.com/tls/ca.crt" \
+  --peerAddresses localhost:9051 \
+  --tlsRootCertFiles "${TEST_NETWORK_DIR}/organizations/peerOrganizations/org2.example.com/peers/peer0.org2.example.com/tls/ca.crt" \
+  -c '{"function":"AccessContract:InitAdmin","Args":[]}'
+
+sleep 3
+echo -e "${GREEN}✓ Chaincode admin initialized${NC}\n"
+
+# Step 14: Initialize the chaincode
+echo -e "${RED}Step 14: Initializing chaincode data...${NC}"
 
 # Initialize commodities
 echo "Initializing commodities..."
@@ -182,8 +204,8 @@ peer chaincode invoke \
 
 echo -e "\n${GREEN}✓ Chaincode initialized successfully${NC}\n"
 
-# Step 14: Test queries
-echo -e "${RED}Step 14: Testing chaincode with sample queries...${NC}"
+# Step 15: Test queries
+echo -e "${RED}Step 15: Testing chaincode with sample queries...${NC}"
 
 echo "Querying Alice's assets..."
 peer chaincode query \
@@ -46,15 +46,19 @@ const GamePage = () => {
             socket.on('refreshResult', setNotification);
 
             socket.on('tradeHistory', (history) => {
-                const processedHistory = history.map(trade => ({
-                    tradeId: trade.id,
-                    fromUserId: trade.fromUserId,
-                    toUserId: trade.toUserId,
-                    tradeDetails: { commodityId: trade.commodityId, quantity: trade.quantity, price: trade.price, action: trade.action },
-                    direction: trade.fromUserId === user.id ? 'outgoing' : 'incoming',
-                    status: trade.status,
-                    message: trade.message,
-                }));
+                const processedHistory = history.map(trade => {
+                    // quantity is the pending remainder; show the quantity originally proposed
+                    const quantity = trade.quantity + (trade.filledQuantity || 0);
+                    return {
+                        tradeId: trade.tradeId,
+                        fromUserId: trade.fromUserId,
+                        toUserId: trade.toUserId,
+                        tradeDetails: { commodityId: trade.commodityId, quantity, unitPrice: trade.unitPrice, totalPrice: trade.unitPrice * quantity, action: trade.action },
+                        direction: trade.fromUserId === user.id ? 'outgoing' : 'incoming',
+                        status: trade.status,
+                        message: trade.message,
+                    };
+                });
                 setActiveTrades(processedHistory);
             });
 
@@ -82,7 +86,9 @@ const GamePage = () => {
     const handleProposeTrade = (e) => {
         e.preventDefault();
         const form = e.target;
-        const tradeDetails = { action: form.action.value, commodityId: form.commodityId.value, quantity: parseInt(form.quantity.value, 10), price: parseFloat(form.price.value) };
+        const quantity = parseInt(form.quantity.value, 10);
+        const unitPrice = parseFloat(form.unitPrice.value);
+        const tradeDetails = { action: form.action.value, commodityId: form.commodityId.value, quantity, unitPrice, totalPrice: unitPrice * quantity };
         const newTrade = { tradeId: uuidv4(), fromUserId: user.id, toUserId: form.toUserId.value, tradeDetails, direction: 'outgoing', status: 'pending' };
         setActiveTrades(prev => [newTrade, ...prev]);
         socket.emit('proposeTrade', newTrade);
@@ -153,7 +159,8 @@ const GamePage = () => {
                         Action: <strong>{tradeDetails.action.toUpperCase()}</strong><br/>
                         Commodity: <strong>{commodity.name}</strong><br/>
                         Quantity: <strong>{tradeDetails.quantity}</strong><br/>
-                        Price: <strong>${tradeDetails.price.toFixed(2)}</strong>
+                        Unit Price: <strong>${tradeDetails.unitPrice.toFixed(2)}</strong><br/>
+                        Total: <strong>${tradeDetails.totalPrice.toFixed(2)}</strong>
                     </p>
                     {message && (
                         <div className={`alert ${status === 'failed' || status === 'rejected' ? 'alert-danger' : 'alert-info'} mt-2 mb-0 py-2`}>
@@ -255,7 +262,7 @@ const GamePage = () => {
                                         <input type="number" name="quantity" placeholder="Qty" className="form-control" min="1" required />
                                     </div>
                                     <div className="col-md-2">
-                                        <input type="number" name="price" placeholder="Price" className="form-control" step="0.01" min="0" required />
+                                        <input type="number" name="unitPrice" placeholder="Unit price" className="form-control" step="0.01" min="0" required />
                                     </div>
                                     <div className="col-12">
                                         <button type="submit" className="btn btn-primary mt-2">Propose</button>